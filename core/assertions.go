@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	. "github.com/twolodzko/goer/types"
+)
+
+// assert_equal/2
+func assertEqual(expected, actual Expr) (Expr, error) {
+	if !Equal(expected, actual) {
+		return nil, errors.NotEqual{expected, actual}
+	}
+	return Atom("ok"), nil
+}
+
+// assert_error/1 and assert_error/2, call the function that should throw
+// the error, optionally with the given reason.
+func assertError(args []Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
+	var expected, fun Expr
+	switch len(args) {
+	case 1:
+		fun = args[0]
+	case 2:
+		expected, fun = args[0], args[1]
+	default:
+		return nil, errors.WrongNumberArgs{}
+	}
+	switch fun.(type) {
+	case Fun, *Closure, buildIn:
+	default:
+		// otherwise, the error of calling it would pass the assertion
+		return nil, errors.NotFunction{fun}
+	}
+
+	result, err := Apply(fun, nil, env, pid)
+	if err == nil {
+		return nil, errors.NoError{expected, result}
+	}
+	if uncatchable(err) {
+		return nil, err
+	}
+	if reason := errors.ToTerm(err); expected != nil && !Equal(expected, reason) {
+		return nil, errors.WrongError{expected, reason}
+	}
+	return Atom("ok"), nil
+}
+
+// Check if the value of the expression matches the pattern, binding its variables.
+func assertMatch(assert AssertMatch, env *envir.Env, pid pids.Pid) (Expr, error) {
+	val, err := Eval(assert.Expr, env, pid)
+	if err != nil {
+		return nil, err
+	}
+	err = matchValue(assert.Pattern, val, env, pid)
+	if uncatchable(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.NotMatched{fmt.Sprint(assert.Pattern), val}
+	}
+	return Atom("ok"), nil
+}
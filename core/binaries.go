@@ -0,0 +1,433 @@
+package core
+
+import (
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	. "github.com/twolodzko/goer/types"
+)
+
+// Type specifiers of the segment of a bit string.
+type segmentType struct {
+	binary bool
+	signed bool
+	little bool
+}
+
+func typeOf(segment Segment) segmentType {
+	var typ segmentType
+	for _, name := range segment.Types {
+		switch name {
+		case "binary", "bytes":
+			typ.binary = true
+		case "integer":
+			typ.binary = false
+		case "signed":
+			typ.signed = true
+		case "unsigned":
+			typ.signed = false
+		case "little":
+			typ.little = true
+		case "big":
+			typ.little = false
+		}
+	}
+	return typ
+}
+
+// Evaluate size of the segment, return -1 if it was not given. The size of the integers
+// is given in bits, the size of the binaries is in bytes.
+func segmentSize(segment Segment, typ segmentType, env *envir.Env, pid pids.Pid) (int, error) {
+	if segment.Size == nil {
+		return defaultSize(typ), nil
+	}
+	val, err := Eval(segment.Size, env, pid)
+	if err != nil {
+		return 0, err
+	}
+	return checkSize(segment, val)
+}
+
+// Size of the segment when it was not given, -1 means the whole binary.
+func defaultSize(typ segmentType) int {
+	if typ.binary {
+		return -1
+	}
+	return 8
+}
+
+// Check the evaluated size of the segment.
+func checkSize(segment Segment, val Expr) (int, error) {
+	size, ok := val.(Int)
+	if !ok {
+		return 0, errors.NotNumber{val}
+	}
+	if size < 0 {
+		return 0, errors.InvalidSegment{segment}
+	}
+	return int(size), nil
+}
+
+// The bits of the binary being constructed, the last byte can be filled partially.
+type bitWriter struct {
+	bin  Binary
+	bits int
+}
+
+// Append `n` bits from the beginning of the bytes, the bits after them need to be zeros.
+func (w *bitWriter) write(buf []byte, n int) {
+	buf = buf[:(n+7)/8]
+	if shift := w.bits % 8; shift == 0 {
+		w.bin = append(w.bin, buf...)
+	} else {
+		for _, b := range buf {
+			w.bin[len(w.bin)-1] |= b >> shift
+			w.bin = append(w.bin, b<<(8-shift))
+		}
+	}
+	w.bits += n
+	w.bin = w.bin[:(w.bits+7)/8]
+}
+
+// The constructed binary, the segments need to add up to the whole bytes.
+func (w *bitWriter) binary() (Binary, error) {
+	if w.bits%8 != 0 {
+		return nil, errors.UnalignedBinary{w.bits}
+	}
+	return w.bin, nil
+}
+
+// Construct the binary from the bit string.
+func buildBinary(bits BitString, env *envir.Env, pid pids.Pid) (Binary, error) {
+	w := &bitWriter{Binary{}, 0}
+	for _, segment := range bits.Segments {
+		typ := typeOf(segment)
+		val, err := Eval(segment.Value, env, pid)
+		if err != nil {
+			return nil, err
+		}
+		size, err := segmentSize(segment, typ, env, pid)
+		if err != nil {
+			return nil, err
+		}
+		if err = appendSegment(w, segment, typ, val, size, pid); err != nil {
+			return nil, err
+		}
+	}
+	return w.binary()
+}
+
+// Append the evaluated value of the segment to the binary.
+func appendSegment(w *bitWriter, segment Segment, typ segmentType, val Expr, size int, pid pids.Pid) error {
+	switch val := val.(type) {
+	case String:
+		// the string literal is a shorthand for its bytes, like the binary
+		if segment.Types == nil && segment.Size == nil {
+			w.write([]byte(val), 8*len(val))
+			return nil
+		}
+		if !typ.binary {
+			return errors.InvalidSegment{segment}
+		}
+		return writeBytes(w, segment, Binary(val), size)
+	case Binary:
+		if !typ.binary {
+			return errors.NotNumber{val}
+		}
+		return writeBytes(w, segment, val, size)
+	case Int:
+		if typ.binary {
+			return errors.NotBinary{val}
+		}
+		// the size is arbitrary, so it is checked before the bytes are allocated
+		if err := stopped(pid.Fits(1 + len(w.bin)/8 + size/64)); err != nil {
+			return err
+		}
+		w.write(encodeInt(val, size, typ.little), size)
+		return nil
+	default:
+		if typ.binary {
+			return errors.NotBinary{val}
+		}
+		return errors.NotNumber{val}
+	}
+}
+
+// Append the bytes of the binary segment, all of them, or the number given as its size.
+func writeBytes(w *bitWriter, segment Segment, val Binary, size int) error {
+	if size >= 0 {
+		if size > len(val) {
+			return errors.InvalidSegment{segment}
+		}
+		val = val[:size]
+	}
+	w.write(val, 8*len(val))
+	return nil
+}
+
+// Match the binary against the bit string pattern.
+func matchBinary(bits BitString, val Expr, env *envir.Env, pid pids.Pid) error {
+	bin, ok := val.(Binary)
+	if !ok {
+		return errors.NoMatch{bits, val}
+	}
+
+	r := &bitReader{bin, 0}
+	for i, segment := range bits.Segments {
+		if isPrefix(segment) {
+			if !cutPrefix(r, segment.Value.(String)) {
+				return errors.NoMatch{bits, bin}
+			}
+			continue
+		}
+
+		typ := typeOf(segment)
+		size, err := segmentSize(segment, typ, env, pid)
+		if err != nil {
+			return err
+		}
+		part, err := takeSegment(bits, r, i, typ, size)
+		if err != nil {
+			return err
+		}
+		err = matchValue(segment.Value, part, env, pid)
+		if err != nil {
+			return err
+		}
+	}
+	if r.left() > 0 {
+		return errors.NoMatch{bits, bin}
+	}
+	return nil
+}
+
+// The segment is a string literal, that matches the bytes.
+func isPrefix(segment Segment) bool {
+	_, ok := segment.Value.(String)
+	return ok && segment.Size == nil && (segment.Types == nil || typeOf(segment).binary)
+}
+
+// The bits of the binary being matched, starting from the position.
+type bitReader struct {
+	bin Binary
+	pos int
+}
+
+// Number of the bits that were not read yet.
+func (r *bitReader) left() int {
+	return 8*len(r.bin) - r.pos
+}
+
+// Read `n` bits, they are returned at the beginning of the bytes, followed by zeros.
+func (r *bitReader) read(n int) Binary {
+	start, shift := r.pos/8, r.pos%8
+	r.pos += n
+	if shift == 0 && n%8 == 0 {
+		return r.bin[start : start+n/8]
+	}
+	buf := make(Binary, (n+7)/8)
+	for i := range buf {
+		buf[i] = r.bin[start+i] << shift
+		if shift > 0 && start+i+1 < len(r.bin) {
+			buf[i] |= r.bin[start+i+1] >> (8 - shift)
+		}
+	}
+	if n%8 != 0 {
+		buf[len(buf)-1] &= 0xff << (8 - n%8)
+	}
+	return buf
+}
+
+// Read the bytes of the string if the binary continues with them.
+func cutPrefix(r *bitReader, prefix String) bool {
+	if r.left() < 8*len(prefix) {
+		return false
+	}
+	pos := r.pos
+	if string(r.read(8*len(prefix))) != string(prefix) {
+		r.pos = pos
+		return false
+	}
+	return true
+}
+
+// Read the value of the i-th segment of the bit string from the binary.
+func takeSegment(bits BitString, r *bitReader, i int, typ segmentType, size int) (Expr, error) {
+	n := size
+	if typ.binary {
+		if size < 0 {
+			// binary without size takes the rest, so it needs to be the last one
+			if i != len(bits.Segments)-1 {
+				return nil, errors.InvalidSegment{bits.Segments[i]}
+			}
+			size = r.left() / 8
+		}
+		n = 8 * size
+	}
+	if r.left() < n {
+		return nil, errors.NoMatch{bits, r.bin}
+	}
+	part := r.read(n)
+	if typ.binary {
+		return part, nil
+	}
+	return decodeInt(part, n, typ.signed, typ.little), nil
+}
+
+// Encode the integer as `size` bits at the beginning of the bytes, the bits that do not fit are lost.
+// In the little-endian order, the last byte keeps the remaining highest bits.
+func encodeInt(val Int, size int, little bool) []byte {
+	buf := make([]byte, (size+7)/8)
+	for i := range buf {
+		// shifting by more than the size of int gives the sign
+		b := byte(val >> min(8*i, 63))
+		if little {
+			buf[i] = b
+		} else {
+			buf[len(buf)-i-1] = b
+		}
+	}
+	pad := 8*len(buf) - size
+	if pad == 0 {
+		return buf
+	}
+	if little {
+		buf[len(buf)-1] <<= pad
+		return buf
+	}
+	for i := range buf {
+		buf[i] <<= pad
+		if i+1 < len(buf) {
+			buf[i] |= buf[i+1] >> (8 - pad)
+		}
+	}
+	return buf
+}
+
+// Decode the integer from `size` bits at the beginning of the bytes, the bits that do not fit are lost.
+func decodeInt(buf []byte, size int, signed, little bool) Int {
+	pad := 8*len(buf) - size
+	var val Int
+	for i := range buf {
+		if little {
+			b := Int(buf[len(buf)-i-1])
+			if i == 0 {
+				// the highest bits are kept in the last byte
+				val = b >> pad
+			} else {
+				val = val<<8 | b
+			}
+		} else if i == len(buf)-1 {
+			val = val<<(8-pad) | Int(buf[i]>>pad)
+		} else {
+			val = val<<8 | Int(buf[i])
+		}
+	}
+	if signed && size > 0 && size < 64 {
+		// extend the sign
+		shift := 64 - size
+		val = val << shift >> shift
+	}
+	return val
+}
+
+// byte_size/1
+func byteSize(arg Expr) (Expr, error) {
+	bin, ok := arg.(Binary)
+	if !ok {
+		return nil, errors.NotBinary{arg}
+	}
+	return Int(len(bin)), nil
+}
+
+// binary_part/3
+func binaryPart(args []Expr, _ *envir.Env, _ pids.Pid) (Expr, error) {
+	if len(args) != 3 {
+		return nil, errors.WrongNumberArgs{}
+	}
+	bin, ok := args[0].(Binary)
+	if !ok {
+		return nil, errors.NotBinary{args[0]}
+	}
+	start, ok := args[1].(Int)
+	if !ok {
+		return nil, errors.NotNumber{args[1]}
+	}
+	length, ok := args[2].(Int)
+	if !ok {
+		return nil, errors.NotNumber{args[2]}
+	}
+	// negative length takes the bytes before the start
+	if length < 0 {
+		start, length = start+length, -length
+	}
+	if start < 0 || int(start+length) > len(bin) {
+		return nil, errors.New("invalid index")
+	}
+	return bin[start : start+length], nil
+}
+
+// binary_to_list/1
+func binaryToList(arg Expr) (Expr, error) {
+	bin, ok := arg.(Binary)
+	if !ok {
+		return nil, errors.NotBinary{arg}
+	}
+	values := make([]Expr, len(bin))
+	for i, b := range bin {
+		values[i] = Int(b)
+	}
+	return List{values}, nil
+}
+
+// list_to_binary/1
+func listToBinary(arg Expr) (Expr, error) {
+	if _, ok := arg.(List); !ok {
+		return nil, errors.NotList{arg}
+	}
+	return appendBytes(Binary{}, arg)
+}
+
+// Append the bytes, binaries, strings, or the nested lists of them to the binary.
+func appendBytes(bin Binary, arg Expr) (Binary, error) {
+	switch val := arg.(type) {
+	case Int:
+		if val < 0 || val > 255 {
+			return nil, errors.NotBinary{val}
+		}
+		return append(bin, byte(val)), nil
+	case Binary:
+		return append(bin, val...), nil
+	case String:
+		return append(bin, val...), nil
+	case List:
+		var err error
+		for _, x := range val.Values {
+			bin, err = appendBytes(bin, x)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return bin, nil
+	default:
+		return nil, errors.NotBinary{val}
+	}
+}
+
+// binary_to_str/1
+func binaryToStr(arg Expr) (Expr, error) {
+	bin, ok := arg.(Binary)
+	if !ok {
+		return nil, errors.NotBinary{arg}
+	}
+	return String(bin), nil
+}
+
+// str_to_binary/1
+func strToBinary(arg Expr) (Expr, error) {
+	str, ok := arg.(String)
+	if !ok {
+		return nil, errors.NotString{arg}
+	}
+	return Binary(str), nil
+}
@@ -2,7 +2,7 @@ package core
 
 import (
 	"fmt"
-	"reflect"
+	"strconv"
 
 	"github.com/twolodzko/goer/core/errors"
 	. "github.com/twolodzko/goer/types"
@@ -16,6 +16,9 @@ func applyBinaryOp(op string, lhs, rhs Expr) (Expr, error) {
 	case "or":
 		lhs, rhs, err := maybeBools(lhs, rhs)
 		return lhs || rhs, err
+	case "xor":
+		lhs, rhs, err := maybeBools(lhs, rhs)
+		return Bool(lhs != rhs), err
 	case "+":
 		lhs, rhs, err := maybeInts(lhs, rhs)
 		return lhs + rhs, err
@@ -25,34 +28,51 @@ func applyBinaryOp(op string, lhs, rhs Expr) (Expr, error) {
 	case "*":
 		lhs, rhs, err := maybeInts(lhs, rhs)
 		return lhs * rhs, err
-	case "/":
+	case "/", "div":
 		lhs, rhs, err := maybeInts(lhs, rhs)
+		if err != nil {
+			return nil, err
+		}
 		if rhs == 0 {
 			return nil, errors.DivisionByZero{}
 		}
-		return lhs / rhs, err
+		return lhs / rhs, nil
 	case "rem":
 		lhs, rhs, err := maybeInts(lhs, rhs)
+		if err != nil {
+			return nil, err
+		}
 		if rhs == 0 {
 			return nil, errors.DivisionByZero{}
 		}
-		return lhs % rhs, err
-	case "<":
+		return lhs % rhs, nil
+	case "band":
 		lhs, rhs, err := maybeInts(lhs, rhs)
-		return Bool(lhs < rhs), err
-	case "<=":
+		return lhs & rhs, err
+	case "bor":
 		lhs, rhs, err := maybeInts(lhs, rhs)
-		return Bool(lhs <= rhs), err
-	case ">":
+		return lhs | rhs, err
+	case "bxor":
 		lhs, rhs, err := maybeInts(lhs, rhs)
-		return Bool(lhs > rhs), err
-	case ">=":
+		return lhs ^ rhs, err
+	case "bsl":
+		lhs, rhs, err := maybeInts(lhs, rhs)
+		return shiftLeft(lhs, rhs), err
+	case "bsr":
 		lhs, rhs, err := maybeInts(lhs, rhs)
-		return Bool(lhs >= rhs), err
-	case "==":
-		return Bool(reflect.DeepEqual(rhs, lhs)), nil
-	case "!=":
-		return Bool(!reflect.DeepEqual(rhs, lhs)), nil
+		return shiftLeft(lhs, -rhs), err
+	case "<":
+		return Bool(Compare(lhs, rhs) < 0), nil
+	case "<=", "=<":
+		return Bool(Compare(lhs, rhs) <= 0), nil
+	case ">":
+		return Bool(Compare(lhs, rhs) > 0), nil
+	case ">=":
+		return Bool(Compare(lhs, rhs) >= 0), nil
+	case "==", "=:=":
+		return Bool(Equal(lhs, rhs)), nil
+	case "!=", "/=", "=/=":
+		return Bool(!Equal(lhs, rhs)), nil
 	case "++":
 		switch lhs := lhs.(type) {
 		case List:
@@ -69,6 +89,20 @@ func applyBinaryOp(op string, lhs, rhs Expr) (Expr, error) {
 	}
 }
 
+// Shift the bits left, or right for the negative shift. As the integers
+// have fixed size, the bits shifted out of the integer are lost.
+func shiftLeft(val, shift Int) Int {
+	switch {
+	case shift <= -strconv.IntSize:
+		// the negation could overflow
+		return val >> (strconv.IntSize - 1)
+	case shift < 0:
+		return val >> -shift
+	default:
+		return val << shift
+	}
+}
+
 // Concatenate two lists or add an element to the list.
 func listAppend(lhs List, rhs Expr) (Expr, error) {
 	switch rhs := rhs.(type) {
@@ -2,7 +2,10 @@ package core
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 	"time"
+	"unicode/utf8"
 
 	"github.com/twolodzko/goer/core/envir"
 	"github.com/twolodzko/goer/core/errors"
@@ -21,10 +24,28 @@ type buildIn = func([]Expr, *envir.Env, pids.Pid) (Expr, error)
 // Initialize the build-in functions for the Env.
 func buildIns() map[string]Expr {
 	vars := make(map[string]Expr)
+	vars["assert_equal"] = twoArgs(assertEqual)
+	vars["assert_error"] = assertError
+	vars["binary_part"] = binaryPart
+	vars["binary_to_list"] = oneArg(binaryToList)
+	vars["binary_to_str"] = oneArg(binaryToStr)
+	vars["byte_size"] = oneArg(byteSize)
+	vars["chars_to_string"] = oneArg(charsToString)
+	vars["ends_with"] = twoArgs(endsWith)
 	vars["error"] = oneArg(throwError)
 	vars["exit"] = oneArg(exit)
+	vars["format"] = format
+	vars["gen_atom"] = genAtom
+	vars["gen_bind"] = twoArgs(genBind)
+	vars["gen_int"] = genInt
+	vars["gen_list"] = oneArg(genList)
+	vars["gen_string"] = genString
+	vars["gen_such_that"] = twoArgs(genSuchThat)
+	vars["gen_tuple"] = oneArg(genTuple)
+	vars["halt"] = halt
 	vars["include"] = include
 	vars["is_atom"] = oneArg(is_type[Atom])
+	vars["is_binary"] = oneArg(is_type[Binary])
 	vars["is_bool"] = oneArg(is_type[Bool])
 	vars["is_int"] = oneArg(is_type[Int])
 	vars["is_list"] = oneArg(is_type[List])
@@ -32,30 +53,130 @@ func buildIns() map[string]Expr {
 	vars["is_tuple"] = oneArg(is_type[Tuple])
 	vars["last"] = oneArg(last)
 	vars["len"] = oneArg(length)
+	vars["length"] = oneArg(length)
+	vars["list_to_binary"] = oneArg(listToBinary)
+	vars["max"] = oneArg(maximum)
+	vars["min"] = oneArg(minimum)
 	vars["nth"] = nth
 	vars["print"] = oneArg(print)
+	vars["printf"] = printf
+	vars["prop_forall"] = propForall
 	vars["rest"] = oneArg(rest)
 	vars["rev"] = oneArg(rev)
 	vars["self"] = self
-	vars["sleep"] = oneArg(sleep)
-	vars["spawn"] = oneArg(spawn)
+	vars["sleep"] = sleep
+	vars["sort"] = sortList
+	vars["spawn"] = spawn
 	vars["split"] = oneArg(split)
+	vars["starts_with"] = twoArgs(startsWith)
 	vars["str"] = oneArg(str)
+	vars["str_to_binary"] = oneArg(strToBinary)
+	vars["string_find"] = twoArgs(stringFind)
+	vars["string_join"] = twoArgs(stringJoin)
+	vars["string_lower"] = oneArg(stringLower)
+	vars["string_replace"] = stringReplace
+	vars["string_split"] = twoArgs(stringSplit)
+	vars["string_substr"] = stringSubstr
+	vars["string_trim"] = oneArg(stringTrim)
+	vars["string_upper"] = oneArg(stringUpper)
+	vars["to_atom"] = oneArg(toAtom)
+	vars["to_int"] = oneArg(toInt)
+	vars["usort"] = oneArg(usort)
 	return vars
 }
 
+// The numbers of the arguments taken by the build-in functions.
+var buildInArities = map[string][]int{
+	"assert_equal":    {2},
+	"assert_error":    {1, 2},
+	"binary_part":     {3},
+	"binary_to_list":  {1},
+	"binary_to_str":   {1},
+	"byte_size":       {1},
+	"chars_to_string": {1},
+	"ends_with":       {2},
+	"error":           {1},
+	"exit":            {1},
+	"format":          {2},
+	"gen_atom":        {0},
+	"gen_bind":        {2},
+	"gen_int":         {0, 2},
+	"gen_list":        {1},
+	"gen_string":      {0},
+	"gen_such_that":   {2},
+	"gen_tuple":       {1},
+	"halt":            {0, 1},
+	"include":         {1},
+	"is_atom":         {1},
+	"is_binary":       {1},
+	"is_bool":         {1},
+	"is_int":          {1},
+	"is_list":         {1},
+	"is_str":          {1},
+	"is_tuple":        {1},
+	"last":            {1},
+	"len":             {1},
+	"length":          {1},
+	"list_to_binary":  {1},
+	"max":             {1},
+	"min":             {1},
+	"nth":             {2},
+	"print":           {1},
+	"printf":          {2},
+	"prop_forall":     {2, 3},
+	"rest":            {1},
+	"rev":             {1},
+	"self":            {0},
+	"sleep":           {1},
+	"sort":            {1, 2},
+	"spawn":           {1},
+	"split":           {1},
+	"starts_with":     {2},
+	"str":             {1},
+	"str_to_binary":   {1},
+	"string_find":     {2},
+	"string_join":     {2},
+	"string_lower":    {1},
+	"string_replace":  {3},
+	"string_split":    {2},
+	"string_substr":   {2, 3},
+	"string_trim":     {1},
+	"string_upper":    {1},
+	"to_atom":         {1},
+	"to_int":          {1},
+	"usort":           {1},
+}
+
+// The numbers of the arguments the build-in function takes, false if there is no such build-in.
+func BuildInArities(name string) ([]int, bool) {
+	arities, ok := buildInArities[name]
+	return arities, ok
+}
+
 // exit/1
 func exit(arg Expr) (Expr, error) {
 	return nil, errors.Exit{arg}
 }
 
+// halt/0 and halt/1
+func halt(args []Expr, _ *envir.Env, _ pids.Pid) (Expr, error) {
+	switch len(args) {
+	case 0:
+		return nil, errors.Halt{0}
+	case 1:
+		status, ok := args[0].(Int)
+		if !ok {
+			return nil, errors.NotNumber{args[0]}
+		}
+		return nil, errors.Halt{int(status)}
+	default:
+		return nil, errors.WrongNumberArgs{}
+	}
+}
+
 // error/1
 func throwError(arg Expr) (Expr, error) {
-	str, ok := arg.(String)
-	if !ok {
-		return nil, errors.NotString{arg}
-	}
-	return nil, errors.New(string(str))
+	return nil, errors.Error{arg}
 }
 
 // print/1
@@ -80,11 +201,18 @@ func self(args []Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
 }
 
 // sleep/1
-func sleep(arg Expr) (Expr, error) {
-	switch expr := arg.(type) {
+func sleep(args []Expr, _ *envir.Env, pid pids.Pid) (Expr, error) {
+	if len(args) != 1 {
+		return nil, errors.WrongNumberArgs{}
+	}
+	switch expr := args[0].(type) {
 	case Int:
-		time.Sleep(time.Duration(expr) * time.Millisecond)
-		return expr, nil
+		select {
+		case <-time.After(time.Duration(expr) * time.Millisecond):
+			return expr, nil
+		case <-pid.Done():
+			return nil, stopped(pid.Err())
+		}
 	default:
 		return nil, errors.NotNumber{expr}
 	}
@@ -108,6 +236,8 @@ func str(arg Expr) (Expr, error) {
 	switch expr := arg.(type) {
 	case String:
 		return expr, nil
+	case Atom:
+		return String(expr), nil
 	default:
 		return String(fmt.Sprintf("%v", expr)), nil
 	}
@@ -144,11 +274,13 @@ func last(arg Expr) (Expr, error) {
 	}
 }
 
-// length/1
+// len/1 and length/1
 func length(arg Expr) (Expr, error) {
 	switch expr := arg.(type) {
 	case List:
 		return Int(expr.Len()), nil
+	case String:
+		return Int(utf8.RuneCountInString(string(expr))), nil
 	default:
 		return nil, errors.NotList{expr}
 	}
@@ -208,6 +340,88 @@ func rev(arg Expr) (Expr, error) {
 	}
 }
 
+// sort/1, sort/2
+func sortList(args []Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
+	switch len(args) {
+	case 1:
+		list, ok := args[0].(List)
+		if !ok {
+			return nil, errors.NotList{args[0]}
+		}
+		sorted := slices.Clone(list.Values)
+		slices.SortStableFunc(sorted, Compare)
+		return List{sorted}, nil
+	case 2:
+		// like in Erlang, the function returns true when the first argument
+		// is less than or equal to the second one
+		list, ok := args[1].(List)
+		if !ok {
+			return nil, errors.NotList{args[1]}
+		}
+		var err error
+		sorted := slices.Clone(list.Values)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if err != nil {
+				return false
+			}
+			// a < b is the same as not b =< a
+			var result Expr
+			result, err = Apply(args[0], []Expr{sorted[j], sorted[i]}, env, pid)
+			switch result {
+			case Bool(true):
+				return false
+			case Bool(false):
+				return true
+			}
+			if err == nil {
+				err = errors.NotBoolean{result}
+			}
+			return false
+		})
+		return List{sorted}, err
+	default:
+		return nil, errors.WrongNumberArgs{}
+	}
+}
+
+// usort/1
+func usort(arg Expr) (Expr, error) {
+	list, ok := arg.(List)
+	if !ok {
+		return nil, errors.NotList{arg}
+	}
+	sorted := slices.Clone(list.Values)
+	slices.SortFunc(sorted, Compare)
+	sorted = slices.CompactFunc(sorted, func(a, b Expr) bool {
+		return Compare(a, b) == 0
+	})
+	return List{sorted}, nil
+}
+
+// min/1
+func minimum(arg Expr) (Expr, error) {
+	list, ok := arg.(List)
+	if !ok {
+		return nil, errors.NotList{arg}
+	}
+	if list.Len() == 0 {
+		return nil, errors.EmptyList{}
+	}
+	return slices.MinFunc(list.Values, Compare), nil
+}
+
+// max/1
+func maximum(arg Expr) (Expr, error) {
+	list, ok := arg.(List)
+	if !ok {
+		return nil, errors.NotList{arg}
+	}
+	if list.Len() == 0 {
+		return nil, errors.EmptyList{}
+	}
+	return slices.MaxFunc(list.Values, Compare), nil
+}
+
 // Decorate simple, single-argument function as a proper buildIn.
 func oneArg(fun func(Expr) (Expr, error)) func([]Expr, *envir.Env, pids.Pid) (Expr, error) {
 	return func(args []Expr, _ *envir.Env, _ pids.Pid) (Expr, error) {
@@ -217,3 +431,13 @@ func oneArg(fun func(Expr) (Expr, error)) func([]Expr, *envir.Env, pids.Pid) (Ex
 		return fun(args[0])
 	}
 }
+
+// Decorate simple, two-argument function as a proper buildIn.
+func twoArgs(fun func(Expr, Expr) (Expr, error)) func([]Expr, *envir.Env, pids.Pid) (Expr, error) {
+	return func(args []Expr, _ *envir.Env, _ pids.Pid) (Expr, error) {
+		if len(args) != 2 {
+			return nil, errors.WrongNumberArgs{}
+		}
+		return fun(args[0], args[1])
+	}
+}
@@ -0,0 +1,249 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	. "github.com/twolodzko/goer/types"
+)
+
+type opcode uint8
+
+const (
+	opConst        opcode = iota // push the constant `a`
+	opPop                        // drop the value from the top of the stack
+	opDup                        // duplicate the value on the top of the stack
+	opLoad                       // push the value of the variable `a`
+	opLoadAtom                   // push the value named by the atom `a`, or the atom itself
+	opLoadOrJump                 // push the value of the variable `a` if bound, otherwise jump to `b`
+	opUnbound                    // throw the unbound variable error for the name `a`
+	opBind                       // match the value with the variable `a`, bind it if unbound
+	opDefine                     // define the name `a` for the value, it needs to be new
+	opTuple                      // make a tuple from `a` values
+	opList                       // make a list from `a` values
+	opBinNew                     // start constructing the binary
+	opBinAppend                  // append the value to the binary using the segment `a` of the bit string `b`
+	opBinEnd                     // finish the binary, it needs to have whole bytes
+	opRecordDecl                 // declare the record `a` under the name `b`
+	opRecordNew                  // start constructing the record `b` declared under `a`
+	opRecordFrom                 // use the value as the base for the record update
+	opRecordField                // assign the value to the field `a` of the record being constructed
+	opRecordEnd                  // finish constructing the record, fill the defaults if `a` is 1
+	opRecordAccess               // get the field `b` of the record declared under `a`
+	opUnary                      // apply the unary operator `a`
+	opBinary                     // apply the binary operator `a`
+	opAdd                        // the operators with the fast paths for integers, `a` is the operator
+	opSub
+	opMul
+	opLess
+	opLessEq
+	opGreater
+	opGreaterEq
+	opEqual
+	opNotEqual
+	opIsTrue       // check if the value is a boolean
+	opJump         // jump to `a`
+	opJumpIf       // jump to `a` if the boolean on the top of the stack is `b`, keep it on the stack
+	opJumpIfFalse  // pop the boolean and jump to `a` if it is false
+	opMatchValue   // match the value with the constant `a`, swap the sides in the errors when `b` is 1
+	opMatchValues  // match two values, swap the sides in the errors when `a` is 1
+	opMatchTuple   // match the tuple of the pattern `a`, push its elements, swap the sides in the errors when `b` is 1
+	opMatchList    // like opMatchTuple, but for the lists
+	opMatchRecord  // match the record pattern `b` declared under `a`, push the values of its fields
+	opMatchBinary  // start matching the binary with the bit string `a`
+	opMatchPrefix  // match the string segment `a` of the bit string at the beginning of the binary
+	opMatchSegment // take the value for the segment `a` of the bit string, the size is on the stack if `b` is 1
+	opMatchBinEnd  // finish matching the binary, nothing should be left
+	opNoMatch      // throw the no match error for the constant `a`
+	opBadMatch     // throw the no match error for the pattern `a` and the value under the error, other errors again
+	opPushFail     // on failed pattern match or a guard jump to `a`
+	opPopFail      // remove the failure handler
+	opGuard        // fail if the value is not true
+	opTry          // on error jump to `a` with the error on the stack
+	opEndTry       // remove the error handler
+	opCatchClass   // push the class of the error
+	opCatchReason  // push the reason of the error
+	opCatchStack   // push the stack trace of the error
+	opFail         // fail the pattern match
+	opRethrow      // throw the error again
+	opCall         // call the function with `a` arguments, `b` is the called expression
+	opTailCall     // like opCall, but replaces the current call
+	opReturn       // return from the function
+	opClosure      // make a closure of the function `a`, visible by its name inside if `b` is 1
+	opArity        // jump to `b` if the function was not called with `a` arguments
+	opArg          // push the argument `a`
+	opClear        // clear the variables bound in the failed function branch
+	opNoBranch     // throw the error for the function call that did not match any branch
+	opNoCaseBranch // throw the error for the unmatched value of the case expression
+	opNoTrueBranch // throw the error for the if expression without a true branch
+	opNotMatched   // throw the assertion error for the value that does not match the pattern `a`
+	opTimeout      // convert the value to the receive timeout
+	opReceive      // wait for the message and push it, jump to `a` on timeout
+)
+
+var opcodeNames = [...]string{
+	"const", "pop", "dup", "load", "load_atom", "load_or_jump", "unbound", "bind", "define",
+	"tuple", "list", "bin_new", "bin_append", "record_decl", "record_new", "record_from",
+	"record_field", "record_end", "record_access", "unary", "binary", "add", "sub", "mul",
+	"less", "less_eq", "greater", "greater_eq", "equal", "not_equal", "is_true", "jump",
+	"jump_if", "jump_if_false", "match_value", "match_values", "match_tuple", "match_list",
+	"match_record", "match_binary", "match_prefix", "match_segment", "match_bin_end",
+	"no_match", "bad_match", "push_fail", "pop_fail", "guard", "try", "end_try", "catch_class",
+	"catch_reason", "catch_stack", "fail", "rethrow", "call", "tail_call", "return",
+	"closure", "arity", "arg", "clear", "no_branch", "no_case_branch", "no_true_branch",
+	"not_matched", "timeout", "receive",
+}
+
+func (op opcode) String() string {
+	return opcodeNames[op]
+}
+
+// Opcodes of the binary operators that have the fast paths.
+var binaryOpcodes = map[string]opcode{
+	"+":   opAdd,
+	"-":   opSub,
+	"*":   opMul,
+	"<":   opLess,
+	"<=":  opLessEq,
+	"=<":  opLessEq,
+	">":   opGreater,
+	">=":  opGreaterEq,
+	"==":  opEqual,
+	"=:=": opEqual,
+	"!=":  opNotEqual,
+	"/=":  opNotEqual,
+	"=/=": opNotEqual,
+}
+
+// Single instruction of the bytecode, the meaning of the arguments depends on the opcode.
+type instr struct {
+	op   opcode
+	a, b int32
+}
+
+// Compiled code of the function or of the top-level expressions. The constants hold
+// the values, but also the source code used in the error messages and the compiled
+// functions.
+type code struct {
+	instrs []instr
+	pos    []Pos // position of each instruction in the source code
+	consts []Expr
+	refs   []*ref
+}
+
+// Reference to the variable, atom, or record. The `path` lists the slots that could hold
+// it in the enclosing functions, starting from the innermost one. If none of them holds
+// it, it is looked up in the global environment. The values are bound in the `slot` of
+// the current function, or in the global environment when it is negative.
+type ref struct {
+	key  Expr // Variable or Atom
+	name string
+	slot int
+	path []slotRef
+}
+
+type slotRef struct {
+	depth, slot int
+}
+
+// Compiled function definition.
+type proto struct {
+	Definition
+	code    *code
+	nslots  int
+	arities map[int]bool
+}
+
+// Variables of the function call, stored in the slots assigned by the compiler.
+type locals struct {
+	slots  []Expr
+	parent *locals
+}
+
+// A function compiled to the bytecode, that encloses the variables of the functions
+// where it was defined, and the global environment.
+type Closure struct {
+	proto  *proto
+	locals *locals
+	env    *envir.Env
+}
+
+func (*Closure) Order() int {
+	return OrderFun
+}
+
+// Closures are ordered by their identity, after the functions evaluated
+// by the tree-walker.
+func (fun *Closure) Compare(other Expr) int {
+	switch other := other.(type) {
+	case *Closure:
+		if fun == other {
+			return 0
+		}
+		if fun.proto != other.proto {
+			return comparePointers(fun.proto, other.proto)
+		}
+		return comparePointers(fun.locals, other.locals)
+	default:
+		return 1
+	}
+}
+
+func (fun *Closure) Hash() uint64 {
+	return uint64(pointer(fun.proto))*31 + uint64(pointer(fun.locals))
+}
+
+func (fun *Closure) String() string {
+	return fun.proto.Definition.String()
+}
+
+// The record declared by the compiled code, with the default values of its fields
+// compiled as the functions without arguments.
+type compiledRecord struct {
+	RecordDecl
+	defaults []*Closure
+}
+
+// Print the bytecode, for debugging.
+func (c *code) String() string {
+	var b strings.Builder
+	for i, in := range c.instrs {
+		fmt.Fprintf(&b, "%4d %-14v %d %d", i, in.op, in.a, in.b)
+		switch in.op {
+		case opConst, opMatchValue, opNoMatch, opBadMatch, opUnary, opBinary, opAdd, opSub, opMul,
+			opLess, opLessEq, opGreater, opGreaterEq, opEqual, opNotEqual:
+			fmt.Fprintf(&b, "\t; %v", c.consts[in.a])
+		case opLoad, opLoadAtom, opLoadOrJump, opBind, opDefine:
+			fmt.Fprintf(&b, "\t; %s", c.refs[in.a].name)
+		case opClosure:
+			fmt.Fprintf(&b, "\n%v", indent(c.consts[in.a].(*proto).code.String()))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func indent(s string) string {
+	return "\t" + strings.ReplaceAll(s, "\n", "\n\t")
+}
+
+// The error thrown when the value does not match the pattern, the sides are swapped
+// when the pattern was on the right-hand side of the match.
+func noMatch(pattern, val Expr, swap bool) error {
+	if swap {
+		return errors.NoMatch{val, pattern}
+	}
+	return errors.NoMatch{pattern, val}
+}
+
+func pointer(p any) uintptr {
+	return reflect.ValueOf(p).Pointer()
+}
+
+func comparePointers(lhs, rhs any) int {
+	return cmp.Compare(pointer(lhs), pointer(rhs))
+}
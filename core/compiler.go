@@ -0,0 +1,825 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	. "github.com/twolodzko/goer/types"
+)
+
+// Compiler of the expressions to the bytecode. The variables bound in the functions
+// are assigned to the slots, the top-level variables live in the global environment.
+type compiler struct {
+	code  *code
+	scope *scope // nil at the top level
+	// position of the currently compiled expression, used to annotate the errors
+	pos Pos
+	// the references are resolved when everything is compiled, since
+	// the enclosing functions can bind the variables later on
+	refs []scopedRef
+}
+
+// Names bound in the function, mapped to their slots.
+type scope struct {
+	names  map[string]int
+	parent *scope
+}
+
+type scopedRef struct {
+	ref   *ref
+	scope *scope
+}
+
+// Jump target that is not known yet.
+type label []int
+
+// Compile the top-level expressions.
+func compile(exprs []Expr) *code {
+	c := &compiler{code: &code{}}
+	c.compileBody(exprs, Pos{}, true)
+	c.emit(opReturn, 0, 0)
+	c.resolve()
+	return c.code
+}
+
+// Resolve the references to the slots of the enclosing functions.
+func (c *compiler) resolve() {
+	for _, r := range c.refs {
+		depth := 0
+		for s := r.scope; s != nil; s = s.parent {
+			if slot, ok := s.names[r.ref.name]; ok {
+				r.ref.path = append(r.ref.path, slotRef{depth, slot})
+			}
+			depth++
+		}
+	}
+}
+
+// Emit the instruction, return its address.
+func (c *compiler) emit(op opcode, a, b int) int {
+	c.code.instrs = append(c.code.instrs, instr{op, int32(a), int32(b)})
+	c.code.pos = append(c.code.pos, c.pos)
+	return len(c.code.instrs) - 1
+}
+
+// Emit the jump instruction with the argument `arg`, the target is set by `c.mark`.
+func (c *compiler) emitJump(l *label, op opcode, arg int) {
+	if hasTargetInB(op) {
+		*l = append(*l, c.emit(op, arg, 0))
+	} else {
+		*l = append(*l, c.emit(op, 0, arg))
+	}
+}
+
+// Set the target of the jumps to the next instruction.
+func (c *compiler) mark(l label) {
+	target := int32(c.here())
+	for _, at := range l {
+		if hasTargetInB(c.code.instrs[at].op) {
+			c.code.instrs[at].b = target
+		} else {
+			c.code.instrs[at].a = target
+		}
+	}
+}
+
+// The jump instructions that use the first argument for something else.
+func hasTargetInB(op opcode) bool {
+	return op == opLoadOrJump || op == opArity
+}
+
+// Address of the next instruction.
+func (c *compiler) here() int {
+	return len(c.code.instrs)
+}
+
+func (c *compiler) constant(val Expr) int {
+	c.code.consts = append(c.code.consts, val)
+	return len(c.code.consts) - 1
+}
+
+// Reference to the name, to be resolved later.
+func (c *compiler) ref(key Expr, name string) int {
+	r := &ref{key: key, name: name, slot: -1}
+	c.refs = append(c.refs, scopedRef{r, c.scope})
+	c.code.refs = append(c.code.refs, r)
+	return len(c.code.refs) - 1
+}
+
+// Reference to the name bound in the current function.
+func (c *compiler) bindRef(key Expr, name string) int {
+	i := c.ref(key, name)
+	if c.scope != nil {
+		r := c.code.refs[i]
+		r.slot = c.scope.slot(name)
+	}
+	return i
+}
+
+// The slot of the name, it is assigned when the name is used for the first time.
+func (s *scope) slot(name string) int {
+	if slot, ok := s.names[name]; ok {
+		return slot
+	}
+	slot := len(s.names)
+	s.names[name] = slot
+	return slot
+}
+
+// Compile the expression at the position, the position applies to the errors
+// thrown by its parts that do not have positions themselves.
+func (c *compiler) at(pos Pos, compile func()) {
+	prev := c.pos
+	c.pos = pos
+	compile()
+	c.pos = prev
+}
+
+// Compile the sequence of expressions, leaving the value of the last one on the stack.
+// Like in the evaluator, the last expression continues the enclosing one, so its errors
+// are annotated with the position `last`.
+func (c *compiler) compileBody(exprs []Expr, last Pos, tail bool) {
+	if len(exprs) == 0 {
+		c.emit(opConst, c.constant(Atom("ok")), 0)
+		return
+	}
+	for _, expr := range exprs[:len(exprs)-1] {
+		c.compileExpr(expr, false)
+		c.emit(opPop, 0, 0)
+	}
+	c.at(last, func() { c.compileExpr(exprs[len(exprs)-1], tail) })
+}
+
+// Compile the expression, leaving its value on the stack. In the tail position
+// the function calls replace the current call.
+func (c *compiler) compileExpr(expr Expr, tail bool) {
+	switch val := expr.(type) {
+	case Variable:
+		c.emit(opLoad, c.ref(val, string(val)), 0)
+	case Dummy:
+		c.emit(opUnbound, c.constant(errors.Unbound{"_"}), 0)
+	case Atom:
+		c.emit(opLoadAtom, c.ref(val, string(val)), 0)
+	case QuotedAtom:
+		c.emit(opConst, c.constant(Atom(val)), 0)
+	case Tuple:
+		for _, v := range val.Values {
+			c.compileExpr(v, false)
+		}
+		c.emit(opTuple, len(val.Values), 0)
+	case List:
+		for _, v := range val.Values {
+			c.compileExpr(v, false)
+		}
+		c.emit(opList, len(val.Values), 0)
+	case BitString:
+		c.at(val.Pos, func() {
+			bits := c.constant(val)
+			c.emit(opBinNew, 0, 0)
+			for i, segment := range val.Segments {
+				c.compileExpr(segment.Value, false)
+				if segment.Size != nil {
+					c.compileExpr(segment.Size, false)
+				}
+				c.emit(opBinAppend, i, bits)
+			}
+			c.emit(opBinEnd, 0, 0)
+		})
+	case RecordDecl:
+		c.at(val.Pos, func() { c.compileRecordDecl(val) })
+	case Record:
+		c.at(val.Pos, func() { c.compileRecord(val) })
+	case RecordAccess:
+		c.at(val.Pos, func() {
+			key := c.ref(recordKey(val.Name), string(recordKey(val.Name)))
+			c.compileExpr(val.Expr, false)
+			c.emit(opRecordAccess, key, c.constant(val))
+		})
+	case UnaryOperation:
+		c.at(val.Pos, func() {
+			c.compileExpr(val.Rhs, false)
+			c.emit(opUnary, c.constant(val.Op), 0)
+		})
+	case BinaryOperation:
+		c.compileOperation(val, tail)
+	case Bracket:
+		c.compileExpr(val.Expr, tail)
+	case Block:
+		outer := c.pos
+		c.at(val.Pos, func() { c.compileBody(val.Body, outer, tail) })
+	case If:
+		c.compileIf(val, tail)
+	case Case:
+		c.compileCase(val, tail)
+	case AssertMatch:
+		c.compileAssertMatch(val)
+	case TryRecover:
+		c.at(val.Pos, func() {
+			var end, recover label
+			c.emitJump(&recover, opTry, 0)
+			c.compileBody(val.Body, val.Pos, false)
+			c.emit(opEndTry, 0, 0)
+			c.emitJump(&end, opJump, 0)
+			c.mark(recover)
+			c.emit(opPop, 0, 0)
+			c.compileBody(val.Recover, val.Pos, false)
+			c.mark(end)
+		})
+	case TryCatch:
+		c.compileTryCatch(val, tail)
+	case Definition:
+		c.at(val.Pos, func() { c.compileFun(val) })
+	case Call:
+		c.at(val.Pos, func() {
+			for _, arg := range val.Args {
+				c.compileExpr(arg, false)
+			}
+			c.compileExpr(val.Callable, false)
+			op := opCall
+			if tail {
+				op = opTailCall
+			}
+			c.emit(op, len(val.Args), c.constant(val.Callable))
+		})
+	case Receive:
+		c.compileReceive(val, tail)
+	case Bool, Int, String, Binary, pids.Pid, Fun, *Closure, *Generator, buildIn:
+		c.emit(opConst, c.constant(val), 0)
+	default:
+		panic(fmt.Sprintf("value of type %T cannot be compiled", val))
+	}
+}
+
+// Compile the binary operation.
+func (c *compiler) compileOperation(val BinaryOperation, tail bool) {
+	prev := c.pos
+	c.pos = val.Pos
+	defer func() { c.pos = prev }()
+
+	switch val.Op {
+	case "=":
+		c.compileMatch(val.Lhs, val.Rhs, true)
+		c.emit(opConst, c.constant(Bool(true)), 0)
+	case "andalso", "orelse":
+		var end label
+		c.compileExpr(val.Lhs, false)
+		c.emit(opIsTrue, 0, 0)
+		short := 0
+		if val.Op == "orelse" {
+			short = 1
+		}
+		c.emitJump(&end, opJumpIf, short)
+		c.emit(opPop, 0, 0)
+		// the right-hand side is not checked, so it can be a tail call
+		c.pos = prev
+		c.compileExpr(val.Rhs, tail)
+		c.mark(end)
+	default:
+		c.compileExpr(val.Lhs, false)
+		c.compileExpr(val.Rhs, false)
+		op, ok := binaryOpcodes[val.Op]
+		if !ok {
+			op = opBinary
+		}
+		c.emit(op, c.constant(val.Op), 0)
+	}
+}
+
+// Compile the if expression.
+func (c *compiler) compileIf(block If, tail bool) {
+	var end label
+	outer := c.pos
+	c.at(block.Pos, func() {
+		for _, branch := range block.Branches {
+			if isTrueish(branch.Cond) {
+				c.compileBody(branch.Body, outer, tail)
+				c.mark(end)
+				return
+			}
+			var next label
+			c.compileExpr(branch.Cond, false)
+			c.emit(opIsTrue, 0, 0)
+			c.emitJump(&next, opJumpIfFalse, 0)
+			c.compileBody(branch.Body, outer, tail)
+			c.emitJump(&end, opJump, 0)
+			c.mark(next)
+		}
+		c.emit(opNoTrueBranch, 0, 0)
+		c.mark(end)
+	})
+}
+
+// Compile the case expression. The value is kept on the stack while
+// the branches are matched.
+func (c *compiler) compileCase(block Case, tail bool) {
+	var end label
+	outer := c.pos
+	c.at(block.Pos, func() {
+		c.compileExpr(block.Arg, false)
+		for _, branch := range block.Branches {
+			var next label
+			c.emitJump(&next, opPushFail, 0)
+			c.emit(opDup, 0, 0)
+			c.compilePattern(branch.Pattern)
+			c.compileGuards(branch.Guards)
+			c.emit(opPopFail, 0, 0)
+			c.emit(opPop, 0, 0)
+			c.compileBody(branch.Body, outer, tail)
+			c.emitJump(&end, opJump, 0)
+			c.mark(next)
+		}
+		c.emit(opNoCaseBranch, 0, 0)
+		c.mark(end)
+	})
+}
+
+// Compile the assertion like the case expression with a single branch,
+// the value that does not match is reported with the pattern.
+func (c *compiler) compileAssertMatch(assert AssertMatch) {
+	var fail, end label
+	c.at(assert.Pos, func() {
+		c.compileExpr(assert.Expr, false)
+		c.emitJump(&fail, opPushFail, 0)
+		c.emit(opDup, 0, 0)
+		c.compilePattern(assert.Pattern)
+		c.emit(opPopFail, 0, 0)
+		c.emit(opPop, 0, 0)
+		c.emit(opConst, c.constant(Atom("ok")), 0)
+		c.emitJump(&end, opJump, 0)
+		c.mark(fail)
+		c.emit(opNotMatched, c.constant(String(fmt.Sprint(assert.Pattern))), 0)
+		c.mark(end)
+	})
+}
+
+// Compile the receive expression. The timeout is kept on the stack while
+// waiting for the messages, and the message while the branches are matched.
+func (c *compiler) compileReceive(block Receive, tail bool) {
+	var end, after label
+	outer := c.pos
+	c.at(block.Pos, func() {
+		if block.After.Cond != nil {
+			c.compileExpr(block.After.Cond, false)
+			c.emit(opTimeout, 0, 0)
+		} else {
+			c.emit(opConst, c.constant(defaultTimeout), 0)
+		}
+		start := c.here()
+		c.emitJump(&after, opReceive, 0)
+		for _, branch := range block.Branches {
+			var next label
+			c.emitJump(&next, opPushFail, 0)
+			c.emit(opDup, 0, 0)
+			c.compilePattern(branch.Pattern)
+			c.compileGuards(branch.Guards)
+			c.emit(opPopFail, 0, 0)
+			c.emit(opPop, 0, 0)
+			c.emit(opPop, 0, 0)
+			c.compileBody(branch.Body, outer, tail)
+			c.emitJump(&end, opJump, 0)
+			c.mark(next)
+		}
+		// the messages that do not match are ignored
+		c.emit(opPop, 0, 0)
+		c.emit(opJump, start, 0)
+		c.mark(after)
+		c.emit(opPop, 0, 0)
+		c.compileBody(block.After.Body, outer, tail)
+		c.mark(end)
+	})
+}
+
+// Compile the try-catch expression. The error is kept on the stack while the
+// branches are matched.
+func (c *compiler) compileTryCatch(block TryCatch, tail bool) {
+	var end, catch label
+	outer := c.pos
+	c.at(block.Pos, func() {
+		c.emitJump(&catch, opTry, 0)
+		c.compileBody(block.Body, block.Pos, false)
+		c.emit(opEndTry, 0, 0)
+		c.emitJump(&end, opJump, 0)
+		c.mark(catch)
+		for _, branch := range block.Branches {
+			var next label
+			c.emitJump(&next, opPushFail, 0)
+			c.compileCatchClass(branch.Class)
+			c.emit(opCatchReason, 0, 0)
+			c.compilePattern(branch.Pattern)
+			c.compileCatchStack(branch.Stack)
+			c.compileGuards(branch.Guards)
+			c.emit(opPopFail, 0, 0)
+			c.emit(opPop, 0, 0)
+			c.compileBody(branch.Body, outer, tail)
+			c.emitJump(&end, opJump, 0)
+			c.mark(next)
+		}
+		c.emit(opRethrow, 0, 0)
+		c.mark(end)
+	})
+}
+
+// Match the class of the error, like `matchClass`.
+func (c *compiler) compileCatchClass(pattern Expr) {
+	switch pattern := pattern.(type) {
+	case Dummy:
+	case Variable:
+		c.emit(opCatchClass, 0, 0)
+		c.emit(opBind, c.bindRef(pattern, string(pattern)), 0)
+	case Atom, QuotedAtom:
+		c.emit(opCatchClass, 0, 0)
+		c.emit(opMatchValue, c.constant(pattern), 0)
+	default:
+		c.emit(opFail, 0, 0)
+	}
+}
+
+// Bind the stack trace of the error, like `matchStack`.
+func (c *compiler) compileCatchStack(pattern Expr) {
+	switch pattern := pattern.(type) {
+	case nil, Dummy:
+	case Variable:
+		c.emit(opCatchStack, 0, 0)
+		c.emit(opBind, c.bindRef(pattern, string(pattern)), 0)
+	default:
+		c.emit(opFail, 0, 0)
+	}
+}
+
+// Compile the guards, the branch fails if any of them is not true.
+func (c *compiler) compileGuards(guards []Expr) {
+	for _, guard := range guards {
+		c.compileExpr(guard, false)
+		c.emit(opGuard, 0, 0)
+	}
+}
+
+// Compile the function definition. The branches are tried one after another, the
+// arguments are matched while the failure handler is set, and when the branch fails,
+// the variables it bound are cleared.
+func (c *compiler) compileFun(def Definition) {
+	p := &proto{Definition: def, arities: make(map[int]bool)}
+
+	outer, outerCode, outerPos := c.scope, c.code, c.pos
+	parent := outer
+	if def.Local {
+		// the function can call itself by the name
+		parent = &scope{map[string]int{def.Name: 0}, outer}
+	}
+	c.scope = &scope{make(map[string]int), parent}
+	c.code = &code{}
+	c.pos = Pos{}
+
+	for _, branch := range def.Branches {
+		var next, clear label
+		p.arities[len(branch.Args)] = true
+		c.emitJump(&next, opArity, len(branch.Args))
+		c.emitJump(&clear, opPushFail, 0)
+		for i, arg := range branch.Args {
+			c.emit(opArg, i, 0)
+			c.compilePattern(arg)
+		}
+		c.compileGuards(branch.Guards)
+		c.emit(opPopFail, 0, 0)
+		c.compileBody(branch.Body, Pos{}, true)
+		c.emit(opReturn, 0, 0)
+		c.mark(clear)
+		c.emit(opClear, 0, 0)
+		c.mark(next)
+	}
+	c.emit(opNoBranch, 0, 0)
+
+	p.code = c.code
+	p.nslots = len(c.scope.names)
+	c.scope, c.code, c.pos = outer, outerCode, outerPos
+
+	local := 0
+	if def.Local {
+		local = 1
+	}
+	c.emit(opClosure, c.constant(p), local)
+	if def.Name != "" && !def.Local {
+		c.emit(opDefine, c.bindRef(Atom(def.Name), def.Name), 0)
+	}
+}
+
+// Compile the record declaration, the default values of the fields are compiled
+// as functions without arguments, evaluated when the record is created.
+func (c *compiler) compileRecordDecl(decl RecordDecl) {
+	var defaults []*proto
+	for _, field := range decl.Fields {
+		if field.Value == nil {
+			defaults = append(defaults, nil)
+			continue
+		}
+		def := Definition{Branches: []FunBranch{{Body: []Expr{field.Value}}}, Pos: decl.Pos}
+		outer, outerCode := c.scope, c.code
+		c.scope = &scope{make(map[string]int), outer}
+		c.code = &code{}
+		c.compileExpr(field.Value, true)
+		c.emit(opReturn, 0, 0)
+		p := &proto{def, c.code, len(c.scope.names), map[int]bool{0: true}}
+		c.scope, c.code = outer, outerCode
+		defaults = append(defaults, p)
+	}
+	key := recordKey(decl.Name)
+	c.emit(opRecordDecl, c.constant(recordProto{decl, defaults}), c.bindRef(key, string(key)))
+}
+
+// Record declaration with compiled default values.
+type recordProto struct {
+	decl     RecordDecl
+	defaults []*proto
+}
+
+// Compile the record construction or update.
+func (c *compiler) compileRecord(record Record) {
+	key := recordKey(record.Name)
+	c.emit(opRecordNew, c.ref(key, string(key)), c.constant(record))
+	if record.Expr != nil {
+		c.compileExpr(record.Expr, false)
+		c.emit(opRecordFrom, 0, 0)
+	}
+	for _, field := range record.Fields {
+		at := c.emit(opRecordField, c.constant(field.Name), 0)
+		c.compileExpr(field.Value, false)
+		// the value is assigned after it is evaluated
+		c.emit(opRecordField, int(c.code.instrs[at].a), 1)
+	}
+	fill := 0
+	if record.Expr == nil {
+		fill = 1
+	}
+	c.emit(opRecordEnd, fill, 0)
+}
+
+// Compile the match (=) operation, following the rules of `match`: the variables
+// on either side are bound, the containers written on both sides are matched
+// element by element, and the other expressions are evaluated and compared.
+// At the top level, the bound variable on the right-hand side is matched by its value.
+func (c *compiler) compileMatch(lhs, rhs Expr, top bool) {
+	switch l := lhs.(type) {
+	case Dummy:
+		return
+	case Variable:
+		if _, ok := rhs.(Dummy); ok {
+			return
+		}
+		c.compileExpr(rhs, false)
+		c.emit(opBind, c.bindRef(l, string(l)), 0)
+		return
+	}
+
+	if vars, ok := rhsVariables(rhs); top && ok {
+		c.compileMatchValue(lhs, rhs, vars)
+		return
+	}
+
+	switch lhs.(type) {
+	case BitString, Record:
+		if _, ok := rhs.(Dummy); ok {
+			return
+		}
+		c.compileExpr(rhs, false)
+		c.compilePattern(lhs)
+		return
+	}
+
+	switch r := rhs.(type) {
+	case Dummy:
+		if !isContainer(lhs) {
+			c.compileExpr(lhs, false)
+			c.emit(opPop, 0, 0)
+		}
+	case Variable:
+		c.compileExpr(lhs, false)
+		c.emit(opBind, c.bindRef(r, string(r)), 0)
+	case BitString, Record:
+		c.compileExpr(lhs, false)
+		c.compilePattern(rhs)
+	default:
+		c.compileValues(lhs, rhs)
+	}
+}
+
+// When the variables of the right-hand side are bound, it is evaluated once and its value
+// is matched against the pattern, the no match error carries the whole value. Otherwise
+// the sides are matched like the containers.
+func (c *compiler) compileMatchValue(lhs, rhs Expr, vars []Variable) {
+	var unbound, fail, end label
+	for _, v := range vars {
+		c.emitJump(&unbound, opLoadOrJump, c.ref(v, string(v)))
+		c.emit(opPop, 0, 0)
+	}
+	c.compileExpr(rhs, false)
+	c.emitJump(&fail, opTry, 0)
+	c.emit(opDup, 0, 0)
+	c.compilePattern(lhs)
+	c.emit(opEndTry, 0, 0)
+	c.emit(opPop, 0, 0)
+	c.emitJump(&end, opJump, 0)
+	c.mark(fail)
+	c.emit(opBadMatch, c.constant(lhs), 0)
+	if len(vars) > 0 {
+		c.mark(unbound)
+		c.compileMatch(lhs, rhs, false)
+	}
+	c.mark(end)
+}
+
+// The variables of the right-hand side of the match, that need to be bound for it
+// to be evaluated, like in `isBound`. It is false if the right-hand side has dummies.
+func rhsVariables(expr Expr) ([]Variable, bool) {
+	var (
+		vars []Variable
+		walk func(Expr) bool
+	)
+	walk = func(expr Expr) bool {
+		switch val := expr.(type) {
+		case Dummy:
+			return false
+		case Variable:
+			vars = append(vars, val)
+		case List:
+			return walkAll(val.Values, walk)
+		case Tuple:
+			return walkAll(val.Values, walk)
+		case BitString:
+			for _, segment := range val.Segments {
+				if !walk(segment.Value) || (segment.Size != nil && !walk(segment.Size)) {
+					return false
+				}
+			}
+		case Record:
+			if val.Expr != nil && !walk(val.Expr) {
+				return false
+			}
+			for _, field := range val.Fields {
+				if !walk(field.Value) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	ok := walk(expr)
+	return vars, ok
+}
+
+func walkAll(exprs []Expr, walk func(Expr) bool) bool {
+	for _, expr := range exprs {
+		if !walk(expr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match the containers written on both sides element by element,
+// otherwise evaluate the expressions and compare them.
+func (c *compiler) compileValues(lhs, rhs Expr) {
+	lvals, lok := containerValues(lhs)
+	rvals, rok := containerValues(rhs)
+	switch {
+	case lok && rok:
+		if !sameKind(lhs, rhs) || len(lvals) != len(rvals) {
+			c.emit(opNoMatch, c.constant(errors.NoMatch{lhs, rhs}), 0)
+			return
+		}
+		for i := range lvals {
+			c.compileMatch(lvals[i], rvals[i], false)
+		}
+	case lok:
+		if _, ok := rhs.(Atom); ok {
+			c.emit(opNoMatch, c.constant(errors.NoMatch{lhs, rhs}), 0)
+			return
+		}
+		c.compileExpr(rhs, false)
+		c.compilePattern(lhs)
+	case rok:
+		if _, ok := lhs.(Atom); ok {
+			c.emit(opNoMatch, c.constant(errors.NoMatch{lhs, rhs}), 0)
+			return
+		}
+		c.compileExpr(lhs, false)
+		c.compilePatternSwapped(rhs, true)
+	default:
+		if l, ok := lhs.(Atom); ok {
+			if r, ok := rhs.(Atom); ok {
+				if l != r {
+					c.emit(opNoMatch, c.constant(errors.NoMatch{lhs, rhs}), 0)
+				}
+				return
+			}
+			c.compileExpr(rhs, false)
+			c.emit(opMatchValue, c.constant(l), 0)
+			return
+		}
+		c.compileExpr(lhs, false)
+		if r, ok := rhs.(Atom); ok {
+			c.emit(opMatchValue, c.constant(r), 1)
+			return
+		}
+		c.compileExpr(rhs, false)
+		c.emit(opMatchValues, 0, 0)
+	}
+}
+
+// Compile the pattern, matched against the value on the top of the stack.
+func (c *compiler) compilePattern(pattern Expr) {
+	c.compilePatternSwapped(pattern, false)
+}
+
+// Compile the pattern, when `swap` is true the pattern was on the right-hand side
+// of the match, so the sides are swapped in the errors.
+func (c *compiler) compilePatternSwapped(pattern Expr, swap bool) {
+	flag := 0
+	if swap {
+		flag = 1
+	}
+	switch p := pattern.(type) {
+	case Dummy:
+		c.emit(opPop, 0, 0)
+	case Variable:
+		c.emit(opBind, c.bindRef(p, string(p)), 0)
+	case Atom, QuotedAtom:
+		c.emit(opMatchValue, c.constant(p), flag)
+	case Tuple:
+		c.emit(opMatchTuple, c.constant(p), flag)
+		for _, v := range p.Values {
+			c.compilePatternSwapped(v, swap)
+		}
+	case List:
+		c.emit(opMatchList, c.constant(p), flag)
+		for _, v := range p.Values {
+			c.compilePatternSwapped(v, swap)
+		}
+	case BitString:
+		c.at(p.Pos, func() { c.compileBinaryPattern(p) })
+	case Record:
+		c.at(p.Pos, func() {
+			key := recordKey(p.Name)
+			c.emit(opMatchRecord, c.ref(key, string(key)), c.constant(p))
+			for _, field := range p.Fields {
+				c.compilePatternSwapped(field.Value, swap)
+			}
+		})
+	case Bool, Int, String, Binary:
+		c.emit(opMatchValue, c.constant(p), flag)
+	default:
+		c.compileExpr(pattern, false)
+		c.emit(opMatchValues, 1-flag, 0)
+	}
+}
+
+// Compile the bit string pattern, the state of the matching is kept on the stack.
+func (c *compiler) compileBinaryPattern(bits BitString) {
+	src := c.constant(bits)
+	c.emit(opMatchBinary, src, 0)
+	for i, segment := range bits.Segments {
+		if isPrefix(segment) {
+			c.emit(opMatchPrefix, i, src)
+			continue
+		}
+		hasSize := 0
+		if segment.Size != nil {
+			c.compileExpr(segment.Size, false)
+			hasSize = 1
+		}
+		c.emit(opMatchSegment, i, hasSize)
+		c.compilePattern(segment.Value)
+	}
+	c.emit(opMatchBinEnd, 0, 0)
+}
+
+// Lists and tuples are matched element by element.
+func isContainer(expr Expr) bool {
+	_, ok := containerValues(expr)
+	return ok
+}
+
+func containerValues(expr Expr) ([]Expr, bool) {
+	switch expr := expr.(type) {
+	case Tuple:
+		return expr.Values, true
+	case List:
+		return expr.Values, true
+	default:
+		return nil, false
+	}
+}
+
+// Both are tuples, or both are lists.
+func sameKind(lhs, rhs Expr) bool {
+	switch lhs.(type) {
+	case Tuple:
+		_, ok := rhs.(Tuple)
+		return ok
+	case List:
+		_, ok := rhs.(List)
+		return ok
+	default:
+		return false
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"math"
+	"os"
 	"time"
 
 	"github.com/twolodzko/goer/core/envir"
@@ -16,7 +17,9 @@ const defaultTimeout time.Duration = math.MaxInt64
 func send(to, msg Expr) (Expr, error) {
 	switch pid := to.(type) {
 	case pids.Pid:
-		pid.Send(msg)
+		if err := pid.Send(msg); err != nil {
+			return nil, err
+		}
 		return msg, nil
 	default:
 		return nil, errors.New("%v is not a pid", to)
@@ -24,22 +27,54 @@ func send(to, msg Expr) (Expr, error) {
 }
 
 // spawn/1
-func spawn(arg Expr) (Expr, error) {
-	fun, ok := arg.(Fun)
-	if !ok {
-		return nil, errors.NotFunction{arg}
+func spawn(args []Expr, _ *envir.Env, parent pids.Pid) (Expr, error) {
+	if len(args) != 1 {
+		return nil, errors.WrongNumberArgs{}
 	}
-	pid := pids.NewPid()
-
-	go func() {
-		defer pid.Close()
-		expr, env, err := fun.call(nil, pid)
-		if err == nil {
-			Eval(expr, env, pid)
+	switch fun := args[0].(type) {
+	case Fun:
+		pid, err := parent.Spawn()
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			defer pid.Close()
+			expr, env, err := fun.call(nil, pid)
+			if err == nil {
+				_, err = Eval(expr, env, pid)
+			}
+			halted(err, pid)
+		}()
+		return pid, nil
+	case *Closure:
+		pid, err := parent.Spawn()
+		if err != nil {
+			return nil, err
 		}
-	}()
+		go func() {
+			defer pid.Close()
+			_, err := fun.call(nil, pid)
+			halted(err, pid)
+		}()
+		return pid, nil
+	default:
+		return nil, errors.NotFunction{args[0]}
+	}
+}
 
-	return pid, nil
+// The process called `halt`, as in Erlang, it stops the whole program, or when the
+// process belongs to a group, all the processes of the group, like the exceeded limits.
+func halted(err error, pid pids.Pid) {
+	if !errors.IsFatal(err) {
+		return
+	}
+	if group := pid.Group(); group != nil {
+		group.Stop(errors.Cause(err))
+		return
+	}
+	if halt, ok := errors.Cause(err).(errors.Halt); ok {
+		os.Exit(halt.Status)
+	}
 }
 
 // Run the receive block.
@@ -55,15 +90,20 @@ func receive(receive Receive, env *envir.Env, pid pids.Pid) (Expr, *envir.Env, e
 		select {
 		case msg := <-pid.Messages():
 			for _, branch := range receive.Branches {
-				if match(branch.Pattern, msg, env, pid) == nil {
-					ok, err := evalAllTrue(branch.Guards, env, pid)
-					if ok && err == nil {
+				err := matchValue(branch.Pattern, msg, env, pid)
+				if uncatchable(err) {
+					return nil, env, err
+				}
+				if err == nil {
+					if evalGuards(branch.Guards, env, pid) {
 						return partialEval(branch.Body, env, pid)
 					}
 				}
 			}
 		case <-time.After(timeout):
 			return partialEval(receive.After.Body, env, pid)
+		case <-pid.Done():
+			return nil, env, stopped(pid.Err())
 		}
 	}
 }
@@ -75,16 +115,7 @@ func getTimeout(r Receive, env *envir.Env, pid pids.Pid) (time.Duration, error)
 		if err != nil {
 			return 0, err
 		}
-
-		switch t := expr.(type) {
-		case Int:
-			return time.Duration(t) * time.Millisecond, nil
-		case Atom:
-			if t == "infinity" {
-				return defaultTimeout, nil
-			}
-		}
-		return 0, errors.NotNumber{expr}
+		return toTimeout(expr)
 	}
 	return defaultTimeout, nil
 }
@@ -2,6 +2,8 @@ package core
 
 import (
 	"fmt"
+	"math"
+	"slices"
 	"testing"
 	"time"
 
@@ -15,6 +17,41 @@ import (
 	. "github.com/twolodzko/goer/types"
 )
 
+// The evaluator and the virtual machine are tested against the same cases.
+var engines = []struct {
+	name     string
+	eval     func(string, *envir.Env, pids.Pid) (Expr, error)
+	evalFile func(string, *envir.Env, pids.Pid) (Expr, error)
+	evalCode func(string, *envir.Env, pids.Pid) (Expr, error)
+}{
+	{"tree", ParseEval, EvalFile, EvalCode},
+	{"vm", ParseRun, RunFile, RunCode},
+}
+
+func TestBuildInArities(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnv()
+	pid := pids.NewPid()
+	defer pid.Close()
+
+	for name, fun := range buildIns() {
+		arities, ok := BuildInArities(name)
+		if !ok {
+			t.Errorf("the arities of %s are missing", name)
+			continue
+		}
+		// the build-ins check the number of the arguments first
+		args := make([]Expr, slices.Max(arities)+1)
+		for i := range args {
+			args[i] = Int(0)
+		}
+		if _, err := fun.(buildIn)(args, env, pid); !cmp.Equal(errors.Cause(err), errors.WrongNumberArgs{}) {
+			t.Errorf("%s called with %d arguments returned %v", name, len(args), err)
+		}
+	}
+}
+
 func TestEvalTerms(t *testing.T) {
 	t.Parallel()
 
@@ -96,202 +133,590 @@ func TestMatch(t *testing.T) {
 func TestParseEval(t *testing.T) {
 	t.Parallel()
 
-	testCases := []struct {
-		input    string
-		expected Expr
-	}{
-		{"1.", Int(1)},
-		{"true.", Bool(true)},
-		{"foo.", Atom("foo")},
-		{"{}.", Tuple{}},
-		{"[].", List{}},
-		{"-6.", Int(-6)},
-		{"+17.", Int(17)},
-		{"{1,-2, not true}.", Tuple{[]Expr{Int(1), Int(-2), Bool(false)}}},
-		{"2+3.", Int(5)},
-		{"2-3.", Int(-1)},
-		{"4/2.", Int(2)},
-		{"1==1.", Bool(true)},
-		{"1==2.", Bool(false)},
-		{"[1,{2,4-1}] == [1,{1+1,3}].", Bool(true)},
-		{"[1,2,3] != [1,2,3,4].", Bool(true)},
-		{"[1,{2,3}] != [1,{2,3}].", Bool(false)},
-		{"foo == bar.", Bool(false)},
-		{"foo == 1.", Bool(false)},
-		{"[] == [].", Bool(true)},
-		{"[2/2,2,3] == [1,1+1,6/2].", Bool(true)},
-		{"{} == {}.", Bool(true)},
-		{"1 < 1+1.", Bool(true)},
-		{"1 > 1.", Bool(false)},
-		{"2+1 <= 6/2.", Bool(true)},
-		{"6/3 >= 4/2/1.", Bool(true)},
-		{"16 rem 5.", Int(1)},
-		{"(20 + 3) rem (12 / 2).", Int(5)},
-		{"true and true.", Bool(true)},
-		{"true and false.", Bool(false)},
-		{"false and true.", Bool(false)},
-		{"false and false.", Bool(false)},
-		{"true or true.", Bool(true)},
-		{"true or false.", Bool(true)},
-		{"false or true.", Bool(true)},
-		{"false or false.", Bool(false)},
-		{"1==0 or 1+1==2.", Bool(true)},
-		{"1==1 and 1==2.", Bool(false)},
-		{"_ = _.", Bool(true)},
-		{"X = 1.", Bool(true)},
-		{"foo = X.", Bool(true)},
-		{"X = _.", Bool(true)},
-		{"1 = 1.", Bool(true)},
-		{"2 = (((1+1))).", Bool(true)},
-		{"2+2 = 4.", Bool(true)},
-		{"4 = 2+2.", Bool(true)},
-		{"if true -> 1+2 end = 3.", Bool(true)},
-		{"6/2 = if true -> 1+2 end.", Bool(true)},
-		{"{[2+2], X, {[foo,4,_]}} = {[4], (7-3), {[foo,X,false]}}.", Bool(true)},
-		{"(4 + 2) / 3.", Int(2)},
-		{"(foo).", Atom("foo")},
-		{"{1, X, [3], _, []} = {1, 2, [Y], {4, 5}, _}.", Bool(true)},
-		{"if true -> 1 end.", Int(1)},
-		{"if false -> wrong; _ -> ok end.", Atom("ok")},
-		{"if _ -> ok; _ -> wrong end.", Atom("ok")},
-		{"if 2+2 == 4 -> ok end.", Atom("ok")},
-		{"case 1 of 1 -> ok end.", Atom("ok")},
-		{"case 5 of X when X > 0, X < 3 -> wrong; X when X > 3 -> ok end.", Atom("ok")},
-		{"case {1, 2} of {1, 3} -> wrong; {_, 2} -> ok end.", Atom("ok")},
-		{"try 1/0 recover nan end.", Atom("nan")},
-		{"try 10/2 recover nan end.", Int(5)},
-		{"(fun() -> ok end)().", Atom("ok")},
-		{"(fun(X) -> X+1 end)(1).", Int(2)},
-		{"(fun(X) -> Y=X+1, 2*X+Y end)(2).", Int(7)},
-		{"(fun (X) when X < 0 -> negative; (X) when X >= 0 -> positive end)(-5).", Atom("negative")},
-		{"(fun (X) when X < 0 -> negative; (X) when X >= 0 -> positive end)(15).", Atom("positive")},
-		{"len([]).", Int(0)},
-		{"len([1,1+2,[]]).", Int(3)},
-		{"nth([1], 0).", Int(1)},
-		{"nth([1,2,3], 2).", Int(3)},
-		{"[] ++ [].", List{}},
-		{"[1,2] ++ [3].", List{[]Expr{Int(1), Int(2), Int(3)}}},
-		{"[] ++ [1].", List{[]Expr{Int(1)}}},
-		{"[] ++ 1.", List{[]Expr{Int(1)}}},
-		{"[1] ++ 2.", List{[]Expr{Int(1), Int(2)}}},
-		{"[] ++ 1 ++ 2.", List{[]Expr{Int(1), Int(2)}}},
-		{`"" ++ "".`, String("")},
-		{`"\"Hello" ++ ", " ++ "World!\"".`, String(`"Hello, World!"`)},
-		{"last([1]).", Int(1)},
-		{"last([1,2,3]).", Int(3)},
-		{"rest([1]).", List{}},
-		{"rest([1,2,3]).", List{[]Expr{Int(1), Int(2)}}},
-		{"rev([]).", List{}},
-		{"rev([1,2,3]).", List{[]Expr{Int(3), Int(2), Int(1)}}},
-		{"is_atom(foo).", Bool(true)},
-		{"is_atom(true).", Bool(false)},
-		{"is_int(42).", Bool(true)},
-		{"is_int(foo).", Bool(false)},
-		{"is_list([]).", Bool(true)},
-		{"is_list([1,2,3]).", Bool(true)},
-		{"is_list({[]}).", Bool(false)},
-		{"is_tuple({}).", Bool(true)},
-		{"is_tuple({1,foo,2+2}).", Bool(true)},
-		{"is_tuple([{}]).", Bool(false)},
-		{`is_str("").`, Bool(true)},
-		{`is_str("yes!").`, Bool(true)},
-		{"is_str(string).", Bool(false)},
-		{`split("").`, List{}},
-		{`split("abc").`, List{[]Expr{String("a"), String("b"), String("c")}}},
-		{`str("hello").`, String("hello")},
-		{"str(42).", String("42")},
-		{`str("foo").`, String("foo")},
-		{"str(2 + 3).", String("5")},
-		{"str([1,1+1,1+2]).", String("[1,2,3]")},
-		{`str({1,[2],"3"}).`, String(`{1,[2],"3"}`)},
-		{`print(foo).`, String("foo")},
-		{`print("Hello, World!").`, String("Hello, World!")},
-		{`print({1,[],"x",true}).`, String(`{1,[],"x",true}`)},
-		{`include("../examples/hello.ge").`, String("Hello, World!")},
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			testCases := []struct {
+				input    string
+				expected Expr
+			}{
+				{"1.", Int(1)},
+				{"true.", Bool(true)},
+				{"foo.", Atom("foo")},
+				{"{}.", Tuple{}},
+				{"[].", List{}},
+				{"-6.", Int(-6)},
+				{"+17.", Int(17)},
+				{"{1,-2, not true}.", Tuple{[]Expr{Int(1), Int(-2), Bool(false)}}},
+				{"2+3.", Int(5)},
+				{"2-3.", Int(-1)},
+				{"4/2.", Int(2)},
+				{"1==1.", Bool(true)},
+				{"1==2.", Bool(false)},
+				{"[1,{2,4-1}] == [1,{1+1,3}].", Bool(true)},
+				{"[1,2,3] != [1,2,3,4].", Bool(true)},
+				{"[1,{2,3}] != [1,{2,3}].", Bool(false)},
+				{"foo == bar.", Bool(false)},
+				{"foo == 1.", Bool(false)},
+				{"[] == [].", Bool(true)},
+				{"[2/2,2,3] == [1,1+1,6/2].", Bool(true)},
+				{"{} == {}.", Bool(true)},
+				{"1 < 1+1.", Bool(true)},
+				{"1 > 1.", Bool(false)},
+				{"2+1 <= 6/2.", Bool(true)},
+				{"6/3 >= 4/2/1.", Bool(true)},
+				{"16 rem 5.", Int(1)},
+				{"(20 + 3) rem (12 / 2).", Int(5)},
+				{"7 div 2.", Int(3)},
+				{"-7 div 2.", Int(-3)},
+				{"-7 rem 2.", Int(-1)},
+				{"1 + 7 div 2 * 3.", Int(10)},
+				{"12 band 10.", Int(8)},
+				{"12 bor 10.", Int(14)},
+				{"12 bxor 10.", Int(6)},
+				{"bnot 5.", Int(-6)},
+				{"bnot -1.", Int(0)},
+				{"1 bsl 10.", Int(1024)},
+				{"1024 bsr 3.", Int(128)},
+				{"-16 bsr 2.", Int(-4)},
+				{"1 bsl -1.", Int(0)},
+				{"4 bsr -1.", Int(8)},
+				{"1 + 1 bsl 2.", Int(8)},
+				{"2 * 3 band 7.", Int(6)},
+				{"1 bor 2 == 3.", Bool(true)},
+				{"2 /= 3.", Bool(true)},
+				{"2 =< 2.", Bool(true)},
+				{"3 =< 2.", Bool(false)},
+				{"{1, [2]} =:= {1, [2]}.", Bool(true)},
+				{"1 =/= 1.", Bool(false)},
+				{"foo < bar.", Bool(false)},
+				{`"abc" < "abd".`, Bool(true)},
+				{"1 < foo.", Bool(true)},
+				{"{1, 2} > {3}.", Bool(true)},
+				{"[1, 2] > [1].", Bool(true)},
+				{`[foo] < "".`, Bool(true)},
+				{"true > false.", Bool(true)},
+				{"self() > fun() -> ok end.", Bool(true)},
+				{"X = fun() -> ok end, X == X.", Bool(true)},
+				{"fun() -> ok end == fun() -> ok end.", Bool(false)},
+				{"len < fun() -> ok end.", Bool(true)},
+				{"self() == self().", Bool(true)},
+				{"F = fun() -> ok end, G = F, F = G, F == G.", Bool(true)},
+				{"P = self(), P = self().", Bool(true)},
+				{"{F, [1]} = {len, [1]}, F([]).", Int(0)},
+				{"<<>>.", Binary{}},
+				{`<<1, 2, "ab", 3:16, -1:8, 258:8>>.`, Binary{1, 2, 'a', 'b', 0, 3, 255, 2}},
+				{"<<1:16/little, 1:32/big, -2:16/signed>>.", Binary{1, 0, 0, 0, 0, 1, 255, 254}},
+				{"X = <<1, 2>>, <<0, X/binary, X:1/binary>>.", Binary{0, 1, 2, 1}},
+				{"<<5:0>>.", Binary{}},
+				{"<<1:4, 1:4>>.", Binary{0x11}},
+				{"<<1:1, 0:2, 5:3, 3:2, 258:8>>.", Binary{0x97, 0x02}},
+				{"<<-1:3, 0:5, 1:12, 15:4>>.", Binary{0xe0, 0x00, 0x1f}},
+				{"<<1:12/little, 2:4>>.", Binary{0x01, 0x02}},
+				{"<<(<<1, 2>>)/binary, 1:4, (<<3>>)/binary, 2:4>>.", Binary{1, 2, 0x10, 0x32}},
+				{`<<"abc"/binary, "de":1/binary, 1>>.`, Binary{'a', 'b', 'c', 'd', 1}},
+				{"<<Version:4, Length:4, Flag:1, Rest:7>> = <<69, 129>>, [Version, Length, Flag, Rest].", List{[]Expr{Int(4), Int(5), Int(1), Int(1)}}},
+				{"<<A:3/signed, B:5, C:12/little, D:4>> = <<229, 1, 2>>, [A, B, C, D].", List{[]Expr{Int(-1), Int(5), Int(1), Int(2)}}},
+				{"<<_:4, X:8, _:4>> = <<18, 52>>, X.", Int(0x23)},
+				{`<<"GET "/binary, Path/binary>> = <<"GET /">>, Path.`, Binary("/")},
+				{"<<Len:16, Data:Len/binary, Rest/binary>> = <<0, 2, 7, 8, 9>>, {Len, Data, Rest}.", Tuple{[]Expr{
+					Int(2), Binary{7, 8}, Binary{9},
+				}}},
+				{"<<A, B:16/little, C:8/signed>> = <<1, 2, 3, 255>>, [A, B, C].", List{[]Expr{Int(1), Int(770), Int(-1)}}},
+				{"<<X:32>> = <<255, 255, 255, 255>>, X.", Int(4294967295)},
+				{"<<X:32/signed>> = <<255, 255, 255, 254>>, X.", Int(-2)},
+				{"<<1, X/binary>> = <<1>>, X.", Binary{}},
+				{`case <<"GET /index">> of <<"POST ", _/binary>> -> post; <<"GET ", P/binary>> -> P end.`, Binary("/index")},
+				{"case <<1, 2>> of <<_>> -> one; <<_, _, _>> -> three; _ -> other end.", Atom("other")},
+				{"(fun (<<N:8, _/binary>>) -> N end)(<<42, 0>>).", Int(42)},
+				{"<<1, 2>> == <<1, 2>>.", Bool(true)},
+				{"<<1>> < <<1, 0>>.", Bool(true)},
+				{"byte_size(<<1, 2, 3>>).", Int(3)},
+				{"binary_part(<<1, 2, 3, 4>>, 1, 2).", Binary{2, 3}},
+				{"binary_part(<<1, 2, 3, 4>>, 4, -3).", Binary{2, 3, 4}},
+				{"binary_to_list(<<1, 2>>).", List{[]Expr{Int(1), Int(2)}}},
+				{`list_to_binary([1, <<2>>, "a", [3, []]]).`, Binary{1, 2, 'a', 3}},
+				{`binary_to_str(<<"abc">>).`, String("abc")},
+				{`str_to_binary("abc").`, Binary("abc")},
+				{"is_binary(<<>>).", Bool(true)},
+				{"is_binary([]).", Bool(false)},
+				{`str(<<"hi">>).`, String(`<<"hi">>`)},
+				{`len("zażółć").`, Int(6)},
+				{`length("żółw").`, Int(4)},
+				{`length([1, 2]).`, Int(2)},
+				{`{find, join, replace, trim, upper, lower, substr}.`, Tuple{[]Expr{Atom("find"), Atom("join"), Atom("replace"), Atom("trim"), Atom("upper"), Atom("lower"), Atom("substr")}}},
+				{`string_split("a,b,,c", ",").`, List{[]Expr{String("a"), String("b"), String(""), String("c")}}},
+				{`string_split("", ",").`, List{[]Expr{String("")}}},
+				{`string_join(["a", "b", "c"], ", ").`, String("a, b, c")},
+				{`string_join([], ", ").`, String("")},
+				{`L = ["a", "b", "c"], L2 = rest(L), {string_join(L2, "-"), L}.`, Tuple{[]Expr{String("a-b"), List{[]Expr{String("a"), String("b"), String("c")}}}}},
+				{`string_substr("zażółć", 2, 3).`, String("żół")},
+				{`string_substr("zażółć", 4).`, String("łć")},
+				{`string_substr("abc", 3, 0).`, String("")},
+				{`string_find("zażółć gęślą", "ć").`, Int(5)},
+				{`string_find("abc", "x").`, Atom("nomatch")},
+				{`string_replace("a-b-c", "-", "+").`, String("a+b+c")},
+				{`string_upper("żółw").`, String("ŻÓŁW")},
+				{`string_lower("ŻÓŁW").`, String("żółw")},
+				{`string_trim(" \t hi \n").`, String("hi")},
+				{`starts_with("hello", "he").`, Bool(true)},
+				{`starts_with("hello", "lo").`, Bool(false)},
+				{`ends_with("hello", "lo").`, Bool(true)},
+				{`to_int(" -42").`, Int(-42)},
+				{`to_atom("foo").`, Atom("foo")},
+				{`chars_to_string(["ż", "ó", "ł", 119]).`, String("żółw")},
+				{`chars_to_string(split("zażółć")).`, String("zażółć")},
+				{`format("", []).`, String("")},
+				{`format("~p, ~w, ~s~n", [{a, "x"}, "y", "z"]).`, String("{a,\"x\"}, \"y\", z\n")},
+				{`format("~s ~s ~s ~s", [foo, <<"bar">>, ["b", "a", 122], "ż"]).`, String("foo bar baz ż")},
+				{`format("~b ~.2b ~.16b ~.16B ~c", [-10, 5, 255, 255, 322]).`, String("-10 101 ff FF ł")},
+				{`format("~.16b ~.16B ~.36B", [-255, -255, 35]).`, String("-ff -FF Z")},
+				{`format("[~5w] [~-5w] [~5..0b] [~.2s] [~6.2s] ~~", [1, 2, 3, "abcdef", "xyz"]).`, String("[    1] [2    ] [00003] [ab] [    xy] ~")},
+				{`format("~10p", [{abc, [1, 2, 3], d}]).`, String("{abc,\n [1,2,3],\n d}")},
+				{`printf("~w", [ok]).`, Atom("ok")},
+				{"sort([]).", List{}},
+				{`sort([3, "a", {1}, [x], foo, 1, {0, 1}, []]).`, List{[]Expr{
+					Int(1), Int(3), Atom("foo"), Tuple{[]Expr{Int(1)}}, Tuple{[]Expr{Int(0), Int(1)}},
+					List{}, List{[]Expr{Atom("x")}}, String("a"),
+				}}},
+				{"sort(fun(A, B) -> A >= B end, [1, 3, 2]).", List{[]Expr{Int(3), Int(2), Int(1)}}},
+				{"sort(fun({A, _}, {B, _}) -> A =< B end, [{2, a}, {1, b}, {2, c}, {1, d}]).", List{[]Expr{
+					Tuple{[]Expr{Int(1), Atom("b")}}, Tuple{[]Expr{Int(1), Atom("d")}},
+					Tuple{[]Expr{Int(2), Atom("a")}}, Tuple{[]Expr{Int(2), Atom("c")}},
+				}}},
+				{"usort([3, 1, 2, 1, 3]).", List{[]Expr{Int(1), Int(2), Int(3)}}},
+				{"usort([]).", List{}},
+				{"min([4, 2, foo]).", Int(2)},
+				{`max([4, "s", foo]).`, String("s")},
+				// integers are fixed-size and wrap around on overflow
+				{"9223372036854775807 + 1.", Int(math.MinInt64)},
+				{"-9223372036854775807 - 1 == -9223372036854775807 - 2 + 1.", Bool(true)},
+				{"(-9223372036854775807 - 1) div -1.", Int(math.MinInt64)},
+				{"4611686018427387904 * 2.", Int(math.MinInt64)},
+				{"1 bsl 63.", Int(math.MinInt64)},
+				{"1 bsl 64.", Int(0)},
+				{"-1 bsr 100.", Int(-1)},
+				{"7 bsr (-9223372036854775807 - 1).", Int(0)},
+				{"true and true.", Bool(true)},
+				{"true and false.", Bool(false)},
+				{"false and true.", Bool(false)},
+				{"false and false.", Bool(false)},
+				{"true or true.", Bool(true)},
+				{"true or false.", Bool(true)},
+				{"false or true.", Bool(true)},
+				{"false or false.", Bool(false)},
+				{"1==0 or 1+1==2.", Bool(true)},
+				{"1==1 and 1==2.", Bool(false)},
+				{"true xor true.", Bool(false)},
+				{"true xor false.", Bool(true)},
+				{"false xor false.", Bool(false)},
+				{"true andalso false.", Bool(false)},
+				{"false andalso 1/0.", Bool(false)},
+				{"true orelse 1/0.", Bool(true)},
+				{"false orelse 1 == 1.", Bool(true)},
+				{"true andalso 42.", Int(42)},
+				{"X = 5, is_list(X) andalso len(X) > 0.", Bool(false)},
+				{"X = 5, case X of Y when len(Y) > 0 -> list; _ -> other end.", Atom("other")},
+				{"X = 5, case X of Y when Y -> wrong; _ -> other end.", Atom("other")},
+				{"(fun (X) when 1/X > 0 -> positive; (_) -> other end)(0).", Atom("other")},
+				{"try error(foo) catch R when R + 1 > 0 -> wrong; R -> R end.", Atom("foo")},
+				{"_ = _.", Bool(true)},
+				{"X = 1.", Bool(true)},
+				{"foo = X.", Bool(true)},
+				{"X = _.", Bool(true)},
+				{"1 = 1.", Bool(true)},
+				{"2 = (((1+1))).", Bool(true)},
+				{"2+2 = 4.", Bool(true)},
+				{"4 = 2+2.", Bool(true)},
+				{"if true -> 1+2 end = 3.", Bool(true)},
+				{"6/2 = if true -> 1+2 end.", Bool(true)},
+				{"{[2+2], X, {[foo,4,_]}} = {[4], (7-3), {[foo,X,false]}}.", Bool(true)},
+				{"(4 + 2) / 3.", Int(2)},
+				{"(foo).", Atom("foo")},
+				{"{1, X, [3], _, []} = {1, 2, [Y], {4, 5}, _}.", Bool(true)},
+				{"if true -> 1 end.", Int(1)},
+				{"if false -> wrong; _ -> ok end.", Atom("ok")},
+				{"if _ -> ok; _ -> wrong end.", Atom("ok")},
+				{"if 2+2 == 4 -> ok end.", Atom("ok")},
+				{"case 1 of 1 -> ok end.", Atom("ok")},
+				{"case 5 of X when X > 0, X < 3 -> wrong; X when X > 3 -> ok end.", Atom("ok")},
+				{"case {1, 2} of {1, 3} -> wrong; {_, 2} -> ok end.", Atom("ok")},
+				{"try 1/0 recover nan end.", Atom("nan")},
+				{"try 10/2 recover nan end.", Int(5)},
+				{"try 10/2 catch _ -> nan end.", Int(5)},
+				{"try 1/0 catch badarith -> nan end.", Atom("nan")},
+				{"try 1/0 catch error:Reason -> Reason end.", Atom("badarith")},
+				{"try exit(done) catch error:_ -> failed; exit:Reason -> {exited, Reason} end.", Tuple{[]Expr{Atom("exited"), Atom("done")}}},
+				{"try error({my, reason}) catch {my, X} -> X end.", Atom("reason")},
+				{"try 1 = 2 catch {badmatch, X} -> X end.", Int(2)},
+				{"try {a, {b, c}} = {a, {b, d}} catch {badmatch, X} -> X end.", Tuple{[]Expr{Atom("a"), Tuple{[]Expr{Atom("b"), Atom("d")}}}}},
+				{"try [1, 2] = [1, 3] catch {badmatch, X} -> X end.", List{[]Expr{Int(1), Int(3)}}},
+				{"try foo + 1 catch C:R -> {C, R} end.", Tuple{[]Expr{Atom("error"), Atom("badarith")}}},
+				{"try case 3 of 1 -> one end catch {case_clause, X} when X > 2 -> X end.", Int(3)},
+				{"try if false -> ok end catch E -> E end.", Atom("if_clause")},
+				{"try not 5 catch E -> E end.", Tuple{[]Expr{Atom("badarg"), Int(5)}}},
+				{"try (fun(1) -> ok end)(2) catch E -> E end.", Atom("function_clause")},
+				{"try len(1, 2) catch {badarity, {len, Args}} -> Args end.", List{[]Expr{Int(1), Int(2)}}},
+				{"try exit(normal) catch error:normal -> wrong; exit:normal -> ok end.", Atom("ok")},
+				{"try X = 1, X(2) catch {badfun, Y} -> Y end.", Int(1)},
+				{"try 1/0 catch error:badarith:Stack -> Stack end.", List{}},
+				{"fun f(X) -> 1/X end, try f(0) catch _:_:Stack -> len(Stack) end.", Int(1)},
+				{`fun id(X) -> X end, A = to_atom("len"), id({A}).`, Tuple{[]Expr{Atom("len")}}},
+				{`fun id(X) -> X end, A = to_atom("len"), id(A) == A.`, Bool(true)},
+				{`fun id(X) -> X end, try error(to_atom("len")) catch C:E -> id({C, E}) end.`, Tuple{[]Expr{Atom("error"), Atom("len")}}},
+				{`fun id(X) -> X end, case {to_atom("len")} of {A} -> id(A) end.`, Atom("len")},
+				{"fun f(min) -> low; (max) -> high; (_) -> other end, f(max).", Atom("high")},
+				{"case {max} of {min} -> low; {max} -> high end.", Atom("high")},
+				{"min == max.", Bool(false)},
+				{"is_atom('max').", Bool(true)},
+				{"{'max', 1}.", Tuple{[]Expr{Atom("max"), Int(1)}}},
+				{"'max' == max.", Bool(false)},
+				{"'max'([1, 3, 2]).", Int(3)},
+				{"fun f('max') -> atom; (max) -> function end, {f('max'), f(max)}.", Tuple{[]Expr{Atom("atom"), Atom("function")}}},
+				{"{'len', X} = {to_atom(\"len\"), 1}, X.", Int(1)},
+				{"case 'len' of len -> yes end.", Atom("yes")},
+				{"try error(boom) catch 'error':E -> E end.", Atom("boom")},
+				{"(fun() -> ok end)().", Atom("ok")},
+				{"(fun(X) -> X+1 end)(1).", Int(2)},
+				{"(fun(X) -> Y=X+1, 2*X+Y end)(2).", Int(7)},
+				{"(fun (X) when X < 0 -> negative; (X) when X >= 0 -> positive end)(-5).", Atom("negative")},
+				{"(fun (X) when X < 0 -> negative; (X) when X >= 0 -> positive end)(15).", Atom("positive")},
+				{"len([]).", Int(0)},
+				{"len([1,1+2,[]]).", Int(3)},
+				{"nth([1], 0).", Int(1)},
+				{"nth([1,2,3], 2).", Int(3)},
+				{"[] ++ [].", List{}},
+				{"[1,2] ++ [3].", List{[]Expr{Int(1), Int(2), Int(3)}}},
+				{"[] ++ [1].", List{[]Expr{Int(1)}}},
+				{"[] ++ 1.", List{[]Expr{Int(1)}}},
+				{"[1] ++ 2.", List{[]Expr{Int(1), Int(2)}}},
+				{"[] ++ 1 ++ 2.", List{[]Expr{Int(1), Int(2)}}},
+				{`"" ++ "".`, String("")},
+				{`"\"Hello" ++ ", " ++ "World!\"".`, String(`"Hello, World!"`)},
+				{"last([1]).", Int(1)},
+				{"last([1,2,3]).", Int(3)},
+				{"rest([1]).", List{}},
+				{"rest([1,2,3]).", List{[]Expr{Int(1), Int(2)}}},
+				{"rev([]).", List{}},
+				{"rev([1,2,3]).", List{[]Expr{Int(3), Int(2), Int(1)}}},
+				{"is_atom(foo).", Bool(true)},
+				{"is_atom(true).", Bool(false)},
+				{"is_int(42).", Bool(true)},
+				{"is_int(foo).", Bool(false)},
+				{"is_list([]).", Bool(true)},
+				{"is_list([1,2,3]).", Bool(true)},
+				{"is_list({[]}).", Bool(false)},
+				{"is_tuple({}).", Bool(true)},
+				{"is_tuple({1,foo,2+2}).", Bool(true)},
+				{"is_tuple([{}]).", Bool(false)},
+				{`is_str("").`, Bool(true)},
+				{`is_str("yes!").`, Bool(true)},
+				{"is_str(string).", Bool(false)},
+				{`split("").`, List{}},
+				{`split("abc").`, List{[]Expr{String("a"), String("b"), String("c")}}},
+				{`str("hello").`, String("hello")},
+				{"str(42).", String("42")},
+				{`str("foo").`, String("foo")},
+				{"str(2 + 3).", String("5")},
+				{"str([1,1+1,1+2]).", String("[1,2,3]")},
+				{`str({1,[2],"3"}).`, String(`{1,[2],"3"}`)},
+				{"str('EXIT').", String("EXIT")},
+				{`str({'EXIT','it\'s',"it's",'fun',ok}).`, String(`{'EXIT','it\'s',"it's",'fun',ok}`)},
+				{"[$h,$i] == [104,105].", Bool(true)},
+				{"-record(user, {name, age = 0}), #user{}.", Tuple{[]Expr{Atom("user"), Atom("undefined"), Int(0)}}},
+				{`-record(user, {name, age = 0}), #user{age = 1 + 2, name = "Ann"}.`, Tuple{[]Expr{Atom("user"), String("Ann"), Int(3)}}},
+				{`-record(user, {name, age = 0}), U = #user{name = "Ann"}, U#user.name.`, String("Ann")},
+				{`-record(user, {name, age = 0}), U = #user{name = "Ann"}, U#user{age = 7}.`, Tuple{[]Expr{Atom("user"), String("Ann"), Int(7)}}},
+				{`-record(user, {name, age = 0}), U = #user{}, U#user{age = 7}#user.age.`, Int(7)},
+				{"-record(user, {name, age = 0}), #user{age = A} = #user{age = 5}, A.", Int(5)},
+				{"-record(user, {name, age = 0}), U = #user{age = 5}, #user{age = A} = U, A.", Int(5)},
+				{"-record(user, {name, age = 0}), {user, undefined, 0} == #user{}.", Bool(true)},
+				{`-record(user, {name, age = 0}), case #user{name = "Bob"} of #user{name = "Ann"} -> ann; #user{name = N} -> N end.`, String("Bob")},
+				{"-record(a, {x}), -record(b, {x}), case #b{x = 1} of #a{} -> a; #b{x = X} -> {b, X} end.", Tuple{[]Expr{Atom("b"), Int(1)}}},
+				{"-record(user, {name, age = 0}), F = fun(U) -> U#user.age end, F(#user{age = 3}).", Int(3)},
+				{"X = {1, 2}, {A, _} = X, A.", Int(1)},
+				{"begin 1, 2 end.", Int(2)},
+				{"begin X = 2, X * 3 end + X.", Int(8)},
+				{"F = fun Fact(0) -> 1; (N) -> N * Fact(N - 1) end, F(5).", Int(120)},
+				{"(fun Len([]) -> 0; (L) -> 1 + Len(rest(L)) end)([a, b, c]).", Int(3)},
+				{"F = fun Loop(0) -> ok; (N) -> Loop(N - 1) end, {F(2), F(3)}.", Tuple{[]Expr{Atom("ok"), Atom("ok")}}},
+				{"Fact = 1, F = fun Fact(0) -> 0; (_) -> Fact(0) end, {Fact, F(7)}.", Tuple{[]Expr{Int(1), Int(0)}}},
+				{`fun outer(N) -> H = fun Down(0) -> done; (X) -> Down(X - 1) end, H(N) end, {outer(2), outer(3)}.`, Tuple{[]Expr{Atom("done"), Atom("done")}}},
+				{"'ok' == ok.", Bool(true)},
+				{`print(foo).`, String("foo")},
+				{`print("Hello, World!").`, String("Hello, World!")},
+				{`print({1,[],"x",true}).`, String(`{1,[],"x",true}`)},
+				{`include("../examples/hello.ge").`, String("Hello, World!")},
+				{"assert_match({ok, X}, {ok, 1}), X.", Int(1)},
+				{"X = 2, assert_match([1, X], [1, 2]).", Atom("ok")},
+				{"assert_equal([1, 2], [1] ++ [2]).", Atom("ok")},
+				{"assert_error(fun() -> 1 / 0 end).", Atom("ok")},
+				{"assert_error(badarith, fun() -> 1 / 0 end).", Atom("ok")},
+				{"try assert_equal(1, 2) catch error:{assertion_failed, Info} -> Info end.", List{[]Expr{
+					Tuple{[]Expr{Atom("expected"), Int(1)}},
+					Tuple{[]Expr{Atom("actual"), Int(2)}},
+				}}},
+			}
+
+			for _, tt := range testCases {
+				func() {
+					env := NewEnv()
+					pid := pids.NewPid()
+					defer pid.Close()
+
+					result, err := engine.eval(tt.input, env, pid)
+					if err != nil {
+						t.Errorf("evaluating '%s' resulted in an error: %s", tt.input, err)
+					} else if !cmp.Equal(result, tt.expected) {
+						t.Errorf("evaluating '%s' returned %v while we expected %v", tt.input, result, tt.expected)
+					}
+				}()
+			}
+		})
 	}
+}
 
-	for _, tt := range testCases {
-		func() {
-			env := NewEnv()
-			pid := pids.NewPid()
-			defer pid.Close()
+func TestParseEvalErrors(t *testing.T) {
+	t.Parallel()
 
-			result, err := ParseEval(tt.input, env, pid)
-			if err != nil {
-				t.Errorf("evaluating '%s' resulted in an error: %s", tt.input, err)
-			} else if !cmp.Equal(result, tt.expected) {
-				t.Errorf("evaluating '%s' returned %v while we expected %v", tt.input, result, tt.expected)
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			testCases := []struct {
+				input string
+				err   error
+			}{
+				{"_.", errors.Unbound{"_"}},
+				{"1 + X.", errors.Unbound{"X"}},
+				{"1 == _.", errors.Unbound{"_"}},
+				{"-foo.", errors.NotNumber{Atom("foo")}},
+				{"not 1.", errors.NotBoolean{Int(1)}},
+				{"2 + x.", errors.NotNumber{Atom("x")}},
+				{"1 and true.", errors.NotBoolean{Int(1)}},
+				{"false or 2.", errors.NotBoolean{Int(2)}},
+				{"1 andalso true.", errors.NotBoolean{Int(1)}},
+				{"false orelse 1/0.", errors.DivisionByZero{}},
+				{"1 xor true.", errors.NotBoolean{Int(1)}},
+				{"1 = 2.", errors.NoMatch{Int(1), Int(2)}},
+				{"{a, {b, c}} = {a, {b, d}}.", errors.NoMatch{
+					Tuple{[]Expr{Atom("a"), Tuple{[]Expr{Atom("b"), Atom("c")}}}},
+					Tuple{[]Expr{Atom("a"), Tuple{[]Expr{Atom("b"), Atom("d")}}}}}},
+				{"[1, 2] = [1, 3].", errors.NoMatch{List{[]Expr{Int(1), Int(2)}}, List{[]Expr{Int(1), Int(3)}}}},
+				{"X = {b, d}, {a, {b, c}} = {a, X}.", errors.NoMatch{
+					Tuple{[]Expr{Atom("a"), Tuple{[]Expr{Atom("b"), Atom("c")}}}},
+					Tuple{[]Expr{Atom("a"), Tuple{[]Expr{Atom("b"), Atom("d")}}}}}},
+				{"fun g(X) -> {a, {b, c}} = {a, X} end, g({b, d}).", errors.NoMatch{
+					Tuple{[]Expr{Atom("a"), Tuple{[]Expr{Atom("b"), Atom("c")}}}},
+					Tuple{[]Expr{Atom("a"), Tuple{[]Expr{Atom("b"), Atom("d")}}}}}},
+				{"fun f() -> {a, 1} end, {a, 2} = f().", errors.NoMatch{Tuple{[]Expr{Atom("a"), Int(2)}}, Tuple{[]Expr{Atom("a"), Int(1)}}}},
+				{"1 / (1 - 1).", errors.DivisionByZero{}},
+				{"17 rem (5 + 5 - 20 / 2).", errors.DivisionByZero{}},
+				{"2 / foo.", errors.NotNumber{Atom("foo")}},
+				{"foo rem 0.", errors.NotNumber{Atom("foo")}},
+				{"1 div 0.", errors.DivisionByZero{}},
+				{"foo band 1.", errors.NotNumber{Atom("foo")}},
+				{"<<1:4>>.", errors.UnalignedBinary{4}},
+				{"<<1:4, 2:8, 3:5>>.", errors.UnalignedBinary{17}},
+				{`<<"abc":8>>.`, errors.InvalidSegment{Segment{String("abc"), Int(8), nil}}},
+				{`<<"abc":4/binary>>.`, errors.InvalidSegment{Segment{String("abc"), Int(4), []string{"binary"}}}},
+				{"<<_:4, X/binary>> = <<1>>.", errors.NoMatch{BitString{[]Segment{{Dummy{}, Int(4), nil}, {Variable("X"), nil, []string{"binary"}}}, Pos{Line: 1, Col: 1}}, Binary{1}}},
+				{"<<foo>>.", errors.NotNumber{Atom("foo")}},
+				{"<<1/binary>>.", errors.NotBinary{Int(1)}},
+				{"<<(<<1>>):2/binary>>.", errors.InvalidSegment{Segment{Bracket{BitString{[]Segment{{Int(1), nil, nil}}, Pos{Line: 1, Col: 4}}}, Int(2), []string{"binary"}}}},
+				{"<<X:16>> = <<1>>.", errors.NoMatch{BitString{[]Segment{{Variable("X"), Int(16), nil}}, Pos{Line: 1, Col: 1}}, Binary{1}}},
+				{"<<X/binary, 1>> = <<1>>.", errors.InvalidSegment{Segment{Variable("X"), nil, []string{"binary"}}}},
+				{"byte_size([]).", errors.NotBinary{List{}}},
+				{"list_to_binary([256]).", errors.NotBinary{Int(256)}},
+				{`string_split(foo, ",").`, errors.NotString{Atom("foo")}},
+				{`string_split("a", 1).`, errors.NotString{Int(1)}},
+				{`string_join(["a", 1], ",").`, errors.NotString{Int(1)}},
+				{`string_substr(foo, 1).`, errors.NotString{Atom("foo")}},
+				{`string_substr("abc", 2, 5).`, errors.New("invalid index")},
+				{`string_find(1, "a").`, errors.NotString{Int(1)}},
+				{`string_replace("a", "b", c).`, errors.NotString{Atom("c")}},
+				{"string_upper(foo).", errors.NotString{Atom("foo")}},
+				{"string_lower(1).", errors.NotString{Int(1)}},
+				{"string_trim([]).", errors.NotString{List{}}},
+				{`starts_with("a", foo).`, errors.NotString{Atom("foo")}},
+				{`ends_with(foo, "a").`, errors.NotString{Atom("foo")}},
+				{`to_int("12a").`, errors.NotNumber{String("12a")}},
+				{"to_int(12).", errors.NotString{Int(12)}},
+				{"to_atom(foo).", errors.NotString{Atom("foo")}},
+				{"chars_to_string([foo]).", errors.NotString{Atom("foo")}},
+				{`format("~w ~w", [1]).`, errors.BadFormat{String("~w ~w"), "not enough arguments"}},
+				{`format("~w", [1, 2]).`, errors.BadFormat{String("~w"), "too many arguments"}},
+				{`format("~", []).`, errors.BadFormat{String("~"), "invalid directive"}},
+				{`format("~z", [1]).`, errors.BadFormat{String("~z"), "unknown control character"}},
+				{`format("~s", [1]).`, errors.NotString{Int(1)}},
+				{`format("~b", [a]).`, errors.NotNumber{Atom("a")}},
+				{`format(foo, []).`, errors.NotString{Atom("foo")}},
+				{`printf("~w", foo).`, errors.NotList{Atom("foo")}},
+				{"sort(foo).", errors.NotList{Atom("foo")}},
+				{"sort(fun(_, _) -> 1 end, [1, 2]).", errors.NotBoolean{Int(1)}},
+				{"sort(1, [1, 2]).", errors.NotFunction{Int(1)}},
+				{"min([]).", errors.EmptyList{}},
+				{"max(1).", errors.NotList{Int(1)}},
+				{"bnot true.", errors.NotNumber{Bool(true)}},
+				{"-(1/0).", errors.DivisionByZero{}},
+				{"(1/0) + 5.", errors.DivisionByZero{}},
+				{"print(str(1/0)).", errors.DivisionByZero{}},
+				{"X = X.", errors.Unbound{"X"}},
+				{"if false -> false end.", errors.NoTrueBranch{}},
+				{"if foo -> bar end.", errors.NotBoolean{Atom("foo")}},
+				{"fun f(X) -> X end, f().", errors.WrongNumberArgs{Atom("f"), nil}},
+				{"fun f() -> nothing end, f(1,2,3).", errors.WrongNumberArgs{Atom("f"), []Expr{Int(1), Int(2), Int(3)}}},
+				{"(fun(1) -> one end)(2).", errors.NoFunBranch{}},
+				{"len([1], [2,3]).", errors.WrongNumberArgs{Atom("len"), []Expr{List{[]Expr{Int(1)}}, List{[]Expr{Int(2), Int(3)}}}}},
+				{"rev(foo).", errors.NotList{Atom("foo")}},
+				{"last(foo).", errors.NotList{Atom("foo")}},
+				{"last([]).", errors.EmptyList{}},
+				{"rest([]).", errors.EmptyList{}},
+				{"rest(foo).", errors.NotList{Atom("foo")}},
+				{`"hi" ++ 42.`, errors.NotString{Int(42)}},
+				{"split(foo).", errors.NotString{Atom("foo")}},
+				{"foo ! {1,2}.", errors.Custom{"foo is not a pid"}},
+				{"case 5 of X when is_atom(X) -> atom; X when is_str(X) -> string end.", errors.NoCaseBranch{Int(5)}},
+				{"spawn(foo).", errors.NotFunction{Atom("foo")}},
+				{"receive after xxx -> wrong end.", errors.NotNumber{Atom("xxx")}},
+				{"fun f()->1 end, fun f()->2 end.", errors.Custom{"f already exists"}},
+				{"(true)(5, 7).", errors.NotFunction{Bool(true)}},
+				//                 +--------(4=X)----------+
+				//        +--------|---(X=7)------+        |
+				//        v        v              v        v
+				{"{[2+2], X, {[foo,4,_]}} = {[4], 7, {[foo,X,false]}}.", errors.NoMatch{Variable("X"), Int(4)}},
+				{"nth(wrong, 1).", errors.NotList{Atom("wrong")}},
+				{"nth([], wrong).", errors.NotNumber{Atom("wrong")}},
+				{"nth([], -1).", errors.Custom{"invalid index"}},
+				{"nth([], 0).", errors.Custom{"invalid index"}},
+				{"nth([], 1).", errors.Custom{"invalid index"}},
+				{"nth([1,2,3], -1).", errors.Custom{"invalid index"}},
+				{"nth([1,2,3], 3).", errors.Custom{"invalid index"}},
+				{"error(wrong).", errors.Error{Atom("wrong")}},
+				{"#user{}.", errors.UndefinedRecord{"user"}},
+				{"fun Loop(X) -> X end, Loop(1).", errors.Unbound{"Loop"}},
+				{"fun f() -> Reslt end.", errors.Unbound{"Reslt"}},
+				{"fun f(X) -> Y = X, Y + Z end.", errors.Unbound{"Z"}},
+				{"fun g() -> F = fun(N) -> F(N - 1) end end.", errors.Unbound{"F"}},
+				{"fun f() -> G = fun() -> Y end, Y = 1, G() end.", errors.Unbound{"Y"}},
+				{"-record(user, {name}), #user{age = 1}.", errors.UndefinedField{"user", "age"}},
+				{"-record(user, {name}), X = {user, 1}, X#user.age.", errors.UndefinedField{"user", "age"}},
+				{"-record(user, {name}), X = {other, 1}, X#user.name.", errors.BadRecord{"user", Tuple{[]Expr{Atom("other"), Int(1)}}}},
+				{"-record(user, {name}), X = 42, X#user{name = 1}.", errors.BadRecord{"user", Int(42)}},
+				{"-record(user, {name}), #user{name = 1, name = 2}.", errors.Custom{"field name assigned twice"}},
+				{"-record(user, {name}), -record(user, {age}).", errors.Custom{"record user already exists"}},
+				{"-record(user, {name}), #user{name = X} = {user}.", errors.NoMatch{Record{nil, "user", []RecordField{{"name", Variable("X")}}, Pos{Line: 1, Col: 24}}, Tuple{[]Expr{Atom("user")}}}},
+				{`error("hello!").`, errors.Error{String("hello!")}},
+				{"try error(inner) catch exit:Reason -> Reason end.", errors.Error{Atom("inner")}},
+				{"try 1/0 catch error:badarith -> error(outer) end.", errors.Error{Atom("outer")}},
+				{"assert_equal(1, 2).", errors.NotEqual{Int(1), Int(2)}},
+				{"assert_match({ok, _}, {fail, 1}).", errors.NotMatched{"{ok,_}", Tuple{[]Expr{Atom("fail"), Int(1)}}}},
+				{"X = 2, assert_match([1, X], [1, 3]).", errors.NotMatched{"[1,X]", List{[]Expr{Int(1), Int(3)}}}},
+				{"assert_error(fun() -> ok end).", errors.NoError{nil, Atom("ok")}},
+				{"assert_error(badarith, fun() -> error(other) end).", errors.WrongError{Atom("badarith"), Atom("other")}},
+				{"assert_error(badarith, 1 / 0).", errors.DivisionByZero{}},
+				{"assert_error(foo).", errors.NotFunction{Atom("foo")}},
 			}
-		}()
+
+			for _, tt := range testCases {
+				func() {
+					env := NewEnv()
+					pid := pids.NewPid()
+					defer pid.Close()
+
+					_, err := engine.eval(tt.input, env, pid)
+					if !cmp.Equal(errors.Cause(err), tt.err) {
+						t.Errorf("evaluating '%s' should throw error: %s, but it thrown: %s", tt.input, tt.err, err)
+					}
+				}()
+			}
+		})
 	}
 }
 
-func TestParseEvalErrors(t *testing.T) {
+func TestErrorPositions(t *testing.T) {
 	t.Parallel()
 
-	testCases := []struct {
-		input string
-		err   error
-	}{
-		{"_.", errors.Unbound{"_"}},
-		{"1 + X.", errors.Unbound{"X"}},
-		{"1 == _.", errors.Unbound{"_"}},
-		{"-foo.", errors.NotNumber{Atom("foo")}},
-		{"not 1.", errors.NotBoolean{Int(1)}},
-		{"2 + x.", errors.NotNumber{Atom("x")}},
-		{"1 and true.", errors.NotBoolean{Int(1)}},
-		{"false or 2.", errors.NotBoolean{Int(2)}},
-		{"1 = 2.", errors.NoMatch{Int(1), Int(2)}},
-		{"1 / (1 - 1).", errors.DivisionByZero{}},
-		{"17 rem (5 + 5 - 20 / 2).", errors.DivisionByZero{}},
-		{"-(1/0).", errors.DivisionByZero{}},
-		{"(1/0) + 5.", errors.DivisionByZero{}},
-		{"print(str(1/0)).", errors.DivisionByZero{}},
-		{"X = X.", errors.Unbound{"X"}},
-		{"if false -> false end.", errors.NoTrueBranch{}},
-		{"if foo -> bar end.", errors.NotBoolean{Atom("foo")}},
-		{"(fun(X) -> X end)().", errors.NoFunBranch{}},
-		{"(fun() -> nothing end)(1,2,3).", errors.NoFunBranch{}},
-		{"len([1], [2,3]).", errors.WrongNumberArgs{}},
-		{"rev(foo).", errors.NotList{Atom("foo")}},
-		{"last(foo).", errors.NotList{Atom("foo")}},
-		{"last([]).", errors.EmptyList{}},
-		{"rest([]).", errors.EmptyList{}},
-		{"rest(foo).", errors.NotList{Atom("foo")}},
-		{`"hi" ++ 42.`, errors.NotString{Int(42)}},
-		{"split(foo).", errors.NotString{Atom("foo")}},
-		{"foo ! {1,2}.", errors.Custom{"foo is not a pid"}},
-		{"case 5 of X when is_atom(X) -> atom; X when is_str(X) -> string end.", errors.NoTrueBranch{}},
-		{"spawn(foo).", errors.NotFunction{Atom("foo")}},
-		{"receive after xxx -> wrong end.", errors.NotNumber{Atom("xxx")}},
-		{"fun f()->1 end, fun f()->2 end.", errors.Custom{"f already exists"}},
-		{"(true)(5, 7).", errors.NotFunction{Bracket{Bool(true)}}},
-		//                 +--------(4=X)----------+
-		//        +--------|---(X=7)------+        |
-		//        v        v              v        v
-		{"{[2+2], X, {[foo,4,_]}} = {[4], 7, {[foo,X,false]}}.", errors.NoMatch{Variable("X"), Int(4)}},
-		{"nth(wrong, 1).", errors.NotList{Atom("wrong")}},
-		{"nth([], wrong).", errors.NotNumber{Atom("wrong")}},
-		{"nth([], -1).", errors.Custom{"invalid index"}},
-		{"nth([], 0).", errors.Custom{"invalid index"}},
-		{"nth([], 1).", errors.Custom{"invalid index"}},
-		{"nth([1,2,3], -1).", errors.Custom{"invalid index"}},
-		{"nth([1,2,3], 3).", errors.Custom{"invalid index"}},
-		{"error(wrong).", errors.NotString{Atom("wrong")}},
-		{`error("hello!").`, errors.Custom{"hello!"}},
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			testCases := []struct {
+				input string
+				pos   Pos
+			}{
+				{"1 + X.", Pos{Line: 1, Col: 5}},
+				{"X = 1,\n  2 = X.", Pos{Line: 2, Col: 5}},
+				{"foo(1, 2).", Pos{Line: 1, Col: 1}},
+				{"[1, 2, -foo].", Pos{Line: 1, Col: 8}},
+				{"fun f(X) -> \n  X / 0 end,\nf(1).", Pos{Line: 2, Col: 5}},
+				{"case 1 of\n  2 -> ok\nend.", Pos{Line: 1, Col: 1}},
+				{"try 1/0 catch exit:_ -> ok end.", Pos{Line: 1, Col: 6}},
+				{"fun f(X) ->\n  X + Y end.", Pos{Line: 2, Col: 7}},
+			}
+
+			for _, tt := range testCases {
+				func() {
+					env := NewEnv()
+					pid := pids.NewPid()
+					defer pid.Close()
+
+					_, err := engine.eval(tt.input, env, pid)
+					located, ok := err.(errors.Located)
+					if !ok {
+						t.Errorf("evaluating '%s' should throw an error with position, but it thrown: %v", tt.input, err)
+					} else if located.Pos != tt.pos {
+						t.Errorf("evaluating '%s' should throw an error at %v, but it was at %v", tt.input, tt.pos, located.Pos)
+					}
+				}()
+			}
+		})
 	}
+}
 
-	for _, tt := range testCases {
-		func() {
-			env := NewEnv()
-			pid := pids.NewPid()
-			defer pid.Close()
+func TestStackTrace(t *testing.T) {
+	t.Parallel()
 
-			_, err := ParseEval(tt.input, env, pid)
-			if !cmp.Equal(err, tt.err) {
-				t.Errorf("evaluating '%s' should throw error: %s, but it thrown: %s", tt.input, tt.err, err)
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			testCases := []struct {
+				input    string
+				expected []errors.Frame
+			}{
+				{"1/0.", nil},
+				{"fun f(X) -> 1/X end,\nf(0).", []errors.Frame{
+					{"f", 1, Pos{Line: 2, Col: 1}},
+				}},
+				{"fun f(X) -> 1/X end,\nfun g(X) -> Y = f(X), Y end,\ng(0).", []errors.Frame{
+					{"f", 1, Pos{Line: 2, Col: 17}},
+					{"g", 1, Pos{Line: 3, Col: 1}},
+				}},
+				// tail calls replace the caller on the stack
+				{"fun f(0) -> 1/0; (N) -> f(N-1) end,\nf(1000).", []errors.Frame{
+					{"f", 1, Pos{Line: 1, Col: 25}},
+				}},
+				{"(fun(X) -> last(X) end)([]).", []errors.Frame{
+					{"last", 1, Pos{Line: 1, Col: 12}},
+					{"fun", 1, Pos{Line: 1, Col: 1}},
+				}},
+				{"fun f() -> 1/0 end,\ntry f() catch exit:_ -> ok end.", []errors.Frame{
+					{"f", 0, Pos{Line: 2, Col: 5}},
+				}},
 			}
-		}()
+
+			for _, tt := range testCases {
+				func() {
+					env := NewEnv()
+					pid := pids.NewPid()
+					defer pid.Close()
+
+					_, err := engine.eval(tt.input, env, pid)
+					if err == nil {
+						t.Errorf("evaluating '%s' should throw an error", tt.input)
+						return
+					}
+					result := errors.StackTrace(err)
+					if !cmp.Equal(result, tt.expected) {
+						t.Errorf("for '%s' expected stack trace %v, got %v", tt.input, tt.expected, result)
+					}
+				}()
+			}
+		})
 	}
 }
 
@@ -327,325 +752,451 @@ func TestPartialEval(t *testing.T) {
 
 func TestMatchSet(t *testing.T) {
 	t.Parallel()
-	var err error
 
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			var err error
 
-	// fresh values
-	_, err = ParseEval("X = 1.", env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	}
-	_, err = ParseEval("Y = X.", env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	}
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	// they are equal
-	_, err = ParseEval("X = Y.", env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	}
+			// fresh values
+			_, err = engine.eval("X = 1.", env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			_, err = engine.eval("Y = X.", env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
 
-	// this match should fail
-	_, err = ParseEval("X = 2.", env, pid)
-	expectedErr := errors.NoMatch{Variable("X"), Int(2)}
-	if !cmp.Equal(err, expectedErr) {
-		t.Errorf("expected error: %s, got %s", expectedErr, err)
+			// they are equal
+			_, err = engine.eval("X = Y.", env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			// this match should fail
+			_, err = engine.eval("X = 2.", env, pid)
+			expectedErr := errors.NoMatch{Variable("X"), Int(2)}
+			if !cmp.Equal(errors.Cause(err), expectedErr) {
+				t.Errorf("expected error: %s, got %s", expectedErr, err)
+			}
+		})
 	}
 }
 
 func TestNamedFun(t *testing.T) {
 	t.Parallel()
 
-	expected := Atom("ok")
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			expected := Atom("ok")
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	result, err := ParseEval(`
-	fun identity(X) -> X end,
-	identity(ok).
-	`, env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	} else if !cmp.Equal(result, expected) {
-		t.Errorf("expected message %v, got %v", expected, result)
+			result, err := engine.eval(`
+			fun identity(X) -> X end,
+			identity(ok).
+			`, env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if !cmp.Equal(result, expected) {
+				t.Errorf("expected message %v, got %v", expected, result)
+			}
+		})
 	}
 }
 
 func TestTailCallOptimization(t *testing.T) {
 	t.Parallel()
 
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	// this code stack-overflows without tail-call optimization
-	largeNumberOfIterations := 1_000_000
-	code := fmt.Sprintf(
-		`fun down
-			(0) -> ok;
-			(X) -> down(X - 1)
-		 end,
-		 down(%d).`,
-		largeNumberOfIterations,
-	)
-	_, err := ParseEval(code, env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
+			// this code stack-overflows without tail-call optimization
+			largeNumberOfIterations := 1_000_000
+			code := fmt.Sprintf(
+				`fun down
+					(0) -> ok;
+					(X) -> down(X - 1)
+				 end,
+				 down(%d).`,
+				largeNumberOfIterations,
+			)
+			_, err := engine.eval(code, env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
 	}
 }
 
-func TestIterating(t *testing.T) {
+func TestLocalNameTailCall(t *testing.T) {
 	t.Parallel()
 
-	var (
-		err              error
-		result, expected types.Expr
-	)
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	_, err = ParseEval(`
-	fun reverse
-		%% interface
-		(Lst) -> reverse(Lst, []);
-		%% implementation
-		([], Acc) -> Acc;
-		(Lst, Acc) -> reverse(rest(Lst), Acc ++ [last(Lst)])
-	end.
-	`, env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
+			code := `F = fun Down(0) -> ok; (X) -> begin Y = X - 1, Down(Y) end end, F(1000000).`
+			_, err := engine.eval(code, env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
 	}
+}
 
-	expected = types.List{[]types.Expr{types.Int(3), types.Int(2), types.Int(1)}}
-	result, err = ParseEval("reverse([1,2,3]).", env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	} else if !cmp.Equal(result, expected) {
-		t.Errorf("expected %v, got %v", expected, result)
+func TestShortCircuitTailCall(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
+
+			// the right-hand side of andalso and orelse is in the tail position
+			code := `fun always(0) -> true; (N) -> N > 0 andalso always(N - 1) end,
+				 always(1000000),
+				 fun count(0) -> false; (N) -> N < 0 orelse count(N - 1) end,
+				 count(1000000).`
+			result, err := engine.eval(code, env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if result != Bool(false) {
+				t.Errorf("expected false, got %v", result)
+			}
+		})
+	}
+}
+
+func TestIterating(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			var (
+				err              error
+				result, expected types.Expr
+			)
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
+
+			_, err = engine.eval(`
+			fun reverse
+				%% interface
+				(Lst) -> reverse(Lst, []);
+				%% implementation
+				([], Acc) -> Acc;
+				(Lst, Acc) -> reverse(rest(Lst), Acc ++ [last(Lst)])
+			end.
+			`, env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			expected = types.List{[]types.Expr{types.Int(3), types.Int(2), types.Int(1)}}
+			result, err = engine.eval("reverse([1,2,3]).", env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if !cmp.Equal(result, expected) {
+				t.Errorf("expected %v, got %v", expected, result)
+			}
+		})
 	}
 }
 
 func TestSleep(t *testing.T) {
 	t.Parallel()
 
-	waitTime := 1500
-	expectedResult := Int(waitTime)
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			waitTime := 1500
+			expectedResult := Int(waitTime)
 
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	startTime := time.Now()
-	result, err := ParseEval(fmt.Sprintf("sleep(%d).", waitTime), env, pid)
-	duration := time.Since(startTime)
+			startTime := time.Now()
+			result, err := engine.eval(fmt.Sprintf("sleep(%d).", waitTime), env, pid)
+			duration := time.Since(startTime)
 
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	} else if !cmp.Equal(result, expectedResult) {
-		t.Errorf("expected %v, got %v", expectedResult, result)
-	}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if !cmp.Equal(result, expectedResult) {
+				t.Errorf("expected %v, got %v", expectedResult, result)
+			}
 
-	// there may be an overhead, but it should not be lower than the expected wait time
-	expectedDuration := time.Duration(waitTime * int(time.Millisecond))
-	if duration < expectedDuration {
-		t.Errorf("the duration was %d msec < %d msec expected", duration.Milliseconds(), expectedDuration.Milliseconds())
+			// there may be an overhead, but it should not be lower than the expected wait time
+			expectedDuration := time.Duration(waitTime * int(time.Millisecond))
+			if duration < expectedDuration {
+				t.Errorf("the duration was %d msec < %d msec expected", duration.Milliseconds(), expectedDuration.Milliseconds())
+			}
+		})
 	}
 }
 
 func TestExit(t *testing.T) {
 	t.Parallel()
 
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	_, err := ParseEval("exit(reason).", env, pid)
-	expectedErr := errors.Exit{Atom("reason")}
-	if !cmp.Equal(err, expectedErr) {
-		t.Errorf("expected error: '%s', got '%s'", expectedErr, err)
+			_, err := engine.eval("exit(reason).", env, pid)
+			expectedErr := errors.Exit{Atom("reason")}
+			if !cmp.Equal(errors.Cause(err), expectedErr) {
+				t.Errorf("expected error: '%s', got '%s'", expectedErr, err)
+			}
+		})
 	}
 }
 
-func TestSelf(t *testing.T) {
+func TestHalt(t *testing.T) {
 	t.Parallel()
 
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	var testCases = []struct {
+		input    string
+		expected errors.Halt
+	}{
+		{"halt().", errors.Halt{0}},
+		{"halt(3).", errors.Halt{3}},
+		// it cannot be caught
+		{"try halt(1) catch _:_ -> caught end.", errors.Halt{1}},
+		{"try halt(2) catch exit:_ -> caught; error:_ -> caught end.", errors.Halt{2}},
+		{"try halt(4) recover caught end.", errors.Halt{4}},
+		{"F = fun() -> halt(5) end, try F() catch _:_ -> caught end.", errors.Halt{5}},
+	}
 
-	result, err := ParseEval("self().", env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
+	for _, engine := range engines {
+		for _, tt := range testCases {
+			t.Run(engine.name+" "+tt.input, func(t *testing.T) {
+				t.Parallel()
+
+				env := NewEnv()
+				pid := pids.NewPid()
+				defer pid.Close()
+
+				_, err := engine.eval(tt.input, env, pid)
+				if !cmp.Equal(errors.Cause(err), tt.expected) {
+					t.Errorf("expected error: '%s', got '%v'", tt.expected, err)
+				}
+			})
+		}
 	}
+}
+
+func TestSelf(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	gotPid, ok := result.(pids.Pid)
-	if !ok {
-		t.Errorf("Expected pid, got %T", result)
-	} else if !cmp.Equal(pid, gotPid) {
-		t.Errorf("expected pid %v, got %v", pid, gotPid)
+			result, err := engine.eval("self().", env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			gotPid, ok := result.(pids.Pid)
+			if !ok {
+				t.Errorf("Expected pid, got %T", result)
+			} else if !cmp.Equal(pid, gotPid) {
+				t.Errorf("expected pid %v, got %v", pid, gotPid)
+			}
+		})
 	}
 }
 
 func TestSendMessage(t *testing.T) {
 	t.Parallel()
 
-	expected := Atom("hi")
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			expected := Atom("hi")
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	// send a message to yourself
-	_, err := ParseEval("self() ! hi.", env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	}
+			// send a message to yourself
+			_, err := engine.eval("self() ! hi.", env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
 
-	// the message is received
-	msg := <-pid.Messages()
-	if !cmp.Equal(msg, expected) {
-		t.Errorf("expected %v, got %v", expected, msg)
+			// the message is received
+			msg := <-pid.Messages()
+			if !cmp.Equal(msg, expected) {
+				t.Errorf("expected %v, got %v", expected, msg)
+			}
+		})
 	}
 }
 
 func TestMessageFromSelf(t *testing.T) {
 	t.Parallel()
 
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	expected := Tuple{[]Expr{pid, Atom("hello")}}
-	result, err := ParseEval(`
-	self() ! hello,
-	receive
-		Msg -> {self(), Msg}
-	end.
-	`, env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	} else if !cmp.Equal(result, expected) {
-		t.Errorf("expected message %v, got %v", expected, result)
+			expected := Tuple{[]Expr{pid, Atom("hello")}}
+			result, err := engine.eval(`
+			self() ! hello,
+			receive
+				Msg -> {self(), Msg}
+			end.
+			`, env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if !cmp.Equal(result, expected) {
+				t.Errorf("expected message %v, got %v", expected, result)
+			}
+		})
 	}
 }
 
 func TestCommunicate(t *testing.T) {
 	t.Parallel()
 
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	expected := Tuple{[]Expr{Atom("ack"), Atom("hi")}}
+			expected := Tuple{[]Expr{Atom("ack"), Atom("hi")}}
 
-	result, err := ParseEval(`
-	Pid = spawn(fun() ->
-		receive
-			{Sender, Msg} ->
-				Sender ! {ack, Msg}
-		end
-	end),
+			result, err := engine.eval(`
+			Pid = spawn(fun() ->
+				receive
+					{Sender, Msg} ->
+						Sender ! {ack, Msg}
+				end
+			end),
 
-	Pid ! {self(), hi},
+			Pid ! {self(), hi},
 
-	receive
-		Msg -> Msg
-	after
-		100 -> timeout
-	end.
-	`, env, pid)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	} else if !cmp.Equal(result, expected) {
-		t.Errorf("expected message %v, got %v", expected, result)
+			receive
+				Msg -> Msg
+			after
+				100 -> timeout
+			end.
+			`, env, pid)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if !cmp.Equal(result, expected) {
+				t.Errorf("expected message %v, got %v", expected, result)
+			}
+		})
 	}
 }
 
 func TestReceiveTimeout(t *testing.T) {
 	t.Parallel()
 
-	waitTime := 1500
-	expectedResult := Atom("ok")
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			waitTime := 1500
+			expectedResult := Atom("ok")
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	startTime := time.Now()
-	result, err := ParseEval(fmt.Sprintf("receive after %d -> ok end.", waitTime), env, pid)
-	duration := time.Since(startTime)
+			startTime := time.Now()
+			result, err := engine.eval(fmt.Sprintf("receive after %d -> ok end.", waitTime), env, pid)
+			duration := time.Since(startTime)
 
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	} else if !cmp.Equal(result, expectedResult) {
-		t.Errorf("expected %v, got %v", expectedResult, result)
-	}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if !cmp.Equal(result, expectedResult) {
+				t.Errorf("expected %v, got %v", expectedResult, result)
+			}
 
-	// there may be an overhead, but it should not be lower than the expected wait time
-	expectedDuration := time.Duration(waitTime * int(time.Millisecond))
-	if duration < expectedDuration {
-		t.Errorf("the duration was %d msec < %d msec expected", duration.Milliseconds(), expectedDuration.Milliseconds())
+			// there may be an overhead, but it should not be lower than the expected wait time
+			expectedDuration := time.Duration(waitTime * int(time.Millisecond))
+			if duration < expectedDuration {
+				t.Errorf("the duration was %d msec < %d msec expected", duration.Milliseconds(), expectedDuration.Milliseconds())
+			}
+		})
 	}
 }
 
 func TestTimeout(t *testing.T) {
 	t.Parallel()
 
-	env := NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
 
-	startTime := time.Now()
-	result, err := ParseEval(`
-	Root = self(),
-
-	fun loop() ->
-		receive
-			keep_awake ->
-				Root ! ok,
-				loop()
-		after
-			200 ->
-				Root ! timeout
-		end
-	end,
-
-	Pid = spawn(fun() -> loop() end),
-
-	sleep(100),
-	Pid ! keep_awake,
-	receive
-		ok -> ok
-	end,
-
-	sleep(100),
-	Pid ! keep_awake,
-	receive
-		ok -> ok
-	end,
-
-	receive
-		Msg -> Msg
-	end.
-	`, env, pid)
-	duration := time.Since(startTime)
-
-	expectedResult := Atom("timeout")
+			startTime := time.Now()
+			result, err := engine.eval(`
+			Root = self(),
+
+			fun loop() ->
+				receive
+					keep_awake ->
+						Root ! ok,
+						loop()
+				after
+					200 ->
+						Root ! timeout
+				end
+			end,
+
+			Pid = spawn(fun() -> loop() end),
+
+			sleep(100),
+			Pid ! keep_awake,
+			receive
+				ok -> ok
+			end,
+
+			sleep(100),
+			Pid ! keep_awake,
+			receive
+				ok -> ok
+			end,
+
+			receive
+				Msg -> Msg
+			end.
+			`, env, pid)
+			duration := time.Since(startTime)
+
+			expectedResult := Atom("timeout")
 
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
-	} else if !cmp.Equal(result, expectedResult) {
-		t.Errorf("expected message %v, got %v", expectedResult, result)
-	}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if !cmp.Equal(result, expectedResult) {
+				t.Errorf("expected message %v, got %v", expectedResult, result)
+			}
 
-	// there may be an overhead, but it should not be lower than the expected wait time
-	expectedDuration := time.Duration(400 * time.Millisecond)
-	if duration < expectedDuration {
-		t.Errorf("the duration was %d msec < %d msec expected", duration.Milliseconds(), expectedDuration.Milliseconds())
+			// there may be an overhead, but it should not be lower than the expected wait time
+			expectedDuration := time.Duration(400 * time.Millisecond)
+			if duration < expectedDuration {
+				t.Errorf("the duration was %d msec < %d msec expected", duration.Milliseconds(), expectedDuration.Milliseconds())
+			}
+		})
 	}
 }
@@ -1,14 +1,16 @@
 package envir
 
 import (
-	"reflect"
-
 	"github.com/twolodzko/goer/core/errors"
 	. "github.com/twolodzko/goer/types"
 )
 
+// Environment holding the values by their names. The frames of the function calls
+// hold the variables in the slots resolved before the evaluation, they have their
+// own names only when the function defines them.
 type Env struct {
 	Elems  map[string]Expr
+	slots  []Expr
 	parent *Env
 }
 
@@ -20,13 +22,64 @@ func EmptyEnv() *Env {
 // Create Env, use the `init` functions to initialize it.
 func InitEnv(init func() map[string]Expr) *Env {
 	vars := init()
-	return &Env{vars, nil}
+	return &Env{vars, nil, nil}
 }
 
 // Create child Env from the parent (current).
 func (parent *Env) Branch() *Env {
 	vars := make(map[string]Expr)
-	return &Env{vars, parent}
+	return &Env{vars, nil, parent}
+}
+
+// Create the frame with `size` empty slots for the variables, with its own
+// names only when `names` is true.
+func (parent *Env) Frame(size int, names bool) *Env {
+	env := &Env{nil, make([]Expr, size), parent}
+	if names {
+		env.Elems = make(map[string]Expr)
+	}
+	return env
+}
+
+// Create the frame holding the values in its slots.
+func (parent *Env) Enclose(values []Expr) *Env {
+	return &Env{nil, values, parent}
+}
+
+// The nearest Env that holds the names.
+func (env *Env) Names() *Env {
+	for env.Elems == nil && env.parent != nil {
+		env = env.parent
+	}
+	return env
+}
+
+// Get the value from the slot of the frame `depth` levels up, nil if it is unbound.
+func (env *Env) Load(depth, slot int) Expr {
+	for ; depth > 0; depth-- {
+		env = env.parent
+	}
+	return env.slots[slot]
+}
+
+// Try to set the value in the slot of the frame, like TrySet.
+func (env *Env) TryBind(slot int, key, value Expr) error {
+	if prev := env.slots[slot]; prev != nil {
+		if !Equal(prev, value) {
+			return errors.NoMatch{key, value}
+		}
+		return nil
+	}
+	env.slots[slot] = value
+	return nil
+}
+
+// Set the value in the Env, it should not exist yet.
+func (env *Env) Set(name string, value Expr) {
+	if env.Elems == nil {
+		env.Elems = make(map[string]Expr)
+	}
+	env.Elems[name] = value
 }
 
 // Get the value from Env, error if not available.
@@ -56,13 +109,27 @@ func (env *Env) TrySet(key, value Expr) error {
 
 	// if it exists
 	if prev, ok := env.Elems[name]; ok {
-		if !reflect.DeepEqual(prev, value) {
+		if !Equal(prev, value) {
 			return errors.NoMatch{key, value}
 		}
 		return nil
 	}
 
-	env.Elems[name] = value
+	env.Set(name, value)
+	return nil
+}
+
+// Remove the value from the Env, so the name can be bound again. The parents
+// are left as they are. If the name is not bound in the Env, throw an error.
+func (env *Env) Unset(key Expr) error {
+	name, err := getName(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := env.Elems[name]; !ok {
+		return errors.Unbound{name}
+	}
+	delete(env.Elems, name)
 	return nil
 }
 
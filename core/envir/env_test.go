@@ -120,3 +120,81 @@ func TestErrors(t *testing.T) {
 		t.Error("set didn't error")
 	}
 }
+
+func TestUnset(t *testing.T) {
+	t.Parallel()
+
+	parent := EmptyEnv()
+	if err := parent.TrySet(Variable("X"), Int(1)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	child := parent.Branch()
+	if err := child.TrySet(Variable("Y"), Int(2)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := child.Unset(Variable("Y")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := child.Get(Variable("Y")); err == nil {
+		t.Error("Y should be unbound")
+	}
+	// it can be bound again to a different value
+	if err := child.TrySet(Variable("Y"), Int(3)); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// the parents are not changed
+	if err := child.Unset(Variable("X")); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := child.Get(Variable("X")); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := child.Unset(Int(1)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestFrames(t *testing.T) {
+	t.Parallel()
+
+	global := EmptyEnv()
+	if err := global.TrySet(Atom("f"), Int(1)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	captured := global.Enclose([]Expr{Int(2)})
+	frame := captured.Frame(2, false)
+
+	if err := frame.TryBind(0, Variable("X"), Int(3)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := frame.TryBind(0, Variable("X"), Int(3)); err != nil {
+		t.Errorf("binding the same value should not fail: %s", err)
+	}
+	if err := frame.TryBind(0, Variable("X"), Int(4)); err != (errors.NoMatch{Variable("X"), Int(4)}) {
+		t.Errorf("binding other value should fail, got %v", err)
+	}
+
+	if val := frame.Load(0, 0); val != Int(3) {
+		t.Errorf("expected 3 in the frame, got %v", val)
+	}
+	if val := frame.Load(0, 1); val != nil {
+		t.Errorf("expected the slot to be empty, got %v", val)
+	}
+	if val := frame.Load(1, 0); val != Int(2) {
+		t.Errorf("expected 2 in the enclosing frame, got %v", val)
+	}
+
+	// the frames without names look them up in the enclosing environment
+	if frame.Names() != global {
+		t.Errorf("the names should be held by the global environment")
+	}
+	if val, err := frame.Get(Atom("f")); err != nil || val != Int(1) {
+		t.Errorf("expected to get f from the global environment, got %v, %v", val, err)
+	}
+	named := captured.Frame(0, true)
+	if named.Names() != named {
+		t.Errorf("the frame should hold its own names")
+	}
+}
@@ -2,80 +2,353 @@ package errors
 
 import (
 	"fmt"
+	"time"
 
 	. "github.com/twolodzko/goer/types"
 )
 
+// Error that can be represented as a goer term, so it can be matched
+// in the "catch" branches of the "try" block.
+type TermError interface {
+	error
+	Term() Expr
+}
+
+// Convert the error to the goer term describing it.
+func ToTerm(err error) Expr {
+	err = Cause(err)
+	if err, ok := err.(TermError); ok {
+		return err.Term()
+	}
+	return String(err.Error())
+}
+
+// The class of the error, either `exit` or `error`.
+func Class(err error) Atom {
+	if _, ok := Cause(err).(Exit); ok {
+		return "exit"
+	}
+	return "error"
+}
+
+// Error annotated with the position in the code where it was thrown,
+// and the stack of the function calls that led to it.
+type Located struct {
+	Err   error
+	Pos   Pos
+	Stack []Frame
+}
+
+func (err Located) Error() string {
+	if err.Pos.IsValid() {
+		return fmt.Sprintf("%v: %v", err.Pos, err.Err)
+	}
+	return err.Err.Error()
+}
+
+func (err Located) Unwrap() error {
+	return err.Err
+}
+
+func (err Located) Position() Pos {
+	if located, ok := err.Err.(interface{ Position() Pos }); ok && !err.Pos.IsValid() {
+		return located.Position()
+	}
+	return err.Pos
+}
+
+// Annotate the error with the position, unless it is unknown
+// or the error already has a position.
+func At(err error, pos Pos) error {
+	if !pos.IsValid() {
+		return err
+	}
+	switch located := err.(type) {
+	case Located:
+		if !located.Position().IsValid() {
+			located.Pos = pos
+			return located
+		}
+		return err
+	case interface{ Position() Pos }:
+		return err
+	default:
+		return Located{err, pos, nil}
+	}
+}
+
+// Function call on the call stack.
+type Frame struct {
+	Name  string
+	Arity int
+	Pos   Pos // where the function was called
+}
+
+func (f Frame) String() string {
+	if f.Pos.IsValid() {
+		return fmt.Sprintf("%s/%d, called at %v", f.Name, f.Arity, f.Pos)
+	}
+	return fmt.Sprintf("%s/%d", f.Name, f.Arity)
+}
+
+// The `{Name, Arity, Location}` term, where location is a list of `{file, File}`
+// and `{line, Line}` tuples, as in Erlang's stack traces.
+func (f Frame) Term() Expr {
+	var location []Expr
+	if f.Pos.File != "" {
+		location = append(location, Tuple{[]Expr{Atom("file"), String(f.Pos.File)}})
+	}
+	if f.Pos.IsValid() {
+		location = append(location, Tuple{[]Expr{Atom("line"), Int(f.Pos.Line)}})
+	}
+	return Tuple{[]Expr{Atom(f.Name), Int(f.Arity), List{location}}}
+}
+
+// Add the function call to the stack trace of the error.
+func WithFrame(err error, frame Frame) error {
+	located, ok := err.(Located)
+	if !ok {
+		located = Located{err, Pos{}, nil}
+	}
+	located.Stack = append(located.Stack, frame)
+	return located
+}
+
+// The stack trace of the error, starting from the innermost call.
+func StackTrace(err error) []Frame {
+	if located, ok := err.(Located); ok {
+		return located.Stack
+	}
+	return nil
+}
+
+// The stack trace of the error as a list of terms.
+func StackTraceTerm(err error) List {
+	var frames []Expr
+	for _, frame := range StackTrace(err) {
+		frames = append(frames, frame.Term())
+	}
+	return List{frames}
+}
+
+// The underlying error, without the annotations.
+func Cause(err error) error {
+	if err, ok := err.(Located); ok {
+		return err.Err
+	}
+	return err
+}
+
 type Unbound struct{ Name string }
 
 func (err Unbound) Error() string {
 	return fmt.Sprintf("variable '%v' is unbound", err.Name)
 }
 
+func (err Unbound) Term() Expr {
+	return Tuple{[]Expr{Atom("unbound"), Atom(err.Name)}}
+}
+
 type NoMatch struct{ Lhs, Rhs Expr }
 
 func (err NoMatch) Error() string {
 	return fmt.Sprintf("'%v' and '%v' do not match", err.Lhs, err.Rhs)
 }
 
+func (err NoMatch) Term() Expr {
+	return Tuple{[]Expr{Atom("badmatch"), err.Rhs}}
+}
+
 type NotNumber struct{ Value Expr }
 
 func (err NotNumber) Error() string {
 	return fmt.Sprintf("'%v' is not a number", err.Value)
 }
 
+func (err NotNumber) Term() Expr {
+	return Atom("badarith")
+}
+
 type NotBoolean struct{ Value Expr }
 
 func (err NotBoolean) Error() string {
 	return fmt.Sprintf("'%v' is not a boolean", err.Value)
 }
 
+func (err NotBoolean) Term() Expr {
+	return Tuple{[]Expr{Atom("badarg"), err.Value}}
+}
+
 type NotString struct{ Value Expr }
 
 func (err NotString) Error() string {
 	return fmt.Sprintf("'%v' is not a string", err.Value)
 }
 
+func (err NotString) Term() Expr {
+	return Tuple{[]Expr{Atom("badarg"), err.Value}}
+}
+
 type NotName struct{ Value Expr }
 
 func (err NotName) Error() string {
 	return fmt.Sprintf("'%v' is not a valid name", err.Value)
 }
 
+func (err NotName) Term() Expr {
+	return Tuple{[]Expr{Atom("badarg"), err.Value}}
+}
+
 type NotList struct{ Value Expr }
 
 func (err NotList) Error() string {
 	return fmt.Sprintf("'%v' is not a list", err.Value)
 }
 
+func (err NotList) Term() Expr {
+	return Tuple{[]Expr{Atom("badarg"), err.Value}}
+}
+
+type NotBinary struct{ Value Expr }
+
+func (err NotBinary) Error() string {
+	return fmt.Sprintf("'%v' is not a binary", err.Value)
+}
+
+func (err NotBinary) Term() Expr {
+	return Tuple{[]Expr{Atom("badarg"), err.Value}}
+}
+
+type InvalidSegment struct{ Segment Expr }
+
+func (err InvalidSegment) Error() string {
+	return fmt.Sprintf("invalid segment of the bit string: %v", err.Segment)
+}
+
+func (err InvalidSegment) Term() Expr {
+	return Tuple{[]Expr{Atom("badarg"), String(fmt.Sprint(err.Segment))}}
+}
+
+type UnalignedBinary struct{ Bits int }
+
+func (err UnalignedBinary) Error() string {
+	return fmt.Sprintf("the bit string has %d bits, binaries need to have whole bytes", err.Bits)
+}
+
+func (err UnalignedBinary) Term() Expr {
+	return Tuple{[]Expr{Atom("badarg"), Int(err.Bits)}}
+}
+
+type BadFormat struct {
+	Format Expr
+	Reason string
+}
+
+func (err BadFormat) Error() string {
+	return fmt.Sprintf("bad format %v: %s", err.Format, err.Reason)
+}
+
+func (err BadFormat) Term() Expr {
+	return Tuple{[]Expr{Atom("badarg"), err.Format}}
+}
+
+type UndefinedRecord struct{ Name string }
+
+func (err UndefinedRecord) Error() string {
+	return fmt.Sprintf("record %v undefined", Atom(err.Name))
+}
+
+func (err UndefinedRecord) Term() Expr {
+	return Tuple{[]Expr{Atom("undefined_record"), Atom(err.Name)}}
+}
+
+type UndefinedField struct{ Record, Field string }
+
+func (err UndefinedField) Error() string {
+	return fmt.Sprintf("field %v undefined in record %v", Atom(err.Field), Atom(err.Record))
+}
+
+func (err UndefinedField) Term() Expr {
+	return Tuple{[]Expr{Atom("undefined_field"), Atom(err.Record), Atom(err.Field)}}
+}
+
+type BadRecord struct {
+	Name  string
+	Value Expr
+}
+
+func (err BadRecord) Error() string {
+	return fmt.Sprintf("'%v' is not a %v record", err.Value, Atom(err.Name))
+}
+
+func (err BadRecord) Term() Expr {
+	return Tuple{[]Expr{Atom("badrecord"), err.Value}}
+}
+
 type DivisionByZero struct{}
 
 func (err DivisionByZero) Error() string {
 	return "division by zero"
 }
 
+func (err DivisionByZero) Term() Expr {
+	return Atom("badarith")
+}
+
 type NoTrueBranch struct{}
 
 func (err NoTrueBranch) Error() string {
 	return "no true branch found"
 }
 
+func (err NoTrueBranch) Term() Expr {
+	return Atom("if_clause")
+}
+
+type NoCaseBranch struct{ Value Expr }
+
+func (err NoCaseBranch) Error() string {
+	return fmt.Sprintf("no case branch matching '%v'", err.Value)
+}
+
+func (err NoCaseBranch) Term() Expr {
+	return Tuple{[]Expr{Atom("case_clause"), err.Value}}
+}
+
 type NotFunction struct{ Value Expr }
 
 func (err NotFunction) Error() string {
 	return fmt.Sprintf("'%v' is not a function", err.Value)
 }
 
+func (err NotFunction) Term() Expr {
+	return Tuple{[]Expr{Atom("badfun"), err.Value}}
+}
+
 type NoFunBranch struct{}
 
 func (err NoFunBranch) Error() string {
 	return "arguments do not match the function definition"
 }
 
-type WrongNumberArgs struct{}
+func (err NoFunBranch) Term() Expr {
+	return Atom("function_clause")
+}
+
+type WrongNumberArgs struct {
+	Fun  Expr
+	Args []Expr
+}
 
 func (err WrongNumberArgs) Error() string {
-	return fmt.Sprintf("wrong number of arguments")
+	if err.Fun == nil {
+		return "wrong number of arguments"
+	}
+	return fmt.Sprintf("wrong number of arguments for '%v': %d", err.Fun, len(err.Args))
+}
+
+func (err WrongNumberArgs) Term() Expr {
+	return Tuple{[]Expr{Atom("badarity"), Tuple{[]Expr{err.Fun, List{err.Args}}}}}
 }
 
 type EmptyList struct{}
@@ -84,18 +357,260 @@ func (err EmptyList) Error() string {
 	return "empty list"
 }
 
+func (err EmptyList) Term() Expr {
+	return Tuple{[]Expr{Atom("badarg"), List{}}}
+}
+
 type Exit struct{ Reason Expr }
 
 func (err Exit) Error() string {
 	return fmt.Sprintf("exception exit: %v", err.Reason)
 }
 
+func (err Exit) Term() Expr {
+	return err.Reason
+}
+
+type Error struct{ Reason Expr }
+
+func (err Error) Error() string {
+	return fmt.Sprintf("exception error: %v", err.Reason)
+}
+
+func (err Error) Term() Expr {
+	return err.Reason
+}
+
+// The request to stop the program with the exit status, it cannot be caught.
+type Halt struct{ Status int }
+
+func (err Halt) Error() string {
+	return fmt.Sprintf("halted with the status %d", err.Status)
+}
+
+func (err Halt) Term() Expr {
+	return Tuple{[]Expr{Atom("halt"), Int(err.Status)}}
+}
+
+// The process was stopped from the outside, for example its context was canceled,
+// it cannot be caught.
+type Stopped struct{ Reason error }
+
+func (err Stopped) Error() string {
+	return fmt.Sprintf("the process was stopped: %v", err.Reason)
+}
+
+func (err Stopped) Unwrap() error {
+	return err.Reason
+}
+
+func (err Stopped) Term() Expr {
+	return Tuple{[]Expr{Atom("stopped"), String(err.Reason.Error())}}
+}
+
+// The error stopping all the processes of the group: `halt` was called,
+// or the limit of the sandbox was exceeded. It cannot be caught.
+func IsFatal(err error) bool {
+	switch Cause(err).(type) {
+	case Halt, ProcessLimit, ReductionLimit, MailboxLimit, MemoryLimit, TimeLimit:
+		return true
+	default:
+		return false
+	}
+}
+
+type ProcessLimit struct{ Limit int }
+
+func (err ProcessLimit) Error() string {
+	return fmt.Sprintf("the limit of %d processes was exceeded", err.Limit)
+}
+
+func (err ProcessLimit) Term() Expr {
+	return Tuple{[]Expr{Atom("limit"), Atom("processes"), Int(err.Limit)}}
+}
+
+type ReductionLimit struct{ Limit int }
+
+func (err ReductionLimit) Error() string {
+	return fmt.Sprintf("the limit of %d reductions was exceeded", err.Limit)
+}
+
+func (err ReductionLimit) Term() Expr {
+	return Tuple{[]Expr{Atom("limit"), Atom("reductions"), Int(err.Limit)}}
+}
+
+type MailboxLimit struct{ Limit int }
+
+func (err MailboxLimit) Error() string {
+	return fmt.Sprintf("the limit of %d messages in the mailbox was exceeded", err.Limit)
+}
+
+func (err MailboxLimit) Term() Expr {
+	return Tuple{[]Expr{Atom("limit"), Atom("mailbox"), Int(err.Limit)}}
+}
+
+type MemoryLimit struct{ Limit int }
+
+func (err MemoryLimit) Error() string {
+	return fmt.Sprintf("the limit of %d words of memory was exceeded", err.Limit)
+}
+
+func (err MemoryLimit) Term() Expr {
+	return Tuple{[]Expr{Atom("limit"), Atom("memory"), Int(err.Limit)}}
+}
+
+type TimeLimit struct{ Limit time.Duration }
+
+func (err TimeLimit) Error() string {
+	return fmt.Sprintf("the time limit of %v was exceeded", err.Limit)
+}
+
+func (err TimeLimit) Term() Expr {
+	return Tuple{[]Expr{Atom("limit"), Atom("time"), Int(err.Limit.Milliseconds())}}
+}
+
+// The build-in function is not allowed in the sandbox.
+type NotAllowed struct{ Name string }
+
+func (err NotAllowed) Error() string {
+	return fmt.Sprintf("%v is not allowed", Atom(err.Name))
+}
+
+func (err NotAllowed) Term() Expr {
+	return Tuple{[]Expr{Atom("not_allowed"), Atom(err.Name)}}
+}
+
+// The file is outside of the directory the files can be included from.
+type AccessDenied struct{ Path string }
+
+func (err AccessDenied) Error() string {
+	return fmt.Sprintf("access to %s was denied", err.Path)
+}
+
+func (err AccessDenied) Term() Expr {
+	return Tuple{[]Expr{Atom("access_denied"), String(err.Path)}}
+}
+
+// The error thrown by the failed assertion.
+func IsAssertion(err error) bool {
+	switch Cause(err).(type) {
+	case NotEqual, NotMatched, NoError, WrongError, PropertyFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// The values compared by `assert_equal` differ.
+type NotEqual struct{ Expected, Actual Expr }
+
+func (err NotEqual) Error() string {
+	return fmt.Sprintf("assertion failed: expected %v, got %v", err.Expected, err.Actual)
+}
+
+func (err NotEqual) Term() Expr {
+	return Tuple{[]Expr{Atom("assertion_failed"), List{[]Expr{
+		Tuple{[]Expr{Atom("expected"), err.Expected}},
+		Tuple{[]Expr{Atom("actual"), err.Actual}},
+	}}}}
+}
+
+// The value does not match the pattern of `assert_match`.
+type NotMatched struct {
+	Pattern string
+	Actual  Expr
+}
+
+func (err NotMatched) Error() string {
+	return fmt.Sprintf("assertion failed: expected to match %s, got %v", err.Pattern, err.Actual)
+}
+
+func (err NotMatched) Term() Expr {
+	return Tuple{[]Expr{Atom("assertion_failed"), List{[]Expr{
+		Tuple{[]Expr{Atom("pattern"), String(err.Pattern)}},
+		Tuple{[]Expr{Atom("actual"), err.Actual}},
+	}}}}
+}
+
+// The function checked by `assert_error` returned the value instead of throwing
+// the error. The expected reason is nil when any error was expected.
+type NoError struct{ Expected, Value Expr }
+
+func (err NoError) Error() string {
+	if err.Expected == nil {
+		return fmt.Sprintf("assertion failed: expected an error, got the value %v", err.Value)
+	}
+	return fmt.Sprintf("assertion failed: expected the error %v, got the value %v", err.Expected, err.Value)
+}
+
+func (err NoError) Term() Expr {
+	var expected Expr = Atom("any")
+	if err.Expected != nil {
+		expected = err.Expected
+	}
+	return Tuple{[]Expr{Atom("assertion_failed"), List{[]Expr{
+		Tuple{[]Expr{Atom("expected"), expected}},
+		Tuple{[]Expr{Atom("value"), err.Value}},
+	}}}}
+}
+
+// The function checked by `assert_error` threw other error than expected.
+type WrongError struct{ Expected, Actual Expr }
+
+func (err WrongError) Error() string {
+	return fmt.Sprintf("assertion failed: expected the error %v, got the error %v", err.Expected, err.Actual)
+}
+
+func (err WrongError) Term() Expr {
+	return Tuple{[]Expr{Atom("assertion_failed"), List{[]Expr{
+		Tuple{[]Expr{Atom("expected"), err.Expected}},
+		Tuple{[]Expr{Atom("actual"), err.Actual}},
+	}}}}
+}
+
+// The property checked by `prop_forall` does not hold. The counterexample is the value
+// shrunk from the original one, the reason is the error thrown by the property,
+// nil if it returned false.
+type PropertyFailed struct {
+	Counterexample, Original Expr
+	Seed                     int64
+	Case                     int
+	Reason                   error
+}
+
+func (err PropertyFailed) Error() string {
+	msg := fmt.Sprintf("property failed in the case %d with the seed %d: counterexample %v", err.Case, err.Seed, err.Counterexample)
+	if !Equal(err.Counterexample, err.Original) {
+		msg += fmt.Sprintf(" (shrunk from %v)", err.Original)
+	}
+	if err.Reason != nil {
+		return msg + ", " + Cause(err.Reason).Error()
+	}
+	return msg + ", returned false"
+}
+
+func (err PropertyFailed) Term() Expr {
+	var reason Expr = Bool(false)
+	if err.Reason != nil {
+		reason = ToTerm(err.Reason)
+	}
+	return Tuple{[]Expr{Atom("property_failed"), List{[]Expr{
+		Tuple{[]Expr{Atom("counterexample"), err.Counterexample}},
+		Tuple{[]Expr{Atom("seed"), Int(err.Seed)}},
+		Tuple{[]Expr{Atom("reason"), reason}},
+	}}}}
+}
+
 type Custom struct{ Msg string }
 
 func (err Custom) Error() string {
 	return err.Msg
 }
 
+func (err Custom) Term() Expr {
+	return String(err.Msg)
+}
+
 // Create a custom error message from the format template and arguments.
 func New(format string, a ...any) Custom {
 	return Custom{fmt.Sprintf(format, a...)}
@@ -4,22 +4,43 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/twolodzko/goer/core/envir"
 	"github.com/twolodzko/goer/core/errors"
 	"github.com/twolodzko/goer/core/pids"
 	"github.com/twolodzko/goer/parser"
+	"github.com/twolodzko/goer/parser/lexer"
 	"github.com/twolodzko/goer/parser/reader"
 	. "github.com/twolodzko/goer/types"
 )
 
 // Evaluate an expression.
-func Eval(expr Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
-	var err error
+func Eval(expr Expr, env *envir.Env, pid pids.Pid) (_ Expr, err error) {
+	// position of the currently evaluated expression, used to annotate the errors
+	var pos Pos
+	// the function called in this loop, tail calls replace it, so the
+	// stack trace is collected only when the error unwinds
+	var frame errors.Frame
+	defer func() {
+		if err != nil {
+			err = errors.At(err, pos)
+			if frame.Name != "" {
+				err = errors.WithFrame(err, frame)
+			}
+		}
+	}()
+
 	for {
+		pos = Pos{}
 		switch val := expr.(type) {
 		case Variable:
 			return env.Get(val)
+		case local:
+			if val := env.Load(val.depth, val.slot); val != nil {
+				return val, nil
+			}
+			return nil, errors.Unbound{val.name}
 		case Dummy:
 			return nil, errors.Unbound{"_"}
 		case Atom:
@@ -27,25 +48,80 @@ func Eval(expr Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
 				return val, nil
 			}
 			return val, nil
-		case Bool, Int, String, pids.Pid, Fun:
+		case QuotedAtom:
+			return Atom(val), nil
+		case Bool, Int, String, Binary, pids.Pid, Fun, *Closure, *Generator, buildIn:
 			return val, nil
 		case Tuple:
 			exprs, err := evalAll(val.Values, env, pid)
-			return Tuple{exprs}, err
+			if err != nil {
+				return nil, err
+			}
+			return allocated(Tuple{exprs}, pid)
 		case List:
 			exprs, err := evalAll(val.Values, env, pid)
-			return List{exprs}, err
+			if err != nil {
+				return nil, err
+			}
+			return allocated(List{exprs}, pid)
+		case BitString:
+			pos = val.Pos
+			bin, err := buildBinary(val, env, pid)
+			if err != nil {
+				return nil, err
+			}
+			return allocated(bin, pid)
+		case RecordDecl:
+			pos = val.Pos
+			return declareRecord(val, env)
+		case Record:
+			pos = val.Pos
+			record, err := evalRecord(val, env, pid)
+			if err != nil {
+				return nil, err
+			}
+			return allocated(record, pid)
+		case RecordAccess:
+			pos = val.Pos
+			return evalRecordAccess(val, env, pid)
 		case UnaryOperation:
+			pos = val.Pos
 			rhs, err := Eval(val.Rhs, env, pid)
 			if err != nil {
 				return nil, err
 			}
 			return applyUnaryOp(val.Op, rhs)
 		case BinaryOperation:
+			pos = val.Pos
 			switch val.Op {
 			case "=":
-				err := match(val.Lhs, val.Rhs, env, pid)
+				// the right-hand side without unbound variables is evaluated once,
+				// and its value is matched against the pattern
+				if !isBound(val.Rhs, env) {
+					err := match(val.Lhs, val.Rhs, env, pid)
+					return Bool(err == nil), err
+				}
+				rhs, err := Eval(val.Rhs, env, pid)
+				if err != nil {
+					return nil, err
+				}
+				err = matchValue(val.Lhs, rhs, env, pid)
+				if _, ok := errors.Cause(err).(errors.NoMatch); ok {
+					// the error carries the whole value, not the part that differs
+					err = errors.NoMatch{val.Lhs, rhs}
+				}
 				return Bool(err == nil), err
+			case "andalso", "orelse":
+				// the right-hand side is evaluated only when needed,
+				// and not checked, so it can be a tail call
+				lhs, err := evalIsTrue(val.Lhs, env, pid)
+				if err != nil {
+					return nil, err
+				}
+				if bool(lhs) == (val.Op == "orelse") {
+					return lhs, nil
+				}
+				expr = val.Rhs
 			default:
 				lhs, err := Eval(val.Lhs, env, pid)
 				if err != nil {
@@ -55,38 +131,71 @@ func Eval(expr Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
 				if err != nil {
 					return nil, err
 				}
-				return applyBinaryOp(val.Op, lhs, rhs)
+				result, err := applyBinaryOp(val.Op, lhs, rhs)
+				if err != nil {
+					return nil, err
+				}
+				return allocated(result, pid)
 			}
 		case Bracket:
 			expr = val.Expr
+		case Block:
+			pos = val.Pos
+			expr, env, err = partialEval(val.Body, env, pid)
+			if err != nil {
+				return nil, err
+			}
 		case If:
+			pos = val.Pos
 			expr, env, err = evalIf(val, env, pid)
 			if err != nil {
 				return nil, err
 			}
 		case Case:
+			pos = val.Pos
 			expr, env, err = evalCase(val, env, pid)
 			if err != nil {
 				return nil, err
 			}
 		case TryRecover:
+			pos = val.Pos
 			if expr, err := EvalBlock(val.Body, env, pid); err == nil {
 				return expr, nil
+			} else if uncatchable(err) {
+				return nil, err
 			} else {
 				return EvalBlock(val.Recover, env, pid)
 			}
+		case TryCatch:
+			pos = val.Pos
+			result, thrown := EvalBlock(val.Body, env, pid)
+			if thrown == nil {
+				return result, nil
+			}
+			if uncatchable(thrown) {
+				return nil, thrown
+			}
+			expr, env, err = evalCatch(val, thrown, env, pid)
+			if err != nil {
+				return nil, err
+			}
 		case Definition:
-			fun := Fun{env, val}
-			if val.Name != "" {
+			// the function that was not resolved before the evaluation
+			expr = resolveDefinition(val)
+		case *function:
+			pos = val.Pos
+			fun := newFun(val, env)
+			if val.Name != "" && !val.Local {
 				name := string(val.Name)
 				_, exists := env.Elems[name]
 				if exists {
 					return nil, errors.New("%s already exists", name)
 				}
-				env.Elems[name] = fun
+				env.Set(name, fun)
 			}
 			return fun, err
 		case Call:
+			pos = val.Pos
 			args, err := evalAll(val.Args, env, pid)
 			if err != nil {
 				return nil, err
@@ -95,19 +204,40 @@ func Eval(expr Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
 			if err != nil {
 				return nil, err
 			}
+			// the loops are the recursive calls, so checking them is enough to stop the process
+			if err := stopped(pid.Tick()); err != nil {
+				return nil, err
+			}
 
-			switch fun := fun.(type) {
+			switch callee := fun.(type) {
 			case Fun:
-				expr, env, err = fun.call(args, pid)
+				frame = errors.Frame{funName(callee.Name, val.Callable), len(args), val.Pos}
+				expr, env, err = callee.call(args, pid)
 				if err != nil {
-					return nil, err
+					return nil, withArity(err, val.Callable, fun, args)
 				}
+			case *Closure:
+				frame = errors.Frame{funName(callee.proto.Name, val.Callable), len(args), val.Pos}
+				result, err := callee.call(args, pid)
+				if err != nil {
+					return nil, withArity(err, val.Callable, fun, args)
+				}
+				return result, nil
 			case buildIn:
-				return fun(args, env, pid)
+				result, err := callee(args, env, pid)
+				if err != nil {
+					err = withArity(err, val.Callable, fun, args)
+					return nil, errors.WithFrame(err, errors.Frame{funName("", val.Callable), len(args), val.Pos})
+				}
+				return allocated(result, pid)
 			default:
-				return nil, errors.NotFunction{val.Callable}
+				return nil, errors.NotFunction{fun}
 			}
+		case AssertMatch:
+			pos = val.Pos
+			return assertMatch(val, env, pid)
 		case Receive:
+			pos = val.Pos
 			expr, env, err = receive(val, env, pid)
 			if err != nil {
 				return nil, err
@@ -118,6 +248,130 @@ func Eval(expr Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
 	}
 }
 
+// The program, or the process, is stopped, the error cannot be caught.
+func uncatchable(err error) bool {
+	_, ok := errors.Cause(err).(errors.Stopped)
+	return ok || errors.IsFatal(err)
+}
+
+// The error reported by the process that should stop, given the reason
+// why it should, or nil if it should keep running.
+func stopped(reason error) error {
+	if reason == nil || errors.IsFatal(reason) {
+		return reason
+	}
+	return errors.Stopped{reason}
+}
+
+// Account the memory taken by the new term against the limit of the process group.
+// The terms it holds were already accounted for when they were created.
+func allocated(expr Expr, pid pids.Pid) (Expr, error) {
+	var size int
+	switch val := expr.(type) {
+	case Tuple:
+		size = 1 + len(val.Values)
+	case List:
+		size = 1 + len(val.Values)
+	case String:
+		size = 1 + len(val)/8
+	case Binary:
+		size = 1 + len(val)/8
+	default:
+		return expr, nil
+	}
+	if err := stopped(pid.Alloc(size)); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+// The words of memory taken by the terms bound in the environment, counted like
+// they are counted by `allocated` when they are created.
+func Retained(env *envir.Env) int {
+	var size int
+	for _, val := range env.Elems {
+		size += words(val)
+	}
+	return size
+}
+
+func words(expr Expr) int {
+	switch val := expr.(type) {
+	case Tuple:
+		size := 1 + len(val.Values)
+		for _, elem := range val.Values {
+			size += words(elem)
+		}
+		return size
+	case List:
+		size := 1 + len(val.Values)
+		for _, elem := range val.Values {
+			size += words(elem)
+		}
+		return size
+	case String:
+		return 1 + len(val)/8
+	case Binary:
+		return 1 + len(val)/8
+	default:
+		return 0
+	}
+}
+
+// Call the function with the arguments.
+func Apply(fun Expr, args []Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
+	switch callee := fun.(type) {
+	case Fun:
+		expr, env, err := callee.call(args, pid)
+		if err != nil {
+			return nil, err
+		}
+		return Eval(expr, env, pid)
+	case *Closure:
+		return callee.call(args, pid)
+	case buildIn:
+		return callee(args, env, pid)
+	default:
+		return nil, errors.NotFunction{fun}
+	}
+}
+
+// The definition of the function written in the code, false for
+// the build-in functions and the values that are not functions.
+func DefinitionOf(fun Expr) (Definition, bool) {
+	switch fun := fun.(type) {
+	case Fun:
+		return fun.Definition, true
+	case *Closure:
+		return fun.proto.Definition, true
+	default:
+		return Definition{}, false
+	}
+}
+
+// Name of the called function used in the stack traces.
+func funName(name string, callable Expr) string {
+	if name != "" {
+		return name
+	}
+	if name, ok := callable.(Atom); ok {
+		return string(name)
+	}
+	return "fun"
+}
+
+// Fill in the details of the wrong number of arguments error, using the name
+// of the function if available, or the function itself otherwise.
+func withArity(err error, callable, fun Expr, args []Expr) error {
+	if e, ok := err.(errors.WrongNumberArgs); ok && e.Fun == nil {
+		if name, ok := callable.(Atom); ok {
+			fun = name
+		}
+		return errors.WrongNumberArgs{fun, args}
+	}
+	return err
+}
+
 // Evaluate a block of code, return result of the last expression.
 func EvalBlock(exprs []Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
 	expr, env, err := partialEval(exprs, env, pid)
@@ -161,32 +415,125 @@ func ParseEval(code string, env *envir.Env, pid pids.Pid) (Expr, error) {
 	if err != nil {
 		return nil, err
 	}
+	result, err := evalResolved(exprs, env, pid)
+	if err != nil {
+		return nil, atVariable(err, []chunk{{code, Pos{Line: 1, Col: 1}}})
+	}
+	return result, nil
+}
+
+// Resolve the variables of the top-level expressions and evaluate them.
+func evalResolved(exprs []Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
+	exprs, err := resolve(exprs, env)
+	if err != nil {
+		return nil, err
+	}
 	return EvalBlock(exprs, env, pid)
 }
 
+// The function evaluating the top-level expressions of the file.
+type evaluator func([]Expr, *envir.Env, pids.Pid) (Expr, error)
+
 // Evaluate a file.
 func EvalFile(path string, env *envir.Env, pid pids.Pid) (Expr, error) {
-	var expr Expr = Atom("ok")
+	return evalFile(path, env, pid, evalResolved)
+}
 
+// Evaluate the code having many expressions terminated with the dots, like the file.
+func EvalCode(code string, env *envir.Env, pid pids.Pid) (Expr, error) {
+	return evalReader(strings.NewReader(code), "", env, pid, evalResolved)
+}
+
+// Read the file expression by expression, and evaluate them using `eval`.
+func evalFile(path string, env *envir.Env, pid pids.Pid, eval evaluator) (Expr, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
+	return evalReader(file, path, env, pid, eval)
+}
+
+// Read the code expression by expression, and evaluate them using `eval`. The whole
+// code is read before evaluating it, so the unbound variables are reported before it runs.
+func evalReader(r io.Reader, path string, env *envir.Env, pid pids.Pid, eval evaluator) (Expr, error) {
+	var (
+		exprs  []Expr
+		chunks []chunk
+	)
 
-	reader := reader.NewReader(file)
+	reader := reader.NewReader(r)
 	for {
 		code, err := reader.Next()
-		switch err {
-		case nil:
-		case io.EOF:
-			return expr, nil
-		default:
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
 			return nil, err
 		}
 
-		expr, err = ParseEval(code, env, pid)
+		start := reader.Pos()
+		start.File = path
+		parsed, err := parser.ParseAt(code, start)
 		if err != nil {
 			return nil, err
 		}
+		exprs = append(exprs, parsed...)
+		chunks = append(chunks, chunk{code, start})
+	}
+
+	if len(exprs) == 0 {
+		return Atom("ok"), nil
+	}
+	expr, err := eval(exprs, env, pid)
+	if err != nil {
+		return nil, atVariable(err, chunks)
+	}
+	return expr, nil
+}
+
+// The code block read from the file, starting at the position.
+type chunk struct {
+	code  string
+	start Pos
+}
+
+// The unbound variable errors are located at the expressions using the variables,
+// since the variables do not keep their positions. Move the error to the first
+// occurrence of the variable in the code that follows that position.
+func atVariable(err error, chunks []chunk) error {
+	unbound, ok := errors.Cause(err).(errors.Unbound)
+	if !ok {
+		return err
+	}
+	located, _ := err.(errors.Located)
+	from := located.Pos
+	for _, c := range chunks {
+		tokens, lexErr := lexer.TokenizeAt(c.code, c.start)
+		if lexErr != nil {
+			return err
+		}
+		for _, t := range tokens {
+			if t.Type != lexer.Variable || t.Value != unbound.Name || isBefore(t.Pos, from) {
+				continue
+			}
+			if located.Err == nil {
+				return errors.At(err, t.Pos)
+			}
+			located.Pos = t.Pos
+			return located
+		}
+	}
+	return err
+}
+
+// The position `a` precedes the valid position `b`, or it is in a different file.
+func isBefore(a, b Pos) bool {
+	if !b.IsValid() {
+		return false
+	}
+	if a.File != b.File {
+		return true
 	}
+	return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
 }
@@ -0,0 +1,235 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	. "github.com/twolodzko/goer/types"
+)
+
+// Line width used by the `~p` directive when the width is not given.
+const prettyWidth = 80
+
+// format/2
+func format(args []Expr, _ *envir.Env, pid pids.Pid) (Expr, error) {
+	if len(args) != 2 {
+		return nil, errors.WrongNumberArgs{}
+	}
+	s, err := formatString(args[0], args[1], pid)
+	if err != nil {
+		return nil, err
+	}
+	return String(s), nil
+}
+
+// printf/2
+func printf(args []Expr, _ *envir.Env, pid pids.Pid) (Expr, error) {
+	if len(args) != 2 {
+		return nil, errors.WrongNumberArgs{}
+	}
+	s, err := formatString(args[0], args[1], pid)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Print(s)
+	return Atom("ok"), nil
+}
+
+// Directive of the format string in the `~[Width][.Precision[.Pad]]Control` form.
+type directive struct {
+	width     int
+	left      bool // negative width
+	precision int  // -1 if not given
+	pad       rune
+	control   rune
+}
+
+// Format the arguments according to the `fmtStr` format string, like Erlang's `io:format`.
+func formatString(fmtStr, args Expr, pid pids.Pid) (string, error) {
+	str, ok := fmtStr.(String)
+	if !ok {
+		return "", errors.NotString{fmtStr}
+	}
+	list, ok := args.(List)
+	if !ok {
+		return "", errors.NotList{args}
+	}
+	values := list.Values
+
+	var b strings.Builder
+	runes := []rune(str)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '~' {
+			b.WriteRune(runes[i])
+			continue
+		}
+
+		d, n, ok := parseDirective(runes[i+1:])
+		if !ok {
+			return "", errors.BadFormat{fmtStr, "invalid directive"}
+		}
+		i += n
+
+		switch d.control {
+		case '~':
+			b.WriteRune('~')
+			continue
+		case 'n':
+			b.WriteRune('\n')
+			continue
+		}
+
+		if len(values) == 0 {
+			return "", errors.BadFormat{fmtStr, "not enough arguments"}
+		}
+		// the field is padded to the width, so it is checked before the padding is allocated
+		if d.control != 'p' {
+			if err := stopped(pid.Fits(1 + b.Len()/8 + d.width/8)); err != nil {
+				return "", err
+			}
+		}
+		s, err := formatValue(d, values[0])
+		if err != nil {
+			return "", err
+		}
+		values = values[1:]
+		b.WriteString(s)
+	}
+
+	if len(values) > 0 {
+		return "", errors.BadFormat{fmtStr, "too many arguments"}
+	}
+	return b.String(), nil
+}
+
+// Parse the directive following the "~" character, return it and the number of consumed runes.
+func parseDirective(runes []rune) (directive, int, bool) {
+	d := directive{precision: -1, pad: ' '}
+	i := 0
+
+	number := func() (int, bool) {
+		start := i
+		for i < len(runes) && '0' <= runes[i] && runes[i] <= '9' {
+			i++
+		}
+		if i == start {
+			return 0, false
+		}
+		n, err := strconv.Atoi(string(runes[start:i]))
+		return n, err == nil
+	}
+
+	if i < len(runes) && runes[i] == '-' {
+		d.left = true
+		i++
+	}
+	d.width, _ = number()
+	if i < len(runes) && runes[i] == '.' {
+		i++
+		if n, ok := number(); ok {
+			d.precision = n
+		}
+		if i < len(runes) && runes[i] == '.' {
+			i++
+			if i >= len(runes) {
+				return d, i, false
+			}
+			d.pad = runes[i]
+			i++
+		}
+	}
+	if i >= len(runes) {
+		return d, i, false
+	}
+	d.control = runes[i]
+	return d, i + 1, true
+}
+
+// Format the value according to the directive.
+func formatValue(d directive, val Expr) (string, error) {
+	var s string
+	switch d.control {
+	case 'p':
+		width := prettyWidth
+		if d.width > 0 {
+			width = d.width
+		}
+		// the width is the width of the line, not the field
+		return Pretty(val, width), nil
+	case 'w':
+		s = fmt.Sprint(val)
+	case 's':
+		str, err := toChars(val)
+		if err != nil {
+			return "", err
+		}
+		if d.precision >= 0 && d.precision < utf8.RuneCountInString(str) {
+			str = string([]rune(str)[:d.precision])
+		}
+		s = str
+	case 'b', 'B':
+		num, ok := val.(Int)
+		if !ok {
+			return "", errors.NotNumber{val}
+		}
+		base := 10
+		if d.precision >= 0 {
+			base = d.precision
+		}
+		if base < 2 || base > 36 {
+			return "", errors.BadFormat{String(fmt.Sprintf("~.%db", base)), "invalid base"}
+		}
+		// like in Erlang, ~b uses the lowercase letters for the digits, and ~B the uppercase ones
+		s = strconv.FormatInt(int64(num), base)
+		if d.control == 'B' {
+			s = strings.ToUpper(s)
+		}
+	case 'c':
+		num, ok := val.(Int)
+		if !ok {
+			return "", errors.NotNumber{val}
+		}
+		s = string(rune(num))
+	default:
+		return "", errors.BadFormat{String("~" + string(d.control)), "unknown control character"}
+	}
+	return padded(s, d), nil
+}
+
+// Convert the string, atom, binary, or a list of characters to a Go string.
+func toChars(val Expr) (string, error) {
+	switch val := val.(type) {
+	case String:
+		return string(val), nil
+	case Atom:
+		return string(val), nil
+	case Binary:
+		return string(val), nil
+	case List:
+		s, err := charsToString(val)
+		if err != nil {
+			return "", err
+		}
+		return string(s.(String)), nil
+	default:
+		return "", errors.NotString{val}
+	}
+}
+
+// Pad the string to the width of the field, by default it is right-justified.
+func padded(s string, d directive) string {
+	n := d.width - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return s
+	}
+	padding := strings.Repeat(string(d.pad), n)
+	if d.left {
+		return s + padding
+	}
+	return padding + s
+}
@@ -1,15 +1,15 @@
 package core
 
 import (
-	"reflect"
-
 	"github.com/twolodzko/goer/core/envir"
 	"github.com/twolodzko/goer/core/errors"
 	"github.com/twolodzko/goer/core/pids"
 	. "github.com/twolodzko/goer/types"
 )
 
-// The Erlang's match (=) operation.
+// The Erlang's match (=) operation, both sides are the expressions. The variables on
+// either side are bound, the containers written on both sides are matched element by
+// element, and the other expressions are evaluated and matched by their values.
 func match(lhs, rhs Expr, env *envir.Env, pid pids.Pid) error {
 	var (
 		final bool
@@ -26,25 +26,25 @@ func match(lhs, rhs Expr, env *envir.Env, pid pids.Pid) error {
 		return err
 	}
 
-	if reflect.TypeOf(lhs) == reflect.TypeOf(rhs) {
-		switch lhs := lhs.(type) {
-		case List:
-			rhs := rhs.(List)
+	switch lhs := lhs.(type) {
+	case List:
+		if rhs, ok := rhs.(List); ok && lhs.Len() == rhs.Len() {
 			return matchAll(lhs.Values, rhs.Values, env, pid)
-		case Tuple:
-			rhs := rhs.(Tuple)
+		}
+	case Tuple:
+		if rhs, ok := rhs.(Tuple); ok && len(lhs.Values) == len(rhs.Values) {
 			return matchAll(lhs.Values, rhs.Values, env, pid)
-		default:
-			if lhs == rhs {
-				return nil
-			}
+		}
+	default:
+		if Equal(lhs, rhs) {
+			return nil
 		}
 	}
 
 	return errors.NoMatch{lhs, rhs}
 }
 
-// Try matching value against key, otherwise evaluate the key.
+// Try matching value against key, otherwise evaluate the key and match the value against it.
 // If the key is a container (List or Tuple), you need to handle it separately.
 func evalMatch(key, val Expr, env *envir.Env, pid pids.Pid) (Expr, bool, error) {
 	var err error
@@ -60,10 +60,34 @@ func evalMatch(key, val Expr, env *envir.Env, pid pids.Pid) (Expr, bool, error)
 			return key, true, err
 		}
 		return key, true, env.TrySet(name, rhs)
+	case local:
+		if _, ok := val.(Dummy); ok {
+			return key, true, nil
+		}
+		rhs, err := Eval(val, env, pid)
+		if err != nil {
+			return key, true, err
+		}
+		return key, true, bindLocal(name, rhs, env)
+	case Atom:
+		// atoms match by their names, not by the functions named by them
+	case BitString:
+		rhs, err := Eval(val, env, pid)
+		if err != nil {
+			return key, true, err
+		}
+		return key, true, matchBinary(name, rhs, env, pid)
+	case Record:
+		// records are matched as tuples
+		key, err = recordPattern(name, env)
 	case List, Tuple:
 		// handle recursive case separately
 	default:
-		key, err = Eval(key, env, pid)
+		value, err := Eval(key, env, pid)
+		if err != nil {
+			return key, true, err
+		}
+		return key, true, matchValue(val, value, env, pid)
 	}
 	return key, false, err
 }
@@ -81,3 +105,143 @@ func matchAll(lhs, rhs []Expr, env *envir.Env, pid pids.Pid) error {
 	}
 	return nil
 }
+
+// Match the pattern against the value that was already evaluated, like the arguments
+// of the function or the received message. The value is not evaluated again, since
+// the atoms in it would evaluate to the functions named by them.
+func matchValue(pattern, val Expr, env *envir.Env, pid pids.Pid) error {
+	switch p := pattern.(type) {
+	case Dummy:
+		return nil
+	case Variable:
+		return env.TrySet(p, val)
+	case local:
+		return bindLocal(p, val, env)
+	case Atom, QuotedAtom:
+		if matchConst(p, val, env) {
+			return nil
+		}
+	case BitString:
+		return matchBinary(p, val, env, pid)
+	case Record:
+		tuple, err := recordPattern(p, env)
+		if err != nil {
+			return err
+		}
+		return matchValue(tuple, val, env, pid)
+	case List:
+		if val, ok := val.(List); ok && p.Len() == val.Len() {
+			return matchArgs(p.Values, val.Values, env, pid)
+		}
+	case Tuple:
+		if val, ok := val.(Tuple); ok && len(p.Values) == len(val.Values) {
+			return matchArgs(p.Values, val.Values, env, pid)
+		}
+	default:
+		expected, err := Eval(pattern, env, pid)
+		if err != nil {
+			return err
+		}
+		if Equal(expected, val) {
+			return nil
+		}
+	}
+	return errors.NoMatch{pattern, val}
+}
+
+// Match the patterns against the evaluated values, element by element.
+func matchArgs(patterns, vals []Expr, env *envir.Env, pid pids.Pid) error {
+	if len(patterns) != len(vals) {
+		return errors.NoMatch{patterns, vals}
+	}
+	for i := range patterns {
+		if err := matchValue(patterns[i], vals[i], env, pid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check if the expression has no dummies nor unbound variables, so it can be evaluated.
+func isBound(expr Expr, env *envir.Env) bool {
+	switch val := expr.(type) {
+	case Dummy:
+		return false
+	case Variable:
+		_, err := env.Get(val)
+		return err == nil
+	case local:
+		return env.Load(val.depth, val.slot) != nil
+	case List:
+		return allBound(val.Values, env)
+	case Tuple:
+		return allBound(val.Values, env)
+	case BitString:
+		for _, segment := range val.Segments {
+			if !isBound(segment.Value, env) || (segment.Size != nil && !isBound(segment.Size, env)) {
+				return false
+			}
+		}
+		return true
+	case Record:
+		if val.Expr != nil && !isBound(val.Expr, env) {
+			return false
+		}
+		for _, field := range val.Fields {
+			if !isBound(field.Value, env) {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+func allBound(exprs []Expr, env *envir.Env) bool {
+	for _, expr := range exprs {
+		if !isBound(expr, env) {
+			return false
+		}
+	}
+	return true
+}
+
+// Atoms match by their names, and the functions named by them, since the atom passed
+// as the value, like `max` in `f(max)`, evaluates to the function it names. The quoted
+// atoms are always atoms, so they match only the same atoms.
+func matchConst(pattern, val Expr, env *envir.Env) bool {
+	if quoted, ok := pattern.(QuotedAtom); ok {
+		return Equal(Atom(quoted), val)
+	}
+	if Equal(pattern, val) {
+		return true
+	}
+	atom, ok := pattern.(Atom)
+	if !ok {
+		return false
+	}
+	switch val.(type) {
+	case Fun, *Closure, buildIn:
+		fun, err := env.Get(atom)
+		return err == nil && Equal(fun, val)
+	default:
+		return false
+	}
+}
+
+// Bind the resolved variable in the frame of the function call. The captured
+// variables belong to the enclosing function, so they are only compared.
+func bindLocal(v local, val Expr, env *envir.Env) error {
+	if v.depth == 0 {
+		return env.TryBind(v.slot, Variable(v.name), val)
+	}
+	prev := env.Load(v.depth, v.slot)
+	if prev == nil {
+		return errors.Unbound{v.name}
+	}
+	if !Equal(prev, val) {
+		return errors.NoMatch{Variable(v.name), val}
+	}
+	return nil
+}
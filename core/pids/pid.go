@@ -1,8 +1,12 @@
 package pids
 
 import (
+	"cmp"
+	"context"
 	"fmt"
+	"sync/atomic"
 
+	"github.com/twolodzko/goer/core/errors"
 	"github.com/twolodzko/goer/types"
 )
 
@@ -10,37 +14,227 @@ import (
 // Internally, it is Go's channel.
 // Pid is a channel for communication between processes.
 type Pid struct {
+	mailbox *mailbox
+	id      uint64
+	// the context stopping the process, nil if it runs until it finishes
+	proc *process
+}
+
+type mailbox struct {
 	channel chan types.Expr
+	// closed when the process finishes, so the messages are not delivered anymore
+	closed chan struct{}
+	// the messages sent, but not received yet
+	pending atomic.Int64
+}
+
+type process struct {
+	ctx   context.Context
+	group *Group
+}
+
+// Group of the processes that are stopped together, the processes
+// spawned by its members join the group.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	limits Limits
+	// the resources used by the processes
+	processes, reductions, memory atomic.Int64
 }
 
+// The limits of the resources used by the group of processes, zero means no limit.
+type Limits struct {
+	// the spawned processes running at once
+	Processes int
+	// the function calls made by all the processes
+	Reductions int
+	// the messages waiting in the mailbox of the process
+	Mailbox int
+	// the words taken by the terms created by all the processes
+	Memory int
+}
+
+// Create the group of processes stopped when the context is done.
+func NewGroup(ctx context.Context, limits Limits) *Group {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{ctx: ctx, cancel: cancel, limits: limits}
+}
+
+// Stop all the processes of the group, the cause is the error they report.
+func (g *Group) Stop(cause error) {
+	g.cancel(cause)
+}
+
+// The context of the group, it is done when the group is stopped.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Start counting the reductions used by the processes from zero, and the memory
+// from the words that are still taken by the terms kept from before.
+func (g *Group) Reset(retained int) {
+	g.reductions.Store(0)
+	g.memory.Store(int64(retained))
+}
+
+// The limit was exceeded, so the group is stopped.
+func (g *Group) exceeded(err error) error {
+	g.Stop(err)
+	return err
+}
+
+// Counter used to give the pids their order.
+var lastId atomic.Uint64
+
 // Initialize new pid.
 func NewPid() Pid {
-	msg := make(chan types.Expr)
-	return Pid{msg}
+	return Pid{&mailbox{channel: make(chan types.Expr), closed: make(chan struct{})}, lastId.Add(1), nil}
+}
+
+// The same process, stopped also when the context is done, it joins the group.
+// The context should be done when the group is stopped.
+func (p Pid) WithContext(ctx context.Context, group *Group) Pid {
+	p.proc = &process{ctx, group}
+	return p
+}
+
+// Initialize the pid of the process spawned by this one, it joins the same group.
+// It should be closed when the process finishes.
+func (p Pid) Spawn() (Pid, error) {
+	pid := NewPid()
+	if p.proc == nil {
+		return pid, nil
+	}
+	g := p.proc.group
+	if n := g.processes.Add(1); g.limits.Processes > 0 && n > int64(g.limits.Processes) {
+		g.processes.Add(-1)
+		return Pid{}, g.exceeded(errors.ProcessLimit{g.limits.Processes})
+	}
+	pid.proc = &process{g.ctx, g}
+	return pid, nil
+}
+
+// The group of the process, nil if it does not belong to any.
+func (p Pid) Group() *Group {
+	if p.proc == nil {
+		return nil
+	}
+	return p.proc.group
+}
+
+// The channel closed when the process should stop, nil if it is never stopped.
+func (p Pid) Done() <-chan struct{} {
+	if p.proc == nil {
+		return nil
+	}
+	return p.proc.ctx.Done()
+}
+
+// The reason why the process should stop, or nil if it should keep running.
+func (p Pid) Err() error {
+	if p.proc == nil {
+		return nil
+	}
+	select {
+	case <-p.proc.ctx.Done():
+		return context.Cause(p.proc.ctx)
+	default:
+		return nil
+	}
+}
+
+// Count the reduction made by the process, return the reason why it should stop,
+// or nil if it should keep running.
+func (p Pid) Tick() error {
+	if p.proc == nil {
+		return nil
+	}
+	if err := p.Err(); err != nil {
+		return err
+	}
+	g := p.proc.group
+	if g.limits.Reductions > 0 && g.reductions.Add(1) > int64(g.limits.Reductions) {
+		return g.exceeded(errors.ReductionLimit{g.limits.Reductions})
+	}
+	return nil
+}
+
+// Count the words of memory taken by the term created by the process.
+func (p Pid) Alloc(size int) error {
+	if p.proc == nil {
+		return nil
+	}
+	g := p.proc.group
+	if g.limits.Memory > 0 && g.memory.Add(int64(size)) > int64(g.limits.Memory) {
+		return g.exceeded(errors.MemoryLimit{g.limits.Memory})
+	}
+	return nil
+}
+
+// Check if the term of the size, in words, can be created without exceeding the limit
+// of the memory, before it is created. It is counted with `Alloc` when it was created.
+func (p Pid) Fits(size int) error {
+	if p.proc == nil {
+		return nil
+	}
+	g := p.proc.group
+	if g.limits.Memory > 0 && int64(size) > int64(g.limits.Memory)-g.memory.Load() {
+		return g.exceeded(errors.MemoryLimit{g.limits.Memory})
+	}
+	return nil
 }
 
 // Messages to receive messages.
 func (p Pid) Messages() <-chan types.Expr {
-	return p.channel
+	return p.mailbox.channel
 }
 
-// Send the message to pid.
-func (p Pid) Send(msg types.Expr) {
+// Send the message to pid. When its mailbox is full, the group of the process is stopped.
+func (p Pid) Send(msg types.Expr) error {
+	n := p.mailbox.pending.Add(1)
+	if p.proc != nil {
+		g := p.proc.group
+		if g.limits.Mailbox > 0 && n > int64(g.limits.Mailbox) {
+			p.mailbox.pending.Add(-1)
+			return g.exceeded(errors.MailboxLimit{g.limits.Mailbox})
+		}
+	}
 	go func() {
-		defer func() { recover() }()
-		p.channel <- msg
+		select {
+		case p.mailbox.channel <- msg:
+		case <-p.mailbox.closed:
+		}
+		p.mailbox.pending.Add(-1)
 	}()
+	return nil
 }
 
-// Close the channel opened for the process.
+// Close the mailbox opened for the process, the messages sent to it are dropped.
 func (p Pid) Close() {
-	close(p.channel)
+	close(p.mailbox.closed)
+	if p.proc != nil {
+		p.proc.group.processes.Add(-1)
+	}
 }
 
 func (this Pid) Equal(other Pid) bool {
-	return this.channel == other.channel
+	return this.mailbox == other.mailbox
+}
+
+func (Pid) Order() int {
+	return types.OrderPid
+}
+
+// Pids are ordered by their creation time.
+func (this Pid) Compare(other types.Expr) int {
+	return cmp.Compare(this.id, other.(Pid).id)
+}
+
+func (p Pid) Hash() uint64 {
+	return p.id
 }
 
 func (p Pid) String() string {
-	return fmt.Sprintf("<%v>", p.channel)
+	return fmt.Sprintf("<%v>", p.mailbox.channel)
 }
@@ -0,0 +1,474 @@
+package core
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	. "github.com/twolodzko/goer/types"
+)
+
+const (
+	// the number of the cases checked by `prop_forall`
+	defaultCases = 100
+	// the maximal size of the generated values, the size grows with the cases
+	maxSize = 100
+	// the number of the values `gen_such_that` draws before giving up
+	maxTries = 100
+	// the number of the times the property is checked while shrinking the counterexample
+	maxShrinkRuns = 1000
+)
+
+// The generator of the random values for the property-based tests. The values are
+// built from the choices drawn from the source, so they can be replayed, and the
+// smaller choices lead to the simpler values.
+type Generator struct {
+	name string
+	args []Expr
+	draw func(*source) (Expr, error)
+}
+
+func (*Generator) Order() int {
+	return OrderFun
+}
+
+// Generators are ordered by their identity, after the build-in functions
+// and before the functions written in the code.
+func (gen *Generator) Compare(other Expr) int {
+	switch other := other.(type) {
+	case *Generator:
+		return comparePointers(gen, other)
+	case Fun, *Closure:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func (gen *Generator) Hash() uint64 {
+	return uint64(pointer(gen))
+}
+
+func (gen *Generator) String() string {
+	args := make([]string, len(gen.args))
+	for i, arg := range gen.args {
+		args[i] = fmt.Sprint(arg)
+	}
+	return fmt.Sprintf("%s(%s)", gen.name, strings.Join(args, ", "))
+}
+
+// The choices the values are generated from. They are replayed from the prefix,
+// past its end they are random, or zeros when shrinking.
+type source struct {
+	prefix  []uint64
+	choices []uint64
+	random  *rand.Rand
+	size    int
+	env     *envir.Env
+	pid     pids.Pid
+}
+
+// Draw the choice from [0, n), the full range when n is zero.
+func (s *source) choose(n uint64) uint64 {
+	var c uint64
+	switch i := len(s.choices); {
+	case i < len(s.prefix):
+		c = min(s.prefix[i], n-1)
+	case s.random != nil && n == 0:
+		c = s.random.Uint64()
+	case s.random != nil:
+		c = s.random.Uint64N(n)
+	}
+	s.choices = append(s.choices, c)
+	return c
+}
+
+// Draw the value: the generators generate it, the tuples and lists are
+// generated element by element, other terms are constants.
+func (s *source) draw(expr Expr) (Expr, error) {
+	switch val := expr.(type) {
+	case *Generator:
+		return val.draw(s)
+	case Tuple:
+		values, err := s.drawAll(val.Values)
+		if err != nil {
+			return nil, err
+		}
+		return Tuple{values}, nil
+	case List:
+		values, err := s.drawAll(val.Values)
+		if err != nil {
+			return nil, err
+		}
+		return List{values}, nil
+	default:
+		return expr, nil
+	}
+}
+
+func (s *source) drawAll(exprs []Expr) ([]Expr, error) {
+	values := make([]Expr, len(exprs))
+	for i, expr := range exprs {
+		val, err := s.draw(expr)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+	return values, nil
+}
+
+// Draw the values until the zero choice, or the size is reached,
+// so removing the choices shortens the sequence.
+func (s *source) drawMany(size int, draw func() (Expr, error)) ([]Expr, error) {
+	var values []Expr
+	for len(values) < size && s.choose(8) != 0 {
+		val, err := draw()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, val)
+	}
+	return values, nil
+}
+
+// The value in [lo, hi], the choice zero is the origin, and the larger choices
+// alternate around it, going further away from it.
+func towards(origin, lo, hi Int, c uint64) Int {
+	below, above := uint64(origin)-uint64(lo), uint64(hi)-uint64(origin)
+	m := min(below, above)
+	switch {
+	case c <= 2*m && c%2 == 1:
+		return origin + Int((c+1)/2)
+	case c <= 2*m:
+		return origin - Int(c/2)
+	case above > below:
+		return origin + Int(c-m)
+	default:
+		return origin - Int(c-m)
+	}
+}
+
+// gen_int/0 and gen_int/2, the integers limited by the size, or from the range,
+// they shrink towards zero.
+func genInt(args []Expr, _ *envir.Env, _ pids.Pid) (Expr, error) {
+	switch len(args) {
+	case 0:
+		return &Generator{"gen_int", args, func(s *source) (Expr, error) {
+			size := Int(s.size)
+			return towards(0, -size, size, s.choose(uint64(2*size+1))), nil
+		}}, nil
+	case 2:
+		lo, ok := args[0].(Int)
+		if !ok {
+			return nil, errors.NotNumber{args[0]}
+		}
+		hi, ok := args[1].(Int)
+		if !ok {
+			return nil, errors.NotNumber{args[1]}
+		}
+		if lo > hi {
+			return nil, errors.New("invalid range")
+		}
+		origin := min(max(0, lo), hi)
+		return &Generator{"gen_int", args, func(s *source) (Expr, error) {
+			return towards(origin, lo, hi, s.choose(uint64(hi)-uint64(lo)+1)), nil
+		}}, nil
+	default:
+		return nil, errors.WrongNumberArgs{}
+	}
+}
+
+// gen_atom/0, the atoms made of the lowercase letters, they shrink towards `a`.
+// The atoms naming the functions evaluate to them, so they are not generated.
+func genAtom(args []Expr, _ *envir.Env, _ pids.Pid) (Expr, error) {
+	if len(args) > 0 {
+		return nil, errors.WrongNumberArgs{}
+	}
+	letter := func(s *source) byte {
+		return 'a' + byte(s.choose(26))
+	}
+	return &Generator{"gen_atom", args, func(s *source) (Expr, error) {
+		for range maxTries {
+			name := []byte{letter(s)}
+			rest, _ := s.drawMany(min(s.size, 8), func() (Expr, error) {
+				return Int(letter(s)), nil
+			})
+			for _, c := range rest {
+				name = append(name, byte(c.(Int)))
+			}
+			if _, err := s.env.Get(Atom(name)); err != nil {
+				return Atom(name), nil
+			}
+		}
+		return nil, errors.New("gen_atom found no atom that is not a function name in %d tries", maxTries)
+	}}, nil
+}
+
+// gen_string/0, the strings of the printable ASCII characters, they shrink
+// towards the empty string, and the characters towards `a`.
+func genString(args []Expr, _ *envir.Env, _ pids.Pid) (Expr, error) {
+	if len(args) > 0 {
+		return nil, errors.WrongNumberArgs{}
+	}
+	return &Generator{"gen_string", args, func(s *source) (Expr, error) {
+		chars, _ := s.drawMany(s.size, func() (Expr, error) {
+			return Int(' ' + (s.choose(95)+65)%95), nil
+		})
+		var b strings.Builder
+		for _, c := range chars {
+			b.WriteByte(byte(c.(Int)))
+		}
+		return String(b.String()), nil
+	}}, nil
+}
+
+// gen_list/1, the lists of the values from the generator, they shrink
+// towards the shorter lists.
+func genList(gen Expr) (Expr, error) {
+	return &Generator{"gen_list", []Expr{gen}, func(s *source) (Expr, error) {
+		values, err := s.drawMany(s.size, func() (Expr, error) {
+			return s.draw(gen)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return List{values}, nil
+	}}, nil
+}
+
+// gen_tuple/1, the tuples of the values from the list of generators.
+func genTuple(gens Expr) (Expr, error) {
+	list, ok := gens.(List)
+	if !ok {
+		return nil, errors.NotList{gens}
+	}
+	return &Generator{"gen_tuple", []Expr{gens}, func(s *source) (Expr, error) {
+		values, err := s.drawAll(list.Values)
+		if err != nil {
+			return nil, err
+		}
+		return Tuple{values}, nil
+	}}, nil
+}
+
+// gen_bind/2, generate the value and pass it to the function, that returns
+// the generator of the final value, or the value itself.
+func genBind(gen, fun Expr) (Expr, error) {
+	return &Generator{"gen_bind", []Expr{gen, fun}, func(s *source) (Expr, error) {
+		val, err := s.draw(gen)
+		if err != nil {
+			return nil, err
+		}
+		next, err := Apply(fun, []Expr{val}, s.env, s.pid)
+		if err != nil {
+			return nil, err
+		}
+		return s.draw(next)
+	}}, nil
+}
+
+// gen_such_that/2, the values from the generator for which the predicate is true.
+func genSuchThat(gen, pred Expr) (Expr, error) {
+	return &Generator{"gen_such_that", []Expr{gen, pred}, func(s *source) (Expr, error) {
+		for range maxTries {
+			val, err := s.draw(gen)
+			if err != nil {
+				return nil, err
+			}
+			ok, err := Apply(pred, []Expr{val}, s.env, s.pid)
+			if err != nil {
+				return nil, err
+			}
+			if ok == Bool(true) {
+				return val, nil
+			}
+		}
+		return nil, errors.New("gen_such_that found no value satisfying the predicate in %d tries", maxTries)
+	}}, nil
+}
+
+// prop_forall/2 and prop_forall/3, check if the property holds for the generated values.
+// The property fails when it returns false or throws an error. The failing value
+// is shrunk to the simplest one for which it still fails. The options are
+// `{cases, N}` and `{seed, S}`, the random seed is reported when it fails.
+func propForall(args []Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
+	var options []Expr
+	switch len(args) {
+	case 2:
+	case 3:
+		list, ok := args[2].(List)
+		if !ok {
+			return nil, errors.NotList{args[2]}
+		}
+		options = list.Values
+	default:
+		return nil, errors.WrongNumberArgs{}
+	}
+	gen, prop := args[0], args[1]
+
+	cases, seed := defaultCases, rand.Int64()
+	for _, option := range options {
+		tuple, ok := option.(Tuple)
+		if !ok || len(tuple.Values) != 2 {
+			return nil, errors.New("invalid option %v", option)
+		}
+		val, ok := tuple.Values[1].(Int)
+		if !ok {
+			return nil, errors.NotNumber{tuple.Values[1]}
+		}
+		switch tuple.Values[0] {
+		case Atom("cases"):
+			cases = int(val)
+		case Atom("seed"):
+			seed = int64(val)
+		default:
+			return nil, errors.New("invalid option %v", option)
+		}
+	}
+
+	p := property{gen, prop, env, pid}
+	random := rand.New(rand.NewPCG(uint64(seed), 0))
+	for i := range cases {
+		s := &source{random: random, size: min(i+1, maxSize), env: env, pid: pid}
+		c, err := p.check(s)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			original := c.value
+			if err := p.shrink(c, s.size); err != nil {
+				return nil, err
+			}
+			return nil, errors.PropertyFailed{c.value, original, seed, i + 1, c.reason}
+		}
+	}
+	return Atom("ok"), nil
+}
+
+type property struct {
+	gen, fun Expr
+	env      *envir.Env
+	pid      pids.Pid
+}
+
+// The value that fails the property, the choices it was generated from,
+// and the error thrown by the property, nil if it returned false.
+type counterexample struct {
+	choices []uint64
+	value   Expr
+	reason  error
+}
+
+// Generate the value and check the property, returns the counterexample if it fails.
+// The errors thrown by the generators and the ones that cannot be caught are returned.
+func (p property) check(s *source) (*counterexample, error) {
+	if err := stopped(p.pid.Err()); err != nil {
+		return nil, err
+	}
+	val, err := s.draw(p.gen)
+	if err != nil {
+		return nil, err
+	}
+	result, err := Apply(p.fun, []Expr{val}, p.env, p.pid)
+	switch {
+	case uncatchable(err):
+		return nil, err
+	case err != nil:
+		return &counterexample{s.choices, val, err}, nil
+	case result == Bool(false):
+		return &counterexample{s.choices, val, nil}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Shrink the counterexample by deleting, zeroing, and reducing its choices, while the
+// property still fails.
+func (p property) shrink(best *counterexample, size int) error {
+	runs := 0
+	// replay the choices, they replace the counterexample if it still fails and they are simpler
+	try := func(choices []uint64) (bool, error) {
+		runs++
+		c, err := p.check(&source{prefix: choices, size: size, env: p.env, pid: p.pid})
+		if uncatchable(err) {
+			return false, err
+		}
+		if err != nil || c == nil || !simpler(c.choices, best.choices) {
+			return false, nil
+		}
+		*best = *c
+		return true, nil
+	}
+
+	for improved := true; improved && runs < maxShrinkRuns; {
+		improved = false
+		for _, k := range []int{8, 4, 2, 1} {
+			for i := len(best.choices) - k; i >= 0 && runs < maxShrinkRuns; i-- {
+				if i+k > len(best.choices) {
+					continue
+				}
+				ok, err := try(slices.Concat(best.choices[:i], best.choices[i+k:]))
+				if err != nil {
+					return err
+				}
+				improved = improved || ok
+			}
+		}
+		for i := 0; i < len(best.choices) && runs < maxShrinkRuns; i++ {
+			if best.choices[i] == 0 {
+				continue
+			}
+			choices := slices.Clone(best.choices)
+			choices[i] = 0
+			ok, err := try(choices)
+			if err != nil {
+				return err
+			}
+			improved = improved || ok
+		}
+		for i := 0; i < len(best.choices) && runs < maxShrinkRuns; i++ {
+			// binary search for the smallest choice that still fails
+			var lo uint64
+			for i < len(best.choices) && lo < best.choices[i] && runs < maxShrinkRuns {
+				choices := slices.Clone(best.choices)
+				choices[i] = lo + (choices[i]-lo)/2
+				ok, err := try(choices)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					lo = choices[i] + 1
+				}
+				improved = improved || ok
+			}
+			// the values are not always ordered by the choices, like the integers
+			// alternating around zero, so try the close ones with the same sign
+			for _, d := range []uint64{1, 2, 4} {
+				if i >= len(best.choices) || best.choices[i] < d || runs >= maxShrinkRuns {
+					break
+				}
+				choices := slices.Clone(best.choices)
+				choices[i] -= d
+				ok, err := try(choices)
+				if err != nil {
+					return err
+				}
+				improved = improved || ok
+			}
+		}
+	}
+	return nil
+}
+
+// The shorter choices are simpler, the ones of the same length are compared lexicographically.
+func simpler(lhs, rhs []uint64) bool {
+	if len(lhs) != len(rhs) {
+		return len(lhs) < len(rhs)
+	}
+	return slices.Compare(lhs, rhs) < 0
+}
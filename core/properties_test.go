@@ -0,0 +1,182 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	. "github.com/twolodzko/goer/types"
+)
+
+func TestTowards(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		origin, lo, hi Int
+		expected       []Int
+	}{
+		{0, -2, 2, []Int{0, 1, -1, 2, -2}},
+		{0, -1, 3, []Int{0, 1, -1, 2, 3}},
+		{0, -3, 1, []Int{0, 1, -1, -2, -3}},
+		{5, 5, 8, []Int{5, 6, 7, 8}},
+		{-5, -8, -5, []Int{-5, -6, -7, -8}},
+	}
+
+	for _, tt := range testCases {
+		var result []Int
+		for c := range uint64(tt.hi - tt.lo + 1) {
+			result = append(result, towards(tt.origin, tt.lo, tt.hi, c))
+		}
+		if !cmp.Equal(result, tt.expected) {
+			t.Errorf("for %d in [%d, %d] expected %v, got %v", tt.origin, tt.lo, tt.hi, tt.expected, result)
+		}
+	}
+}
+
+func TestForall(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			testCases := []struct {
+				input    string
+				expected Expr
+			}{
+				{"prop_forall(gen_list(gen_int()), fun(L) -> rev(rev(L)) == L end).", Atom("ok")},
+				{"prop_forall(gen_int(-3, 3), fun(X) -> X >= -3 andalso X =< 3 end).", Atom("ok")},
+				{"prop_forall({gen_atom(), gen_string()}, fun({A, S}) -> is_atom(A) andalso is_str(S) end).", Atom("ok")},
+				{"prop_forall(gen_such_that(gen_int(), fun(X) -> X > 0 end), fun(X) -> X > 0 end, [{cases, 10}]).", Atom("ok")},
+				{"prop_forall(gen_int(), fun(X) -> assert_equal(X, X) end, [{seed, 42}]).", Atom("ok")},
+				{"fun a() -> ok end, prop_forall(gen_atom(), fun(A) -> is_atom(A) end).", Atom("ok")},
+				{"str(gen_list(gen_int(0, 9))).", String("gen_list(gen_int(0, 9))")},
+				{"{bind, forall, such_that}.", Tuple{[]Expr{Atom("bind"), Atom("forall"), Atom("such_that")}}},
+			}
+
+			for _, tt := range testCases {
+				func() {
+					env := NewEnv()
+					pid := pids.NewPid()
+					defer pid.Close()
+
+					result, err := engine.eval(tt.input, env, pid)
+					if err != nil {
+						t.Errorf("evaluating '%s' resulted in an error: %s", tt.input, err)
+					} else if !cmp.Equal(result, tt.expected) {
+						t.Errorf("evaluating '%s' returned %v while we expected %v", tt.input, result, tt.expected)
+					}
+				}()
+			}
+		})
+	}
+}
+
+func TestShrinking(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			testCases := []struct {
+				gen, prop string
+				expected  Expr
+			}{
+				{"gen_int()", "fun(X) -> X < 10 end", Int(10)},
+				{"gen_int(-100, 100)", "fun(X) -> X > -7 end", Int(-7)},
+				{"gen_int(5, 100)", "fun(X) -> X < 0 end", Int(5)},
+				{"gen_int(-3, 3)", "fun(X) -> 10 div X > -100 end", Int(0)},
+				{"gen_list(gen_int())", "fun(L) -> len(L) < 3 end", List{[]Expr{Int(0), Int(0), Int(0)}}},
+				{"gen_string()", "fun(S) -> len(S) < 2 end", String("aa")},
+				{"gen_atom()", "fun(A) -> len(str(A)) < 3 end", Atom("aaa")},
+				{"{gen_int(), gen_atom()}", "fun({N, _}) -> N < 3 end", Tuple{[]Expr{Int(3), Atom("a")}}},
+				{"gen_tuple([gen_int(0, 9), gen_int(0, 9)])", "fun({A, B}) -> A < 5 orelse B < 5 end", Tuple{[]Expr{Int(5), Int(5)}}},
+				{"gen_bind(gen_int(0, 10), fun(N) -> N * 2 end)", "fun(X) -> X < 7 end", Int(8)},
+				{"gen_bind(gen_int(1, 5), fun(N) -> {N, gen_int(0, N)} end)", "fun({_, X}) -> X < 2 end", Tuple{[]Expr{Int(2), Int(2)}}},
+				{"gen_such_that(gen_int(), fun(N) -> N rem 2 == 0 end)", "fun(X) -> X < 5 end", Int(6)},
+			}
+
+			for _, tt := range testCases {
+				func() {
+					env := NewEnv()
+					pid := pids.NewPid()
+					defer pid.Close()
+
+					input := "try prop_forall(" + tt.gen + ", " + tt.prop + ") " +
+						"catch error:{property_failed, [{counterexample, C}, _, _]} -> C end."
+					result, err := engine.eval(input, env, pid)
+					if err != nil {
+						t.Errorf("evaluating '%s' resulted in an error: %s", input, err)
+					} else if !cmp.Equal(result, tt.expected) {
+						t.Errorf("evaluating '%s' returned %v while we expected %v", input, result, tt.expected)
+					}
+				}()
+			}
+		})
+	}
+}
+
+func TestForallSeed(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range engines {
+		var errs []error
+		for range 2 {
+			env := NewEnv()
+			pid := pids.NewPid()
+			_, err := engine.eval("prop_forall(gen_list(gen_int()), fun(L) -> len(L) < 5 end, [{seed, 7}]).", env, pid)
+			pid.Close()
+			errs = append(errs, errors.Cause(err))
+		}
+		failed, ok := errs[0].(errors.PropertyFailed)
+		if !ok || failed.Seed != 7 {
+			t.Fatalf("%s: expected the property to fail with the seed, got %v", engine.name, errs[0])
+		}
+		if !cmp.Equal(errs[0], errs[1]) {
+			t.Errorf("%s: the same seed gave different results: %v and %v", engine.name, errs[0], errs[1])
+		}
+	}
+}
+
+func TestForallErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			testCases := []struct {
+				input string
+				err   error
+			}{
+				{"gen_int(2, 1).", errors.New("invalid range")},
+				{"gen_int(a, 1).", errors.NotNumber{Atom("a")}},
+				{"gen_tuple(1).", errors.NotList{Int(1)}},
+				{"prop_forall(gen_int(), fun(X) -> X end, ok).", errors.NotList{Atom("ok")}},
+				{"prop_forall(gen_int(), fun(X) -> X end, [{cases, x}]).", errors.NotNumber{Atom("x")}},
+				{"prop_forall(gen_int(), fun(X) -> X end, [{size, 1}]).", errors.New("invalid option {size,1}")},
+				{"prop_forall(gen_such_that(gen_int(), fun(_) -> false end), fun(X) -> true end).", errors.New("gen_such_that found no value satisfying the predicate in 100 tries")},
+				{"prop_forall(gen_bind(gen_int(), fun(X) -> error(boom) end), fun(X) -> true end).", errors.Error{Atom("boom")}},
+				{"prop_forall(gen_int(), fun(X) -> X < 1000 end, [{seed, 1}, {cases, 0}]), prop_forall(0, fun(X) -> X > 0 end, [{seed, 1}]).",
+					errors.PropertyFailed{Int(0), Int(0), 1, 1, nil}},
+				{"prop_forall(0, fun(X) -> 1 / X end, [{seed, 1}]).",
+					errors.PropertyFailed{Int(0), Int(0), 1, 1, errors.DivisionByZero{}}},
+			}
+
+			for _, tt := range testCases {
+				func() {
+					env := NewEnv()
+					pid := pids.NewPid()
+					defer pid.Close()
+
+					_, err := engine.eval(tt.input, env, pid)
+					err = errors.Cause(err)
+					if failed, ok := err.(errors.PropertyFailed); ok {
+						failed.Reason = errors.Cause(failed.Reason)
+						err = failed
+					}
+					if !cmp.Equal(err, tt.err) {
+						t.Errorf("evaluating '%s' should throw error: %s, but it thrown: %s", tt.input, tt.err, err)
+					}
+				}()
+			}
+		})
+	}
+}
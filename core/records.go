@@ -0,0 +1,202 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	. "github.com/twolodzko/goer/types"
+)
+
+// Records are stored in the environment under the names that are not valid
+// variable names or atoms, so they do not collide with them.
+func recordKey(name string) Atom {
+	return Atom("#" + name)
+}
+
+// Declare the record in the current environment.
+func declareRecord(decl RecordDecl, env *envir.Env) (Expr, error) {
+	key := string(recordKey(decl.Name))
+	if _, exists := env.Elems[key]; exists {
+		return nil, errors.New("record %v already exists", Atom(decl.Name))
+	}
+	seen := make(map[string]bool)
+	for _, field := range decl.Fields {
+		if seen[field.Name] {
+			return nil, errors.New("field %v declared twice in record %v", Atom(field.Name), Atom(decl.Name))
+		}
+		seen[field.Name] = true
+	}
+	env.Set(key, &declaredRecord{decl, env})
+	return Atom("ok"), nil
+}
+
+// The record declared by the evaluated code, the default values of its fields
+// are evaluated in the environment where it was declared.
+type declaredRecord struct {
+	RecordDecl
+	env *envir.Env
+}
+
+// Find the declaration of the record.
+func getRecord(name string, env *envir.Env) (RecordDecl, error) {
+	_, decl, err := findRecord(name, env)
+	return decl, err
+}
+
+// Find the record stored in the environment, and its declaration.
+func findRecord(name string, env *envir.Env) (Expr, RecordDecl, error) {
+	val, err := env.Get(recordKey(name))
+	if err != nil {
+		return nil, RecordDecl{}, errors.UndefinedRecord{name}
+	}
+	switch record := val.(type) {
+	case *declaredRecord:
+		return record, record.RecordDecl, nil
+	case *compiledRecord:
+		return record, record.RecordDecl, nil
+	default:
+		return nil, RecordDecl{}, errors.UndefinedRecord{name}
+	}
+}
+
+// Evaluate the default value of the field `i` of the record.
+func recordDefault(record Expr, i int, pid pids.Pid) (Expr, error) {
+	switch record := record.(type) {
+	case *declaredRecord:
+		return Eval(record.Fields[i].Value, record.env, pid)
+	case *compiledRecord:
+		return record.defaults[i].call(nil, pid)
+	default:
+		panic(fmt.Sprintf("%T is not a record", record))
+	}
+}
+
+// Index of the field in the tuple representing the record, the first element is the name.
+func fieldIndex(decl RecordDecl, field string) (int, error) {
+	for i, f := range decl.Fields {
+		if f.Name == field {
+			return i + 1, nil
+		}
+	}
+	return 0, errors.UndefinedField{decl.Name, field}
+}
+
+// Evaluate the record construction or update, the records are tuples tagged with their names.
+func evalRecord(record Record, env *envir.Env, pid pids.Pid) (Expr, error) {
+	declared, decl, err := findRecord(record.Name, env)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []Expr
+	if record.Expr != nil {
+		val, err := Eval(record.Expr, env, pid)
+		if err != nil {
+			return nil, err
+		}
+		tuple, err := asRecord(val, decl)
+		if err != nil {
+			return nil, err
+		}
+		values = append([]Expr{}, tuple.Values...)
+	} else {
+		values = make([]Expr, len(decl.Fields)+1)
+		values[0] = Atom(decl.Name)
+	}
+
+	assigned := make(map[int]bool)
+	for _, field := range record.Fields {
+		i, err := fieldIndex(decl, field.Name)
+		if err != nil {
+			return nil, err
+		}
+		if assigned[i] {
+			return nil, errors.New("field %v assigned twice", Atom(field.Name))
+		}
+		assigned[i] = true
+		values[i], err = Eval(field.Value, env, pid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if record.Expr == nil {
+		// the defaults are evaluated when the record is created
+		for i, field := range decl.Fields {
+			if assigned[i+1] {
+				continue
+			}
+			if field.Value == nil {
+				values[i+1] = Atom("undefined")
+				continue
+			}
+			values[i+1], err = recordDefault(declared, i, pid)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return Tuple{values}, nil
+}
+
+// Evaluate the access to the field of the record.
+func evalRecordAccess(access RecordAccess, env *envir.Env, pid pids.Pid) (Expr, error) {
+	decl, err := getRecord(access.Name, env)
+	if err != nil {
+		return nil, err
+	}
+	i, err := fieldIndex(decl, access.Field)
+	if err != nil {
+		return nil, err
+	}
+	val, err := Eval(access.Expr, env, pid)
+	if err != nil {
+		return nil, err
+	}
+	tuple, err := asRecord(val, decl)
+	if err != nil {
+		return nil, err
+	}
+	return tuple.Values[i], nil
+}
+
+// Check if the value is the tuple representing the record.
+func asRecord(val Expr, decl RecordDecl) (Tuple, error) {
+	tuple, ok := val.(Tuple)
+	if !ok || len(tuple.Values) != len(decl.Fields)+1 || !Equal(tuple.Values[0], Atom(decl.Name)) {
+		return Tuple{}, errors.BadRecord{decl.Name, val}
+	}
+	return tuple, nil
+}
+
+// Translate the record pattern to the tuple pattern, the fields
+// that were not given match anything.
+func recordPattern(record Record, env *envir.Env) (Tuple, error) {
+	if record.Expr != nil {
+		return Tuple{}, errors.New("record update %v cannot be used as a pattern", record)
+	}
+	decl, err := getRecord(record.Name, env)
+	if err != nil {
+		return Tuple{}, err
+	}
+	return recordTuple(record, decl)
+}
+
+// The tuple pattern for the record pattern of the declared record.
+func recordTuple(record Record, decl RecordDecl) (Tuple, error) {
+	values := make([]Expr, len(decl.Fields)+1)
+	values[0] = Atom(decl.Name)
+	for i := range decl.Fields {
+		values[i+1] = Dummy{}
+	}
+	for _, field := range record.Fields {
+		i, err := fieldIndex(decl, field.Name)
+		if err != nil {
+			return Tuple{}, err
+		}
+		values[i] = field.Value
+	}
+	return Tuple{values}, nil
+}
@@ -0,0 +1,486 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	. "github.com/twolodzko/goer/types"
+)
+
+// Variable resolved to the `slot` of the frame `depth` levels up from the frame
+// of the function call. The frame one level up holds the variables captured
+// by the function.
+type local struct {
+	name        string
+	depth, slot int
+}
+
+func (v local) String() string {
+	return v.name
+}
+
+// Function definition with the variables resolved to the slots. When the function
+// is defined, the `captures` are copied from the enclosing frame, so it encloses only
+// the variables it uses. The local name of the function is held in the first slot.
+type function struct {
+	Definition
+	branches []branch
+	captures []local
+}
+
+// Branch of the function, its frame has `size` slots and it holds its own
+// names only when the branch defines them.
+type branch struct {
+	FunBranch
+	size  int
+	names bool
+}
+
+// Resolver of the variables used in the functions to the slots of their frames.
+// The top-level variables live in the global environment and are looked up by
+// their names. It also reports the variables that are used before they are bound.
+type resolver struct {
+	scope *clauseScope // nil at the top level
+	env   *envir.Env
+	// position of the currently resolved expression, used to annotate the errors
+	pos Pos
+	// the variables bound at the top level
+	top map[string]bool
+	// the variables that were not bound when used, the ones used in the functions
+	// may be bound at the top level later on, so they are checked at the end
+	unbound []unboundUse
+}
+
+// Variables bound in the branch of the function, mapped to their slots.
+type clauseScope struct {
+	slots map[string]int
+	names bool
+	fun   *funScope
+}
+
+// Variables captured by the function, mapped to the slots of the enclosing frame.
+type funScope struct {
+	captured map[string]int
+	fun      *function
+	parent   *clauseScope
+}
+
+type unboundUse struct {
+	name  string
+	pos   Pos
+	inFun bool
+}
+
+// Resolve the variables of the top-level expressions, error if any of them is used
+// when it is not bound. The functions can use the top-level variables bound by any
+// of the expressions, since they are looked up when the function is called.
+func resolve(exprs []Expr, env *envir.Env) ([]Expr, error) {
+	r := &resolver{env: env, top: make(map[string]bool)}
+	exprs = r.body(exprs)
+	for _, u := range r.unbound {
+		if u.inFun && r.isGlobal(u.name) {
+			continue
+		}
+		return exprs, errors.At(errors.Unbound{u.name}, u.pos)
+	}
+	return exprs, nil
+}
+
+// Resolve the function defined outside of the resolved code.
+func resolveDefinition(def Definition) *function {
+	r := &resolver{top: make(map[string]bool)}
+	return r.fun(def)
+}
+
+// The name is bound in the global environment.
+func (r *resolver) isGlobal(name string) bool {
+	if r.top[name] {
+		return true
+	}
+	if r.env == nil {
+		return true
+	}
+	_, err := r.env.Get(Variable(name))
+	return err == nil
+}
+
+// Resolve the expression at the position, the position applies to the
+// unbound variables that do not have positions themselves.
+func (r *resolver) at(pos Pos, resolve func()) {
+	prev := r.pos
+	r.pos = pos
+	resolve()
+	r.pos = prev
+}
+
+func (r *resolver) body(exprs []Expr) []Expr {
+	resolved := make([]Expr, len(exprs))
+	for i, expr := range exprs {
+		resolved[i] = r.expr(expr)
+	}
+	return resolved
+}
+
+// Resolve the expression, the variables used in it need to be bound.
+func (r *resolver) expr(expr Expr) Expr {
+	switch val := expr.(type) {
+	case Variable:
+		return r.use(val)
+	case Tuple:
+		return Tuple{r.body(val.Values)}
+	case List:
+		return List{r.body(val.Values)}
+	case BitString:
+		var bits BitString
+		r.at(val.Pos, func() {
+			bits = BitString{make([]Segment, len(val.Segments)), val.Pos}
+			for i, segment := range val.Segments {
+				bits.Segments[i] = Segment{r.expr(segment.Value), r.optional(segment.Size), segment.Types}
+			}
+		})
+		return bits
+	case RecordDecl:
+		if r.scope != nil {
+			r.scope.names = true
+		}
+		decl := RecordDecl{val.Name, make([]RecordField, len(val.Fields)), val.Pos}
+		r.at(val.Pos, func() {
+			for i, field := range val.Fields {
+				decl.Fields[i] = RecordField{field.Name, r.optional(field.Value)}
+			}
+		})
+		return decl
+	case Record:
+		record := Record{nil, val.Name, make([]RecordField, len(val.Fields)), val.Pos}
+		r.at(val.Pos, func() {
+			record.Expr = r.optional(val.Expr)
+			for i, field := range val.Fields {
+				record.Fields[i] = RecordField{field.Name, r.expr(field.Value)}
+			}
+		})
+		return record
+	case RecordAccess:
+		var access RecordAccess
+		r.at(val.Pos, func() {
+			access = RecordAccess{r.expr(val.Expr), val.Name, val.Field, val.Pos}
+		})
+		return access
+	case UnaryOperation:
+		var op UnaryOperation
+		r.at(val.Pos, func() {
+			op = UnaryOperation{val.Op, r.expr(val.Rhs), val.Pos}
+		})
+		return op
+	case BinaryOperation:
+		var op BinaryOperation
+		r.at(val.Pos, func() {
+			op = BinaryOperation{val.Op, nil, nil, val.Pos}
+			if val.Op == "=" {
+				op.Lhs, op.Rhs = r.match(val.Lhs, val.Rhs, true)
+			} else {
+				op.Lhs = r.expr(val.Lhs)
+				op.Rhs = r.expr(val.Rhs)
+			}
+		})
+		return op
+	case Bracket:
+		return Bracket{r.expr(val.Expr)}
+	case Block:
+		var block Block
+		r.at(val.Pos, func() { block = Block{r.body(val.Body), val.Pos} })
+		return block
+	case If:
+		block := If{make([]IfBranch, len(val.Branches)), val.Pos}
+		r.at(val.Pos, func() {
+			for i, branch := range val.Branches {
+				block.Branches[i] = IfBranch{r.expr(branch.Cond), r.body(branch.Body)}
+			}
+		})
+		return block
+	case Case:
+		var block Case
+		r.at(val.Pos, func() {
+			block = Case{r.expr(val.Arg), r.branches(val.Branches), val.Pos}
+		})
+		return block
+	case AssertMatch:
+		var assert AssertMatch
+		r.at(val.Pos, func() {
+			expr := r.expr(val.Expr)
+			assert = AssertMatch{r.pattern(val.Pattern), expr, val.Pos}
+		})
+		return assert
+	case Receive:
+		var block Receive
+		r.at(val.Pos, func() {
+			after := IfBranch{r.optional(val.After.Cond), nil}
+			block = Receive{r.branches(val.Branches), after, val.Pos}
+			block.After.Body = r.body(val.After.Body)
+		})
+		return block
+	case TryRecover:
+		var block TryRecover
+		r.at(val.Pos, func() {
+			block = TryRecover{r.body(val.Body), r.body(val.Recover), val.Pos}
+		})
+		return block
+	case TryCatch:
+		block := TryCatch{nil, make([]CatchBranch, len(val.Branches)), val.Pos}
+		r.at(val.Pos, func() {
+			block.Body = r.body(val.Body)
+			for i, branch := range val.Branches {
+				block.Branches[i] = CatchBranch{
+					r.pattern(branch.Class),
+					r.pattern(branch.Pattern),
+					r.optionalPattern(branch.Stack),
+					r.body(branch.Guards),
+					r.body(branch.Body),
+				}
+			}
+		})
+		return block
+	case Definition:
+		var fun *function
+		r.at(val.Pos, func() { fun = r.fun(val) })
+		return fun
+	case Call:
+		var call Call
+		r.at(val.Pos, func() {
+			// the arguments are evaluated before the called expression
+			args := r.body(val.Args)
+			call = Call{r.expr(val.Callable), args, val.Pos}
+		})
+		return call
+	case Dummy, Atom, QuotedAtom, Bool, Int, String, Binary, pids.Pid, Fun, *Closure, *Generator, buildIn, local, *function:
+		return val
+	default:
+		panic(fmt.Sprintf("value of type %T cannot be resolved", val))
+	}
+}
+
+// Resolve the expression that is not always given.
+func (r *resolver) optional(expr Expr) Expr {
+	if expr == nil {
+		return nil
+	}
+	return r.expr(expr)
+}
+
+func (r *resolver) optionalPattern(expr Expr) Expr {
+	if expr == nil {
+		return nil
+	}
+	return r.pattern(expr)
+}
+
+// Resolve the branches of the case or receive expressions.
+func (r *resolver) branches(branches []PatternBranch) []PatternBranch {
+	resolved := make([]PatternBranch, len(branches))
+	for i, branch := range branches {
+		pattern := r.pattern(branch.Pattern)
+		resolved[i] = PatternBranch{pattern, r.body(branch.Guards), r.body(branch.Body)}
+	}
+	return resolved
+}
+
+// Resolve the function definition, each of its branches has its own frame.
+func (r *resolver) fun(def Definition) *function {
+	fun := &function{Definition: def}
+	scope := &funScope{make(map[string]int), fun, r.scope}
+	if def.Local {
+		// the function can call itself by the name
+		scope.captured[def.Name] = 0
+	}
+
+	outer, outerPos := r.scope, r.pos
+	for _, b := range def.Branches {
+		r.scope = &clauseScope{make(map[string]int), false, scope}
+		var resolved FunBranch
+		for _, arg := range b.Args {
+			resolved.Args = append(resolved.Args, r.pattern(arg))
+		}
+		resolved.Guards = r.body(b.Guards)
+		resolved.Body = r.body(b.Body)
+		fun.branches = append(fun.branches, branch{resolved, len(r.scope.slots), r.scope.names})
+	}
+	r.scope, r.pos = outer, outerPos
+
+	if def.Name != "" && !def.Local && r.scope != nil {
+		r.scope.names = true
+	}
+	return fun
+}
+
+// Resolve the variable that is used, it can be bound in the current function,
+// captured from the enclosing ones, or it is the top-level variable.
+func (r *resolver) use(name Variable) Expr {
+	if r.scope == nil {
+		if !r.isGlobal(string(name)) {
+			r.unbound = append(r.unbound, unboundUse{string(name), r.pos, false})
+		}
+		return name
+	}
+	if v, ok := r.scope.lookup(string(name)); ok {
+		return v
+	}
+	r.unbound = append(r.unbound, unboundUse{string(name), r.pos, true})
+	return name
+}
+
+// Resolve the variable that is bound, the variables are always bound
+// in the frame of the current function.
+func (r *resolver) bind(name Variable) Expr {
+	if r.scope == nil {
+		r.top[string(name)] = true
+		return name
+	}
+	slot, ok := r.scope.slots[string(name)]
+	if !ok {
+		slot = len(r.scope.slots)
+		r.scope.slots[string(name)] = slot
+	}
+	return local{string(name), 0, slot}
+}
+
+// Find the variable bound in the branch, or captured by the function.
+func (s *clauseScope) lookup(name string) (local, bool) {
+	if slot, ok := s.slots[name]; ok {
+		return local{name, 0, slot}, true
+	}
+	return s.fun.capture(name)
+}
+
+// Find the variable captured by the function, it is captured when it is used
+// for the first time, if it is bound in the enclosing function.
+func (s *funScope) capture(name string) (local, bool) {
+	if slot, ok := s.captured[name]; ok {
+		return local{name, 1, slot}, true
+	}
+	if s.parent == nil {
+		return local{}, false
+	}
+	from, ok := s.parent.lookup(name)
+	if !ok {
+		return local{}, false
+	}
+	slot := len(s.captured)
+	s.captured[name] = slot
+	s.fun.captures = append(s.fun.captures, from)
+	return local{name, 1, slot}, true
+}
+
+// Resolve the pattern matched against a value, following the rules of `match`.
+func (r *resolver) pattern(pattern Expr) Expr {
+	switch p := pattern.(type) {
+	case Variable:
+		return r.bind(p)
+	case BitString:
+		var bits BitString
+		r.at(p.Pos, func() { bits = r.binaryPattern(p) })
+		return bits
+	case Record:
+		if p.Expr != nil {
+			return r.expr(p)
+		}
+		record := Record{nil, p.Name, make([]RecordField, len(p.Fields)), p.Pos}
+		r.at(p.Pos, func() {
+			for i, field := range p.Fields {
+				record.Fields[i] = RecordField{field.Name, r.pattern(field.Value)}
+			}
+		})
+		return record
+	case Tuple:
+		values := make([]Expr, len(p.Values))
+		for i, v := range p.Values {
+			values[i] = r.pattern(v)
+		}
+		return Tuple{values}
+	case List:
+		values := make([]Expr, len(p.Values))
+		for i, v := range p.Values {
+			values[i] = r.pattern(v)
+		}
+		return List{values}
+	default:
+		return r.expr(pattern)
+	}
+}
+
+// Resolve the bit string pattern, the sizes can use the variables
+// bound by the preceding segments.
+func (r *resolver) binaryPattern(bits BitString) BitString {
+	resolved := BitString{make([]Segment, len(bits.Segments)), bits.Pos}
+	for i, segment := range bits.Segments {
+		size := r.optional(segment.Size)
+		resolved.Segments[i] = Segment{r.pattern(segment.Value), size, segment.Types}
+	}
+	return resolved
+}
+
+// Resolve the match (=) operation, following the rules of `match` and `evalMatch`:
+// the variables on either side are bound, the containers written on both sides are
+// matched element by element, and the other expressions are evaluated. At the top
+// level, the bound variable on the right-hand side is matched by its value.
+func (r *resolver) match(lhs, rhs Expr, top bool) (Expr, Expr) {
+	switch l := lhs.(type) {
+	case Dummy:
+		return lhs, rhs
+	case Variable:
+		if _, ok := rhs.(Dummy); !ok {
+			rhs = r.expr(rhs)
+		}
+		return r.bind(l), rhs
+	case BitString:
+		rhs = r.expr(rhs)
+		return r.pattern(l), rhs
+	case Record:
+		lhs = r.pattern(l)
+	case Atom, Tuple, List:
+	default:
+		lhs = r.expr(lhs)
+	}
+
+	switch v := rhs.(type) {
+	case Dummy:
+		return lhs, rhs
+	case Variable:
+		if top {
+			// the bound variable is matched by its value
+			if r.scope != nil {
+				if bound, ok := r.scope.lookup(string(v)); ok {
+					return r.pattern(lhs), bound
+				}
+			}
+			if r.isGlobal(string(v)) {
+				return r.pattern(lhs), v
+			}
+		}
+		lhs = r.expr(lhs)
+		return lhs, r.bind(v)
+	case BitString:
+		lhs = r.expr(lhs)
+		return lhs, r.pattern(v)
+	case Record:
+		rhs = r.pattern(v)
+	case Atom, Tuple, List:
+	default:
+		rhs = r.expr(rhs)
+	}
+
+	lvals, lok := containerValues(lhs)
+	rvals, rok := containerValues(rhs)
+	if lok && rok && sameKind(lhs, rhs) && len(lvals) == len(rvals) {
+		lres := make([]Expr, len(lvals))
+		rres := make([]Expr, len(rvals))
+		for i := range lvals {
+			lres[i], rres[i] = r.match(lvals[i], rvals[i], false)
+		}
+		if _, ok := lhs.(Tuple); ok {
+			return Tuple{lres}, Tuple{rres}
+		}
+		return List{lres}, List{rres}
+	}
+	return r.pattern(lhs), r.pattern(rhs)
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	"github.com/twolodzko/goer/parser"
+	. "github.com/twolodzko/goer/types"
+)
+
+func TestResolveSlots(t *testing.T) {
+	t.Parallel()
+
+	exprs, err := parser.Parse("fun(X, Unused) -> fun(Y) -> Z = X + Y, Z end end.")
+	if err != nil {
+		t.Fatalf("unexpected parsing error: %s", err)
+	}
+	exprs, err = resolve(exprs, NewEnv())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	outer := exprs[0].(*function)
+	inner := outer.branches[0].Body[0].(*function)
+
+	if outer.branches[0].size != 2 || inner.branches[0].size != 2 {
+		t.Errorf("expected 2 and 2 slots, got %d and %d", outer.branches[0].size, inner.branches[0].size)
+	}
+	// only the used variable is captured
+	if !slices.Equal(inner.captures, []local{{"X", 0, 0}}) {
+		t.Errorf("unexpected captures: %v", inner.captures)
+	}
+
+	body := inner.branches[0].Body
+	expected := []Expr{
+		BinaryOperation{"=", local{"Z", 0, 1}, BinaryOperation{"+", local{"X", 1, 0}, local{"Y", 0, 0}, Pos{Line: 1, Col: 35}}, Pos{Line: 1, Col: 31}},
+		local{"Z", 0, 1},
+	}
+	if !cmp.Equal(body, expected, cmp.AllowUnexported(local{})) {
+		t.Errorf("expected %#v, got %#v", expected, body)
+	}
+}
+
+func TestUnboundBeforeRun(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			testCases := []struct {
+				input string
+				eval  func(string, *envir.Env, pids.Pid) (Expr, error)
+				pos   Pos
+			}{
+				{"self() ! hello, fun f() -> Reslt end.", engine.eval, Pos{Line: 1, Col: 28}},
+				// the whole file is checked before running any of it
+				{"self() ! hello.\nfun f() -> print(Reslt) end.", engine.evalCode, Pos{Line: 2, Col: 18}},
+			}
+
+			for _, tt := range testCases {
+				func() {
+					env := NewEnv()
+					pid := pids.NewPid()
+					defer pid.Close()
+
+					_, err := tt.eval(tt.input, env, pid)
+					if !cmp.Equal(errors.Cause(err), errors.Unbound{"Reslt"}) {
+						t.Errorf("for '%s' expected the unbound variable error, got %v", tt.input, err)
+					}
+					if located, ok := err.(errors.Located); !ok || located.Pos != tt.pos {
+						t.Errorf("for '%s' expected the error at %v, got %v", tt.input, tt.pos, err)
+					}
+					// nothing was evaluated
+					select {
+					case msg := <-pid.Messages():
+						t.Errorf("for '%s' the code was evaluated before reporting the error, it sent %v", tt.input, msg)
+					case <-time.After(50 * time.Millisecond):
+					}
+					if _, err := env.Get(Atom("f")); err == nil {
+						t.Errorf("for '%s' the function was defined", tt.input)
+					}
+				}()
+			}
+		})
+	}
+}
+
+func TestUnboundAfterFile(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			testCases := []struct {
+				input    string
+				expected Expr
+				err      error
+			}{
+				{"fun f() -> Xx end. Xx = 1. f().", Int(1), nil},
+				{"fun f() -> fun() -> Xx end end. Xx = 2. (f())().", Int(2), nil},
+				{"fun f() -> Xx end. ok.", nil, errors.Unbound{"Xx"}},
+				{"Yy = 1. fun f() -> Xx + Yy end. f().", nil, errors.Unbound{"Xx"}},
+				{"X = Yy. Yy = 1.", nil, errors.Unbound{"Yy"}},
+			}
+
+			for _, tt := range testCases {
+				func() {
+					env := NewEnv()
+					pid := pids.NewPid()
+					defer pid.Close()
+
+					result, err := engine.evalCode(tt.input, env, pid)
+					if !cmp.Equal(errors.Cause(err), tt.err) {
+						t.Errorf("evaluating '%s' should throw error: %v, but it thrown: %v", tt.input, tt.err, err)
+					} else if !cmp.Equal(result, tt.expected) {
+						t.Errorf("evaluating '%s' returned %v while we expected %v", tt.input, result, tt.expected)
+					}
+				}()
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"cmp"
 	"fmt"
+	"reflect"
 
 	"github.com/twolodzko/goer/core/envir"
 	"github.com/twolodzko/goer/core/errors"
@@ -9,29 +11,84 @@ import (
 	. "github.com/twolodzko/goer/types"
 )
 
-// A function that encloses the environment where it was defined.
+// A function that encloses the variables it uses, and the environment where it was defined.
 type Fun struct {
 	parentEnv *envir.Env
-	Definition
+	*function
 }
 
-// Call the function with the arguments.
+// Create the function, the captured variables are copied from the frame where it is defined.
+func newFun(def *function, env *envir.Env) Fun {
+	names := env.Names()
+	if !def.Local && len(def.captures) == 0 {
+		return Fun{names, def}
+	}
+	offset := 0
+	if def.Local {
+		offset = 1
+	}
+	values := make([]Expr, len(def.captures)+offset)
+	for i, v := range def.captures {
+		values[i+offset] = env.Load(v.depth, v.slot)
+	}
+	fun := Fun{names.Enclose(values), def}
+	if def.Local {
+		// the function can call itself by the name
+		values[0] = fun
+	}
+	return fun
+}
+
+// Call the function with the arguments, each branch is matched in a fresh frame.
 func (fun Fun) call(args []Expr, pid pids.Pid) (Expr, *envir.Env, error) {
-	for _, branch := range fun.Branches {
-		env := fun.parentEnv.Branch()
-		if matchAll(branch.Args, args, env, pid) == nil {
-			ok, err := evalAllTrue(branch.Guards, env, pid)
-			if err != nil {
-				return nil, env, err
-			}
-			if ok {
+	arityMatched := false
+	for _, branch := range fun.branches {
+		if len(branch.Args) != len(args) {
+			continue
+		}
+		arityMatched = true
+
+		env := fun.parentEnv.Frame(branch.size, branch.names)
+		err := matchArgs(branch.Args, args, env, pid)
+		if uncatchable(err) {
+			return nil, fun.parentEnv, err
+		}
+		if err == nil {
+			if evalGuards(branch.Guards, env, pid) {
 				return partialEval(branch.Body, env, pid)
 			}
 		}
 	}
+	if !arityMatched {
+		return nil, fun.parentEnv, errors.WrongNumberArgs{}
+	}
 	return nil, fun.parentEnv, errors.NoFunBranch{}
 }
 
+func (Fun) Order() int {
+	return OrderFun
+}
+
+// Functions are ordered by their identity: the environment where they
+// were defined and their code. The build-in functions go first.
+func (fun Fun) Compare(other Expr) int {
+	switch other := other.(type) {
+	case Fun:
+		if o := cmp.Compare(reflect.ValueOf(fun.parentEnv).Pointer(), reflect.ValueOf(other.parentEnv).Pointer()); o != 0 {
+			return o
+		}
+		return cmp.Compare(reflect.ValueOf(fun.Branches).Pointer(), reflect.ValueOf(other.Branches).Pointer())
+	case *Closure:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func (fun Fun) Hash() uint64 {
+	return uint64(reflect.ValueOf(fun.parentEnv).Pointer())*31 + uint64(reflect.ValueOf(fun.Branches).Pointer())
+}
+
 func (fun Fun) String() string {
 	return fmt.Sprintf("%s", fun.Definition)
 }
@@ -55,6 +112,7 @@ func evalIf(block If, env *envir.Env, pid pids.Pid) (Expr, *envir.Env, error) {
 	return nil, env, errors.NoTrueBranch{}
 }
 
+// Evaluate a case expression.
 func evalCase(block Case, env *envir.Env, pid pids.Pid) (Expr, *envir.Env, error) {
 	val, err := Eval(block.Arg, env, pid)
 	if err != nil {
@@ -62,17 +120,70 @@ func evalCase(block Case, env *envir.Env, pid pids.Pid) (Expr, *envir.Env, error
 	}
 	for _, branch := range block.Branches {
 		// no match error = true
-		if match(val, branch.Pattern, env, pid) == nil {
-			ok, err := evalAllTrue(branch.Guards, env, pid)
-			if err != nil {
-				return nil, env, err
-			}
-			if ok {
+		err := matchValue(branch.Pattern, val, env, pid)
+		if uncatchable(err) {
+			return nil, env, err
+		}
+		if err == nil {
+			if evalGuards(branch.Guards, env, pid) {
 				return partialEval(branch.Body, env, pid)
 			}
 		}
 	}
-	return nil, env, errors.NoTrueBranch{}
+	return nil, env, errors.NoCaseBranch{val}
+}
+
+// Evaluate the catch branches of the try expression for the error thrown in its body.
+// If none of the branches match, the error is thrown again.
+func evalCatch(block TryCatch, thrown error, env *envir.Env, pid pids.Pid) (Expr, *envir.Env, error) {
+	class := errors.Class(thrown)
+	reason := errors.ToTerm(thrown)
+	for _, branch := range block.Branches {
+		if matchClass(branch.Class, class, env) == nil &&
+			matchValue(branch.Pattern, reason, env, pid) == nil &&
+			matchStack(branch.Stack, thrown, env) == nil &&
+			evalGuards(branch.Guards, env, pid) {
+			return partialEval(branch.Body, env, pid)
+		}
+	}
+	return nil, env, thrown
+}
+
+// Match the class of the error. The class is not matched using `match`, since
+// `error` and `exit` atoms would evaluate to the build-in functions.
+func matchClass(pattern Expr, class Atom, env *envir.Env) error {
+	switch pattern := pattern.(type) {
+	case Dummy:
+		return nil
+	case Variable:
+		return env.TrySet(pattern, class)
+	case local:
+		return bindLocal(pattern, class, env)
+	case Atom:
+		if pattern == class {
+			return nil
+		}
+	case QuotedAtom:
+		if Atom(pattern) == class {
+			return nil
+		}
+	}
+	return errors.NoMatch{pattern, class}
+}
+
+// Bind the stack trace of the error. Like the class, it is not matched using `match`,
+// since the function names would evaluate to the functions.
+func matchStack(pattern Expr, thrown error, env *envir.Env) error {
+	switch pattern := pattern.(type) {
+	case nil, Dummy:
+		return nil
+	case Variable:
+		return env.TrySet(pattern, errors.StackTraceTerm(thrown))
+	case local:
+		return bindLocal(pattern, errors.StackTraceTerm(thrown), env)
+	default:
+		return errors.NoMatch{pattern, errors.StackTraceTerm(thrown)}
+	}
 }
 
 // Is the expression true-ish (bool or dummy).
@@ -87,15 +198,16 @@ func isTrueish(expr Expr) bool {
 	}
 }
 
-// Evaluate all expressions (short circuit), check if they are all true.
-func evalAllTrue(exprs []Expr, env *envir.Env, pid pids.Pid) (Bool, error) {
+// Evaluate the guards (short circuit), check if they are all true. As in Erlang,
+// a guard that throws an error or does not evaluate to a boolean fails.
+func evalGuards(exprs []Expr, env *envir.Env, pid pids.Pid) bool {
 	for _, expr := range exprs {
 		ok, err := evalIsTrue(expr, env, pid)
 		if !ok || err != nil {
-			return false, err
+			return false
 		}
 	}
-	return true, nil
+	return true
 }
 
 // Evaluate expression and check if it is true.
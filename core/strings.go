@@ -0,0 +1,197 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	. "github.com/twolodzko/goer/types"
+)
+
+// string_split/2
+func stringSplit(s, sep Expr) (Expr, error) {
+	str, err := toStrings(s, sep)
+	if err != nil {
+		return nil, err
+	}
+	var parts []Expr
+	for _, part := range strings.Split(str[0], str[1]) {
+		parts = append(parts, String(part))
+	}
+	return List{parts}, nil
+}
+
+// string_join/2
+func stringJoin(lst, sep Expr) (Expr, error) {
+	list, ok := lst.(List)
+	if !ok {
+		return nil, errors.NotList{lst}
+	}
+	str, err := toStrings(list.Values...)
+	if err != nil {
+		return nil, err
+	}
+	delim, err := toStrings(sep)
+	if err != nil {
+		return nil, err
+	}
+	return String(strings.Join(str, delim[0])), nil
+}
+
+// string_substr/2, string_substr/3
+func stringSubstr(args []Expr, _ *envir.Env, _ pids.Pid) (Expr, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, errors.WrongNumberArgs{}
+	}
+	str, ok := args[0].(String)
+	if !ok {
+		return nil, errors.NotString{args[0]}
+	}
+	runes := []rune(str)
+	start, ok := args[1].(Int)
+	if !ok {
+		return nil, errors.NotNumber{args[1]}
+	}
+	end := Int(len(runes))
+	if len(args) == 3 {
+		length, ok := args[2].(Int)
+		if !ok {
+			return nil, errors.NotNumber{args[2]}
+		}
+		end = start + length
+	}
+	if start < 0 || start > end || int(end) > len(runes) {
+		return nil, errors.New("invalid index")
+	}
+	return String(runes[start:end]), nil
+}
+
+// string_find/2
+func stringFind(s, sub Expr) (Expr, error) {
+	str, err := toStrings(s, sub)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.Index(str[0], str[1])
+	if i < 0 {
+		return Atom("nomatch"), nil
+	}
+	// index of the code point, not the byte
+	return Int(utf8.RuneCountInString(str[0][:i])), nil
+}
+
+// string_replace/3
+func stringReplace(args []Expr, _ *envir.Env, _ pids.Pid) (Expr, error) {
+	if len(args) != 3 {
+		return nil, errors.WrongNumberArgs{}
+	}
+	str, err := toStrings(args...)
+	if err != nil {
+		return nil, err
+	}
+	return String(strings.ReplaceAll(str[0], str[1], str[2])), nil
+}
+
+// string_upper/1
+func stringUpper(arg Expr) (Expr, error) {
+	str, err := toStrings(arg)
+	if err != nil {
+		return nil, err
+	}
+	return String(strings.ToUpper(str[0])), nil
+}
+
+// string_lower/1
+func stringLower(arg Expr) (Expr, error) {
+	str, err := toStrings(arg)
+	if err != nil {
+		return nil, err
+	}
+	return String(strings.ToLower(str[0])), nil
+}
+
+// string_trim/1
+func stringTrim(arg Expr) (Expr, error) {
+	str, err := toStrings(arg)
+	if err != nil {
+		return nil, err
+	}
+	return String(strings.TrimSpace(str[0])), nil
+}
+
+// starts_with/2
+func startsWith(s, prefix Expr) (Expr, error) {
+	str, err := toStrings(s, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return Bool(strings.HasPrefix(str[0], str[1])), nil
+}
+
+// ends_with/2
+func endsWith(s, suffix Expr) (Expr, error) {
+	str, err := toStrings(s, suffix)
+	if err != nil {
+		return nil, err
+	}
+	return Bool(strings.HasSuffix(str[0], str[1])), nil
+}
+
+// to_int/1
+func toInt(arg Expr) (Expr, error) {
+	str, err := toStrings(arg)
+	if err != nil {
+		return nil, err
+	}
+	num, err := strconv.Atoi(strings.TrimSpace(str[0]))
+	if err != nil {
+		return nil, errors.NotNumber{arg}
+	}
+	return Int(num), nil
+}
+
+// to_atom/1
+func toAtom(arg Expr) (Expr, error) {
+	str, err := toStrings(arg)
+	if err != nil {
+		return nil, err
+	}
+	return Atom(str[0]), nil
+}
+
+// chars_to_string/1
+func charsToString(arg Expr) (Expr, error) {
+	list, ok := arg.(List)
+	if !ok {
+		return nil, errors.NotList{arg}
+	}
+	var b strings.Builder
+	for _, val := range list.Values {
+		switch val := val.(type) {
+		case String:
+			b.WriteString(string(val))
+		case Int:
+			// code point
+			b.WriteRune(rune(val))
+		default:
+			return nil, errors.NotString{val}
+		}
+	}
+	return String(b.String()), nil
+}
+
+// Cast the arguments to Go strings.
+func toStrings(args ...Expr) ([]string, error) {
+	str := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := arg.(String)
+		if !ok {
+			return nil, errors.NotString{arg}
+		}
+		str[i] = string(s)
+	}
+	return str, nil
+}
@@ -14,6 +14,9 @@ func applyUnaryOp(op string, expr Expr) (Expr, error) {
 	case "-":
 		val, err := maybeInt(expr)
 		return -val, err
+	case "bnot":
+		val, err := maybeInt(expr)
+		return ^val, err
 	case "not":
 		switch expr := expr.(type) {
 		case Bool:
@@ -0,0 +1,840 @@
+package core
+
+import (
+	"strings"
+	"time"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	"github.com/twolodzko/goer/parser"
+	. "github.com/twolodzko/goer/types"
+)
+
+// Virtual machine running the compiled code. It has the same semantics as `Eval`, but
+// the variables of the functions are stored in the slots resolved by the compiler,
+// instead of the environments looked up by their names.
+type vm struct {
+	pid      pids.Pid
+	stack    []Expr
+	calls    []activation
+	handlers []handler
+}
+
+// Call of the compiled function, or the top-level code.
+type activation struct {
+	code   *code
+	pc     int
+	base   int // where the arguments start on the stack
+	args   []Expr
+	locals *locals
+	env    *envir.Env
+	// the function called, tail calls replace it, so the
+	// stack trace is collected only when the error unwinds
+	frame errors.Frame
+	fun   Expr
+	// the called expression, used in the wrong number of arguments
+	// error, nil when the function was called by a build-in
+	callable Expr
+}
+
+// Handler of the errors thrown in the try block (`catch` is true), or of the failed
+// pattern matches and guards. When it is triggered, the stack is truncated to `sp`,
+// and the code jumps to `pc` of the call at `depth`.
+type handler struct {
+	pc, sp, depth int
+	catch         bool
+}
+
+// The failed pattern match handled in the same function call, it does not need
+// the details of the error.
+var errFail = errors.New("pattern match failed")
+
+// State of matching the binary against the bit string.
+type binaryMatch struct {
+	bits   BitString
+	reader *bitReader
+}
+
+// State of constructing the record.
+type recordBuilder struct {
+	decl     RecordDecl
+	record   Expr // as stored in the environment
+	values   []Expr
+	assigned []bool
+	field    int
+}
+
+// Compile the expressions to the bytecode and run them.
+func Run(exprs []Expr, env *envir.Env, pid pids.Pid) (Expr, error) {
+	// the compiler resolves the variables on its own, but the
+	// unbound variables are reported like by the evaluator
+	if _, err := resolve(exprs, env); err != nil {
+		return nil, err
+	}
+	vm := &vm{pid: pid}
+	vm.calls = append(vm.calls, activation{code: compile(exprs), env: env})
+	return vm.run(0)
+}
+
+// Parse the code string, compile, and run it.
+func ParseRun(code string, env *envir.Env, pid pids.Pid) (Expr, error) {
+	exprs, err := parser.Parse(code)
+	if err != nil {
+		return nil, err
+	}
+	result, err := Run(exprs, env, pid)
+	if err != nil {
+		return nil, atVariable(err, []chunk{{code, Pos{Line: 1, Col: 1}}})
+	}
+	return result, nil
+}
+
+// Compile and run a file.
+func RunFile(path string, env *envir.Env, pid pids.Pid) (Expr, error) {
+	return evalFile(path, env, pid, Run)
+}
+
+// Compile and run the code having many expressions, like the file.
+func RunCode(code string, env *envir.Env, pid pids.Pid) (Expr, error) {
+	return evalReader(strings.NewReader(code), "", env, pid, Run)
+}
+
+// Call the closure with the arguments.
+func (fun *Closure) call(args []Expr, pid pids.Pid) (Expr, error) {
+	vm := &vm{pid: pid}
+	vm.stack = append(vm.stack, args...)
+	vm.enter(fun, len(args), nil, errors.Frame{}, false)
+	return vm.run(0)
+}
+
+// Start the call of the closure, the arguments are on the top of the stack.
+// The tail call replaces the current call.
+func (vm *vm) enter(fun *Closure, n int, callable Expr, frame errors.Frame, tail bool) {
+	base := len(vm.stack) - n
+	if tail {
+		act := &vm.calls[len(vm.calls)-1]
+		copy(vm.stack[act.base:], vm.stack[base:])
+		base = act.base
+		vm.stack = vm.stack[:base+n]
+		vm.calls = vm.calls[:len(vm.calls)-1]
+	}
+	vm.calls = append(vm.calls, activation{
+		code:     fun.proto.code,
+		base:     base,
+		args:     vm.stack[base : base+n],
+		locals:   &locals{make([]Expr, fun.proto.nslots), fun.locals},
+		env:      fun.env,
+		frame:    frame,
+		fun:      fun,
+		callable: callable,
+	})
+}
+
+// Run the code until the call at the `stop` depth returns.
+func (vm *vm) run(stop int) (Expr, error) {
+	for {
+		act := &vm.calls[len(vm.calls)-1]
+		in := act.code.instrs[act.pc]
+		act.pc++
+
+		var err error
+		switch in.op {
+		case opConst:
+			vm.push(act.code.consts[in.a])
+		case opPop:
+			vm.pop()
+		case opDup:
+			vm.push(vm.top())
+		case opLoad:
+			r := act.code.refs[in.a]
+			if val, ok := vm.lookup(r, act); ok {
+				vm.push(val)
+			} else {
+				err = errors.Unbound{r.name}
+			}
+		case opLoadAtom:
+			r := act.code.refs[in.a]
+			if val, ok := vm.lookup(r, act); ok {
+				vm.push(val)
+			} else {
+				vm.push(r.key)
+			}
+		case opLoadOrJump:
+			if val, ok := vm.lookup(act.code.refs[in.a], act); ok {
+				vm.push(val)
+			} else {
+				act.pc = int(in.b)
+			}
+		case opUnbound:
+			err = act.code.consts[in.a].(error)
+		case opBind:
+			err = vm.bind(act.code.refs[in.a], vm.pop(), act)
+		case opDefine:
+			r := act.code.refs[in.a]
+			if vm.isDefined(r, act) {
+				err = errors.New("%s already exists", r.name)
+				break
+			}
+			vm.define(r, vm.top(), act)
+		case opTuple:
+			tuple := Tuple{vm.popN(int(in.a))}
+			vm.push(tuple)
+			_, err = allocated(tuple, vm.pid)
+		case opList:
+			list := List{vm.popN(int(in.a))}
+			vm.push(list)
+			_, err = allocated(list, vm.pid)
+		case opBinNew:
+			vm.push(&bitWriter{Binary{}, 0})
+		case opBinAppend:
+			err = vm.appendSegment(act.code.consts[in.b].(BitString).Segments[in.a])
+		case opBinEnd:
+			var bin Binary
+			if bin, err = vm.pop().(*bitWriter).binary(); err == nil {
+				vm.push(bin)
+			}
+		case opRecordDecl:
+			err = vm.declareRecord(act.code.consts[in.a].(recordProto), act.code.refs[in.b], act)
+		case opRecordNew:
+			err = vm.newRecord(act.code.refs[in.a], act.code.consts[in.b].(Record), act)
+		case opRecordFrom:
+			val := vm.pop()
+			b := vm.top().(*recordBuilder)
+			var tuple Tuple
+			if tuple, err = asRecord(val, b.decl); err == nil {
+				b.values = append([]Expr{}, tuple.Values...)
+			}
+		case opRecordField:
+			err = vm.setField(act.code.consts[in.a].(string), in.b == 1)
+		case opRecordEnd:
+			err = vm.endRecord(in.a == 1)
+		case opRecordAccess:
+			err = vm.accessRecord(act.code.refs[in.a], act.code.consts[in.b].(RecordAccess), act)
+		case opUnary:
+			var val Expr
+			if val, err = applyUnaryOp(act.code.consts[in.a].(string), vm.pop()); err == nil {
+				vm.push(val)
+			}
+		case opBinary:
+			rhs := vm.pop()
+			var val Expr
+			if val, err = applyBinaryOp(act.code.consts[in.a].(string), vm.pop(), rhs); err == nil {
+				vm.push(val)
+				_, err = allocated(val, vm.pid)
+			}
+		case opAdd, opSub, opMul, opLess, opLessEq, opGreater, opGreaterEq, opEqual, opNotEqual:
+			err = vm.binaryOp(in.op, act.code.consts[in.a].(string))
+		case opIsTrue:
+			if _, ok := vm.top().(Bool); !ok {
+				err = errors.NotBoolean{vm.top()}
+			}
+		case opJump:
+			act.pc = int(in.a)
+		case opJumpIf:
+			if vm.top() == Bool(in.b == 1) {
+				act.pc = int(in.a)
+			}
+		case opJumpIfFalse:
+			if vm.pop() == Bool(false) {
+				act.pc = int(in.a)
+			}
+		case opMatchValue:
+			val := vm.pop()
+			if pattern := act.code.consts[in.a]; !matchConst(pattern, val, act.env) {
+				err = vm.noMatch(pattern, val, in.b == 1)
+			}
+		case opMatchValues:
+			rhs := vm.pop()
+			lhs := vm.pop()
+			if in.a == 1 {
+				lhs, rhs = rhs, lhs
+			}
+			if err = matchValues(lhs, rhs); err != nil && vm.failing() {
+				err = errFail
+			}
+		case opMatchTuple:
+			pattern := act.code.consts[in.a].(Tuple)
+			val := vm.pop()
+			if tuple, ok := val.(Tuple); ok && len(tuple.Values) == len(pattern.Values) {
+				vm.pushReversed(tuple.Values)
+			} else {
+				err = vm.noMatch(pattern, val, in.b == 1)
+			}
+		case opMatchList:
+			pattern := act.code.consts[in.a].(List)
+			val := vm.pop()
+			if list, ok := val.(List); ok && list.Len() == pattern.Len() {
+				vm.pushReversed(list.Values)
+			} else {
+				err = vm.noMatch(pattern, val, in.b == 1)
+			}
+		case opMatchRecord:
+			err = vm.matchRecord(act.code.refs[in.a], act.code.consts[in.b].(Record), act)
+		case opMatchBinary:
+			bits := act.code.consts[in.a].(BitString)
+			val := vm.pop()
+			if bin, ok := val.(Binary); ok {
+				vm.push(&binaryMatch{bits, &bitReader{bin, 0}})
+			} else {
+				err = vm.noMatch(bits, val, false)
+			}
+		case opMatchPrefix:
+			m := vm.top().(*binaryMatch)
+			if !cutPrefix(m.reader, m.bits.Segments[in.a].Value.(String)) {
+				err = vm.noMatch(m.bits, m.reader.bin, false)
+			}
+		case opMatchSegment:
+			err = vm.matchSegment(int(in.a), in.b == 1)
+		case opMatchBinEnd:
+			if m := vm.pop().(*binaryMatch); m.reader.left() > 0 {
+				err = vm.noMatch(m.bits, m.reader.bin, false)
+			}
+		case opNoMatch:
+			err = act.code.consts[in.a].(error)
+			if vm.failing() {
+				err = errFail
+			}
+		case opBadMatch:
+			err = vm.pop().(error)
+			val := vm.pop()
+			if _, ok := errors.Cause(err).(errors.NoMatch); ok {
+				err = errors.NoMatch{act.code.consts[in.a], val}
+			}
+		case opPushFail, opTry:
+			vm.handlers = append(vm.handlers, handler{int(in.a), len(vm.stack), len(vm.calls), in.op == opTry})
+		case opPopFail, opEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case opGuard:
+			if vm.pop() != Bool(true) {
+				err = errFail
+			}
+		case opFail:
+			err = errFail
+		case opCatchClass:
+			vm.push(errors.Class(vm.top().(error)))
+		case opCatchReason:
+			vm.push(errors.ToTerm(vm.top().(error)))
+		case opCatchStack:
+			vm.push(errors.StackTraceTerm(vm.top().(error)))
+		case opRethrow:
+			err = vm.pop().(error)
+		case opCall, opTailCall:
+			err = vm.call(int(in.a), act.code.consts[in.b], act.code.pos[act.pc-1], in.op == opTailCall)
+		case opReturn:
+			result := vm.pop()
+			vm.stack = vm.stack[:act.base]
+			vm.calls = vm.calls[:len(vm.calls)-1]
+			if len(vm.calls) == stop {
+				return result, nil
+			}
+			vm.push(result)
+		case opClosure:
+			fun := &Closure{act.code.consts[in.a].(*proto), act.locals, act.env}
+			if in.b == 1 {
+				// the function can call itself by the name
+				fun.locals = &locals{[]Expr{fun}, act.locals}
+			}
+			vm.push(fun)
+		case opArity:
+			if len(act.args) != int(in.a) {
+				act.pc = int(in.b)
+			}
+		case opArg:
+			vm.push(act.args[in.a])
+		case opClear:
+			clear(act.locals.slots)
+		case opNoBranch:
+			err = errors.NoFunBranch{}
+			if !act.fun.(*Closure).proto.arities[len(act.args)] {
+				err = errors.WrongNumberArgs{}
+			}
+			if act.callable != nil {
+				err = withArity(err, act.callable, act.fun, append([]Expr(nil), act.args...))
+			}
+		case opNoCaseBranch:
+			err = errors.NoCaseBranch{vm.pop()}
+		case opNoTrueBranch:
+			err = errors.NoTrueBranch{}
+		case opNotMatched:
+			err = errors.NotMatched{string(act.code.consts[in.a].(String)), vm.pop()}
+		case opTimeout:
+			var timeout time.Duration
+			if timeout, err = toTimeout(vm.pop()); err == nil {
+				vm.push(timeout)
+			}
+		case opReceive:
+			select {
+			case msg := <-vm.pid.Messages():
+				vm.push(msg)
+			case <-time.After(vm.top().(time.Duration)):
+				act.pc = int(in.a)
+			case <-vm.pid.Done():
+				err = stopped(vm.pid.Err())
+			}
+		default:
+			panic("invalid opcode " + in.op.String())
+		}
+
+		if err != nil {
+			if err = vm.throw(err, stop); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (vm *vm) push(val Expr) {
+	vm.stack = append(vm.stack, val)
+}
+
+func (vm *vm) pop() Expr {
+	val := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return val
+}
+
+func (vm *vm) top() Expr {
+	return vm.stack[len(vm.stack)-1]
+}
+
+// Pop `n` values, in the order they were pushed.
+func (vm *vm) popN(n int) []Expr {
+	if n == 0 {
+		return nil
+	}
+	vals := make([]Expr, n)
+	copy(vals, vm.stack[len(vm.stack)-n:])
+	vm.stack = vm.stack[:len(vm.stack)-n]
+	return vals
+}
+
+// Push the values, so that the first one is on the top.
+func (vm *vm) pushReversed(vals []Expr) {
+	for i := len(vals) - 1; i >= 0; i-- {
+		vm.push(vals[i])
+	}
+}
+
+// Handle the error: jump to the closest handler, unwinding the calls if needed.
+// The errors that unwind past the `stop` depth are returned.
+func (vm *vm) throw(err error, stop int) error {
+	act := &vm.calls[len(vm.calls)-1]
+	if err != errFail {
+		err = errors.At(err, act.code.pos[act.pc-1])
+	}
+	halting := uncatchable(err)
+	for {
+		depth := len(vm.calls)
+		if n := len(vm.handlers); !halting && n > 0 && vm.handlers[n-1].depth == depth {
+			h := vm.handlers[n-1]
+			vm.handlers = vm.handlers[:n-1]
+			vm.stack = vm.stack[:h.sp]
+			if h.catch {
+				vm.push(err)
+			}
+			act.pc = h.pc
+			return nil
+		}
+
+		if act.frame.Name != "" {
+			err = errors.WithFrame(err, act.frame)
+		}
+		err = errors.At(err, act.frame.Pos)
+		vm.stack = vm.stack[:act.base]
+		vm.calls = vm.calls[:depth-1]
+		if len(vm.calls) == stop {
+			return err
+		}
+		act = &vm.calls[len(vm.calls)-1]
+		err = errors.At(err, act.code.pos[act.pc-1])
+	}
+}
+
+// The failed pattern match is handled in the current call.
+func (vm *vm) failing() bool {
+	n := len(vm.handlers)
+	return n > 0 && !vm.handlers[n-1].catch && vm.handlers[n-1].depth == len(vm.calls)
+}
+
+// The error for the value not matching the pattern.
+func (vm *vm) noMatch(pattern, val Expr, swap bool) error {
+	if vm.failing() {
+		return errFail
+	}
+	return noMatch(pattern, val, swap)
+}
+
+// Match the values, the lists and tuples are matched element by element,
+// like in `match`, so the error points to the elements that differ.
+func matchValues(lhs, rhs Expr) error {
+	switch l := lhs.(type) {
+	case List:
+		if r, ok := rhs.(List); ok && l.Len() == r.Len() {
+			return matchAllValues(l.Values, r.Values)
+		}
+	case Tuple:
+		if r, ok := rhs.(Tuple); ok && len(l.Values) == len(r.Values) {
+			return matchAllValues(l.Values, r.Values)
+		}
+	default:
+		if Equal(lhs, rhs) {
+			return nil
+		}
+	}
+	return errors.NoMatch{lhs, rhs}
+}
+
+func matchAllValues(lhs, rhs []Expr) error {
+	for i := range lhs {
+		if err := matchValues(lhs[i], rhs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find the value of the reference in the slots of the enclosing functions,
+// or in the global environment.
+func (vm *vm) lookup(r *ref, act *activation) (Expr, bool) {
+	for _, s := range r.path {
+		l := act.locals
+		for i := 0; i < s.depth; i++ {
+			l = l.parent
+		}
+		if val := l.slots[s.slot]; val != nil {
+			return val, true
+		}
+	}
+	val, err := act.env.Get(r.key)
+	return val, err == nil
+}
+
+// Match the value with the variable, bind it if it is not bound in the current function.
+func (vm *vm) bind(r *ref, val Expr, act *activation) error {
+	if r.slot < 0 {
+		if err := act.env.TrySet(r.key, val); err != nil {
+			return vm.noMatch(r.key, val, false)
+		}
+		return nil
+	}
+	slot := &act.locals.slots[r.slot]
+	if *slot == nil {
+		*slot = val
+		return nil
+	}
+	if !Equal(*slot, val) {
+		return vm.noMatch(r.key, val, false)
+	}
+	return nil
+}
+
+// The name is already defined in the current function.
+func (vm *vm) isDefined(r *ref, act *activation) bool {
+	if r.slot < 0 {
+		_, ok := act.env.Elems[r.name]
+		return ok
+	}
+	return act.locals.slots[r.slot] != nil
+}
+
+// Define the name in the current function.
+func (vm *vm) define(r *ref, val Expr, act *activation) {
+	if r.slot < 0 {
+		act.env.Elems[r.name] = val
+	} else {
+		act.locals.slots[r.slot] = val
+	}
+}
+
+// Call the function with `n` arguments from the top of the stack, the function is above them.
+func (vm *vm) call(n int, callable Expr, pos Pos, tail bool) error {
+	if err := stopped(vm.pid.Tick()); err != nil {
+		return err
+	}
+	fun := vm.pop()
+	switch callee := fun.(type) {
+	case *Closure:
+		frame := errors.Frame{funName(callee.proto.Name, callable), n, pos}
+		vm.enter(callee, n, callable, frame, tail)
+		return nil
+	case Fun:
+		args := vm.popN(n)
+		frame := errors.Frame{funName(callee.Name, callable), n, pos}
+		expr, env, err := callee.call(args, vm.pid)
+		if err != nil {
+			return errors.WithFrame(withArity(err, callable, fun, args), frame)
+		}
+		result, err := Eval(expr, env, vm.pid)
+		if err != nil {
+			return errors.WithFrame(err, frame)
+		}
+		vm.push(result)
+		return nil
+	case buildIn:
+		args := vm.popN(n)
+		result, err := callee(args, vm.calls[len(vm.calls)-1].env, vm.pid)
+		if err != nil {
+			err = withArity(err, callable, fun, args)
+			return errors.WithFrame(err, errors.Frame{funName("", callable), n, pos})
+		}
+		vm.push(result)
+		_, err = allocated(result, vm.pid)
+		return err
+	default:
+		return errors.NotFunction{fun}
+	}
+}
+
+// Apply the integer operation, or fall back to `applyBinaryOp` for the operator `name`.
+func (vm *vm) binaryOp(op opcode, name string) error {
+	rhs := vm.pop()
+	lhs := vm.pop()
+	if x, ok := lhs.(Int); ok {
+		if y, ok := rhs.(Int); ok {
+			switch op {
+			case opAdd:
+				vm.push(x + y)
+			case opSub:
+				vm.push(x - y)
+			case opMul:
+				vm.push(x * y)
+			case opLess:
+				vm.push(Bool(x < y))
+			case opLessEq:
+				vm.push(Bool(x <= y))
+			case opGreater:
+				vm.push(Bool(x > y))
+			case opGreaterEq:
+				vm.push(Bool(x >= y))
+			case opEqual:
+				vm.push(Bool(x == y))
+			case opNotEqual:
+				vm.push(Bool(x != y))
+			}
+			return nil
+		}
+	}
+	val, err := applyBinaryOp(name, lhs, rhs)
+	if err != nil {
+		return err
+	}
+	vm.push(val)
+	_, err = allocated(val, vm.pid)
+	return err
+}
+
+// Append the value of the segment to the binary, the size is on the top of the stack if given.
+func (vm *vm) appendSegment(segment Segment) error {
+	typ := typeOf(segment)
+	size := defaultSize(typ)
+	if segment.Size != nil {
+		var err error
+		if size, err = checkSize(segment, vm.pop()); err != nil {
+			return err
+		}
+	}
+	val := vm.pop()
+	w := vm.top().(*bitWriter)
+	prefix := len(w.bin)
+	if err := appendSegment(w, segment, typ, val, size, vm.pid); err != nil {
+		return err
+	}
+	// the binary is built segment by segment, so only the new bytes are accounted for
+	_, err := allocated(w.bin[prefix:], vm.pid)
+	return err
+}
+
+// Take the value of the i-th segment from the binary, the size is on the top of the stack if given.
+func (vm *vm) matchSegment(i int, hasSize bool) error {
+	var sizeVal Expr
+	if hasSize {
+		sizeVal = vm.pop()
+	}
+	m := vm.top().(*binaryMatch)
+	segment := m.bits.Segments[i]
+	typ := typeOf(segment)
+	size := defaultSize(typ)
+	if hasSize {
+		var err error
+		if size, err = checkSize(segment, sizeVal); err != nil {
+			return err
+		}
+	}
+	part, err := takeSegment(m.bits, m.reader, i, typ, size)
+	if err != nil {
+		if _, ok := err.(errors.NoMatch); ok && vm.failing() {
+			return errFail
+		}
+		return err
+	}
+	vm.push(part)
+	return nil
+}
+
+// Find the declaration of the record, and the record as stored in the environment.
+func (vm *vm) getRecord(r *ref, act *activation) (RecordDecl, Expr, error) {
+	val, ok := vm.lookup(r, act)
+	if !ok {
+		return RecordDecl{}, nil, errors.UndefinedRecord{r.name[1:]}
+	}
+	switch record := val.(type) {
+	case *compiledRecord:
+		return record.RecordDecl, record, nil
+	case *declaredRecord:
+		return record.RecordDecl, record, nil
+	default:
+		return RecordDecl{}, nil, errors.UndefinedRecord{r.name[1:]}
+	}
+}
+
+// Declare the record in the current function, like `declareRecord`.
+func (vm *vm) declareRecord(rp recordProto, r *ref, act *activation) error {
+	decl := rp.decl
+	if vm.isDefined(r, act) {
+		return errors.New("record %v already exists", Atom(decl.Name))
+	}
+	seen := make(map[string]bool)
+	for _, field := range decl.Fields {
+		if seen[field.Name] {
+			return errors.New("field %v declared twice in record %v", Atom(field.Name), Atom(decl.Name))
+		}
+		seen[field.Name] = true
+	}
+	record := &compiledRecord{decl, make([]*Closure, len(rp.defaults))}
+	for i, p := range rp.defaults {
+		if p != nil {
+			record.defaults[i] = &Closure{p, act.locals, act.env}
+		}
+	}
+	vm.define(r, record, act)
+	vm.push(Atom("ok"))
+	return nil
+}
+
+// Start constructing the record, like `evalRecord`.
+func (vm *vm) newRecord(r *ref, record Record, act *activation) error {
+	decl, declared, err := vm.getRecord(r, act)
+	if err != nil {
+		return err
+	}
+	values := make([]Expr, len(decl.Fields)+1)
+	values[0] = Atom(decl.Name)
+	vm.push(&recordBuilder{decl, declared, values, make([]bool, len(values)), 0})
+	return nil
+}
+
+// Start assigning the field of the record, or assign the value from the top of the stack.
+func (vm *vm) setField(name string, assign bool) error {
+	if assign {
+		val := vm.pop()
+		b := vm.top().(*recordBuilder)
+		b.values[b.field] = val
+		return nil
+	}
+	b := vm.top().(*recordBuilder)
+	i, err := fieldIndex(b.decl, name)
+	if err != nil {
+		return err
+	}
+	if b.assigned[i] {
+		return errors.New("field %v assigned twice", Atom(name))
+	}
+	b.assigned[i] = true
+	b.field = i
+	return nil
+}
+
+// Finish constructing the record, the defaults are evaluated when it is created.
+func (vm *vm) endRecord(fill bool) error {
+	b := vm.pop().(*recordBuilder)
+	if fill {
+		for i, field := range b.decl.Fields {
+			if b.assigned[i+1] {
+				continue
+			}
+			var err error
+			if field.Value == nil {
+				b.values[i+1] = Atom("undefined")
+			} else if record, ok := b.record.(*compiledRecord); ok {
+				b.values[i+1], err = vm.callDefault(record.defaults[i])
+			} else {
+				b.values[i+1], err = recordDefault(b.record, i, vm.pid)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	record := Tuple{b.values}
+	vm.push(record)
+	_, err := allocated(record, vm.pid)
+	return err
+}
+
+// Evaluate the default value of the record field.
+func (vm *vm) callDefault(fun *Closure) (Expr, error) {
+	stop := len(vm.calls)
+	vm.enter(fun, 0, nil, errors.Frame{}, false)
+	return vm.run(stop)
+}
+
+// Access the field of the record, like `evalRecordAccess`.
+func (vm *vm) accessRecord(r *ref, access RecordAccess, act *activation) error {
+	val := vm.pop()
+	decl, _, err := vm.getRecord(r, act)
+	if err != nil {
+		return err
+	}
+	i, err := fieldIndex(decl, access.Field)
+	if err != nil {
+		return err
+	}
+	tuple, err := asRecord(val, decl)
+	if err != nil {
+		return err
+	}
+	vm.push(tuple.Values[i])
+	return nil
+}
+
+// Match the record pattern, push the values of its fields.
+func (vm *vm) matchRecord(r *ref, record Record, act *activation) error {
+	val := vm.pop()
+	if record.Expr != nil {
+		return errors.New("record update %v cannot be used as a pattern", record)
+	}
+	decl, _, err := vm.getRecord(r, act)
+	if err != nil {
+		return err
+	}
+	pattern, err := recordTuple(record, decl)
+	if err != nil {
+		return err
+	}
+	tuple, ok := val.(Tuple)
+	if !ok || len(tuple.Values) != len(pattern.Values) {
+		return vm.noMatch(pattern, val, false)
+	}
+	if !Equal(pattern.Values[0], tuple.Values[0]) {
+		return vm.noMatch(pattern.Values[0], tuple.Values[0], false)
+	}
+	for j := len(record.Fields) - 1; j >= 0; j-- {
+		i, _ := fieldIndex(decl, record.Fields[j].Name)
+		vm.push(tuple.Values[i])
+	}
+	return nil
+}
+
+// Convert the value to the receive timeout.
+func toTimeout(val Expr) (time.Duration, error) {
+	switch t := val.(type) {
+	case Int:
+		return time.Duration(t) * time.Millisecond, nil
+	case Atom:
+		if t == "infinity" {
+			return defaultTimeout, nil
+		}
+	}
+	return 0, errors.NotNumber{val}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/twolodzko/goer/core/pids"
+	"github.com/twolodzko/goer/parser"
+	. "github.com/twolodzko/goer/types"
+)
+
+func TestCompileSlots(t *testing.T) {
+	t.Parallel()
+
+	exprs, err := parser.Parse("fun(X) -> fun(Y) -> Z = X + Y, Z end end.")
+	if err != nil {
+		t.Fatalf("unexpected parsing error: %s", err)
+	}
+	outer := compile(exprs).consts[0].(*proto)
+	inner := outer.code.consts[0].(*proto)
+
+	testCases := []struct {
+		code     *code
+		name     string
+		expected []slotRef
+	}{
+		{outer.code, "X", []slotRef{{0, 0}}},
+		{inner.code, "X", []slotRef{{1, 0}}},
+		{inner.code, "Y", []slotRef{{0, 0}}},
+		{inner.code, "Z", []slotRef{{0, 1}}},
+	}
+
+	for _, tt := range testCases {
+		for _, r := range tt.code.refs {
+			if r.name == tt.name && !slices.Equal(r.path, tt.expected) {
+				t.Errorf("%s should be resolved to %v, got %v", tt.name, tt.expected, r.path)
+			}
+		}
+	}
+	if outer.nslots != 1 || inner.nslots != 2 {
+		t.Errorf("expected 1 and 2 slots, got %d and %d", outer.nslots, inner.nslots)
+	}
+}
+
+func TestMixedEngines(t *testing.T) {
+	t.Parallel()
+
+	env := NewEnv()
+	pid := pids.NewPid()
+	defer pid.Close()
+
+	_, err := ParseEval("fun twice(F, X) -> F(F(X)) end.", env, pid)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = ParseRun("fun inc(X) -> X + 1 end.", env, pid)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected Expr
+	}{
+		{"twice(inc, 1).", Int(3)},
+		{"twice(fun(X) -> inc(X) * 2 end, 1).", Int(10)},
+		{"sort(fun(A, B) -> A >= B end, [1, 3, 2]).", List{[]Expr{Int(3), Int(2), Int(1)}}},
+		{"inc == inc.", Bool(true)},
+		{"twice < inc.", Bool(true)},
+	}
+
+	for _, tt := range testCases {
+		for _, engine := range engines {
+			result, err := engine.eval(tt.input, env, pid)
+			if err != nil {
+				t.Errorf("%s: evaluating '%s' resulted in an error: %s", engine.name, tt.input, err)
+			} else if !cmp.Equal(result, tt.expected) {
+				t.Errorf("%s: evaluating '%s' returned %v while we expected %v", engine.name, tt.input, result, tt.expected)
+			}
+		}
+	}
+}
+
+func BenchmarkFizzBuzz(b *testing.B) {
+	for _, engine := range engines {
+		b.Run(engine.name, func(b *testing.B) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
+
+			_, err := engine.eval(`
+			fun fizzbuzz(N) ->
+				case {N rem 3, N rem 5} of
+					{0, 0} -> "FizzBuzz";
+					{0, _} -> "Fizz";
+					{_, 0} -> "Buzz";
+					_ -> N
+				end
+			end,
+			fun loop(N, Max) when N > Max -> ok;
+				(N, Max) -> fizzbuzz(N), loop(N + 1, Max)
+			end.
+			`, env, pid)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.eval("loop(1, 1000).", env, pid); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStdlib(b *testing.B) {
+	for _, engine := range engines {
+		b.Run(engine.name, func(b *testing.B) {
+			env := NewEnv()
+			pid := pids.NewPid()
+			defer pid.Close()
+
+			_, err := engine.evalFile("../examples/stdlib.ge", env, pid)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var list []Expr
+			for i := 0; i < 100; i++ {
+				list = append(list, Int(i))
+			}
+			env.Elems["List"] = List{list}
+			code := "filter(map(List, fun(X) -> X * 3 end), fun(X) -> X rem 2 == 0 end)."
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.eval(code, env, pid); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
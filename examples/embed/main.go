@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/twolodzko/goer/goer"
+)
+
+const code = `
+fun greet(Name) ->
+	format("Hello ~s!", [upper(Name)])
+end.
+
+fun counter(N) ->
+	receive
+		{From, increment} ->
+			From ! {count, N + 1},
+			counter(N + 1)
+	end
+end.
+
+fun forever() -> forever() end.
+`
+
+func main() {
+	in := goer.New(goer.Config{})
+	defer in.Close()
+	ctx := context.Background()
+
+	// the Go function callable from the goer code
+	in.Register("upper", func(args ...goer.Term) (goer.Term, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("upper takes one argument")
+		}
+		str, ok := goer.ToGo(args[0]).(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a string", args[0])
+		}
+		return strings.ToUpper(str), nil
+	})
+
+	if _, err := in.Eval(ctx, code); err != nil {
+		log.Fatal(err)
+	}
+
+	greeting, err := in.Call(ctx, "greet", "world")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(goer.ToGo(greeting))
+
+	// talk to the goer process
+	counter, err := in.Eval(ctx, "spawn(fun() -> counter(0) end).")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for range 3 {
+		in.Send(counter, goer.Tuple{[]goer.Term{in.Self(), goer.Atom("increment")}})
+		reply, err := in.Receive(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(reply)
+	}
+
+	// stop the code that runs for too long
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = in.Eval(ctx, "forever().")
+	fmt.Println(err)
+}
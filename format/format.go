@@ -0,0 +1,924 @@
+package format
+
+import (
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/twolodzko/goer/parser"
+	"github.com/twolodzko/goer/parser/lexer"
+	"github.com/twolodzko/goer/parser/reader"
+	. "github.com/twolodzko/goer/types"
+)
+
+const (
+	// The expressions longer than the width are broken into multiple lines.
+	Width  = 100
+	indent = "    "
+)
+
+// Format the code in the canonical layout, keeping the comments
+// and the blank lines that separate the expressions.
+func Source(src string) (string, error) {
+	// the shebang line is kept as it is, the reader skips it
+	var shebang string
+	if strings.HasPrefix(src, "#!") {
+		end := strings.IndexByte(src, '\n')
+		if end < 0 {
+			end = len(src)
+		}
+		shebang, src = src[:end], src[end:]
+	}
+	out, err := source(src)
+	if err != nil || shebang == "" {
+		return out, err
+	}
+	// the blank line separating it from the code is kept as well
+	if rest := strings.TrimLeft(src, "\n"); len(src)-len(rest) > 1 && out != "" {
+		shebang += "\n"
+	}
+	return shebang + "\n" + out, nil
+}
+
+func source(src string) (string, error) {
+	tokens, comments, err := lexer.TokenizeComments(src, Pos{Line: 1, Col: 1})
+	if err != nil {
+		return "", err
+	}
+	exprs, seps, err := parseAll(src)
+	if err != nil {
+		return "", err
+	}
+
+	p := printer{tokens: tokens, ends: blockEnds(tokens)}
+	p.events = events(src, tokens, comments)
+	end := Pos{Line: math.MaxInt}
+	out := p.sequence(exprs, 0, seps, end)
+	if out == "" {
+		return "", nil
+	}
+	return out + "\n", nil
+}
+
+// Parse the code block by block, return all the expressions with the separators
+// that follow them, the "." ends the block and "," separates the expressions in it.
+func parseAll(src string) ([]Expr, []string, error) {
+	var (
+		exprs []Expr
+		seps  []string
+	)
+	reader := reader.NewReader(strings.NewReader(src))
+	for {
+		code, err := reader.Next()
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		if tokens, err := lexer.Tokenize(code); err != nil || len(tokens) > 0 {
+			block, err := parser.ParseAt(code, reader.Pos())
+			if err != nil {
+				return nil, nil, err
+			}
+			for i := range block {
+				if i == len(block)-1 {
+					seps = append(seps, ".")
+				} else {
+					seps = append(seps, ",")
+				}
+			}
+			exprs = append(exprs, block...)
+		}
+		if err == io.EOF {
+			return exprs, seps, nil
+		}
+	}
+}
+
+// Printer of the parsed code. The expressions do not keep the tokens they were
+// parsed from, so while printing them in order, the printer follows the tokens:
+// it keeps the original spelling of the literals, and finds where the expressions
+// start, to print the comments before them.
+type printer struct {
+	tokens []lexer.Token
+	// index of the next token to follow, and the position of the last followed one
+	cursor int
+	last   Pos
+	// the comments and blank lines that were not printed yet, in order
+	events []event
+	// positions of the "end" tokens closing the blocks, by the positions of the blocks
+	ends map[Pos]Pos
+}
+
+// The comment, or the blank line if the text is empty.
+type event struct {
+	text     string
+	pos      Pos
+	trailing bool
+}
+
+// Collect the comments and the blank lines, except for the ones inside the strings.
+func events(src string, tokens []lexer.Token, comments []lexer.Comment) []event {
+	inString := make(map[int]bool)
+	for _, t := range tokens {
+		if t.Type == lexer.String {
+			for i := 1; i <= strings.Count(t.Value, "\n"); i++ {
+				inString[t.Pos.Line+i] = true
+			}
+		}
+	}
+
+	var events []event
+	for i, line := range strings.Split(src, "\n") {
+		if strings.TrimSpace(line) == "" && !inString[i+1] {
+			events = append(events, event{"", Pos{Line: i + 1}, false})
+		}
+	}
+	for _, c := range comments {
+		events = append(events, event{c.Text, c.Pos, c.Trailing})
+	}
+	slices.SortStableFunc(events, func(a, b event) int {
+		if a.pos.Line != b.pos.Line {
+			return a.pos.Line - b.pos.Line
+		}
+		return a.pos.Col - b.pos.Col
+	})
+	return events
+}
+
+// Match the blocks with the "end" tokens closing them.
+func blockEnds(tokens []lexer.Token) map[Pos]Pos {
+	ends := make(map[Pos]Pos)
+	var open []Pos
+	for _, t := range tokens {
+		switch t.Type {
+		case lexer.Fun, lexer.Begin, lexer.If, lexer.Case, lexer.Receive, lexer.Try:
+			open = append(open, t.Pos)
+		case lexer.End:
+			if len(open) > 0 {
+				ends[open[len(open)-1]] = t.Pos
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return ends
+}
+
+// Print the expressions one per line, each followed by its separator. The comments are
+// printed before the expressions they precede, and the ones found before the `closing`
+// position, after them.
+func (p *printer) sequence(exprs []Expr, level int, seps []string, closing Pos) string {
+	var b strings.Builder
+	for i, expr := range exprs {
+		lead, trail := p.flush(p.anchor(expr), level, i > 0, false)
+		if i > 0 {
+			b.WriteString(seps[i-1] + trail + "\n")
+		}
+		b.WriteString(lead)
+		b.WriteString(strings.Repeat(indent, level) + p.expr(expr, level))
+	}
+	if len(exprs) > 0 {
+		b.WriteString(seps[len(exprs)-1])
+	}
+	p.close(&b, closing, level, len(exprs) > 0)
+	return b.String()
+}
+
+// Print the body of the branch, the expressions are separated by commas.
+func (p *printer) body(exprs []Expr, level int, closing Pos) string {
+	seps := make([]string, len(exprs))
+	for i := range len(exprs) - 1 {
+		seps[i] = ","
+	}
+	return p.sequence(exprs, level, seps, closing)
+}
+
+// Print the comments found before the `closing` position at the end of the block.
+func (p *printer) close(b *strings.Builder, closing Pos, level int, prev bool) {
+	if !closing.IsValid() {
+		return
+	}
+	lead, trail := p.flush(closing, level, prev, true)
+	b.WriteString(trail)
+	if lead != "" {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(strings.TrimSuffix(lead, "\n"))
+	}
+}
+
+// Print the comments and blank lines found before the `anchor` position. The comments that
+// follow the code on the same line are returned separately as `trail`, when there was
+// a `prev` expression they can follow. The blank lines are not printed at the start,
+// or at the `closing` of the block.
+func (p *printer) flush(anchor Pos, level int, prev, closing bool) (lead, trail string) {
+	if !anchor.IsValid() {
+		return "", ""
+	}
+	blank := false
+	for len(p.events) > 0 && before(p.events[0].pos, anchor) {
+		ev := p.events[0]
+		p.events = p.events[1:]
+		switch {
+		case ev.text == "":
+			blank = blank || prev || lead != ""
+		case ev.trailing && prev && lead == "" && !blank:
+			trail += " " + ev.text
+		default:
+			if blank {
+				lead += "\n"
+				blank = false
+			}
+			lead += strings.Repeat(indent, level) + ev.text + "\n"
+		}
+	}
+	if blank && !closing {
+		lead += "\n"
+	}
+	return lead, trail
+}
+
+// There is a comment between the positions, that was not printed yet.
+func (p *printer) commented(from, to Pos) bool {
+	for _, ev := range p.events {
+		if ev.text != "" && before(from, ev.pos) && before(ev.pos, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// Position where the expression starts: the first of the positions it holds, and the
+// position of the next token the printer would follow.
+func (p *printer) anchor(exprs ...Expr) Pos {
+	start, _ := spans(p.ends, exprs...)
+	if next, ok := p.peek(); ok && (!start.IsValid() || before(next, start)) {
+		return next
+	}
+	return start
+}
+
+// Print the expression starting at the indentation `level`, the lines after
+// the first one are indented. When the expression fits in the line, and there
+// are no comments inside of it, it is printed in one line.
+func (p *printer) expr(expr Expr, level int) string {
+	cursor, last := p.cursor, p.last
+	start := p.anchor(expr)
+	if s, ok := p.flat(expr); ok && len(indent)*level+utf8.RuneCountInString(s) < Width {
+		_, end := spans(p.ends, expr)
+		if before(end, p.last) {
+			end = p.last
+		}
+		if !p.commented(start, end) {
+			return s
+		}
+	}
+	p.cursor, p.last = cursor, last
+	return p.broken(expr, level)
+}
+
+// Print the expressions in one line, separated by commas.
+func (p *printer) flatAll(exprs []Expr) (string, bool) {
+	parts := make([]string, len(exprs))
+	for i, expr := range exprs {
+		s, ok := p.flat(expr)
+		if !ok {
+			return "", false
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, ", "), true
+}
+
+// Print the expression in one line, if it can be printed so.
+func (p *printer) flat(expr Expr) (string, bool) {
+	switch val := expr.(type) {
+	case Tuple:
+		s, ok := p.flatAll(val.Values)
+		return "{" + s + "}", ok
+	case List:
+		s, ok := p.flatAll(val.Values)
+		return "[" + s + "]", ok
+	case BitString:
+		parts := make([]string, len(val.Segments))
+		for i, segment := range val.Segments {
+			s, ok := p.segment(segment)
+			if !ok {
+				return "", false
+			}
+			parts[i] = s
+		}
+		return "<<" + strings.Join(parts, ", ") + ">>", true
+	case Bracket:
+		s, ok := p.flat(val.Expr)
+		return "(" + s + ")", ok
+	case UnaryOperation:
+		s, ok := p.flat(val.Rhs)
+		return unary(val.Op, s), ok
+	case BinaryOperation:
+		lhs, ok := p.flat(val.Lhs)
+		if !ok {
+			return "", false
+		}
+		rhs, ok := p.flat(val.Rhs)
+		return lhs + " " + val.Op + " " + rhs, ok
+	case AssertMatch:
+		return p.flat(assertCall(val))
+	case Call:
+		callable, ok := p.flat(val.Callable)
+		if !ok {
+			return "", false
+		}
+		args, ok := p.flatAll(val.Args)
+		return callable + "(" + args + ")", ok
+	case RecordDecl:
+		p.take(lexer.Atom, "record")
+		name := p.name(val.Name)
+		fields, ok := p.flatFields(val.Fields)
+		return "-record(" + name + ", {" + fields + "})", ok
+	case Record:
+		var s string
+		if val.Expr != nil {
+			var ok bool
+			if s, ok = p.flat(val.Expr); !ok {
+				return "", false
+			}
+		}
+		s += "#" + p.name(val.Name)
+		fields, ok := p.flatFields(val.Fields)
+		return s + "{" + fields + "}", ok
+	case RecordAccess:
+		s, ok := p.flat(val.Expr)
+		return s + "#" + p.name(val.Name) + "." + p.name(val.Field), ok
+	case Block:
+		if len(val.Body) != 1 {
+			return "", false
+		}
+		s, ok := p.flat(val.Body[0])
+		return "begin " + s + " end", ok
+	case Definition:
+		if len(val.Branches) != 1 || len(val.Branches[0].Body) != 1 || val.Name != "" && !val.Local {
+			return "", false
+		}
+		s := "fun" + p.funName(val)
+		branch := val.Branches[0]
+		head, ok := p.funHead(branch)
+		if !ok {
+			return "", false
+		}
+		body, ok := p.flat(branch.Body[0])
+		return s + head + " " + body + " end", ok
+	case If, Case, Receive, TryRecover, TryCatch:
+		return "", false
+	default:
+		return p.leaf(expr), true
+	}
+}
+
+// Print the expression broken into multiple lines.
+func (p *printer) broken(expr Expr, level int) string {
+	margin := "\n" + strings.Repeat(indent, level)
+	switch val := expr.(type) {
+	case Tuple:
+		return "{" + p.elements(val.Values, level, lexer.BraceLeft, lexer.BraceRight) + "}"
+	case List:
+		return "[" + p.elements(val.Values, level, lexer.SuareBracketLeft, lexer.SquareBracketRight) + "]"
+	case BitString:
+		parts := make([]string, len(val.Segments))
+		for i, segment := range val.Segments {
+			// the segments are short, they are flat
+			parts[i], _ = p.segment(segment)
+		}
+		inner := strings.Repeat(indent, level+1)
+		return "<<\n" + inner + strings.Join(parts, ",\n"+inner) + margin + ">>"
+	case Bracket:
+		return "(" + p.expr(val.Expr, level) + ")"
+	case UnaryOperation:
+		return unary(val.Op, p.expr(val.Rhs, level))
+	case BinaryOperation:
+		lhs := p.expr(val.Lhs, level)
+		switch val.Rhs.(type) {
+		case Tuple, List, Call, Record, BitString:
+			// the brackets are opened in the same line
+			return lhs + " " + val.Op + " " + p.expr(val.Rhs, level)
+		}
+		rhs := p.expr(val.Rhs, level+1)
+		return lhs + " " + val.Op + margin + indent + rhs
+	case AssertMatch:
+		return p.broken(assertCall(val), level)
+	case Call:
+		callable := p.expr(val.Callable, level)
+		return callable + "(" + p.elements(val.Args, level, lexer.BracketLeft, lexer.BracketRight) + ")"
+	case RecordDecl:
+		p.take(lexer.Atom, "record")
+		name := p.name(val.Name)
+		return "-record(" + name + ", {" + p.fields(val.Fields, level) + "})"
+	case Record:
+		var s string
+		if val.Expr != nil {
+			s = p.expr(val.Expr, level)
+		}
+		s += "#" + p.name(val.Name)
+		return s + "{" + p.fields(val.Fields, level) + "}"
+	case RecordAccess:
+		return p.expr(val.Expr, level) + "#" + p.name(val.Name) + "." + p.name(val.Field)
+	case Block:
+		return "begin\n" + p.body(val.Body, level+1, p.ends[val.Pos]) + margin + "end"
+	case Definition:
+		return p.fun(val, level)
+	case If:
+		var b strings.Builder
+		b.WriteString("if\n")
+		for i, branch := range val.Branches {
+			p.branch(&b, i, level+1, branch.Cond)
+			b.WriteString(p.expr(branch.Cond, level+1) + " ->\n")
+			b.WriteString(p.body(branch.Body, level+2, closing(i, len(val.Branches), p.ends[val.Pos])))
+		}
+		return b.String() + margin + "end"
+	case Case:
+		var b strings.Builder
+		b.WriteString("case " + p.expr(val.Arg, level) + " of\n")
+		p.patternBranches(&b, val.Branches, level, p.ends[val.Pos])
+		return b.String() + margin + "end"
+	case Receive:
+		var b strings.Builder
+		b.WriteString("receive")
+		end := p.ends[val.Pos]
+		if val.After.Cond != nil {
+			end = Pos{}
+		}
+		if len(val.Branches) > 0 {
+			b.WriteString("\n")
+			p.patternBranches(&b, val.Branches, level, end)
+		}
+		if val.After.Cond != nil {
+			lead, trail := p.flush(p.anchor(val.After.Cond), level, len(val.Branches) > 0, false)
+			b.WriteString(trail + "\n" + lead)
+			b.WriteString(strings.Repeat(indent, level) + "after " + p.expr(val.After.Cond, level) + " ->\n")
+			b.WriteString(p.body(val.After.Body, level+1, p.ends[val.Pos]))
+		}
+		return b.String() + margin + "end"
+	case TryRecover:
+		body := p.body(val.Body, level+1, Pos{})
+		recover := p.body(val.Recover, level+1, p.ends[val.Pos])
+		return "try\n" + body + margin + "recover\n" + recover + margin + "end"
+	case TryCatch:
+		var b strings.Builder
+		b.WriteString("try\n" + p.body(val.Body, level+1, Pos{}) + margin + "catch\n")
+		for i, branch := range val.Branches {
+			p.branch(&b, i, level+1, branch.Class, branch.Pattern)
+			b.WriteString(p.catchPattern(branch, level+1))
+			b.WriteString(p.guards(branch.Guards, level+1) + " ->\n")
+			b.WriteString(p.body(branch.Body, level+2, closing(i, len(val.Branches), p.ends[val.Pos])))
+		}
+		return b.String() + margin + "end"
+	default:
+		return p.leaf(expr)
+	}
+}
+
+// Start the branch `i` of the block: print the comments that precede it,
+// the separator of the previous branch, and the indentation.
+func (p *printer) branch(b *strings.Builder, i, level int, start ...Expr) {
+	lead, trail := p.flush(p.anchor(start...), level, i > 0, false)
+	if i > 0 {
+		b.WriteString(";" + trail + "\n")
+	}
+	b.WriteString(lead)
+	b.WriteString(strings.Repeat(indent, level))
+}
+
+// The comments before the `end` of the block are printed after the body of its last branch.
+func closing(i, n int, end Pos) Pos {
+	if i == n-1 {
+		return end
+	}
+	return Pos{}
+}
+
+// Print the branches of the case or receive blocks, the last one
+// is followed by the comments found before the `closing` position.
+func (p *printer) patternBranches(b *strings.Builder, branches []PatternBranch, level int, end Pos) {
+	for i, branch := range branches {
+		p.branch(b, i, level+1, branch.Pattern)
+		b.WriteString(p.expr(branch.Pattern, level+1))
+		b.WriteString(p.guards(branch.Guards, level+1) + " ->\n")
+		b.WriteString(p.body(branch.Body, level+2, closing(i, len(branches), end)))
+	}
+}
+
+// Print the `Class:Pattern:Stack` of the catch branch, the class is
+// omitted when it matches any errors, and there is no stack.
+func (p *printer) catchPattern(branch CatchBranch, level int) string {
+	if _, ok := branch.Class.(Dummy); ok && branch.Stack == nil {
+		// it may be written explicitly as `_:Pattern`
+		if p.explicitClass() {
+			p.take(lexer.Dummy, "_")
+		}
+		return p.expr(branch.Pattern, level)
+	}
+	s := p.expr(branch.Class, level) + ":" + p.expr(branch.Pattern, level)
+	if branch.Stack != nil {
+		s += ":" + p.expr(branch.Stack, level)
+	}
+	return s
+}
+
+// The next token is the `_` class followed by `:`.
+func (p *printer) explicitClass() bool {
+	i := p.next()
+	return i+1 < len(p.tokens) && p.tokens[i].Type == lexer.Dummy &&
+		p.tokens[i+1].Type == lexer.Operator && p.tokens[i+1].Value == ":"
+}
+
+// Print the function definition, its branches are printed one per line.
+func (p *printer) fun(def Definition, level int) string {
+	margin := "\n" + strings.Repeat(indent, level)
+	s := "fun" + p.funName(def)
+	end := p.ends[def.Pos]
+	if len(def.Branches) == 1 {
+		head, _ := p.funHead(def.Branches[0])
+		return s + head + "\n" + p.body(def.Branches[0].Body, level+1, end) + margin + "end"
+	}
+
+	var b strings.Builder
+	b.WriteString(s + "\n")
+	for i, branch := range def.Branches {
+		var start []Expr
+		if len(branch.Args) > 0 {
+			start = branch.Args[:1]
+		}
+		p.branch(&b, i, level+1, start...)
+		head, _ := p.funHead(branch)
+		b.WriteString(head + "\n")
+		b.WriteString(p.body(branch.Body, level+2, closing(i, len(def.Branches), end)))
+	}
+	return b.String() + margin + "end"
+}
+
+// The name following the "fun" keyword.
+func (p *printer) funName(def Definition) string {
+	switch {
+	case def.Local:
+		p.take(lexer.Variable, def.Name)
+		return " " + def.Name
+	case def.Name != "":
+		return " " + p.name(def.Name)
+	default:
+		return ""
+	}
+}
+
+// Print the `(Args) when Guards ->` head of the function branch.
+func (p *printer) funHead(branch FunBranch) (string, bool) {
+	args, ok := p.flatAll(branch.Args)
+	if !ok {
+		return "", false
+	}
+	guards, ok := p.flatAll(branch.Guards)
+	if !ok {
+		return "", false
+	}
+	if guards != "" {
+		guards = " when " + guards
+	}
+	return "(" + args + ")" + guards + " ->", true
+}
+
+func (p *printer) guards(guards []Expr, level int) string {
+	if len(guards) == 0 {
+		return ""
+	}
+	parts := make([]string, len(guards))
+	for i, guard := range guards {
+		parts[i] = p.expr(guard, level)
+	}
+	return " when " + strings.Join(parts, ", ")
+}
+
+// Print the elements of the container one per line, the container is enclosed
+// in the `open` and `close` brackets.
+func (p *printer) elements(exprs []Expr, level int, open, close lexer.TokenType) string {
+	if len(exprs) == 0 {
+		return ""
+	}
+	closing := p.closer(open, close)
+	seps := make([]string, len(exprs))
+	for i := range len(exprs) - 1 {
+		seps[i] = ","
+	}
+	return "\n" + p.sequence(exprs, level+1, seps, closing) + "\n" + strings.Repeat(indent, level)
+}
+
+// Position of the `close` bracket matching the first `open` bracket that
+// follows the printed tokens, so the comments before it can be printed.
+func (p *printer) closer(open, close lexer.TokenType) Pos {
+	depth := 0
+	for _, t := range p.tokens[p.cursor:] {
+		switch {
+		case t.Type == open:
+			depth++
+		case t.Type == close && depth > 0:
+			depth--
+			if depth == 0 {
+				return t.Pos
+			}
+		}
+	}
+	return Pos{}
+}
+
+func (p *printer) flatFields(fields []RecordField) (string, bool) {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = p.name(field.Name)
+		if field.Value != nil {
+			s, ok := p.flat(field.Value)
+			if !ok {
+				return "", false
+			}
+			parts[i] += " = " + s
+		}
+	}
+	return strings.Join(parts, ", "), true
+}
+
+// Print the fields of the record one per line, like the elements of the
+// containers, with the comments that precede them.
+func (p *printer) fields(fields []RecordField, level int) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	closing := p.closer(lexer.BraceLeft, lexer.BraceRight)
+	var b strings.Builder
+	for i, field := range fields {
+		// the field starts at its name, the next token
+		lead, trail := p.flush(p.anchor(), level+1, i > 0, false)
+		if i > 0 {
+			b.WriteString("," + trail + "\n")
+		}
+		b.WriteString(lead)
+		b.WriteString(strings.Repeat(indent, level+1) + p.name(field.Name))
+		if field.Value != nil {
+			b.WriteString(" = " + p.expr(field.Value, level+1))
+		}
+	}
+	p.close(&b, closing, level+1, true)
+	return "\n" + b.String() + "\n" + strings.Repeat(indent, level)
+}
+
+// Print the `Value:Size/Type-...` segment of the bit string.
+func (p *printer) segment(segment Segment) (string, bool) {
+	s, ok := p.flat(segment.Value)
+	if !ok {
+		return "", false
+	}
+	if segment.Size != nil {
+		size, ok := p.flat(segment.Size)
+		if !ok {
+			return "", false
+		}
+		s += ":" + size
+	}
+	if segment.Types != nil {
+		for _, typ := range segment.Types {
+			p.take(lexer.Atom, typ)
+		}
+		s += "/" + strings.Join(segment.Types, "-")
+	}
+	return s, true
+}
+
+// Print the atom naming a record, field or function.
+func (p *printer) name(name string) string {
+	p.take(lexer.Atom, name)
+	return Atom(name).String()
+}
+
+// Print the literal, keeping the original spelling of the strings and characters.
+func (p *printer) leaf(expr Expr) string {
+	switch val := expr.(type) {
+	case Atom:
+		p.take(lexer.Atom, string(val))
+		return val.String()
+	case QuotedAtom:
+		p.take(lexer.Atom, string(val))
+		return val.String()
+	case Bool:
+		p.take(lexer.Atom, fmtBool(val))
+		return fmtBool(val)
+	case Variable:
+		p.take(lexer.Variable, string(val))
+		return string(val)
+	case Dummy:
+		p.take(lexer.Dummy, "_")
+		return "_"
+	case Int:
+		if t, ok := p.take(lexer.Char, ""); ok && t.Type == lexer.Char {
+			return t.Value
+		}
+		return strconv.Itoa(int(val))
+	case String:
+		if t, ok := p.take(lexer.String, ""); ok {
+			if s, err := strconv.Unquote(t.Value); err == nil && s == string(val) {
+				return t.Value
+			}
+		}
+		return val.String()
+	default:
+		return ""
+	}
+}
+
+func fmtBool(b Bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// The assertion is written like the call of the function.
+func assertCall(assert AssertMatch) Call {
+	return Call{Atom("assert_match"), []Expr{assert.Pattern, assert.Expr}, assert.Pos}
+}
+
+// The symbolic operators are printed next to the operand,
+// unless the operand starts with an operator itself.
+func unary(op, rhs string) string {
+	if op != "-" && op != "+" || strings.HasPrefix(rhs, "-") || strings.HasPrefix(rhs, "+") {
+		return op + " " + rhs
+	}
+	return op + rhs
+}
+
+// Index of the next literal token.
+func (p *printer) next() int {
+	i := p.cursor
+	for i < len(p.tokens) && !isLiteral(p.tokens[i].Type) {
+		i++
+	}
+	return i
+}
+
+// Position of the next literal token.
+func (p *printer) peek() (Pos, bool) {
+	if i := p.next(); i < len(p.tokens) {
+		return p.tokens[i].Pos, true
+	}
+	return Pos{}, false
+}
+
+// Follow the next literal token, if it is of the type, and has the value. The numbers
+// and the characters are both integers, so the number matches the character as well.
+// For the characters and strings, the value is not compared.
+func (p *printer) take(typ lexer.TokenType, value string) (lexer.Token, bool) {
+	i := p.next()
+	if i >= len(p.tokens) {
+		return lexer.Token{}, false
+	}
+	t := p.tokens[i]
+	switch {
+	case typ == lexer.Char && (t.Type == lexer.Char || t.Type == lexer.Number):
+	case typ == lexer.String && t.Type == lexer.String:
+	case typ == lexer.Atom && t.Type == lexer.Atom && (t.Value == value || strings.HasPrefix(t.Value, "'")):
+	case t.Type == typ && t.Value == value:
+	default:
+		return lexer.Token{}, false
+	}
+	p.cursor = i + 1
+	p.last = t.Pos
+	return t, true
+}
+
+// The tokens that are printed as the literals, names, and variables.
+func isLiteral(typ lexer.TokenType) bool {
+	switch typ {
+	case lexer.Atom, lexer.Variable, lexer.Dummy, lexer.Number, lexer.String, lexer.Char:
+		return true
+	default:
+		return false
+	}
+}
+
+func before(a, b Pos) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+}
+
+// The first and the last of the positions held by the expressions,
+// including the "end" tokens closing the blocks.
+func spans(ends map[Pos]Pos, exprs ...Expr) (first, last Pos) {
+	see := func(pos Pos) {
+		if !pos.IsValid() {
+			return
+		}
+		if !first.IsValid() || before(pos, first) {
+			first = pos
+		}
+		if before(last, pos) {
+			last = pos
+		}
+		if end, ok := ends[pos]; ok && before(last, end) {
+			last = end
+		}
+	}
+	var walk func(Expr)
+	walkAll := func(exprs []Expr) {
+		for _, expr := range exprs {
+			walk(expr)
+		}
+	}
+	walk = func(expr Expr) {
+		switch val := expr.(type) {
+		case Tuple:
+			walkAll(val.Values)
+		case List:
+			walkAll(val.Values)
+		case BitString:
+			see(val.Pos)
+			for _, segment := range val.Segments {
+				walk(segment.Value)
+				walk(segment.Size)
+			}
+		case Bracket:
+			walk(val.Expr)
+		case UnaryOperation:
+			see(val.Pos)
+			walk(val.Rhs)
+		case BinaryOperation:
+			see(val.Pos)
+			walk(val.Lhs)
+			walk(val.Rhs)
+		case Call:
+			see(val.Pos)
+			walk(val.Callable)
+			walkAll(val.Args)
+		case AssertMatch:
+			walk(assertCall(val))
+		case RecordDecl:
+			see(val.Pos)
+			for _, field := range val.Fields {
+				walk(field.Value)
+			}
+		case Record:
+			see(val.Pos)
+			walk(val.Expr)
+			for _, field := range val.Fields {
+				walk(field.Value)
+			}
+		case RecordAccess:
+			see(val.Pos)
+			walk(val.Expr)
+		case Block:
+			see(val.Pos)
+			walkAll(val.Body)
+		case Definition:
+			see(val.Pos)
+			for _, branch := range val.Branches {
+				walkAll(branch.Args)
+				walkAll(branch.Guards)
+				walkAll(branch.Body)
+			}
+		case If:
+			see(val.Pos)
+			for _, branch := range val.Branches {
+				walk(branch.Cond)
+				walkAll(branch.Body)
+			}
+		case Case:
+			see(val.Pos)
+			walk(val.Arg)
+			for _, branch := range val.Branches {
+				walk(branch.Pattern)
+				walkAll(branch.Guards)
+				walkAll(branch.Body)
+			}
+		case Receive:
+			see(val.Pos)
+			for _, branch := range val.Branches {
+				walk(branch.Pattern)
+				walkAll(branch.Guards)
+				walkAll(branch.Body)
+			}
+			walk(val.After.Cond)
+			walkAll(val.After.Body)
+		case TryRecover:
+			see(val.Pos)
+			walkAll(val.Body)
+			walkAll(val.Recover)
+		case TryCatch:
+			see(val.Pos)
+			walkAll(val.Body)
+			for _, branch := range val.Branches {
+				walk(branch.Class)
+				walk(branch.Pattern)
+				walk(branch.Stack)
+				walkAll(branch.Guards)
+				walkAll(branch.Body)
+			}
+		}
+	}
+	walkAll(exprs)
+	return first, last
+}
@@ -0,0 +1,192 @@
+package format
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/twolodzko/goer/types"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"X=1,Y = X+2.", "X = 1,\nY = X + 2.\n"},
+		{"{ok,[1,2],<<A:8/integer-big,\"ab\">>}.", "{ok, [1, 2], <<A:8/integer-big, \"ab\">>}.\n"},
+		{"B = - -1, C = -X, D = not X.", "B = - -1,\nC = -X,\nD = not X.\n"},
+		{"F = fun(X)->X+1 end.", "F = fun(X) -> X + 1 end.\n"},
+		{"'hello world'.", "'hello world'.\n"},
+		{"{'max', 'foo'('x')}.", "{'max', foo('x')}.\n"},
+		// the original spelling of the characters and strings is kept
+		{"[$a, $\\n, \"x\\ty\"].", "[$a, $\\n, \"x\\ty\"].\n"},
+		{"fun f(X) -> X end.", "fun f(X) ->\n    X\nend.\n"},
+		{
+			"fun f(0) -> zero; (N) when N > 0 -> N end.",
+			"fun f\n    (0) ->\n        zero;\n    (N) when N > 0 ->\n        N\nend.\n",
+		},
+		{
+			"case X of 1->a; _->b end.",
+			"case X of\n    1 ->\n        a;\n    _ ->\n        b\nend.\n",
+		},
+		{
+			"if X > 0 -> pos; true -> neg end.",
+			"if\n    X > 0 ->\n        pos;\n    true ->\n        neg\nend.\n",
+		},
+		{
+			"R = receive {ok, X} -> X after 100 -> timeout end.",
+			"R =\n    receive\n        {ok, X} ->\n            X\n    after 100 ->\n        timeout\n    end.\n",
+		},
+		{"receive after 10 -> ok end.", "receive\nafter 10 ->\n    ok\nend.\n"},
+		{
+			"try foo() catch error:R:S -> {R, S}; _:Other -> Other end.",
+			"try\n    foo()\ncatch\n    error:R:S ->\n        {R, S};\n    Other ->\n        Other\nend.\n",
+		},
+		{"try 1 recover 2 end.", "try\n    1\nrecover\n    2\nend.\n"},
+		{"assert_match({ok,X},f()).", "assert_match({ok, X}, f()).\n"},
+		{"-record(user,{name,age=0}). U=#user{name=\"x\"}, U#user.age.", "-record(user, {name, age = 0}).\nU = #user{name = \"x\"},\nU#user.age.\n"},
+		{
+			"L = [aaaaaaaaaaaaaaaaaaaaaaaa, bbbbbbbbbbbbbbbbbbbbbbbbbbbbbb, cccccccccccccccccccccccccccccccc, dddddddddddddddddddddd].",
+			"L = [\n    aaaaaaaaaaaaaaaaaaaaaaaa,\n    bbbbbbbbbbbbbbbbbbbbbbbbbbbbbb,\n    cccccccccccccccccccccccccccccccc,\n    dddddddddddddddddddddd\n].\n",
+		},
+		// the shebang line is kept
+		{"#!/usr/bin/env goer\n% header\nX=1.\n", "#!/usr/bin/env goer\n% header\nX = 1.\n"},
+		{"#!/usr/bin/env goer\n\nX=1.\n", "#!/usr/bin/env goer\n\nX = 1.\n"},
+		// comments and blank lines
+		{"% header\n\n\n\nX = 1. % trailing\n% the end\n", "% header\n\nX = 1. % trailing\n% the end\n"},
+		{"X = 1,\n\n\nY = 2.", "X = 1,\n\nY = 2.\n"},
+		{
+			"fun f(X) ->\n  % leading\n  Y = X, % trailing\n\n  Y\n  % closing\nend.",
+			"fun f(X) ->\n    % leading\n    Y = X, % trailing\n\n    Y\n    % closing\nend.\n",
+		},
+		{
+			"case X of\n  % first\n  1 -> a;\n  % second\n  _ -> b % last\nend.",
+			"case X of\n    % first\n    1 ->\n        a;\n    % second\n    _ ->\n        b % last\nend.\n",
+		},
+		// the comments stay with the fields of the records
+		{
+			"-record(user, {name, % the name\n  age = 0}).",
+			"-record(user, {\n    name, % the name\n    age = 0\n}).\n",
+		},
+		{
+			"U = #user{\n  % first\n  name = \"x\",\n  age = 1}.",
+			"U = #user{\n    % first\n    name = \"x\",\n    age = 1\n}.\n",
+		},
+		// and so do the comments after the last elements
+		{
+			"-record(user, {name, % the name\n age = 0 % age\n}).",
+			"-record(user, {\n    name, % the name\n    age = 0 % age\n}).\n",
+		},
+		{"[1, % one\n 2 % two\n].", "[\n    1, % one\n    2 % two\n].\n"},
+		{"{a, % first\n b % last\n % own line\n}.", "{\n    a, % first\n    b % last\n    % own line\n}.\n"},
+		{"f(1, % one\n  2 % two\n).", "f(\n    1, % one\n    2 % two\n).\n"},
+		{"[{a, % inner\n  b}, c % outer\n].", "[\n    {\n        a, % inner\n        b\n    },\n    c % outer\n].\n"},
+		// the comment inside of the expression keeps it from being joined into one line
+		{"F = fun(X) ->\n  % identity\n  X end.", "F =\n    fun(X) ->\n        % identity\n        X\n    end.\n"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := Source(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, result)
+			}
+			again, err := Source(result)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if again != result {
+				t.Errorf("formatting is not idempotent, got:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestSourceInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := Source("X = (1.")
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// The examples are formatted as in the golden files, run
+// the tests with the `-update` flag to regenerate them.
+func TestGolden(t *testing.T) {
+	t.Parallel()
+
+	paths, err := filepath.Glob("../examples/*.ge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the cases that are not covered by the examples
+	cases, err := filepath.Glob("testdata/*.ge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths, cases...)
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := Source(string(src))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			golden := filepath.Join("testdata", filepath.Base(path)+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(result), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != string(expected) {
+				t.Errorf("expected:\n%s\ngot:\n%s", expected, result)
+			}
+
+			again, err := Source(result)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if again != result {
+				t.Errorf("formatting is not idempotent, got:\n%s", again)
+			}
+
+			// the formatted code is the same code
+			before, _, err := parseAll(string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, _, err := parseAll(result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(before, after, cmpopts.IgnoreTypes(types.Pos{})); diff != "" {
+				t.Errorf("the code changed after formatting:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package goer
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+
+	"github.com/twolodzko/goer/core"
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/pids"
+	"github.com/twolodzko/goer/types"
+)
+
+// Convert the Go value to the goer term:
+//
+//   - the booleans, integers, and strings to the goer ones,
+//   - the byte slices to binaries,
+//   - the slices and arrays to lists,
+//   - the maps to the lists of the `{Key, Value}` tuples, ordered by the keys,
+//   - nil to the `undefined` atom.
+//
+// The terms are kept as they are, but the values in the lists and tuples are converted.
+func FromGo(value any) (Term, error) {
+	switch value := value.(type) {
+	case nil:
+		return types.Atom("undefined"), nil
+	case types.Bool, types.Int, types.String, types.Atom, types.Binary, Pid,
+		core.Fun, *core.Closure, *core.Generator, func([]types.Expr, *envir.Env, pids.Pid) (types.Expr, error):
+		return value, nil
+	case types.Tuple:
+		values, err := fromGoAll(value.Values)
+		if err != nil {
+			return nil, err
+		}
+		return types.Tuple{values}, nil
+	case types.List:
+		values, err := fromGoAll(value.Values)
+		if err != nil {
+			return nil, err
+		}
+		return types.List{values}, nil
+	case bool:
+		return types.Bool(value), nil
+	case string:
+		return types.String(value), nil
+	case []byte:
+		return types.Binary(value), nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return types.Int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt {
+			return nil, fmt.Errorf("%v is too large for the goer integer", value)
+		}
+		return types.Int(v.Uint()), nil
+	case reflect.Slice, reflect.Array:
+		values := make([]types.Expr, v.Len())
+		for i := range values {
+			var err error
+			if values[i], err = FromGo(v.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+		return types.List{values}, nil
+	case reflect.Map:
+		var pairs []types.Expr
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := FromGo(iter.Key().Interface())
+			if err != nil {
+				return nil, err
+			}
+			val, err := FromGo(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, types.Tuple{[]types.Expr{key, val}})
+		}
+		slices.SortFunc(pairs, types.Compare)
+		return types.List{pairs}, nil
+	}
+	return nil, fmt.Errorf("%T cannot be converted to the goer term", value)
+}
+
+func fromGoAll(values []types.Expr) ([]types.Expr, error) {
+	converted := make([]types.Expr, len(values))
+	for i, value := range values {
+		var err error
+		if converted[i], err = FromGo(value); err != nil {
+			return nil, err
+		}
+	}
+	return converted, nil
+}
+
+// Convert the goer term to the Go value:
+//
+//   - the booleans, integers, and strings to the Go ones,
+//   - the binaries to byte slices,
+//   - the lists and tuples to the `[]any` slices.
+//
+// The atoms, pids, and functions are kept as they are.
+func ToGo(term Term) any {
+	switch term := term.(type) {
+	case types.Bool:
+		return bool(term)
+	case types.Int:
+		return int(term)
+	case types.String:
+		return string(term)
+	case types.Binary:
+		return []byte(term)
+	case types.List:
+		return toGoAll(term.Values)
+	case types.Tuple:
+		return toGoAll(term.Values)
+	default:
+		return term
+	}
+}
+
+func toGoAll(terms []types.Expr) []any {
+	values := make([]any, len(terms))
+	for i, term := range terms {
+		values[i] = ToGo(term)
+	}
+	return values
+}
@@ -0,0 +1,327 @@
+package goer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	goerrors "github.com/twolodzko/goer/core/errors"
+)
+
+var configs = []struct {
+	name   string
+	config Config
+}{
+	{"tree", Config{}},
+	{"vm", Config{VM: true}},
+}
+
+func TestEval(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range configs {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			in := New(tt.config)
+			defer in.Close()
+
+			ctx := context.Background()
+			if _, err := in.Eval(ctx, "fun double(X) -> X * 2 end. Y = 21."); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			// the names are kept between the evaluations
+			result, err := in.Eval(ctx, "double(Y).")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !cmp.Equal(result, Term(Int(42))) {
+				t.Errorf("expected 42, got %v", result)
+			}
+
+			result, err = in.Call(ctx, "double", 5)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !cmp.Equal(result, Term(Int(10))) {
+				t.Errorf("expected 10, got %v", result)
+			}
+			if _, err := in.Call(ctx, "unknown"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range configs {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			in := New(tt.config)
+			defer in.Close()
+
+			in.Register("lookup", func(args ...Term) (Term, error) {
+				if len(args) != 1 {
+					return nil, fmt.Errorf("expected one argument, got %d", len(args))
+				}
+				users := map[string]int{"alice": 32, "bob": 27}
+				age, ok := users[ToGo(args[0]).(string)]
+				if !ok {
+					return nil, fmt.Errorf("%v not found", args[0])
+				}
+				return age, nil
+			})
+
+			result, err := in.Eval(context.Background(), `
+			Alice = lookup("alice"),
+			Missing = try lookup("eve") catch error:Reason -> Reason end,
+			Arity = try lookup() catch error:R -> R end,
+			{Alice, Missing, Arity}.
+			`)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected := Tuple{[]Term{Int(32), String("\"eve\" not found"), String("expected one argument, got 0")}}
+			if !cmp.Equal(result, Term(expected)) {
+				t.Errorf("expected %v, got %v", expected, result)
+			}
+
+			// the user-defined functions take precedence
+			result, err = in.Eval(context.Background(), "fun lookup(X) -> X end, lookup(eve).")
+			if err != nil || !cmp.Equal(result, Term(Atom("eve"))) {
+				t.Errorf("expected eve, got %v (%v)", result, err)
+			}
+		})
+	}
+}
+
+func TestFromGo(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		value    any
+		expected Term
+	}{
+		{nil, Atom("undefined")},
+		{true, Bool(true)},
+		{42, Int(42)},
+		{int8(-3), Int(-3)},
+		{uint16(7), Int(7)},
+		{"abc", String("abc")},
+		{[]byte{1, 2}, Binary{1, 2}},
+		{Atom("ok"), Atom("ok")},
+		{[]int{1, 2, 3}, List{[]Term{Int(1), Int(2), Int(3)}}},
+		{[2]string{"a", "b"}, List{[]Term{String("a"), String("b")}}},
+		{[]any{1, "a", []any{true}}, List{[]Term{Int(1), String("a"), List{[]Term{Bool(true)}}}}},
+		{map[string]int{"b": 2, "a": 1}, List{[]Term{
+			Tuple{[]Term{String("a"), Int(1)}},
+			Tuple{[]Term{String("b"), Int(2)}},
+		}}},
+		{Tuple{[]Term{Atom("ok"), 1}}, Tuple{[]Term{Atom("ok"), Int(1)}}},
+		{List{[]Term{"x"}}, List{[]Term{String("x")}}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprintf("%#v", tt.value), func(t *testing.T) {
+			t.Parallel()
+
+			result, err := FromGo(tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !cmp.Equal(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFromGoInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range []any{1.5, struct{}{}, []any{1, 2.5}, uint64(1 << 63), Tuple{[]Term{1.5}}} {
+		if _, err := FromGo(value); err == nil {
+			t.Errorf("expected an error for %#v", value)
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		term     Term
+		expected any
+	}{
+		{Bool(true), true},
+		{Int(42), 42},
+		{String("abc"), "abc"},
+		{Binary{1, 2}, []byte{1, 2}},
+		{Atom("ok"), Atom("ok")},
+		{List{[]Term{Int(1), String("a")}}, []any{1, "a"}},
+		{Tuple{[]Term{Atom("ok"), List{[]Term{Bool(false)}}}}, []any{Atom("ok"), []any{false}}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprint(tt.term), func(t *testing.T) {
+			t.Parallel()
+
+			result := ToGo(tt.term)
+			if !cmp.Equal(result, tt.expected) {
+				t.Errorf("expected %#v, got %#v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		code     string
+		cancel   bool
+		expected error
+	}{
+		{"fun loop() -> loop() end, loop().", false, context.DeadlineExceeded},
+		{"fun loop(N) -> loop(N + 1) end, loop(0).", true, context.Canceled},
+		{"receive never -> ok end.", false, context.DeadlineExceeded},
+		{"sleep(10000).", true, context.Canceled},
+		// it cannot be caught
+		{"fun loop() -> loop() end, try loop() catch _:_ -> caught end.", false, context.DeadlineExceeded},
+		{"try receive never -> ok end recover caught end.", true, context.Canceled},
+	}
+
+	for _, engine := range configs {
+		for _, tt := range testCases {
+			t.Run(engine.name+" "+tt.code, func(t *testing.T) {
+				t.Parallel()
+
+				in := New(engine.config)
+				defer in.Close()
+
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				if tt.cancel {
+					time.AfterFunc(10*time.Millisecond, cancel)
+				}
+				_, err := in.Eval(ctx, tt.code)
+				if !errors.Is(err, tt.expected) {
+					t.Errorf("expected %v, got %v", tt.expected, err)
+				}
+				var stopped goerrors.Stopped
+				if !errors.As(err, &stopped) {
+					t.Errorf("expected the process to be stopped, got %v", err)
+				}
+
+				// the interpreter can be used again
+				result, err := in.Eval(context.Background(), "1 + 2.")
+				if err != nil || !cmp.Equal(result, Term(Int(3))) {
+					t.Errorf("expected 3, got %v (%v)", result, err)
+				}
+			})
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range configs {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			in := New(tt.config)
+			defer in.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			// the messages sent from Go can be received by the code
+			in.Send(in.Self(), 42)
+			result, err := in.Eval(ctx, "receive X -> X + 1 end.")
+			if err != nil || !cmp.Equal(result, Term(Int(43))) {
+				t.Errorf("expected 43, got %v (%v)", result, err)
+			}
+
+			// the process outlives the evaluation that spawned it
+			server, err := in.Eval(ctx, `
+			fun serve() ->
+				receive
+					{From, Msg} ->
+						From ! {echo, Msg},
+						serve()
+				end
+			end,
+			spawn(fun() -> serve() end).
+			`)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			for _, msg := range []string{"hello", "world"} {
+				if err := in.Send(server, Tuple{[]Term{in.Self(), msg}}); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				reply, err := in.Receive(ctx)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if expected := []any{Atom("echo"), msg}; !cmp.Equal(ToGo(reply), expected) {
+					t.Errorf("expected %v, got %v", expected, reply)
+				}
+			}
+
+			if err := in.Send(Atom("nobody"), 1); err == nil {
+				t.Error("expected an error")
+			}
+			short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if _, err := in.Receive(short); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected the deadline to pass, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHalt(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range configs {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			in := New(tt.config)
+			defer in.Close()
+
+			// halting the spawned process does not exit the program, but stops the interpreter
+			_, err := in.Eval(context.Background(), "spawn(fun() -> halt(3) end), receive never -> ok end.")
+			if expected := (goerrors.Halt{3}); !cmp.Equal(goerrors.Cause(err), error(expected)) {
+				t.Errorf("expected %v, got %v", expected, err)
+			}
+			_, err = in.Eval(context.Background(), "1 + 2, self().")
+			if expected := (goerrors.Halt{3}); !cmp.Equal(goerrors.Cause(err), error(expected)) {
+				t.Errorf("expected %v, got %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	in := New(Config{})
+	in.Close()
+
+	_, err := in.Eval(context.Background(), "self().")
+	var stopped goerrors.Stopped
+	if !errors.As(err, &stopped) || !strings.Contains(err.Error(), "the interpreter was closed") {
+		t.Errorf("expected the interpreter to be closed, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package goer
+
+import (
+	"context"
+	"time"
+
+	"github.com/twolodzko/goer/core"
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	"github.com/twolodzko/goer/types"
+)
+
+// The goer values.
+type (
+	Term   = types.Expr
+	Atom   = types.Atom
+	Bool   = types.Bool
+	Int    = types.Int
+	String = types.String
+	Binary = types.Binary
+	Tuple  = types.Tuple
+	List   = types.List
+	Pid    = pids.Pid
+)
+
+// The configuration of the interpreter, the zero value is the default one.
+type Config struct {
+	// compile the code to the bytecode and run it on the virtual machine
+	VM bool
+	// the restrictions of the code, nil when it is not sandboxed
+	Sandbox *Sandbox
+}
+
+// Interpreter of the goer code embedded in the Go program. The code is evaluated
+// in the interpreter's own process, so the names it defines are kept between
+// the evaluations, and the messages sent to it can be received by the Go code.
+// The code should not be evaluated concurrently.
+type Interpreter struct {
+	env      *envir.Env
+	builtins *envir.Env
+	pid      pids.Pid
+	group    *pids.Group
+	evalCode func(string, *envir.Env, pids.Pid) (types.Expr, error)
+	evalFile func(string, *envir.Env, pids.Pid) (types.Expr, error)
+	// the time limit of the evaluation, zero if there is none
+	timeout time.Duration
+}
+
+// Create the interpreter, it should be closed when it is not needed anymore.
+func New(config Config) *Interpreter {
+	builtins := core.NewEnv()
+	evalCode, evalFile := core.EvalCode, core.EvalFile
+	if config.VM {
+		evalCode, evalFile = core.RunCode, core.RunFile
+	}
+	var timeout time.Duration
+	if config.Sandbox != nil {
+		config.Sandbox.restrict(builtins, evalFile)
+		timeout = config.Sandbox.Timeout
+	}
+	return &Interpreter{
+		builtins.Branch(),
+		builtins,
+		pids.NewPid(),
+		pids.NewGroup(context.Background(), config.Sandbox.limits()),
+		evalCode,
+		evalFile,
+		timeout,
+	}
+}
+
+// Stop the processes spawned by the code, and release the interpreter.
+func (in *Interpreter) Close() {
+	in.group.Stop(errors.New("the interpreter was closed"))
+	in.pid.Close()
+}
+
+// Make the Go function callable from the code by the name, like the build-in functions.
+// It gets the arguments as the goer terms, the result is converted with `FromGo`.
+// The error it returns is thrown as the goer error.
+func (in *Interpreter) Register(name string, fn func(args ...Term) (Term, error)) {
+	in.builtins.Set(name, func(args []types.Expr, _ *envir.Env, _ pids.Pid) (types.Expr, error) {
+		result, err := fn(args...)
+		if err != nil {
+			return nil, err
+		}
+		return FromGo(result)
+	})
+}
+
+// Evaluate the code and return the result of its last expression, the expressions are
+// terminated with the dots, as in the files. The evaluation is stopped when the context
+// is done, the error then wraps the context's error. The processes spawned by the code
+// keep running until the interpreter is closed.
+func (in *Interpreter) Eval(ctx context.Context, code string) (Term, error) {
+	return in.run(ctx, func(pid pids.Pid) (types.Expr, error) {
+		return in.evalCode(code, in.env, pid)
+	})
+}
+
+// Evaluate the file, like `Eval`.
+func (in *Interpreter) EvalFile(ctx context.Context, path string) (Term, error) {
+	return in.run(ctx, func(pid pids.Pid) (types.Expr, error) {
+		return in.evalFile(path, in.env, pid)
+	})
+}
+
+// Call the function defined in the code, or the build-in, with the arguments
+// converted with `FromGo`. It is stopped when the context is done, like `Eval`.
+func (in *Interpreter) Call(ctx context.Context, name string, args ...any) (Term, error) {
+	fun, err := in.env.Get(types.Atom(name))
+	if err != nil {
+		return nil, err
+	}
+	terms := make([]types.Expr, len(args))
+	for i, arg := range args {
+		if terms[i], err = FromGo(arg); err != nil {
+			return nil, err
+		}
+	}
+	return in.run(ctx, func(pid pids.Pid) (types.Expr, error) {
+		return core.Apply(fun, terms, in.env, pid)
+	})
+}
+
+// Run the evaluation in the interpreter's process stopped when the context is done,
+// or when the interpreter is closed. The reductions are counted for each evaluation,
+// while the memory also counts the terms bound in the interpreter by the previous
+// ones. Calling `halt`, or exceeding the limits of the sandbox, stops all its processes.
+func (in *Interpreter) run(ctx context.Context, eval func(pids.Pid) (types.Expr, error)) (Term, error) {
+	in.group.Reset(core.Retained(in.env))
+	if in.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, in.timeout, errors.TimeLimit{in.timeout})
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	group := in.group.Context()
+	if group.Err() != nil {
+		cancel(context.Cause(group))
+	}
+	stop := context.AfterFunc(group, func() { cancel(context.Cause(group)) })
+	defer stop()
+
+	result, err := eval(in.pid.WithContext(ctx, in.group))
+	if errors.IsFatal(err) {
+		in.group.Stop(errors.Cause(err))
+	}
+	return result, err
+}
+
+// The pid of the interpreter's process, `self()` in the evaluated code.
+func (in *Interpreter) Self() Pid {
+	return in.pid
+}
+
+// Send the message, converted with `FromGo`, to the process.
+func (in *Interpreter) Send(to Term, msg any) error {
+	pid, ok := to.(pids.Pid)
+	if !ok {
+		return errors.New("%v is not a pid", to)
+	}
+	term, err := FromGo(msg)
+	if err != nil {
+		return err
+	}
+	return pid.Send(term)
+}
+
+// Receive the message sent to the interpreter's process, waiting for it until the context is done.
+func (in *Interpreter) Receive(ctx context.Context) (Term, error) {
+	select {
+	case msg := <-in.pid.Messages():
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
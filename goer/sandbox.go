@@ -0,0 +1,118 @@
+package goer
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	"github.com/twolodzko/goer/types"
+)
+
+// The restrictions of the code run in the sandbox, the zero values mean no limit.
+// When any of the limits is exceeded, all the processes of the interpreter are stopped.
+type Sandbox struct {
+	// the build-in functions that can be called, all of them when nil
+	Builtins []string
+	// the directory that the files can be included from, relative to it,
+	// when empty, the files cannot be included
+	Root string
+	// the processes spawned by the code, running at once
+	Processes int
+	// the function calls made by all the processes during each evaluation
+	Reductions int
+	// the messages waiting in the mailbox of a process
+	Mailbox int
+	// the rough size of the terms created by all the processes during each evaluation, in words
+	Memory int
+	// the time that each evaluation can take
+	Timeout time.Duration
+}
+
+func (s *Sandbox) limits() pids.Limits {
+	if s == nil {
+		return pids.Limits{}
+	}
+	return pids.Limits{
+		Processes:  s.Processes,
+		Reductions: s.Reductions,
+		Mailbox:    s.Mailbox,
+		Memory:     s.Memory,
+	}
+}
+
+// Replace the build-in functions that are not allowed, and the `include`
+// that would read the files from outside of the root directory.
+func (s *Sandbox) restrict(builtins *envir.Env, evalFile func(string, *envir.Env, pids.Pid) (types.Expr, error)) {
+	builtins.Set("include", includeFrom(s.Root, evalFile))
+	if s.Builtins == nil {
+		return
+	}
+	allowed := make(map[string]bool)
+	for _, name := range s.Builtins {
+		allowed[name] = true
+	}
+	for name := range builtins.Elems {
+		if !allowed[name] {
+			builtins.Set(name, notAllowed(name))
+		}
+	}
+}
+
+func notAllowed(name string) func([]types.Expr, *envir.Env, pids.Pid) (types.Expr, error) {
+	return func([]types.Expr, *envir.Env, pids.Pid) (types.Expr, error) {
+		return nil, errors.NotAllowed{name}
+	}
+}
+
+// The `include` build-in reading only the files from the root directory,
+// they are evaluated by the same engine as the code.
+func includeFrom(root string, evalFile func(string, *envir.Env, pids.Pid) (types.Expr, error)) func([]types.Expr, *envir.Env, pids.Pid) (types.Expr, error) {
+	return func(args []types.Expr, env *envir.Env, pid pids.Pid) (types.Expr, error) {
+		if len(args) != 1 {
+			return nil, errors.WrongNumberArgs{}
+		}
+		path, ok := args[0].(types.String)
+		if !ok {
+			return nil, errors.NotString{args[0]}
+		}
+		resolved, ok := inside(root, string(path))
+		if !ok {
+			return nil, errors.AccessDenied{string(path)}
+		}
+		return evalFile(resolved, env, pid)
+	}
+}
+
+// Resolve the path relative to the root directory, it should not lead outside of it,
+// also by following the symbolic links.
+func inside(root, path string) (string, bool) {
+	if root == "" {
+		return "", false
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", false
+	}
+	path = filepath.Join(root, path)
+	if !within(root, path) {
+		return "", false
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", false
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// the file does not exist, reading it will fail
+		return path, true
+	}
+	return path, within(realRoot, realPath)
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
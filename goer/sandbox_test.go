@@ -0,0 +1,241 @@
+package goer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/twolodzko/goer/core"
+	goerrors "github.com/twolodzko/goer/core/errors"
+)
+
+func TestSandboxLimits(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		sandbox  Sandbox
+		code     string
+		expected error
+	}{
+		{
+			Sandbox{Processes: 2},
+			"spawn(fun() -> receive after 1000 -> ok end end), spawn(fun() -> receive after 1000 -> ok end end).",
+			nil,
+		},
+		{
+			Sandbox{Processes: 2},
+			"F = fun() -> receive after 1000 -> ok end end, spawn(F), spawn(F), spawn(F).",
+			goerrors.ProcessLimit{2},
+		},
+		{
+			Sandbox{Processes: 2},
+			"fun loop(0) -> ok; (N) -> spawn(fun() -> ok end), sleep(1), loop(N - 1) end, loop(5).",
+			nil,
+		},
+		{Sandbox{Reductions: 1000}, "fun loop(N) -> loop(N + 1) end, loop(0).", goerrors.ReductionLimit{1000}},
+		{Sandbox{Reductions: 1000}, "fun loop(N) -> loop(N + 1) end, try loop(0) catch _:_ -> caught end.", goerrors.ReductionLimit{1000}},
+		{
+			Sandbox{Reductions: 1000},
+			"spawn(fun() -> fun loop() -> loop() end, loop() end), receive after 1000 -> ok end.",
+			goerrors.ReductionLimit{1000},
+		},
+		{Sandbox{Mailbox: 3}, "self() ! 1, self() ! 2, self() ! 3.", nil},
+		{Sandbox{Mailbox: 3}, "self() ! 1, self() ! 2, self() ! 3, self() ! 4.", goerrors.MailboxLimit{3}},
+		{Sandbox{Memory: 1000}, "[{1, [2, 3], \"abc\", <<1, 2>>}] ++ [4].", nil},
+		{Sandbox{Memory: 1000}, "fun grow(L) -> grow(L ++ [L]) end, grow([]).", goerrors.MemoryLimit{1000}},
+		{Sandbox{Memory: 1000}, "fun grow(S) -> grow({S, S}) end, grow(x).", goerrors.MemoryLimit{1000}},
+		{Sandbox{Memory: 1000}, `format("~100w ~.8p", [1, {a, b}]).`, nil},
+		{Sandbox{Memory: 1000}, `format("~1000000000000w", [1]).`, goerrors.MemoryLimit{1000}},
+		{Sandbox{Memory: 1000}, "<<1:64, 2:800>>.", nil},
+		{Sandbox{Memory: 1000}, "X = <<0:8000000000000>>.", goerrors.MemoryLimit{1000}},
+		{Sandbox{Timeout: 20 * time.Millisecond}, "receive after 10 -> ok end.", nil},
+		{Sandbox{Timeout: 20 * time.Millisecond}, "receive after 1000 -> ok end.", goerrors.TimeLimit{20 * time.Millisecond}},
+		{Sandbox{Timeout: 20 * time.Millisecond}, "fun loop() -> loop() end, loop().", goerrors.TimeLimit{20 * time.Millisecond}},
+	}
+
+	for _, engine := range configs {
+		for _, tt := range testCases {
+			t.Run(engine.name+" "+tt.code, func(t *testing.T) {
+				t.Parallel()
+
+				config := engine.config
+				config.Sandbox = &tt.sandbox
+				in := New(config)
+				defer in.Close()
+
+				_, err := in.Eval(context.Background(), tt.code)
+				if !cmp.Equal(goerrors.Cause(err), tt.expected) {
+					t.Fatalf("expected %v, got %v", tt.expected, err)
+				}
+				if tt.expected == nil {
+					return
+				}
+				// all the processes were stopped
+				_, err = in.Eval(context.Background(), "self().")
+				if !cmp.Equal(goerrors.Cause(err), tt.expected) {
+					t.Errorf("expected %v, got %v", tt.expected, err)
+				}
+			})
+		}
+	}
+}
+
+func TestSandboxLimitsPerEval(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range configs {
+		config := engine.config
+		config.Sandbox = &Sandbox{Reductions: 1000, Memory: 1000}
+		in := New(config)
+		defer in.Close()
+
+		if _, err := in.Eval(context.Background(), "fun f(X) -> X end."); err != nil {
+			t.Fatal(err)
+		}
+		for i := range 500 {
+			_, err := in.Eval(context.Background(), `{len([1]), f([1, 2, 3]), "abc"}.`)
+			if err != nil {
+				t.Fatalf("%s: evaluation %d failed: %v", engine.name, i, err)
+			}
+		}
+		result, err := in.Eval(context.Background(), "1.")
+		if err != nil || result != Int(1) {
+			t.Errorf("%s: expected 1, got %v and %v", engine.name, result, err)
+		}
+	}
+}
+
+func TestSandboxMemoryKeptBetweenEvals(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range configs {
+		config := engine.config
+		config.Sandbox = &Sandbox{Memory: 100}
+		in := New(config)
+		defer in.Close()
+
+		list := "[" + strings.Repeat("0, ", 79) + "0]"
+		var err error
+		for i := range 3 {
+			_, err = in.Eval(context.Background(), fmt.Sprintf("L%d = %s.", i, list))
+			if err != nil {
+				break
+			}
+		}
+		if expected := (goerrors.MemoryLimit{100}); !cmp.Equal(goerrors.Cause(err), error(expected)) {
+			t.Errorf("%s: expected %v, got %v", engine.name, expected, err)
+		}
+	}
+}
+
+func TestSandboxBuiltins(t *testing.T) {
+	t.Parallel()
+
+	for _, engine := range configs {
+		t.Run(engine.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := engine.config
+			config.Sandbox = &Sandbox{Builtins: []string{"len", "self"}}
+			in := New(config)
+			defer in.Close()
+			in.Register("double", func(args ...Term) (Term, error) {
+				return ToGo(args[0]).(int) * 2, nil
+			})
+
+			ctx := context.Background()
+			result, err := in.Eval(ctx, "{len([1, 2]), double(2), try print(hello) catch error:{not_allowed, _} -> denied end}.")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected := Tuple{[]Term{Int(2), Int(4), Atom("denied")}}
+			if !cmp.Equal(result, Term(expected)) {
+				t.Errorf("expected %v, got %v", expected, result)
+			}
+
+			_, err = in.Eval(ctx, "spawn(fun() -> ok end).")
+			if expected := (goerrors.NotAllowed{"spawn"}); !cmp.Equal(goerrors.Cause(err), error(expected)) {
+				t.Errorf("expected %v, got %v", expected, err)
+			}
+			_, err = in.Call(ctx, "include", "file.ge")
+			if expected := (goerrors.NotAllowed{"include"}); !cmp.Equal(goerrors.Cause(err), error(expected)) {
+				t.Errorf("expected %v, got %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestSandboxInclude(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	for path, content := range map[string]string{
+		"root/lib.ge":          "fun double(X) -> X * 2 end.",
+		"root/nested/other.ge": "fun triple(X) -> X * 3 end.",
+		"secret.ge":            "fun secret() -> 42 end.",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.ge"), filepath.Join(root, "link.ge")); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		root     string
+		path     string
+		expected error
+	}{
+		{root, "lib.ge", nil},
+		{root, "nested/other.ge", nil},
+		{root, "nested/../lib.ge", nil},
+		{root, "../secret.ge", goerrors.AccessDenied{"../secret.ge"}},
+		{root, "nested/../../secret.ge", goerrors.AccessDenied{"nested/../../secret.ge"}},
+		{root, "link.ge", goerrors.AccessDenied{"link.ge"}},
+		{"", "lib.ge", goerrors.AccessDenied{"lib.ge"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.root+" "+tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			in := New(Config{Sandbox: &Sandbox{Root: tt.root}})
+			defer in.Close()
+
+			_, err := in.Call(context.Background(), "include", tt.path)
+			if !cmp.Equal(goerrors.Cause(err), tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	// the absolute paths are relative to the root as well
+	in := New(Config{Sandbox: &Sandbox{Root: root}})
+	defer in.Close()
+	result, err := in.Eval(context.Background(), `include("/lib.ge"), double(21).`)
+	if err != nil || !cmp.Equal(result, Term(Int(42))) {
+		t.Errorf("expected 42, got %v (%v)", result, err)
+	}
+
+	// the files are run by the same engine as the code
+	in = New(Config{VM: true, Sandbox: &Sandbox{Root: root}})
+	defer in.Close()
+	if _, err := in.Eval(context.Background(), `include("lib.ge").`); err != nil {
+		t.Fatal(err)
+	}
+	fun, _ := in.env.Get(Atom("double"))
+	if _, ok := fun.(*core.Closure); !ok {
+		t.Errorf("expected the compiled function, got %T", fun)
+	}
+}
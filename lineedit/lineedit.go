@@ -0,0 +1,306 @@
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// The line was abandoned with ^C.
+var ErrInterrupted = errors.New("interrupted")
+
+// Editor reads the lines typed in the terminal, letting the user move the cursor,
+// recall the previous lines from the history, and complete the names with Tab.
+// When the input is not a terminal, the lines are read as they are.
+type Editor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	fd      int // the terminal that is put in the raw mode, or -1
+	editing bool
+	// the lines entered earlier, the latest last
+	History []string
+	// the names that the words are completed to
+	Complete func() []string
+}
+
+func NewEditor(in io.Reader, out io.Writer) *Editor {
+	e := &Editor{bufio.NewReader(in), out, -1, false, nil, nil}
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		e.fd = int(f.Fd())
+		e.editing = true
+	}
+	return e
+}
+
+// The input is the terminal, so the lines are edited as they are typed.
+func (e *Editor) Interactive() bool {
+	return e.editing
+}
+
+// Add the line to the history, unless it is empty or it repeats the last one.
+func (e *Editor) AddHistory(line string) bool {
+	if strings.TrimSpace(line) == "" || (len(e.History) > 0 && e.History[len(e.History)-1] == line) {
+		return false
+	}
+	e.History = append(e.History, line)
+	return true
+}
+
+// Show the prompt and read the line. At the end of the input, or when ^D
+// is pressed in the empty line, it returns `io.EOF`.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !e.editing {
+		return e.readPlain(prompt)
+	}
+	if e.fd >= 0 {
+		restore, err := makeRaw(e.fd)
+		if err != nil {
+			return e.readPlain(prompt)
+		}
+		defer restore()
+	}
+	return e.edit(prompt)
+}
+
+func (e *Editor) readPlain(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// The keys, as they are read in the raw mode.
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlH     = 8
+	tab       = 9
+	lineFeed  = 10
+	ctrlK     = 11
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlU     = 21
+	ctrlW     = 23
+	escape    = 27
+	backspace = 127
+)
+
+// The line being edited.
+type state struct {
+	prompt string
+	line   []rune
+	pos    int
+	// the position in the history, and the line that was edited before moving through it
+	index   int
+	current []rune
+}
+
+func (e *Editor) edit(prompt string) (string, error) {
+	s := &state{prompt: prompt, index: len(e.History)}
+	e.refresh(s)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(s.line) > 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return string(s.line), nil
+			}
+			return "", err
+		}
+		switch r {
+		case enter, lineFeed:
+			fmt.Fprint(e.out, "\r\n")
+			return string(s.line), nil
+		case ctrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case ctrlD:
+			if len(s.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			s.delete()
+		case backspace, ctrlH:
+			if s.pos > 0 {
+				s.pos--
+				s.delete()
+			}
+		case ctrlA:
+			s.pos = 0
+		case ctrlE:
+			s.pos = len(s.line)
+		case ctrlB:
+			s.pos = max(s.pos-1, 0)
+		case ctrlF:
+			s.pos = min(s.pos+1, len(s.line))
+		case ctrlK:
+			s.line = s.line[:s.pos]
+		case ctrlU:
+			s.line = s.line[s.pos:]
+			s.pos = 0
+		case ctrlW:
+			start := s.pos
+			for start > 0 && unicode.IsSpace(s.line[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(s.line[start-1]) {
+				start--
+			}
+			s.line = slices.Delete(s.line, start, s.pos)
+			s.pos = start
+		case ctrlP:
+			e.recall(s, -1)
+		case ctrlN:
+			e.recall(s, 1)
+		case tab:
+			e.complete(s)
+		case escape:
+			e.escape(s)
+		default:
+			if unicode.IsPrint(r) {
+				s.line = slices.Insert(s.line, s.pos, r)
+				s.pos++
+			}
+		}
+		e.refresh(s)
+	}
+}
+
+// Handle the escape sequences of the arrows, Home, End, and Delete keys.
+func (e *Editor) escape(s *state) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return
+	}
+	if r >= '0' && r <= '9' {
+		// the `ESC [ n ~` sequences
+		if next, _, err := e.in.ReadRune(); err != nil || next != '~' {
+			return
+		}
+		switch r {
+		case '1', '7':
+			r = 'H'
+		case '4', '8':
+			r = 'F'
+		case '3':
+			s.delete()
+			return
+		}
+	}
+	switch r {
+	case 'A':
+		e.recall(s, -1)
+	case 'B':
+		e.recall(s, 1)
+	case 'C':
+		s.pos = min(s.pos+1, len(s.line))
+	case 'D':
+		s.pos = max(s.pos-1, 0)
+	case 'H':
+		s.pos = 0
+	case 'F':
+		s.pos = len(s.line)
+	}
+}
+
+// Delete the character under the cursor.
+func (s *state) delete() {
+	if s.pos < len(s.line) {
+		s.line = slices.Delete(s.line, s.pos, s.pos+1)
+	}
+}
+
+// Move through the history, the line edited before is restored when moving past its end.
+func (e *Editor) recall(s *state, step int) {
+	index := s.index + step
+	if index < 0 || index > len(e.History) {
+		return
+	}
+	if s.index == len(e.History) {
+		s.current = s.line
+	}
+	s.index = index
+	if index == len(e.History) {
+		s.line = s.current
+	} else {
+		s.line = []rune(e.History[index])
+	}
+	s.pos = len(s.line)
+}
+
+// Complete the word before the cursor. When there are many candidates, it is extended
+// up to their common prefix, or when that is not possible, the candidates are listed.
+func (e *Editor) complete(s *state) {
+	if e.Complete == nil {
+		return
+	}
+	start := s.pos
+	for start > 0 && isWordChar(s.line[start-1]) {
+		start--
+	}
+	word := string(s.line[start:s.pos])
+	if word == "" {
+		return
+	}
+
+	var candidates []string
+	for _, name := range e.Complete() {
+		if strings.HasPrefix(name, word) && !slices.Contains(candidates, name) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	slices.Sort(candidates)
+
+	prefix := commonPrefix(candidates)
+	if prefix == word && len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		return
+	}
+	insert := []rune(prefix)[len([]rune(word)):]
+	s.line = slices.Insert(s.line, s.pos, insert...)
+	s.pos += len(insert)
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '@' || r == ':'
+}
+
+func commonPrefix(words []string) string {
+	prefix := []rune(words[0])
+	for _, word := range words[1:] {
+		runes := []rune(word)
+		n := 0
+		for n < len(prefix) && n < len(runes) && prefix[n] == runes[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
+
+// Redraw the line and place the cursor.
+func (e *Editor) refresh(s *state) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", s.prompt, string(s.line))
+	if back := len(s.line) - s.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
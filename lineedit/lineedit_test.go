@@ -0,0 +1,173 @@
+package lineedit
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Editor reading the keys as they would be typed in the terminal.
+func typing(keys string) *Editor {
+	e := NewEditor(strings.NewReader(keys), io.Discard)
+	e.editing = true
+	return e
+}
+
+func TestInteractive(t *testing.T) {
+	t.Parallel()
+
+	if NewEditor(strings.NewReader("abc\n"), io.Discard).Interactive() {
+		t.Error("the editor reading from a string should not be interactive")
+	}
+	if !typing("abc\n").Interactive() {
+		t.Error("the editor reading from the terminal should be interactive")
+	}
+}
+
+func TestEdit(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		keys     string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"abc\n", "abc"},
+		{"abc", "abc"},
+		{"abd\x7fc\r", "abc"},
+		// arrows
+		{"ac\x1b[Db\r", "abc"},
+		{"ac\x1b[D\x1b[D\x1b[Cb\r", "abc"},
+		{"bc\x1b[Ha\x1b[Fd\r", "abcd"},
+		{"bc\x1bOHa\x1bOFd\r", "abcd"},
+		{"bc\x1b[1~a\x1b[4~d\r", "abcd"},
+		{"axbc\x1b[H\x1b[C\x1b[3~\r", "abc"},
+		// control keys
+		{"bc\x01a\x05d\r", "abcd"},
+		{"ac\x02b\x06d\r", "abcd"},
+		{"abXYZ\x02\x02\x02\x0b\r", "ab"},
+		{"XYab\x02\x02\x15\r", "ab"},
+		{"ab cd ef\x17\r", "ab cd "},
+		{"aXbc\x01\x06\x04\r", "abc"},
+		{"a\tb\r", "ab"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.keys, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := typing(tt.keys).ReadLine("> ")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestEditEnd(t *testing.T) {
+	t.Parallel()
+
+	if _, err := typing("\x04").ReadLine("> "); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if _, err := typing("").ReadLine("> "); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if _, err := typing("abc\x03").ReadLine("> "); err != ErrInterrupted {
+		t.Errorf("expected the interruption, got %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	e := typing("\x1b[A\r\x1b[A\x1b[A\r\x1b[A\x1b[A\x1b[A\x1b[B\r\x10\x10\x0e\x0e\r")
+	e.AddHistory("first")
+	e.AddHistory("second")
+	e.AddHistory("second")
+	e.AddHistory("  ")
+	if expected := []string{"first", "second"}; !cmp.Equal(e.History, expected) {
+		t.Fatalf("expected %q, got %q", expected, e.History)
+	}
+
+	var result []string
+	for range 4 {
+		line, err := e.ReadLine("> ")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result = append(result, line)
+	}
+	// moving past the last line restores the edited line
+	expected := []string{"second", "first", "second", ""}
+	if !cmp.Equal(result, expected) {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		keys     string
+		expected string
+	}{
+		{"pri\t\r", "print"},
+		{"X = pri\t(1)\r", "X = print(1)"},
+		{"fo\t\r", "foo_ba"},
+		{"fo\tr\t\r", "foo_bar"},
+		// the second Tab lists the candidates
+		{"fo\t\tx\r", "foo_bax"},
+		{"zzz\t\r", "zzz"},
+		{"\t\r", ""},
+		{":he\t\r", ":help"},
+		{"(pri\t)\x01\r", "(print)"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.keys, func(t *testing.T) {
+			t.Parallel()
+
+			e := typing(tt.keys)
+			e.Complete = func() []string {
+				return []string{"print", "printf", "foo_bar", "foo_baz", ":help"}
+			}
+			result, err := e.ReadLine("> ")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestReadPlain(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	e := NewEditor(strings.NewReader("one\r\ntwo"), &out)
+	var result []string
+	for {
+		line, err := e.ReadLine("> ")
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result = append(result, line)
+	}
+	if expected := []string{"one", "two"}; !cmp.Equal(result, expected) {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+	if out.String() != "> > > " {
+		t.Errorf("unexpected prompts: %q", out.String())
+	}
+}
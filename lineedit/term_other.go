@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package lineedit
+
+import "errors"
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("the raw mode of the terminal is not supported")
+}
@@ -0,0 +1,877 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/twolodzko/goer/core"
+	"github.com/twolodzko/goer/parser"
+	"github.com/twolodzko/goer/parser/lexer"
+	"github.com/twolodzko/goer/parser/reader"
+	. "github.com/twolodzko/goer/types"
+)
+
+// Severity of the problem, errors are the problems that make the code fail
+// when it is evaluated, warnings are the likely mistakes.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Problem found in the code.
+type Diagnostic struct {
+	Pos      Pos
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v: %s", d.Pos, d.Severity, d.Message)
+}
+
+// Lint the file.
+func File(path string) ([]Diagnostic, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Lint(file, path)
+}
+
+// Lint the code read from `in`, the positions refer to the `file`. The files included
+// by the code are looked up in the current directory, and then next to the `file`.
+func Lint(in io.Reader, file string) ([]Diagnostic, error) {
+	l := newLinter(file, make(map[string]bool))
+	if err := l.read(in); err != nil {
+		return nil, err
+	}
+	l.finish()
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i].Pos, l.diagnostics[j].Pos
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return l.diagnostics, nil
+}
+
+// The static analyzer walking the parsed code. It follows the same rules for binding
+// the variables as the evaluation does: the variables bound at the top level are
+// global, the functions have their own frames and capture the variables they use.
+type linter struct {
+	file  string
+	scope *scope
+	top   *scope
+	// position of the currently linted expression, the variables do not have positions
+	pos         Pos
+	diagnostics []Diagnostic
+	// the tokens of the currently linted code block, and the occurrences of the variables
+	// in it, each of them is claimed by the variable linted at it
+	tokens      []lexer.Token
+	occurrences []occurrence
+	// the function branch arguments are linted
+	args bool
+	// the arities of the functions defined by their names
+	funs  map[string][]int
+	calls []call
+	// the variables used in the functions that were not bound before, they can
+	// be bound at the top level later on, so they are checked at the end
+	unbound []unboundUse
+	// the files that were already included
+	included map[string]bool
+	// the code includes the files that cannot be found, so
+	// the functions it calls may be defined there
+	dynamic bool
+}
+
+// Variables bound in the function branch, or at the top level.
+type scope struct {
+	vars   map[string]*variable
+	parent *scope
+	// all the variables bound in the scope, in the order they were bound
+	bound []*variable
+}
+
+type variable struct {
+	name string
+	pos  Pos
+	used bool
+	// the expression where it is bound only in some of the branches
+	unsafe string
+	// the variables bound in the branches that this one merges
+	branches []*variable
+}
+
+type call struct {
+	name  string
+	arity int
+	pos   Pos
+}
+
+type unboundUse struct {
+	name string
+	pos  Pos
+}
+
+// The variable token at the index of the code block.
+type occurrence struct {
+	name    string
+	index   int
+	pos     Pos
+	claimed bool
+}
+
+func newLinter(file string, included map[string]bool) *linter {
+	top := newScope(nil)
+	return &linter{
+		file:     file,
+		scope:    top,
+		top:      top,
+		funs:     make(map[string][]int),
+		included: included,
+	}
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{make(map[string]*variable), parent, nil}
+}
+
+// Read the code block by block, and lint them in order.
+func (l *linter) read(in io.Reader) error {
+	reader := reader.NewReader(in)
+	for {
+		code, err := reader.Next()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		start := reader.Pos()
+		start.File = l.file
+		// the code may be just the comments
+		if tokens, err := lexer.Tokenize(code); err != nil || len(tokens) > 0 {
+			l.parse(code, start)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// Parse the code block and lint it, the parsing errors are reported as well.
+func (l *linter) parse(code string, start Pos) {
+	l.tokens, l.occurrences = nil, nil
+	if tokens, err := lexer.TokenizeAt(code, start); err == nil {
+		l.tokens = tokens
+		for i, tok := range tokens {
+			if tok.Type == lexer.Variable {
+				l.occurrences = append(l.occurrences, occurrence{tok.Value, i, tok.Pos, false})
+			}
+		}
+	}
+
+	exprs, err := parser.ParseAt(code, start)
+	if err != nil {
+		pos := start
+		var located interface{ Position() Pos }
+		if errors.As(err, &located) && located.Position().IsValid() {
+			pos = located.Position()
+		}
+		msg := strings.TrimPrefix(err.Error(), pos.String()+": ")
+		l.diagnostics = append(l.diagnostics, Diagnostic{pos, Error, msg})
+		return
+	}
+	l.body(exprs)
+}
+
+// Check what can be checked only when the whole code was seen.
+func (l *linter) finish() {
+	for _, u := range l.unbound {
+		if v, ok := l.top.vars[u.name]; ok {
+			l.markUsed(v)
+			continue
+		}
+		l.pos = u.pos
+		l.report(Error, "variable '%s' is unbound", u.name)
+	}
+	l.unused(l.top)
+
+	for _, c := range l.calls {
+		l.pos = c.pos
+		if arities, ok := l.funs[c.name]; ok {
+			if !slices.Contains(arities, c.arity) {
+				l.report(Error, "function '%s' is called with %s, but it takes %s", c.name, arguments(c.arity), alternatives(arities))
+			}
+			continue
+		}
+		if arities, ok := core.BuildInArities(c.name); ok {
+			if !slices.Contains(arities, c.arity) {
+				l.report(Error, "function '%s' is called with %s, but it takes %s", c.name, arguments(c.arity), alternatives(arities))
+			}
+			continue
+		}
+		if !l.dynamic {
+			l.report(Error, "function '%s' is not defined", c.name)
+		}
+	}
+}
+
+func (l *linter) report(severity Severity, format string, args ...any) {
+	l.reportAt(l.pos, severity, format, args...)
+}
+
+func (l *linter) reportAt(pos Pos, severity Severity, format string, args ...any) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{pos, severity, fmt.Sprintf(format, args...)})
+}
+
+// The position of the variable linted at the current position. The variables do not have
+// positions, so it is the closest of its occurrences in the code block not claimed yet.
+func (l *linter) claim(name string) Pos {
+	at := slices.IndexFunc(l.tokens, func(tok lexer.Token) bool {
+		return tok.Pos.Line > l.pos.Line || (tok.Pos.Line == l.pos.Line && tok.Pos.Col >= l.pos.Col)
+	})
+	if at < 0 {
+		at = len(l.tokens)
+	}
+	closest := -1
+	for i, o := range l.occurrences {
+		if o.name != name || o.claimed {
+			continue
+		}
+		if closest < 0 || distance(o.index, at) < distance(l.occurrences[closest].index, at) {
+			closest = i
+		}
+	}
+	if closest < 0 {
+		return l.pos
+	}
+	l.occurrences[closest].claimed = true
+	return l.occurrences[closest].pos
+}
+
+func distance(i, j int) int {
+	if i > j {
+		return i - j
+	}
+	return j - i
+}
+
+// Lint the expression at the position, the position applies to the
+// problems with the variables that do not have positions themselves.
+func (l *linter) at(pos Pos, lint func()) {
+	prev := l.pos
+	l.pos = pos
+	lint()
+	l.pos = prev
+}
+
+func (l *linter) body(exprs []Expr) {
+	for _, expr := range exprs {
+		l.expr(expr)
+	}
+}
+
+func (l *linter) optional(expr Expr) {
+	if expr != nil {
+		l.expr(expr)
+	}
+}
+
+// Lint the expression, the variables used in it need to be bound.
+func (l *linter) expr(expr Expr) {
+	switch val := expr.(type) {
+	case Variable:
+		l.use(string(val))
+	case Tuple:
+		l.body(val.Values)
+	case List:
+		l.body(val.Values)
+	case BitString:
+		l.at(val.Pos, func() {
+			for _, segment := range val.Segments {
+				l.expr(segment.Value)
+				l.optional(segment.Size)
+			}
+		})
+	case RecordDecl:
+		l.at(val.Pos, func() {
+			for _, field := range val.Fields {
+				l.optional(field.Value)
+			}
+		})
+	case Record:
+		l.at(val.Pos, func() {
+			l.optional(val.Expr)
+			for _, field := range val.Fields {
+				l.expr(field.Value)
+			}
+		})
+	case RecordAccess:
+		l.at(val.Pos, func() { l.expr(val.Expr) })
+	case UnaryOperation:
+		l.at(val.Pos, func() { l.expr(val.Rhs) })
+	case BinaryOperation:
+		l.at(val.Pos, func() {
+			if val.Op == "=" {
+				l.match(val.Lhs, val.Rhs, true)
+			} else {
+				l.expr(val.Lhs)
+				l.expr(val.Rhs)
+			}
+		})
+	case Bracket:
+		l.expr(val.Expr)
+	case Block:
+		l.at(val.Pos, func() { l.body(val.Body) })
+	case If:
+		l.at(val.Pos, func() { l.ifBranches(val.Branches) })
+	case Case:
+		l.at(val.Pos, func() {
+			l.expr(val.Arg)
+			l.branches("case", val.Branches, nil)
+		})
+	case AssertMatch:
+		l.at(val.Pos, func() {
+			l.expr(val.Expr)
+			l.pattern(val.Pattern)
+		})
+	case Receive:
+		l.at(val.Pos, func() {
+			var after []func()
+			if val.After.Cond != nil {
+				after = append(after, func() {
+					l.expr(val.After.Cond)
+					l.body(val.After.Body)
+				})
+			}
+			l.branches("receive", val.Branches, after)
+		})
+	case TryRecover:
+		l.at(val.Pos, func() {
+			l.alternatives("try", []func(){
+				func() { l.body(val.Body) },
+				func() { l.body(val.Recover) },
+			})
+		})
+	case TryCatch:
+		l.at(val.Pos, func() { l.tryCatch(val) })
+	case Definition:
+		l.at(val.Pos, func() { l.fun(val) })
+	case Call:
+		l.at(val.Pos, func() {
+			l.body(val.Args)
+			if name, ok := val.Callable.(Atom); ok {
+				l.calls = append(l.calls, call{string(name), len(val.Args), val.Pos})
+				if name == "include" && len(val.Args) == 1 {
+					l.include(val.Args[0])
+				}
+				return
+			}
+			l.expr(val.Callable)
+		})
+	}
+}
+
+// Lint the branches of the if expression, the conditions are not patterns.
+func (l *linter) ifBranches(branches []IfBranch) {
+	alternatives := make([]func(), len(branches))
+	catchAll := 0
+	for i, branch := range branches {
+		if catchAll > 0 {
+			l.unreachable("if", i+1, catchAll)
+		}
+		if catchAll == 0 && isTrueish(branch.Cond) {
+			catchAll = i + 1
+		}
+		alternatives[i] = func() {
+			l.expr(branch.Cond)
+			l.body(branch.Body)
+		}
+	}
+	l.alternatives("if", alternatives)
+}
+
+// Lint the pattern branches of the case or receive expressions, followed by the `extra` ones.
+func (l *linter) branches(kind string, branches []PatternBranch, extra []func()) {
+	var alternatives []func()
+	catchAll := 0
+	for i, branch := range branches {
+		alternatives = append(alternatives, func() {
+			if catchAll > 0 {
+				l.unreachable(kind, i+1, catchAll)
+			} else if len(branch.Guards) == 0 && l.isCatchAll(branch.Pattern) {
+				catchAll = i + 1
+			}
+			l.pattern(branch.Pattern)
+			l.body(branch.Guards)
+			l.body(branch.Body)
+		})
+	}
+	l.alternatives(kind, append(alternatives, extra...))
+}
+
+// Lint the try expression, the variables bound in its body are not bound
+// when the error is catched.
+func (l *linter) tryCatch(block TryCatch) {
+	alternatives := []func(){func() { l.body(block.Body) }}
+	catchAll := 0
+	for i, branch := range block.Branches {
+		alternatives = append(alternatives, func() {
+			if catchAll > 0 {
+				l.unreachable("catch", i+1, catchAll)
+			} else if len(branch.Guards) == 0 && l.isCatchAll(branch.Class) && l.isCatchAll(branch.Pattern) {
+				catchAll = i + 1
+			}
+			l.pattern(branch.Class)
+			l.pattern(branch.Pattern)
+			if branch.Stack != nil {
+				l.pattern(branch.Stack)
+			}
+			l.body(branch.Guards)
+			l.body(branch.Body)
+		})
+	}
+	l.alternatives("try", alternatives)
+}
+
+// Lint the alternative branches of the expression one by one, each of them starts with
+// the same variables bound. The variables bound only in some of them are unsafe to use.
+func (l *linter) alternatives(kind string, branches []func()) {
+	before := make(map[string]bool)
+	for name := range l.scope.vars {
+		before[name] = true
+	}
+
+	var names []string
+	bound := make(map[string][]*variable)
+	for _, branch := range branches {
+		branch()
+		for name, v := range l.scope.vars {
+			if before[name] {
+				continue
+			}
+			if _, ok := bound[name]; !ok {
+				names = append(names, name)
+			}
+			bound[name] = append(bound[name], v)
+			delete(l.scope.vars, name)
+		}
+	}
+
+	for _, name := range names {
+		v := &variable{name: name, pos: bound[name][0].pos, branches: bound[name]}
+		if len(v.branches) < len(branches) {
+			v.unsafe = kind
+		}
+		for _, b := range v.branches {
+			if b.unsafe != "" {
+				v.unsafe = b.unsafe
+			}
+		}
+		l.scope.vars[name] = v
+	}
+}
+
+// Report the clause `i` that is never reached, since the clause `catchAll` matches everything.
+func (l *linter) unreachable(kind string, i, catchAll int) {
+	l.report(Warning, "clause %d of the %s expression cannot match, since clause %d always matches", i, kind, catchAll)
+}
+
+// Lint the function definition, each of its branches has its own scope.
+func (l *linter) fun(def Definition) {
+	if def.Name != "" && !def.Local {
+		for _, branch := range def.Branches {
+			if !slices.Contains(l.funs[def.Name], len(branch.Args)) {
+				l.funs[def.Name] = append(l.funs[def.Name], len(branch.Args))
+			}
+		}
+	}
+
+	outer := l.scope
+	fun := newScope(outer)
+	if def.Local {
+		// the function can call itself by the name
+		fun.vars[def.Name] = &variable{name: def.Name, pos: l.claim(def.Name), used: true}
+	}
+
+	// the clauses that match all the arguments, by the arity
+	catchAll := make(map[int]int)
+	for i, branch := range def.Branches {
+		arity := len(branch.Args)
+		if first, ok := catchAll[arity]; ok {
+			l.report(Warning, "clause %d of the function cannot match, since clause %d always matches", i+1, first)
+		} else if len(branch.Guards) == 0 && isCatchAllArgs(branch.Args) {
+			catchAll[arity] = i + 1
+		}
+
+		l.scope = newScope(fun)
+		l.args = true
+		for _, arg := range branch.Args {
+			l.pattern(arg)
+		}
+		l.args = false
+		l.body(branch.Guards)
+		l.body(branch.Body)
+		l.unused(l.scope)
+	}
+	l.scope = outer
+}
+
+// Lint the file included by the code, the functions it defines and the variables
+// it binds at the top level become available for the code that follows.
+func (l *linter) include(arg Expr) {
+	name, ok := arg.(String)
+	if !ok {
+		l.dynamic = true
+		return
+	}
+	path, ok := l.find(string(name))
+	if !ok {
+		l.report(Warning, "included file '%s' cannot be found", string(name))
+		l.dynamic = true
+		return
+	}
+	if l.included[path] {
+		return
+	}
+	l.included[path] = true
+
+	file, err := os.Open(path)
+	if err != nil {
+		l.report(Warning, "included file '%s' cannot be read: %s", string(name), err)
+		l.dynamic = true
+		return
+	}
+	defer file.Close()
+
+	included := newLinter(path, l.included)
+	if err := included.read(file); err != nil {
+		l.report(Warning, "included file '%s' cannot be read: %s", string(name), err)
+	}
+	for name, arities := range included.funs {
+		for _, arity := range arities {
+			if !slices.Contains(l.funs[name], arity) {
+				l.funs[name] = append(l.funs[name], arity)
+			}
+		}
+	}
+	l.dynamic = l.dynamic || included.dynamic
+	if l.scope == l.top {
+		for name := range included.top.vars {
+			if _, ok := l.top.vars[name]; !ok {
+				l.top.vars[name] = &variable{name: name, pos: l.pos, used: true}
+			}
+		}
+	}
+}
+
+// Find the included file in the current directory, or next to the linted file.
+func (l *linter) find(name string) (string, bool) {
+	candidates := []string{name}
+	if l.file != "" && !filepath.IsAbs(name) {
+		candidates = append(candidates, filepath.Join(filepath.Dir(l.file), name))
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// Report the variables bound in the scope that were never used.
+// The variables with names starting with `_` are never reported.
+func (l *linter) unused(s *scope) {
+	for _, v := range s.bound {
+		if !v.used && !strings.HasPrefix(v.name, "_") {
+			l.diagnostics = append(l.diagnostics, Diagnostic{v.pos, Warning, fmt.Sprintf("variable '%s' is unused", v.name)})
+		}
+	}
+}
+
+// Find the variable bound in the scope, or in the enclosing ones.
+func (l *linter) lookup(name string) (*variable, bool) {
+	for s := l.scope; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Lint the variable that is used, it can be bound in the current function,
+// the enclosing ones, or at the top level.
+func (l *linter) use(name string) {
+	pos := l.claim(name)
+	if v, ok := l.lookup(name); ok {
+		l.useVariable(v, pos)
+		return
+	}
+	if l.scope == l.top {
+		l.reportAt(pos, Error, "variable '%s' is unbound", name)
+		return
+	}
+	l.unbound = append(l.unbound, unboundUse{name, pos})
+}
+
+// Mark the variable used at the position.
+func (l *linter) useVariable(v *variable, pos Pos) {
+	l.markUsed(v)
+	if v.unsafe != "" {
+		l.reportAt(pos, Error, "variable '%s' is unsafe, it is not bound in all the branches of the %s expression", v.name, v.unsafe)
+		// report it only once
+		v.unsafe = ""
+	}
+}
+
+func (l *linter) markUsed(v *variable) {
+	v.used = true
+	for _, b := range v.branches {
+		l.markUsed(b)
+	}
+}
+
+// Lint the variable that is bound, the variables are always bound in the current
+// scope. When it is already bound there, it is matched by its value.
+func (l *linter) bind(name string) {
+	pos := l.claim(name)
+	if v, ok := l.scope.vars[name]; ok {
+		l.useVariable(v, pos)
+		return
+	}
+	if l.args && l.shadows(name) {
+		l.reportAt(pos, Warning, "variable '%s' shadows the variable of the enclosing function", name)
+	}
+	v := &variable{name: name, pos: pos}
+	l.scope.vars[name] = v
+	l.scope.bound = append(l.scope.bound, v)
+}
+
+// The argument of the function is bound in the enclosing function, so it is not
+// matched by its value, like in the other patterns, but it is a new variable.
+func (l *linter) shadows(name string) bool {
+	for s := l.scope.parent; s != nil && s != l.top; s = s.parent {
+		if _, ok := s.vars[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// The pattern matches any value.
+func (l *linter) isCatchAll(pattern Expr) bool {
+	switch p := pattern.(type) {
+	case Dummy:
+		return true
+	case Variable:
+		_, bound := l.lookup(string(p))
+		return !bound
+	default:
+		return false
+	}
+}
+
+// The arguments of the function branch match any values.
+func isCatchAllArgs(args []Expr) bool {
+	seen := make(map[Variable]bool)
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case Dummy:
+		case Variable:
+			if seen[arg] {
+				return false
+			}
+			seen[arg] = true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// The condition of the if branch that is always true.
+func isTrueish(expr Expr) bool {
+	switch val := expr.(type) {
+	case Dummy:
+		return true
+	case Bool:
+		return bool(val)
+	default:
+		return false
+	}
+}
+
+// Lint the pattern matched against a value, the variables in it are bound.
+func (l *linter) pattern(pattern Expr) {
+	switch p := pattern.(type) {
+	case Variable:
+		l.bind(string(p))
+	case BitString:
+		// the sizes can use the variables bound by the preceding segments
+		l.at(p.Pos, func() {
+			for _, segment := range p.Segments {
+				l.optional(segment.Size)
+				l.pattern(segment.Value)
+			}
+		})
+	case Record:
+		if p.Expr != nil {
+			l.expr(p)
+			return
+		}
+		l.at(p.Pos, func() {
+			for _, field := range p.Fields {
+				l.pattern(field.Value)
+			}
+		})
+	case Tuple:
+		for _, v := range p.Values {
+			l.pattern(v)
+		}
+	case List:
+		for _, v := range p.Values {
+			l.pattern(v)
+		}
+	default:
+		l.expr(pattern)
+	}
+}
+
+// Lint the match (=) operation, following the rules of the evaluation: the variables
+// on either side are bound, the containers written on both sides are matched element
+// by element, and the other expressions are evaluated. At the top level, the bound
+// variable on the right-hand side is matched by its value.
+func (l *linter) match(lhs, rhs Expr, top bool) {
+	// the sides that are the containers are linted at the end
+	lhsLinted, rhsLinted := true, true
+	switch p := lhs.(type) {
+	case Dummy:
+		l.expr(rhs)
+		return
+	case Variable:
+		if _, ok := rhs.(Dummy); !ok {
+			l.expr(rhs)
+		}
+		l.bind(string(p))
+		return
+	case BitString:
+		l.expr(rhs)
+		l.pattern(p)
+		return
+	case Record:
+		l.pattern(p)
+	case Atom, Tuple, List:
+		lhsLinted = false
+	default:
+		l.expr(lhs)
+	}
+
+	switch v := rhs.(type) {
+	case Dummy:
+		if !lhsLinted {
+			l.pattern(lhs)
+		}
+		return
+	case Variable:
+		if _, bound := l.lookup(string(v)); top && bound {
+			if !lhsLinted {
+				l.pattern(lhs)
+			}
+			l.use(string(v))
+			return
+		}
+		if !lhsLinted {
+			l.expr(lhs)
+		}
+		l.bind(string(v))
+		return
+	case BitString:
+		if !lhsLinted {
+			l.expr(lhs)
+		}
+		l.pattern(v)
+		return
+	case Record:
+		l.pattern(v)
+	case Atom, Tuple, List:
+		rhsLinted = false
+	default:
+		l.expr(rhs)
+	}
+
+	lvals, _ := containerValues(lhs)
+	rvals, _ := containerValues(rhs)
+	if !lhsLinted && !rhsLinted && sameKind(lhs, rhs) && len(lvals) == len(rvals) {
+		for i := range lvals {
+			l.match(lvals[i], rvals[i], false)
+		}
+		return
+	}
+	if !lhsLinted {
+		l.pattern(lhs)
+	}
+	if !rhsLinted {
+		l.pattern(rhs)
+	}
+}
+
+func containerValues(expr Expr) ([]Expr, bool) {
+	switch expr := expr.(type) {
+	case Tuple:
+		return expr.Values, true
+	case List:
+		return expr.Values, true
+	default:
+		return nil, false
+	}
+}
+
+// Both are tuples, or both are lists.
+func sameKind(lhs, rhs Expr) bool {
+	switch lhs.(type) {
+	case Tuple:
+		_, ok := rhs.(Tuple)
+		return ok
+	case List:
+		_, ok := rhs.(List)
+		return ok
+	default:
+		return false
+	}
+}
+
+// List the alternatives, e.g. "1, 2 or 3".
+func alternatives(arities []int) string {
+	sorted := slices.Clone(arities)
+	slices.Sort(sorted)
+	words := make([]string, len(sorted))
+	for i, arity := range sorted {
+		words[i] = fmt.Sprint(arity)
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
@@ -0,0 +1,162 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		input    string
+		expected []string
+	}{
+		{`X = 1, X + 2.`, nil},
+		{`fun f(X) -> X end. f(1).`, nil},
+		{`fun f(_X) -> ok end.`, nil},
+		{`fun f(X) -> case X of 1 -> Y = a; _ -> Y = b end, Y end.`, nil},
+		{`fun f(N) -> G = fun(M) -> N + M end, G(1) end.`, nil},
+		{`fun f() -> F = fun Loop(0) -> ok; (N) -> Loop(N - 1) end, F(3) end.`, nil},
+		// the top-level variables can be bound after the function is defined
+		{`fun f() -> X end. X = 1.`, nil},
+		// the bound variable is matched by its value
+		{`X = {1, 2}, {A, B} = X, A + B.`, nil},
+		// the assertion binds the variables of the pattern
+		{`fun f() -> assert_match({ok, X}, g()), X end. fun g() -> {ok, 1} end.`, nil},
+		{`fun f() -> assert_equal(1, X) end.`, []string{"1:28: error: variable 'X' is unbound"}},
+		{`include("missing.ge").`, []string{"1:1: warning: included file 'missing.ge' cannot be found"}},
+		// unbound
+		{`X + 1.`, []string{"1:1: error: variable 'X' is unbound"}},
+		{`fun f(Result) -> Reslt end.`, []string{
+			"1:7: warning: variable 'Result' is unused",
+			"1:18: error: variable 'Reslt' is unbound",
+		}},
+		{"fun f(X) ->\n  X + Y end.", []string{"2:7: error: variable 'Y' is unbound"}},
+		{`fun f() -> G = fun() -> Y end, Y = 1, G() end.`, []string{
+			"1:25: error: variable 'Y' is unbound",
+			"1:32: warning: variable 'Y' is unused",
+		}},
+		{`fun f(X) -> X + Y + Y end.`, []string{
+			"1:17: error: variable 'Y' is unbound",
+			"1:21: error: variable 'Y' is unbound",
+		}},
+		{`X = Y, X.`, []string{"1:5: error: variable 'Y' is unbound"}},
+		// unused
+		{`fun f(X) -> Y = X, ok end.`, []string{"1:13: warning: variable 'Y' is unused"}},
+		{`fun f(X) -> case X of {A, _} -> ok end end.`, []string{"1:24: warning: variable 'A' is unused"}},
+		// shadowed
+		{`fun m() -> Z = 1, fun(Z) -> Z end end.`, []string{
+			"1:12: warning: variable 'Z' is unused",
+			"1:23: warning: variable 'Z' shadows the variable of the enclosing function",
+		}},
+		{`fun m(X) -> F = fun(Y) -> fun({X, Y}) -> X + Y end end, F(X) end.`, []string{
+			"1:21: warning: variable 'Y' is unused",
+			"1:32: warning: variable 'X' shadows the variable of the enclosing function",
+			"1:35: warning: variable 'Y' shadows the variable of the enclosing function",
+		}},
+		{`X = 1. fun f(X) -> X end. print(X).`, nil},
+		// unsafe
+		{`fun f(X) -> case X of 1 -> Y = a; _ -> ok end, Y end.`, []string{
+			"1:48: error: variable 'Y' is unsafe, it is not bound in all the branches of the case expression",
+		}},
+		{`fun f() -> try Y = g() catch _ -> ok end, Y end. fun g() -> ok end.`, []string{
+			"1:43: error: variable 'Y' is unsafe, it is not bound in all the branches of the try expression",
+		}},
+		// unreachable
+		{`fun f(X) -> case X of _ -> a; 1 -> b end end.`, []string{
+			"1:13: warning: clause 2 of the case expression cannot match, since clause 1 always matches",
+		}},
+		{`fun f(X) -> case X of Y -> Y; 1 -> b end end.`, []string{
+			"1:13: warning: clause 2 of the case expression cannot match, since clause 1 always matches",
+		}},
+		{`fun f(X, Y) -> case X of Y -> a; 1 -> b end end.`, nil},
+		{`fun f(X) -> case X of _ when X > 0 -> a; 1 -> b end end.`, nil},
+		{`fun f(X) -> if true -> a; X -> b end end.`, []string{
+			"1:13: warning: clause 2 of the if expression cannot match, since clause 1 always matches",
+		}},
+		{`fun f(_) -> a; (1) -> b; (_, _) -> c end.`, []string{
+			"1:1: warning: clause 2 of the function cannot match, since clause 1 always matches",
+		}},
+		{`fun f(X, X) -> a; (_, _) -> b end.`, nil},
+		// calls
+		{`fun f(X) -> X end. f(1, 2).`, []string{"1:20: error: function 'f' is called with 2 arguments, but it takes 1"}},
+		{`fun f(X) -> X; (X, Y) -> X + Y end. f().`, []string{"1:37: error: function 'f' is called with 0 arguments, but it takes 1 or 2"}},
+		{`prnt("hello").`, []string{"1:1: error: function 'prnt' is not defined"}},
+		{`print("hello").`, nil},
+		{`len(1, 2).`, []string{"1:1: error: function 'len' is called with 2 arguments, but it takes 1"}},
+		{`string_substr("abc").`, []string{"1:1: error: function 'string_substr' is called with 1 argument, but it takes 2 or 3"}},
+		{`string_substr("abc", 1), self().`, nil},
+		// parsing errors
+		{"X = 1, print(X).\n1 + .", []string{"2:5: error: unexpected: ."}},
+		{"print(1).\n% the end\n", nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			diagnostics, err := Lint(strings.NewReader(tt.input), "")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var result []string
+			for _, d := range diagnostics {
+				result = append(result, d.String())
+			}
+			if !cmp.Equal(result, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLintIncluded(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.ge")
+	if err := os.WriteFile(lib, []byte("Answer = 42.\nfun double(X) -> X * 2 end.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.ge")
+	code := "include(\"lib.ge\").\ndouble(Answer).\ndouble(1, 2).\n"
+	if err := os.WriteFile(main, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics, err := File(main)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var result []string
+	for _, d := range diagnostics {
+		result = append(result, d.String())
+	}
+	expected := []string{main + ":3:1: error: function 'double' is called with 2 arguments, but it takes 1"}
+	if !cmp.Equal(result, expected) {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestLintExamples(t *testing.T) {
+	t.Parallel()
+
+	paths, err := filepath.Glob("../examples/*.ge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		diagnostics, err := File(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(diagnostics) > 0 {
+			t.Errorf("unexpected problems in %s: %v", path, diagnostics)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package lsp
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/twolodzko/goer/parser"
+	"github.com/twolodzko/goer/parser/lexer"
+	"github.com/twolodzko/goer/parser/reader"
+	. "github.com/twolodzko/goer/types"
+)
+
+// The document open in the editor, and what is known about its code.
+// The code blocks that cannot be tokenized are skipped, and the ones
+// that cannot be parsed contribute only their tokens.
+type document struct {
+	uri, path, text string
+	// the lines of the text, to convert the columns
+	lines   []string
+	tokens  []lexer.Token
+	symbols []DocumentSymbol
+	// the named functions, including the ones from the included files
+	funs map[string][]function
+	// the files included by the code, by their positions
+	includes map[Pos]string
+}
+
+// The named function, with the location of its name.
+type function struct {
+	def      Definition
+	location Location
+}
+
+// Read the code of the document, the included files are
+// looked up, but the ones already `seen` are skipped.
+func analyze(uri, text string, seen map[string]bool) *document {
+	doc := &document{
+		uri:      uri,
+		path:     uriToPath(uri),
+		text:     text,
+		lines:    strings.Split(text, "\n"),
+		funs:     make(map[string][]function),
+		includes: make(map[Pos]string),
+	}
+	seen[doc.path] = true
+
+	reader := reader.NewReader(strings.NewReader(text))
+	for {
+		code, err := reader.Next()
+		if err != nil && err != io.EOF {
+			return doc
+		}
+		if tokens, lexErr := lexer.TokenizeAt(code, reader.Pos()); lexErr == nil {
+			doc.tokens = append(doc.tokens, tokens...)
+			if exprs, parseErr := parser.ParseAt(code, reader.Pos()); parseErr == nil {
+				for _, expr := range exprs {
+					doc.topLevel(expr, tokens, seen)
+				}
+			}
+		}
+		if err == io.EOF {
+			return doc
+		}
+	}
+}
+
+// Record the symbols defined by the top-level expression.
+func (doc *document) topLevel(expr Expr, tokens []lexer.Token, seen map[string]bool) {
+	switch val := expr.(type) {
+	case Definition:
+		i := tokenIndex(tokens, val.Pos)
+		if val.Name == "" || val.Local || i < 0 || i+1 >= len(tokens) {
+			return
+		}
+		name := doc.tokenRange(tokens[i+1])
+		doc.funs[val.Name] = append(doc.funs[val.Name], function{val, Location{doc.uri, name}})
+		doc.symbols = append(doc.symbols, DocumentSymbol{
+			Name:           val.Name,
+			Detail:         arities(val),
+			Kind:           symbolFunction,
+			Range:          Range{name.Start, doc.blockEnd(tokens, i)},
+			SelectionRange: name,
+		})
+	case RecordDecl:
+		i := tokenIndex(tokens, val.Pos)
+		if i < 0 {
+			return
+		}
+		// the name follows the bracket in `-record(Name, ...)`
+		j := slices.IndexFunc(tokens[i:], func(tok lexer.Token) bool { return tok.Type == lexer.BracketLeft })
+		if j < 0 || i+j+1 >= len(tokens) {
+			return
+		}
+		doc.symbols = append(doc.symbols, DocumentSymbol{
+			Name:           val.Name,
+			Kind:           symbolStruct,
+			Range:          Range{doc.tokenRange(tokens[i]).Start, doc.bracketEnd(tokens, i+j)},
+			SelectionRange: doc.tokenRange(tokens[i+j+1]),
+		})
+	case BinaryOperation:
+		name, ok := val.Lhs.(Variable)
+		i := tokenIndex(tokens, val.Pos)
+		if val.Op != "=" || !ok || i < 1 {
+			return
+		}
+		rng := doc.tokenRange(tokens[i-1])
+		doc.symbols = append(doc.symbols, DocumentSymbol{
+			Name:           string(name),
+			Kind:           symbolVariable,
+			Range:          rng,
+			SelectionRange: rng,
+		})
+	case Call:
+		callable, ok := val.Callable.(Atom)
+		if !ok || callable != "include" || len(val.Args) != 1 {
+			return
+		}
+		name, ok := val.Args[0].(String)
+		i := tokenIndex(tokens, val.Pos)
+		if !ok || i < 0 || i+2 >= len(tokens) {
+			return
+		}
+		path, ok := doc.find(string(name))
+		if !ok {
+			return
+		}
+		doc.includes[tokens[i+2].Pos] = path
+		if seen[path] {
+			return
+		}
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		included := analyze(pathToURI(path), string(text), seen)
+		for name, funs := range included.funs {
+			doc.funs[name] = append(doc.funs[name], funs...)
+		}
+	}
+}
+
+// Find the included file in the current directory, or next to the document.
+func (doc *document) find(name string) (string, bool) {
+	candidates := []string{name}
+	if doc.path != "" && !filepath.IsAbs(name) {
+		candidates = append(candidates, filepath.Join(filepath.Dir(doc.path), name))
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return path, true
+			}
+			return abs, true
+		}
+	}
+	return "", false
+}
+
+// The token at the position, or right before it.
+func (doc *document) tokenAt(pos Position) (int, bool) {
+	for i, tok := range doc.tokens {
+		rng := doc.tokenRange(tok)
+		if rng.Start.Line == pos.Line && rng.Start.Character <= pos.Character && pos.Character <= rng.End.Character {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// The variables that are in scope at the position. The tokens preceding it are
+// followed: the variables bound at the top level are visible in the code that
+// follows, and the ones bound in the function are visible only until its clause
+// ends. The variable that is written at the position is not included.
+func (doc *document) variablesAt(pos Position) []string {
+	type frame struct {
+		fun  bool
+		vars []string
+	}
+	var (
+		globals []string
+		stack   = []frame{{}}
+	)
+	for i, tok := range doc.tokens {
+		rng := doc.tokenRange(tok)
+		if rng.End.Line > pos.Line || (rng.End.Line == pos.Line && rng.End.Character >= pos.Character) {
+			break
+		}
+		top := &stack[len(stack)-1]
+		switch tok.Type {
+		case lexer.Variable:
+			if !slices.Contains(top.vars, tok.Value) {
+				top.vars = append(top.vars, tok.Value)
+			}
+		case lexer.Fun, lexer.If, lexer.Begin, lexer.Case, lexer.Receive, lexer.Try:
+			stack = append(stack, frame{fun: tok.Type == lexer.Fun})
+		case lexer.Semicolon:
+			if len(stack) > 1 {
+				top.vars = nil
+			}
+		case lexer.End:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+				if !top.fun {
+					// the variables bound in the branches are visible after them
+					stack[len(stack)-1].vars = append(stack[len(stack)-1].vars, top.vars...)
+				}
+			}
+		case lexer.Dot:
+			if isRecordAccess(doc.tokens[:i]) {
+				continue
+			}
+			globals = append(globals, stack[0].vars...)
+			stack = []frame{{}}
+		}
+	}
+	var vars []string
+	for _, f := range stack {
+		vars = append(vars, f.vars...)
+	}
+	vars = append(vars, globals...)
+	slices.Sort(vars)
+	return slices.Compact(vars)
+}
+
+// The dot follows the `#name` of the record access.
+func isRecordAccess(preceding []lexer.Token) bool {
+	n := len(preceding)
+	return n >= 2 && preceding[n-1].Type == lexer.Atom && preceding[n-2].Type == lexer.Hash
+}
+
+// Markdown description of the function, listing the heads of its clauses.
+func describe(name string, funs []function) string {
+	var heads []string
+	for _, fun := range funs {
+		for _, branch := range fun.def.Branches {
+			head := fmt.Sprintf("%v(%s)", Atom(name), join(branch.Args, ", "))
+			if branch.Guards != nil {
+				head += " when " + join(branch.Guards, ", ")
+			}
+			heads = append(heads, head)
+		}
+	}
+	return "```erlang\n" + strings.Join(heads, "\n") + "\n```"
+}
+
+func join(exprs []Expr, sep string) string {
+	var s []string
+	for _, expr := range exprs {
+		s = append(s, fmt.Sprint(expr))
+	}
+	return strings.Join(s, sep)
+}
+
+// The arities of the function, e.g. `/1, /2`.
+func arities(def Definition) string {
+	var s []string
+	for _, branch := range def.Branches {
+		arity := fmt.Sprintf("/%d", len(branch.Args))
+		if !slices.Contains(s, arity) {
+			s = append(s, arity)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// Index of the token at the position, or -1.
+func tokenIndex(tokens []lexer.Token, pos Pos) int {
+	for i, tok := range tokens {
+		if tok.Pos.Line == pos.Line && tok.Pos.Col == pos.Col {
+			return i
+		}
+	}
+	return -1
+}
+
+// The end of the block that starts with the i-th token, at its `end` keyword.
+func (doc *document) blockEnd(tokens []lexer.Token, i int) Position {
+	depth := 0
+	for _, tok := range tokens[i:] {
+		switch tok.Type {
+		case lexer.Fun, lexer.If, lexer.Begin, lexer.Case, lexer.Receive, lexer.Try:
+			depth++
+		case lexer.End:
+			depth--
+			if depth == 0 {
+				return doc.tokenRange(tok).End
+			}
+		}
+	}
+	return doc.tokenRange(tokens[len(tokens)-1]).End
+}
+
+// The end of the bracket that is opened by the i-th token.
+func (doc *document) bracketEnd(tokens []lexer.Token, i int) Position {
+	depth := 0
+	for _, tok := range tokens[i:] {
+		switch tok.Type {
+		case lexer.BracketLeft:
+			depth++
+		case lexer.BracketRight:
+			depth--
+			if depth == 0 {
+				return doc.tokenRange(tok).End
+			}
+		}
+	}
+	return doc.tokenRange(tokens[len(tokens)-1]).End
+}
+
+// The range of the token, the tokens spanning multiple lines end at the end of the first one.
+func (doc *document) tokenRange(tok lexer.Token) Range {
+	start := doc.position(tok.Pos)
+	value, _, _ := strings.Cut(tok.Value, "\n")
+	return Range{start, Position{start.Line, start.Character + utf16Len(value)}}
+}
+
+// Convert the one-based position in the code, where the columns count the characters,
+// to the zero-based LSP position, where they count the UTF-16 code units.
+func (doc *document) position(pos Pos) Position {
+	line, col := max(pos.Line-1, 0), max(pos.Col-1, 0)
+	if line >= len(doc.lines) {
+		return Position{line, col}
+	}
+	runes := []rune(doc.lines[line])
+	if col > len(runes) {
+		return Position{line, len(utf16.Encode(runes)) + col - len(runes)}
+	}
+	return Position{line, len(utf16.Encode(runes[:col]))}
+}
+
+// Length of the string in the UTF-16 code units.
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,264 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/twolodzko/goer/core"
+	"github.com/twolodzko/goer/lint"
+	"github.com/twolodzko/goer/parser/lexer"
+)
+
+type server struct {
+	out      io.Writer
+	docs     map[string]*document
+	builtins []string
+}
+
+// Serve the Language Server Protocol requests read from `in`, writing the responses
+// to `out`, until the client sends the `exit` notification or closes the input.
+func Serve(in io.Reader, out io.Writer) error {
+	var builtins []string
+	for name := range core.NewEnv().Elems {
+		builtins = append(builtins, name)
+	}
+	slices.Sort(builtins)
+
+	s := &server{out, make(map[string]*document), builtins}
+	r := bufio.NewReader(in)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				if err := s.respond(nil, nil, &responseError{parseError, err.Error()}); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Handle the request or the notification. The notifications are not answered,
+// the unknown ones are ignored.
+func (s *server) handle(msg message) error {
+	result, rerr := s.call(msg.Method, msg.Params)
+	if msg.ID == nil {
+		return nil
+	}
+	return s.respond(msg.ID, result, rerr)
+}
+
+func (s *server) call(method string, params json.RawMessage) (any, *responseError) {
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"positionEncoding":       "utf-16", // the default, the columns count the UTF-16 code units
+				"textDocumentSync":       1,        // the full text is sent on every change
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"completionProvider":     map[string]any{},
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "goer"},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &responseError{invalidParams, err.Error()}
+		}
+		return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &responseError{invalidParams, err.Error()}
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &responseError{invalidParams, err.Error()}
+		}
+		delete(s.docs, p.TextDocument.URI)
+		if err := s.publish(p.TextDocument.URI, []Diagnostic{}); err != nil {
+			return nil, &responseError{internalError, err.Error()}
+		}
+		return nil, nil
+	case "textDocument/definition":
+		return withPosition(s, params, s.definition)
+	case "textDocument/hover":
+		return withPosition(s, params, s.hover)
+	case "textDocument/completion":
+		return withPosition(s, params, s.completion)
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &responseError{invalidParams, err.Error()}
+		}
+		doc, ok := s.docs[p.TextDocument.URI]
+		if !ok || doc.symbols == nil {
+			return []DocumentSymbol{}, nil
+		}
+		return doc.symbols, nil
+	default:
+		return nil, &responseError{methodNotFound, fmt.Sprintf("method '%s' is not supported", method)}
+	}
+}
+
+// Call the handler for the position in the open document, the result is `null`
+// when the document is not open.
+func withPosition[T any](s *server, params json.RawMessage, handler func(*document, Position) T) (any, *responseError) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &responseError{invalidParams, err.Error()}
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	return handler(doc, p.Position), nil
+}
+
+// Analyze the new content of the document, and publish the problems found in it.
+func (s *server) update(uri, text string) *responseError {
+	doc := analyze(uri, text, make(map[string]bool))
+	s.docs[uri] = doc
+
+	problems, err := lint.Lint(strings.NewReader(text), doc.path)
+	if err != nil {
+		return &responseError{internalError, err.Error()}
+	}
+	diagnostics := []Diagnostic{}
+	for _, p := range problems {
+		if p.Pos.File != doc.path {
+			continue
+		}
+		severity := severityError
+		if p.Severity == lint.Warning {
+			severity = severityWarning
+		}
+		diagnostics = append(diagnostics, Diagnostic{doc.rangeAt(doc.position(p.Pos)), severity, "goer", p.Message})
+	}
+	if err := s.publish(uri, diagnostics); err != nil {
+		return &responseError{internalError, err.Error()}
+	}
+	return nil
+}
+
+// The range of the token at the position, or the empty range when there is none.
+func (doc *document) rangeAt(pos Position) Range {
+	if i, ok := doc.tokenAt(pos); ok {
+		return doc.tokenRange(doc.tokens[i])
+	}
+	return Range{pos, pos}
+}
+
+func (s *server) publish(uri string, diagnostics []Diagnostic) error {
+	params, err := json.Marshal(PublishDiagnosticsParams{uri, diagnostics})
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, message{Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+func (s *server) respond(id *json.RawMessage, result any, rerr *responseError) error {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	msg := message{ID: id, Error: rerr}
+	if rerr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = raw
+	}
+	return writeMessage(s.out, msg)
+}
+
+// The locations of the definitions of the function, or the included file.
+func (s *server) definition(doc *document, pos Position) []Location {
+	i, ok := doc.tokenAt(pos)
+	if !ok {
+		return nil
+	}
+	tok := doc.tokens[i]
+	switch tok.Type {
+	case lexer.String:
+		if path, ok := doc.includes[tok.Pos]; ok {
+			return []Location{{pathToURI(path), Range{}}}
+		}
+	case lexer.Atom:
+		var locations []Location
+		for _, fun := range doc.funs[tok.Value] {
+			locations = append(locations, fun.location)
+		}
+		return locations
+	}
+	return nil
+}
+
+// The clauses of the function, or the note about the build-in function.
+func (s *server) hover(doc *document, pos Position) *Hover {
+	i, ok := doc.tokenAt(pos)
+	if !ok || doc.tokens[i].Type != lexer.Atom {
+		return nil
+	}
+	tok := doc.tokens[i]
+	if funs, ok := doc.funs[tok.Value]; ok {
+		return &Hover{MarkupContent{"markdown", describe(tok.Value, funs)}, doc.tokenRange(tok)}
+	}
+	if _, ok := slices.BinarySearch(s.builtins, tok.Value); ok {
+		return &Hover{MarkupContent{"markdown", fmt.Sprintf("build-in function `%s`", tok.Value)}, doc.tokenRange(tok)}
+	}
+	return nil
+}
+
+// The variables in scope, the functions defined in the code, and the build-in functions.
+func (s *server) completion(doc *document, pos Position) []CompletionItem {
+	items := []CompletionItem{}
+	for _, name := range doc.variablesAt(pos) {
+		items = append(items, CompletionItem{name, completionVariable, ""})
+	}
+	var names []string
+	for name := range doc.funs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		var details []string
+		for _, fun := range doc.funs[name] {
+			details = append(details, arities(fun.def))
+		}
+		items = append(items, CompletionItem{name, completionFunction, strings.Join(details, ", ")})
+	}
+	for _, name := range s.builtins {
+		if _, ok := doc.funs[name]; !ok {
+			items = append(items, CompletionItem{name, completionFunction, "build-in"})
+		}
+	}
+	return items
+}
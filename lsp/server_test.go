@@ -0,0 +1,326 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// The client talking to the server over the pipes, as the editor would.
+type client struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    chan message
+	id     int
+	done   chan error
+	notifs []message
+}
+
+// Start the server, its messages are read as soon as they are written,
+// so it is never blocked when the client is sending.
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t, inW, make(chan message, 100), 0, make(chan error, 1), nil}
+	go func() {
+		err := Serve(inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+	go func() {
+		defer close(c.out)
+		r := bufio.NewReader(outR)
+		for {
+			msg, err := readMessage(r)
+			if err != nil {
+				return
+			}
+			c.out <- msg
+		}
+	}()
+	return c
+}
+
+func (c *client) receive() message {
+	c.t.Helper()
+	msg, ok := <-c.out
+	if !ok {
+		c.t.Fatal("the server closed the connection")
+	}
+	return msg
+}
+
+func (c *client) send(msg message) {
+	c.t.Helper()
+	if err := writeMessage(c.in, msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.send(message{Method: method, Params: raw})
+}
+
+// Send the request and wait for the response, decoding its result into `result`.
+// The notifications received in the meantime are collected.
+func (c *client) request(method string, params any, result any) {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(fmt.Sprint(c.id))
+	raw, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.send(message{ID: &id, Method: method, Params: raw})
+	for {
+		msg := c.receive()
+		if msg.ID == nil {
+			c.notifs = append(c.notifs, msg)
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("expected the response to %s, got %s", id, *msg.ID)
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("unexpected error: %s", msg.Error.Message)
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatal(err)
+		}
+		return
+	}
+}
+
+// The diagnostics published last for the document.
+func (c *client) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+	for i := len(c.notifs) - 1; i >= 0; i-- {
+		var p PublishDiagnosticsParams
+		if err := json.Unmarshal(c.notifs[i].Params, &p); err != nil {
+			c.t.Fatal(err)
+		}
+		if c.notifs[i].Method == "textDocument/publishDiagnostics" && p.URI == uri {
+			return p.Diagnostics
+		}
+	}
+	c.t.Fatalf("no diagnostics published for %s", uri)
+	return nil
+}
+
+func (c *client) open(uri, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocumentItem{uri, text}})
+}
+
+func (c *client) exit() {
+	c.t.Helper()
+	var result any
+	c.request("shutdown", nil, &result)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func at(uri string, line, char int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocumentIdentifier{uri}, Position{line, char}}
+}
+
+func span(line, start, end int) Range {
+	return Range{Position{line, start}, Position{line, end}}
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.ge")
+	if err := os.WriteFile(lib, []byte("fun double(X) -> X * 2 end.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := pathToURI(filepath.Join(dir, "main.ge"))
+	code := `include("lib.ge").
+-record(point, {x, y}).
+Limit = 10.
+fun fact(0) -> 1;
+    (N) when N > 0 -> N * fact(N - 1)
+end.
+fun f(X) ->
+    Y = double(X),
+
+end.
+print(fact(Limit) + Z).
+`
+
+	c := newClient(t)
+	var init struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.request("initialize", map[string]any{}, &init)
+	if init.Capabilities["hoverProvider"] != true {
+		t.Errorf("unexpected capabilities: %v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]any{})
+	c.open(uri, code)
+
+	t.Run("diagnostics", func(t *testing.T) {
+		// the diagnostics are published before the response to the next request
+		var symbols []DocumentSymbol
+		c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocumentIdentifier{uri}}, &symbols)
+		expected := []Diagnostic{
+			{span(9, 0, 3), severityError, "goer", "unexpected: end"},
+			{span(10, 20, 21), severityError, "goer", "variable 'Z' is unbound"},
+		}
+		if result := c.diagnostics(uri); !cmp.Equal(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+	})
+
+	t.Run("definition", func(t *testing.T) {
+		var result []Location
+		c.request("textDocument/definition", at(uri, 10, 7), &result)
+		expected := []Location{{uri, span(3, 4, 8)}}
+		if !cmp.Equal(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+
+		c.request("textDocument/definition", at(uri, 0, 10), &result)
+		expected = []Location{{pathToURI(lib), Range{}}}
+		if !cmp.Equal(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+
+		c.request("textDocument/definition", at(uri, 7, 10), &result)
+		expected = []Location{{pathToURI(lib), span(0, 4, 10)}}
+		if !cmp.Equal(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+	})
+
+	t.Run("hover", func(t *testing.T) {
+		var result *Hover
+		c.request("textDocument/hover", at(uri, 10, 8), &result)
+		expected := &Hover{MarkupContent{"markdown", "```erlang\nfact(0)\nfact(N) when N > 0\n```"}, span(10, 6, 10)}
+		if !cmp.Equal(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+
+		c.request("textDocument/hover", at(uri, 10, 2), &result)
+		expected = &Hover{MarkupContent{"markdown", "build-in function `print`"}, span(10, 0, 5)}
+		if !cmp.Equal(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+
+		result = nil
+		c.request("textDocument/hover", at(uri, 2, 1), &result)
+		if result != nil {
+			t.Errorf("expected no hover, got %v", result)
+		}
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		var result []CompletionItem
+		c.request("textDocument/completion", at(uri, 8, 0), &result)
+		var variables, functions []string
+		for _, item := range result {
+			switch item.Kind {
+			case completionVariable:
+				variables = append(variables, item.Label)
+			case completionFunction:
+				functions = append(functions, item.Label)
+			}
+		}
+		// the variables of the other functions are not in scope
+		if expected := []string{"Limit", "X", "Y"}; !cmp.Equal(variables, expected) {
+			t.Errorf("expected %v, got %v", expected, variables)
+		}
+		for _, name := range []string{"double", "fact", "print", "spawn"} {
+			if !slices.Contains(functions, name) {
+				t.Errorf("%s is missing in the completions", name)
+			}
+		}
+	})
+
+	t.Run("symbols", func(t *testing.T) {
+		var result []DocumentSymbol
+		c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocumentIdentifier{uri}}, &result)
+		expected := []DocumentSymbol{
+			{"point", "", symbolStruct, span(1, 0, 22), span(1, 8, 13)},
+			{"Limit", "", symbolVariable, span(2, 0, 5), span(2, 0, 5)},
+			{"fact", "/1", symbolFunction, Range{Position{3, 4}, Position{5, 3}}, span(3, 4, 8)},
+		}
+		if !cmp.Equal(result, expected) {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+	})
+
+	t.Run("change", func(t *testing.T) {
+		params := DidChangeTextDocumentParams{TextDocument: TextDocumentIdentifier{uri}}
+		params.ContentChanges = append(params.ContentChanges, struct {
+			Text string `json:"text"`
+		}{"X = 1, X.\n"})
+		c.notify("textDocument/didChange", params)
+		var symbols []DocumentSymbol
+		c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocumentIdentifier{uri}}, &symbols)
+		if result := c.diagnostics(uri); len(result) != 0 {
+			t.Errorf("expected no diagnostics, got %v", result)
+		}
+	})
+
+	c.exit()
+}
+
+func TestServerUTF16(t *testing.T) {
+	t.Parallel()
+
+	uri := pathToURI(filepath.Join(t.TempDir(), "main.ge"))
+	code := "fun fact(N) -> N end.\nS = \"😀ż\", Z + fact(len(S)).\n"
+
+	c := newClient(t)
+	var init struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.request("initialize", map[string]any{}, &init)
+	if init.Capabilities["positionEncoding"] != "utf-16" {
+		t.Errorf("unexpected capabilities: %v", init.Capabilities)
+	}
+	c.open(uri, code)
+
+	// the emoji takes two UTF-16 code units
+	var hover *Hover
+	c.request("textDocument/hover", at(uri, 1, 16), &hover)
+	if hover == nil || !cmp.Equal(hover.Range, span(1, 15, 19)) {
+		t.Errorf("expected the hover at %v, got %v", span(1, 15, 19), hover)
+	}
+	expected := []Diagnostic{{span(1, 11, 12), severityError, "goer", "variable 'Z' is unbound"}}
+	if result := c.diagnostics(uri); !cmp.Equal(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	c.exit()
+}
+
+func TestServerUnknownMethod(t *testing.T) {
+	t.Parallel()
+
+	c := newClient(t)
+	id := json.RawMessage("7")
+	c.send(message{ID: &id, Method: "workspace/unknown"})
+	msg := c.receive()
+	if msg.Error == nil || msg.Error.Code != methodNotFound {
+		t.Errorf("expected the 'method not found' error, got %v", msg)
+	}
+	c.exit()
+}
@@ -1,61 +1,268 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/twolodzko/goer/core"
+	goerrors "github.com/twolodzko/goer/core/errors"
 	"github.com/twolodzko/goer/core/pids"
-	"github.com/twolodzko/goer/parser/reader"
+	"github.com/twolodzko/goer/format"
+	"github.com/twolodzko/goer/lint"
+	"github.com/twolodzko/goer/lsp"
+	"github.com/twolodzko/goer/types"
+	"github.com/twolodzko/goer/unittest"
 )
 
 func main() {
-	if len(os.Args) == 1 {
-		repl()
-		return
+	useVM := flag.Bool("vm", false, "compile the code to the bytecode and run it on the virtual machine")
+	flag.Parse()
+
+	evalFile, parseEval := core.EvalFile, core.ParseEval
+	if *useVM {
+		evalFile, parseEval = core.RunFile, core.ParseRun
 	}
 
-	env := core.NewEnv()
-	pid := pids.NewPid()
-	defer pid.Close()
+	if flag.NArg() == 0 {
+		repl(parseEval, evalFile)
+		return
+	}
 
-	for _, arg := range os.Args[1:] {
-		_, err := core.EvalFile(arg, env, pid)
-		if err != nil {
+	switch flag.Arg(0) {
+	case "lint":
+		os.Exit(lintFiles(flag.Args()[1:]))
+	case "fmt":
+		os.Exit(formatFiles(flag.Args()[1:]))
+	case "test":
+		os.Exit(testFiles(flag.Args()[1:], *useVM))
+	case "lsp":
+		if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
 			log.Fatal(err)
 		}
+		return
 	}
+
+	os.Exit(runScript(flag.Arg(0), flag.Args()[1:], evalFile))
 }
 
-func repl() {
+// Evaluate the script, and when it defines the `main` function, call it with the list of
+// the command-line arguments. Return the exit status, given to `halt`, or 1 on an error.
+func runScript(path string, args []string, evalFile evaluator) int {
 	env := core.NewEnv()
 	pid := pids.NewPid()
 	defer pid.Close()
 
-	reader := reader.NewReader(os.Stdin)
+	_, err := evalFile(path, env, pid)
+	if err == nil {
+		if main, ok := env.Elems["main"]; ok {
+			var list []types.Expr
+			for _, arg := range args {
+				list = append(list, types.String(arg))
+			}
+			_, err = core.Apply(main, []types.Expr{types.List{list}}, env, pid)
+		}
+	}
+	if halt, ok := goerrors.Cause(err).(goerrors.Halt); ok {
+		return halt.Status
+	}
+	if err != nil {
+		printError(err, "")
+		return 1
+	}
+	return 0
+}
+
+// Lint the files, print the problems found. The exit code is 1 if any were found.
+func lintFiles(paths []string) int {
+	code := 0
+	for _, path := range paths {
+		diagnostics, err := lint.File(path)
+		if err != nil {
+			printError(err, "")
+			return 1
+		}
+		for _, d := range diagnostics {
+			print(d)
+			code = 1
+		}
+	}
+	return code
+}
 
-	fmt.Println("Press ^C to exit.")
-	fmt.Println()
+// Format the files, print them, or with the `-w` flag, overwrite them.
+func formatFiles(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the formatted code to the files instead of printing it")
+	flags.Parse(args)
 
-	for {
-		fmt.Print("> ")
-		code, err := reader.Next()
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
 		if err != nil {
-			printError(err)
+			printError(err, "")
+			return 1
 		}
-		expr, err := core.ParseEval(code, env, pid)
+		formatted, err := format.Source(string(src))
 		if err != nil {
-			printError(err)
+			printError(inFile(path, err), string(src))
+			return 1
+		}
+		if !*write {
+			fmt.Print(formatted)
+			continue
+		}
+		if formatted == string(src) {
 			continue
 		}
-		print(expr)
+		if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+			printError(err, "")
+			return 1
+		}
+	}
+	return 0
+}
+
+// Prefix the error with the path of the file, the positions are written as `file:line:col`.
+func inFile(path string, err error) error {
+	var located interface{ Position() types.Pos }
+	if errors.As(err, &located) && located.Position().IsValid() {
+		return fmt.Errorf("%s:%w", path, err)
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+// Run the tests found in the files and directories, print the failures and the summary.
+// The exit code is 1 if any of the tests failed, or any of the files could not be evaluated.
+func testFiles(args []string, useVM bool) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	timeout := flags.Duration("timeout", 5*time.Second, "the time limit of each test, zero for no limit")
+	junit := flags.String("junit", "", "write the report in the JUnit XML format to the file")
+	verbose := flags.Bool("v", false, "print also the tests that passed")
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := unittest.Find(paths)
+	if err != nil {
+		printError(err, "")
+		return 1
+	}
+
+	code, passed, failed := 0, 0, 0
+	var suites []unittest.Suite
+	for _, file := range files {
+		suite := unittest.RunFile(file, unittest.Config{VM: useVM, Timeout: *timeout})
+		suites = append(suites, suite)
+		for _, r := range suite.Results {
+			if r.Err != nil {
+				print(fmt.Sprintf("--- FAIL: %s (%v, %.2fs)", r.Name, r.Pos, r.Duration.Seconds()))
+				printError(r.Err, "")
+			} else if *verbose {
+				print(fmt.Sprintf("--- PASS: %s (%.2fs)", r.Name, r.Duration.Seconds()))
+			}
+		}
+		n := suite.Failed()
+		passed += len(suite.Results) - n
+		failed += n
+		switch {
+		case suite.Err != nil:
+			printError(suite.Err, "")
+			print(fmt.Sprintf("FAIL\t%s\t%.2fs", file, suite.Duration.Seconds()))
+			code = 1
+		case n > 0:
+			print(fmt.Sprintf("FAIL\t%s\t%.2fs", file, suite.Duration.Seconds()))
+			code = 1
+		default:
+			print(fmt.Sprintf("ok\t%s\t%.2fs", file, suite.Duration.Seconds()))
+		}
+	}
+	print(fmt.Sprintf("%d passed, %d failed", passed, failed))
+
+	if *junit != "" {
+		file, err := os.Create(*junit)
+		if err != nil {
+			printError(err, "")
+			return 1
+		}
+		defer file.Close()
+		if err := unittest.WriteJUnit(file, suites); err != nil {
+			printError(err, "")
+			return 1
+		}
+	}
+	return code
+}
+
+// Print the error and its stack trace.
+func printError(msg error, source string) {
+	print(formatError(msg, source))
+}
+
+// Describe the error and its stack trace. If the position of the error is known, show also
+// the excerpt of the code where it happened, taken from the `source` or the file it refers to.
+func formatError(msg error, source string) string {
+	lines := []string{fmt.Sprintf("ERROR: %s", msg)}
+
+	var located interface{ Position() types.Pos }
+	if errors.As(msg, &located) {
+		if excerpt, ok := excerptAt(source, located.Position()); ok {
+			lines = append(lines, excerpt)
+		}
+	}
+	for _, frame := range goerrors.StackTrace(msg) {
+		lines = append(lines, fmt.Sprintf("    in %v", frame))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Show the excerpt of the code at the position, taken from the `source`
+// or the file that the position refers to.
+func excerptAt(source string, pos types.Pos) (string, bool) {
+	if !pos.IsValid() {
+		return "", false
+	}
+	if pos.File != "" {
+		content, err := os.ReadFile(pos.File)
+		if err != nil {
+			return "", false
+		}
+		source = string(content)
 	}
+	return excerpt(source, pos)
 }
 
-func printError(msg error) {
-	print(fmt.Sprintf("ERROR: %s", msg))
+// Show the line of the `source` code at the position, with a caret pointing at the column.
+func excerpt(source string, pos types.Pos) (string, bool) {
+	lines := strings.Split(source, "\n")
+	if pos.Line > len(lines) {
+		return "", false
+	}
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+
+	// keep the tabs, so the caret is aligned with the code
+	var indent strings.Builder
+	for i, r := range []rune(line) {
+		if i >= pos.Col-1 {
+			break
+		}
+		if unicode.IsSpace(r) {
+			indent.WriteRune(r)
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+
+	number := fmt.Sprint(pos.Line)
+	margin := strings.Repeat(" ", len(number))
+	return fmt.Sprintf("%s | %s\n%s | %s^", number, line, margin, indent.String()), true
 }
 
 func print(msg any) {
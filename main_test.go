@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/twolodzko/goer/core"
+	"github.com/twolodzko/goer/format"
+	"github.com/twolodzko/goer/parser"
+)
+
+func TestRunScript(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		script   string
+		args     []string
+		expected int
+	}{
+		{"X = 1.", nil, 0},
+		{"halt(3).", nil, 3},
+		{"error(boom).", nil, 1},
+		{"#!/usr/bin/env goer\nX = 1.", nil, 0},
+		// the main function gets the arguments
+		{"fun main(Args) -> halt(len(Args)) end.", []string{"a", "b"}, 2},
+		{"fun main([\"x\", N]) -> halt(to_int(N)) end.", []string{"x", "7"}, 7},
+		{"fun main(_) -> ok end.", []string{"a"}, 0},
+		{"fun main([]) -> ok end.", []string{"a"}, 1},
+		// it cannot be caught
+		{"fun main(_) -> try halt(4) catch _:_ -> ok end end.", nil, 4},
+		{"fun main(_) -> spawn(fun() -> ok end), halt(5) end.", nil, 5},
+	}
+
+	for _, engine := range []struct {
+		name     string
+		evalFile evaluator
+	}{
+		{"tree", core.EvalFile},
+		{"vm", core.RunFile},
+	} {
+		for _, tt := range testCases {
+			t.Run(engine.name+" "+tt.script, func(t *testing.T) {
+				t.Parallel()
+
+				path := filepath.Join(t.TempDir(), "script.ge")
+				if err := os.WriteFile(path, []byte(tt.script), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if status := runScript(path, tt.args, engine.evalFile); status != tt.expected {
+					t.Errorf("expected the status %d, got %d", tt.expected, status)
+				}
+			})
+		}
+	}
+}
+
+func TestInFile(t *testing.T) {
+	t.Parallel()
+
+	_, err := format.Source("X = 1,\nY = .")
+	expected := "ERROR: fm.ge:2:5: unexpected: .\n2 | Y = .\n  |     ^"
+	if msg := formatError(inFile("fm.ge", err), "X = 1,\nY = ."); msg != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, msg)
+	}
+	expected = "ERROR: fm.ge: body of the expression cannot be empty"
+	if msg := formatError(inFile("fm.ge", parser.EmptyBody{}), ""); msg != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, msg)
+	}
+}
+
+func TestTestFiles(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		code     string
+		expected int
+	}{
+		{"fun one_test() -> assert_equal(1, 1) end.", 0},
+		{"fun one_test() -> assert_equal(1, 2) end.", 1},
+		{"fun one_test() -> ok end. X = .", 1},
+		// no tests
+		{"X = 1.", 0},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.code, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "code_test.ge"), []byte(tt.code), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			report := filepath.Join(dir, "report.xml")
+			if status := testFiles([]string{"-junit", report, dir}, false); status != tt.expected {
+				t.Errorf("expected the status %d, got %d", tt.expected, status)
+			}
+			if _, err := os.Stat(report); err != nil {
+				t.Errorf("the report was not written: %s", err)
+			}
+		})
+	}
+}
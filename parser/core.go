@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"unicode/utf8"
+
 	"github.com/twolodzko/goer/parser/lexer"
 	. "github.com/twolodzko/goer/types"
 )
@@ -30,9 +32,37 @@ func (p *Parser) skip() {
 	p.pos++
 }
 
+// The last token returned by the parser.
+func (p *Parser) previous() lexer.Token {
+	if p.pos == 0 || p.pos > len(p.tokens) {
+		return lexer.Token{}
+	}
+	return p.tokens[p.pos-1]
+}
+
+// Position of the next token, or the end of the input.
+func (p *Parser) position() Pos {
+	if token, ok := p.peek(); ok {
+		return token.Pos
+	}
+	if len(p.tokens) == 0 {
+		return Pos{}
+	}
+	last := p.tokens[len(p.tokens)-1]
+	end := last.Pos
+	end.Col += utf8.RuneCountInString(last.Value)
+	return end
+}
+
 // Parse the string.
 func Parse(input string) ([]Expr, error) {
-	tokens, err := lexer.Tokenize(input)
+	return ParseAt(input, Pos{Line: 1, Col: 1})
+}
+
+// Parse the string, the positions in the code
+// are counted from the `start` position.
+func ParseAt(input string, start Pos) ([]Expr, error) {
+	tokens, err := lexer.TokenizeAt(input, start)
 	if err != nil {
 		return nil, err
 	}
@@ -4,12 +4,19 @@ import (
 	"fmt"
 
 	"github.com/twolodzko/goer/parser/lexer"
+	"github.com/twolodzko/goer/types"
 )
 
-type EoF struct{}
+type EoF struct {
+	Pos types.Pos
+}
 
 func (err EoF) Error() string {
-	return "unexpected end of input"
+	return withPos(err.Pos, "unexpected end of input")
+}
+
+func (err EoF) Position() types.Pos {
+	return err.Pos
 }
 
 type Unexpected struct {
@@ -17,15 +24,24 @@ type Unexpected struct {
 }
 
 func (err Unexpected) Error() string {
-	return fmt.Sprintf("unexpected: %v", err.Token)
+	return withPos(err.Token.Pos, fmt.Sprintf("unexpected: %v", err.Token))
+}
+
+func (err Unexpected) Position() types.Pos {
+	return err.Token.Pos
 }
 
 type Missing struct {
 	Token lexer.TokenType
+	Pos   types.Pos
 }
 
 func (err Missing) Error() string {
-	return fmt.Sprintf("missing: %v", err.Token)
+	return withPos(err.Pos, fmt.Sprintf("missing: %v", err.Token))
+}
+
+func (err Missing) Position() types.Pos {
+	return err.Pos
 }
 
 type EmptyBody struct{}
@@ -33,3 +49,11 @@ type EmptyBody struct{}
 func (err EmptyBody) Error() string {
 	return "body of the expression cannot be empty"
 }
+
+// Prefix the message with the position, if it is known.
+func withPos(pos types.Pos, msg string) string {
+	if pos.IsValid() {
+		return fmt.Sprintf("%v: %s", pos, msg)
+	}
+	return msg
+}
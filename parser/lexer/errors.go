@@ -1,6 +1,10 @@
 package lexer
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/twolodzko/goer/types"
+)
 
 type EoF struct{}
 
@@ -14,8 +18,18 @@ func (err Empty) Error() string {
 	return "empty token"
 }
 
-type Invalid struct{ Value string }
+type Invalid struct {
+	Value string
+	Pos   types.Pos
+}
 
 func (err Invalid) Error() string {
+	if err.Pos.IsValid() {
+		return fmt.Sprintf("%v: invalid token '%s'", err.Pos, err.Value)
+	}
 	return fmt.Sprintf("invalid token '%s'", err.Value)
 }
+
+func (err Invalid) Position() types.Pos {
+	return err.Pos
+}
@@ -1,19 +1,27 @@
 package lexer
 
 import (
+	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/twolodzko/goer/types"
 )
 
 type lexer struct {
-	input string // the string being scanned
-	pos   int    // current position in the input
-	start int    // position where did the current token start
-	head  rune   // the cursor: the currently processed rune
+	input string    // the string being scanned
+	pos   int       // current position in the input
+	start int       // position where did the current token start
+	head  rune      // the cursor: the currently processed rune
+	at    types.Pos // line and column of the `pos` position
+	from  types.Pos // line and column where the current token started
+	// the comments skipped so far, and the line where the last token ended
+	comments []Comment
+	lastLine int
 }
 
-func newLexer(input string) lexer {
-	return lexer{input, 0, 0, utf8.RuneError}
+func newLexer(input string, start types.Pos) lexer {
+	return lexer{input, 0, 0, utf8.RuneError, start, start, nil, 0}
 }
 
 // Read a token, return the status.
@@ -24,6 +32,7 @@ func (l *lexer) nextToken() (Token, error) {
 
 	// reset token starting position in the input
 	l.start = l.pos
+	l.from = l.at
 
 	// peek first rune
 	if l.next() == 0 {
@@ -37,7 +46,7 @@ func (l *lexer) nextToken() (Token, error) {
 		if size == 0 {
 			return Token{}, Empty{}
 		}
-		return Token{atomType(val), val}, nil
+		return Token{atomType(val), val, l.from}, nil
 	}
 
 	// variable
@@ -53,7 +62,7 @@ func (l *lexer) nextToken() (Token, error) {
 			l.takeWhile(isName)
 			return l.collectToken(Variable)
 		}
-		return Token{Dummy, "_"}, nil
+		return Token{Dummy, "_", l.from}, nil
 	}
 
 	// integer
@@ -62,27 +71,54 @@ func (l *lexer) nextToken() (Token, error) {
 		return l.collectToken(Number)
 	}
 
+	// brackets of the binary, they are not a part of the longer operator
+	if l.expectIs('<') && l.expectNext(isRune('<')) || l.expectIs('>') && l.expectNext(isRune('>')) {
+		l.next()
+		val, _ := l.collect()
+		return Token{operatorType(val), val, l.from}, nil
+	}
+
 	// operator
 	if l.expect(isOperator) {
 		l.takeWhile(isOperator)
 		val, _ := l.collect()
-		return Token{operatorType(val), val}, nil
+		return Token{operatorType(val), val, l.from}, nil
 	}
 
 	// string
 	if l.expectIs('"') {
-		l.readString()
+		l.readQuoted('"')
 		return l.collectToken(String)
 	}
 
-	// skip the comment
+	// quoted atom
+	if l.expectIs('\'') {
+		l.readQuoted('\'')
+		return l.collectToken(Atom)
+	}
+
+	// character
+	if l.expectIs('$') {
+		if l.next() == 0 {
+			return Token{}, Invalid{"$", l.from}
+		}
+		if l.expectIs('\\') {
+			l.readEscape()
+		}
+		return l.collectToken(Char)
+	}
+
+	// skip the comment, but keep it aside
 	if l.expectIs('%') {
 		l.takeUntilIs('\n')
+		text, _ := l.collect()
+		trailing := l.lastLine > 0 && l.lastLine == l.from.Line
+		l.comments = append(l.comments, Comment{strings.TrimRightFunc(text, unicode.IsSpace), l.from, trailing})
 		return l.nextToken()
 	}
 
 	// other special, single-character tokens
-	return literalToken(l.head)
+	return literalToken(l.head, l.from)
 }
 
 // Collect the substring in the `start:pos` range.
@@ -96,7 +132,7 @@ func (l *lexer) collectToken(typ TokenType) (Token, error) {
 	if size == 0 {
 		return Token{}, Empty{}
 	}
-	return Token{typ, val}, nil
+	return Token{typ, val, l.from}, nil
 }
 
 // Peek what is the next rune without moving the pointer.
@@ -106,12 +142,26 @@ func (l *lexer) peek() (rune, int) {
 
 // Move the `head` cursor one rune ahead, return size of the rune.
 func (l *lexer) next() int {
-	var width int
-	l.head, width = l.peek()
-	l.pos += width
+	r, width := l.peek()
+	l.move(r, width)
 	return width
 }
 
+// Move the `head` cursor to the rune, update the position.
+func (l *lexer) move(r rune, width int) {
+	l.head = r
+	l.pos += width
+	if width == 0 {
+		return
+	}
+	if r == '\n' {
+		l.at.Line++
+		l.at.Col = 1
+	} else {
+		l.at.Col++
+	}
+}
+
 // The cursor matched the condition.
 func (l *lexer) expect(matches func(rune) bool) bool {
 	return matches(l.head)
@@ -136,8 +186,7 @@ func (l *lexer) takeWhile(matches func(rune) bool) bool {
 		if width == 0 || !matches(r) {
 			break
 		}
-		l.head = r
-		l.pos += width
+		l.move(r, width)
 	}
 	return l.pos > start
 }
@@ -151,8 +200,8 @@ func (l *lexer) takeUntilIs(r rune) {
 	}
 }
 
-// Take characters until '"' while respecting quoted characters.
-func (l *lexer) readString() bool {
+// Take characters until the `quote` while respecting quoted characters.
+func (l *lexer) readQuoted(quote rune) bool {
 	for {
 		r, width := l.peek()
 		if width == 0 {
@@ -163,8 +212,22 @@ func (l *lexer) readString() bool {
 		switch r {
 		case '\\':
 			l.next()
-		case '"':
+		case quote:
 			return true
 		}
 	}
 }
+
+// Take the escape sequence following the backslash, like "\n" or "\x41".
+func (l *lexer) readEscape() {
+	if l.next() > 0 && l.expectIs('x') {
+		l.takeN(2, isHexDigit)
+	}
+}
+
+// Take up to `n` characters that match the condition.
+func (l *lexer) takeN(n int, matches func(rune) bool) {
+	for i := 0; i < n && l.expectNext(matches); i++ {
+		l.next()
+	}
+}
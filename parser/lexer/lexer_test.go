@@ -5,6 +5,7 @@ import (
 	"unicode"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/twolodzko/goer/types"
 )
 
 func TestTokenize(t *testing.T) {
@@ -15,40 +16,63 @@ func TestTokenize(t *testing.T) {
 		expected []Token
 	}{
 		{"", nil},
-		{"foo", []Token{{Atom, "foo"}}},
-		{"bar   ", []Token{{Atom, "bar"}}},
-		{"other_@Atom", []Token{{Atom, "other_@Atom"}}},
-		{"X", []Token{{Variable, "X"}}},
-		{" Abc	", []Token{{Variable, "Abc"}}},
-		{" _ ", []Token{{Dummy, "_"}}},
-		{" _This	", []Token{{Variable, "_This"}}},
-		{"42", []Token{{Number, "42"}}},
-		{"  123  ", []Token{{Number, "123"}}},
-		{".", []Token{{Dot, "."}}},
-		{",", []Token{{Comma, ","}}},
-		{";", []Token{{Semicolon, ";"}}},
-		{"()", []Token{{BracketLeft, "("}, {BracketRight, ")"}}},
-		{"{}", []Token{{BraceLeft, "{"}, {BraceRight, "}"}}},
-		{"+", []Token{{Operator, "+"}}},
-		{"->", []Token{{Arrow, "->"}}},
-		{"when", []Token{{When, "when"}}},
-		{"not Thing", []Token{{Operator, "not"}, {Variable, "Thing"}}},
-		{"2 + 3", []Token{{Number, "2"}, {Operator, "+"}, {Number, "3"}}},
-		{"36-X", []Token{{Number, "36"}, {Operator, "-"}, {Variable, "X"}}},
-		{"8*14", []Token{{Number, "8"}, {Operator, "*"}, {Number, "14"}}},
-		{"<=", []Token{{Operator, "<="}}},
-		{"21=/=12", []Token{{Number, "21"}, {Operator, "=/="}, {Number, "12"}}},
-		{"2*PI", []Token{{Number, "2"}, {Operator, "*"}, {Variable, "PI"}}},
+		{"foo", []Token{{Atom, "foo", col(1)}}},
+		{"bar   ", []Token{{Atom, "bar", col(1)}}},
+		{"other_@Atom", []Token{{Atom, "other_@Atom", col(1)}}},
+		{"X", []Token{{Variable, "X", col(1)}}},
+		{" Abc	", []Token{{Variable, "Abc", col(2)}}},
+		{" _ ", []Token{{Dummy, "_", col(2)}}},
+		{" _This	", []Token{{Variable, "_This", col(2)}}},
+		{"42", []Token{{Number, "42", col(1)}}},
+		{"  123  ", []Token{{Number, "123", col(3)}}},
+		{".", []Token{{Dot, ".", col(1)}}},
+		{",", []Token{{Comma, ",", col(1)}}},
+		{";", []Token{{Semicolon, ";", col(1)}}},
+		{"()", []Token{{BracketLeft, "(", col(1)}, {BracketRight, ")", col(2)}}},
+		{"{}", []Token{{BraceLeft, "{", col(1)}, {BraceRight, "}", col(2)}}},
+		{"+", []Token{{Operator, "+", col(1)}}},
+		{"->", []Token{{Arrow, "->", col(1)}}},
+		{"when", []Token{{When, "when", col(1)}}},
+		{"catch", []Token{{Catch, "catch", col(1)}}},
+		{"error:X", []Token{{Atom, "error", col(1)}, {Operator, ":", col(6)}, {Variable, "X", col(7)}}},
+		{"not Thing", []Token{{Operator, "not", col(1)}, {Variable, "Thing", col(5)}}},
+		{"2 + 3", []Token{{Number, "2", col(1)}, {Operator, "+", col(3)}, {Number, "3", col(5)}}},
+		{"36-X", []Token{{Number, "36", col(1)}, {Operator, "-", col(3)}, {Variable, "X", col(4)}}},
+		{"8*14", []Token{{Number, "8", col(1)}, {Operator, "*", col(2)}, {Number, "14", col(3)}}},
+		{"<=", []Token{{Operator, "<=", col(1)}}},
+		{"21=/=12", []Token{{Number, "21", col(1)}, {Operator, "=/=", col(3)}, {Number, "12", col(6)}}},
+		{"7 div 2", []Token{{Number, "7", col(1)}, {Operator, "div", col(3)}, {Number, "2", col(7)}}},
+		{"bnot X bsl 2", []Token{
+			{Operator, "bnot", col(1)}, {Variable, "X", col(6)}, {Operator, "bsl", col(8)}, {Number, "2", col(12)},
+		}},
+		{"<<-1>>", []Token{{BinaryLeft, "<<", col(1)}, {Operator, "-", col(3)}, {Number, "1", col(4)}, {BinaryRight, ">>", col(5)}}},
+		{"<<X:16/binary>>=B", []Token{
+			{BinaryLeft, "<<", col(1)}, {Variable, "X", col(3)}, {Operator, ":", col(4)}, {Number, "16", col(5)},
+			{Operator, "/", col(7)}, {Atom, "binary", col(8)}, {BinaryRight, ">>", col(14)}, {Operator, "=", col(16)},
+			{Variable, "B", col(17)},
+		}},
+		{"2*PI", []Token{{Number, "2", col(1)}, {Operator, "*", col(2)}, {Variable, "PI", col(3)}}},
 		{"(2+7)/3", []Token{
-			{BracketLeft, "("}, {Number, "2"}, {Operator, "+"}, {Number, "7"}, {BracketRight, ")"},
-			{Operator, "/"}, {Number, "3"},
+			{BracketLeft, "(", col(1)}, {Number, "2", col(2)}, {Operator, "+", col(3)}, {Number, "7", col(4)}, {BracketRight, ")", col(5)},
+			{Operator, "/", col(6)}, {Number, "3", col(7)},
+		}},
+		{"% hey, skip this comment\n ok", []Token{{Atom, "ok", types.Pos{Line: 2, Col: 2}}}},
+		{"alone  % also skip that comment", []Token{{Atom, "alone", col(1)}}},
+		{"(_)", []Token{{BracketLeft, "(", col(1)}, {Dummy, "_", col(2)}, {BracketRight, ")", col(3)}}},
+		{`""`, []Token{{String, `""`, col(1)}}},
+		{`"Hello, World!"`, []Token{{String, `"Hello, World!"`, col(1)}}},
+		{`"\"Hello,\nWorld!\""`, []Token{{String, `"\"Hello,\nWorld!\""`, col(1)}}},
+		{"'EXIT'", []Token{{Atom, "'EXIT'", col(1)}}},
+		{"U#user.name", []Token{
+			{Variable, "U", col(1)}, {Hash, "#", col(2)}, {Atom, "user", col(3)}, {Dot, ".", col(7)}, {Atom, "name", col(8)},
+		}},
+		{`'it\'s ok'`, []Token{{Atom, `'it\'s ok'`, col(1)}}},
+		{"$a", []Token{{Char, "$a", col(1)}}},
+		{"$ ", []Token{{Char, "$ ", col(1)}}},
+		{`[$\n,$\x41]`, []Token{
+			{SuareBracketLeft, "[", col(1)}, {Char, `$\n`, col(2)}, {Comma, ",", col(5)},
+			{Char, `$\x41`, col(6)}, {SquareBracketRight, "]", col(11)},
 		}},
-		{"% hey, skip this comment\n ok", []Token{{Atom, "ok"}}},
-		{"alone  % also skip that comment", []Token{{Atom, "alone"}}},
-		{"(_)", []Token{{BracketLeft, "("}, {Dummy, "_"}, {BracketRight, ")"}}},
-		{`""`, []Token{{String, `""`}}},
-		{`"Hello, World!"`, []Token{{String, `"Hello, World!"`}}},
-		{`"\"Hello,\nWorld!\""`, []Token{{String, `"\"Hello,\nWorld!\""`}}},
 	}
 
 	for _, tt := range testCases {
@@ -62,6 +86,72 @@ func TestTokenize(t *testing.T) {
 	}
 }
 
+func TestTokenizeAt(t *testing.T) {
+	t.Parallel()
+
+	input := "foo(X,\n  \"a\nb\") ->\n\tok ."
+	expected := []Token{
+		{Atom, "foo", types.Pos{File: "test.ge", Line: 3, Col: 5}},
+		{BracketLeft, "(", types.Pos{File: "test.ge", Line: 3, Col: 8}},
+		{Variable, "X", types.Pos{File: "test.ge", Line: 3, Col: 9}},
+		{Comma, ",", types.Pos{File: "test.ge", Line: 3, Col: 10}},
+		{String, "\"a\nb\"", types.Pos{File: "test.ge", Line: 4, Col: 3}},
+		{BracketRight, ")", types.Pos{File: "test.ge", Line: 5, Col: 3}},
+		{Arrow, "->", types.Pos{File: "test.ge", Line: 5, Col: 5}},
+		{Atom, "ok", types.Pos{File: "test.ge", Line: 6, Col: 2}},
+		{Dot, ".", types.Pos{File: "test.ge", Line: 6, Col: 5}},
+	}
+
+	result, err := TokenizeAt(input, types.Pos{File: "test.ge", Line: 3, Col: 5})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !cmp.Equal(result, expected) {
+		t.Errorf("expected %v, got: %v", expected, result)
+	}
+}
+
+func TestTokenizeComments(t *testing.T) {
+	t.Parallel()
+
+	input := "% header\nfoo(X), % trailing  \n%% own line\nok."
+	expectedTokens := []Token{
+		{Atom, "foo", types.Pos{Line: 2, Col: 1}},
+		{BracketLeft, "(", types.Pos{Line: 2, Col: 4}},
+		{Variable, "X", types.Pos{Line: 2, Col: 5}},
+		{BracketRight, ")", types.Pos{Line: 2, Col: 6}},
+		{Comma, ",", types.Pos{Line: 2, Col: 7}},
+		{Atom, "ok", types.Pos{Line: 4, Col: 1}},
+		{Dot, ".", types.Pos{Line: 4, Col: 3}},
+	}
+	expectedComments := []Comment{
+		{"% header", types.Pos{Line: 1, Col: 1}, false},
+		{"% trailing", types.Pos{Line: 2, Col: 9}, true},
+		{"%% own line", types.Pos{Line: 3, Col: 1}, false},
+	}
+
+	tokens, comments, err := TokenizeComments(input, types.Pos{Line: 1, Col: 1})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !cmp.Equal(tokens, expectedTokens) {
+		t.Errorf("expected %v, got: %v", expectedTokens, tokens)
+	}
+	if !cmp.Equal(comments, expectedComments) {
+		t.Errorf("expected %v, got: %v", expectedComments, comments)
+	}
+}
+
+func TestTokenizeInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := Tokenize("foo(\n  ^")
+	expected := Invalid{"^", types.Pos{Line: 2, Col: 3}}
+	if !cmp.Equal(err, expected) {
+		t.Errorf("expected %v, got: %v", expected, err)
+	}
+}
+
 func TestTakeWhile(t *testing.T) {
 	t.Parallel()
 
@@ -79,7 +169,7 @@ func TestTakeWhile(t *testing.T) {
 	}
 
 	for _, tt := range testCases {
-		lx := newLexer(tt.input)
+		lx := newLexer(tt.input, col(1))
 		lx.next()
 		lx.takeWhile(tt.fun)
 		if lx.pos != tt.pos {
@@ -87,3 +177,8 @@ func TestTakeWhile(t *testing.T) {
 		}
 	}
 }
+
+// Position in the first line of the input.
+func col(n int) types.Pos {
+	return types.Pos{Line: 1, Col: n}
+}
@@ -1,6 +1,10 @@
 package lexer
 
-import "unicode"
+import (
+	"unicode"
+
+	"github.com/twolodzko/goer/types"
+)
 
 // Character that can be a part of atom or variable name.
 func isName(r rune) bool {
@@ -8,6 +12,11 @@ func isName(r rune) bool {
 	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' || r == '@'
 }
 
+// Hexadecimal digit.
+func isHexDigit(r rune) bool {
+	return unicode.Is(unicode.ASCII_Hex_Digit, r)
+}
+
 // The rune can be part of the operator name.
 func isOperator(r rune) bool {
 	switch r {
@@ -18,8 +27,15 @@ func isOperator(r rune) bool {
 	}
 }
 
+// Matcher for the specific rune.
+func isRune(r rune) func(rune) bool {
+	return func(other rune) bool {
+		return other == r
+	}
+}
+
 // Match a single-character token.
-func literalToken(r rune) (Token, error) {
+func literalToken(r rune, pos types.Pos) (Token, error) {
 	var typ TokenType
 	switch r {
 	case '.':
@@ -28,6 +44,8 @@ func literalToken(r rune) (Token, error) {
 		typ = Comma
 	case ';':
 		typ = Semicolon
+	case '#':
+		typ = Hash
 	case '(':
 		typ = BracketLeft
 	case ')':
@@ -43,19 +61,22 @@ func literalToken(r rune) (Token, error) {
 	case '_':
 		typ = Dummy
 	default:
-		return Token{}, Invalid{string(r)}
+		return Token{}, Invalid{string(r), pos}
 	}
-	return Token{typ, string(r)}, nil
+	return Token{typ, string(r), pos}, nil
 }
 
 func atomType(s string) TokenType {
 	switch s {
-	case "not", "rem", "div", "and", "or":
+	case "not", "rem", "div", "and", "or", "xor", "andalso", "orelse",
+		"bnot", "band", "bor", "bxor", "bsl", "bsr":
 		return Operator
 	case "fun":
 		return Fun
 	case "if":
 		return If
+	case "begin":
+		return Begin
 	case "case":
 		return Case
 	case "of":
@@ -72,6 +93,8 @@ func atomType(s string) TokenType {
 		return Try
 	case "recover":
 		return Recover
+	case "catch":
+		return Catch
 	default:
 		return Atom
 	}
@@ -81,6 +104,10 @@ func operatorType(s string) TokenType {
 	switch s {
 	case "->":
 		return Arrow
+	case "<<":
+		return BinaryLeft
+	case ">>":
+		return BinaryRight
 	default:
 		return Operator
 	}
@@ -1,18 +1,33 @@
 package lexer
 
+import "github.com/twolodzko/goer/types"
+
 // Convert string to a list of tokens.
 func Tokenize(input string) ([]Token, error) {
+	return TokenizeAt(input, types.Pos{Line: 1, Col: 1})
+}
+
+// Convert string to a list of tokens, the positions
+// of the tokens are counted from the `start` position.
+func TokenizeAt(input string, start types.Pos) ([]Token, error) {
+	tokens, _, err := TokenizeComments(input, start)
+	return tokens, err
+}
+
+// Convert string to a list of tokens, and collect the comments found between them.
+func TokenizeComments(input string, start types.Pos) ([]Token, []Comment, error) {
 	var tokens []Token
-	lx := newLexer(input)
+	lx := newLexer(input, start)
 	for {
 		t, err := lx.nextToken()
 		switch err {
 		case nil:
 			tokens = append(tokens, t)
+			lx.lastLine = lx.at.Line
 		case EoF{}:
-			return tokens, nil
+			return tokens, lx.comments, nil
 		default:
-			return nil, err
+			return nil, nil, err
 		}
 	}
 }
@@ -1,5 +1,7 @@
 package lexer
 
+import "github.com/twolodzko/goer/types"
+
 type TokenType int
 
 const (
@@ -8,6 +10,7 @@ const (
 	Dummy                               // starts with "_"
 	Number                              // integer or float
 	String                              // string
+	Char                                // character, e.g. "$a"
 	Operator                            // operator, e.g. "+", "*", "==", "!", "not", ...
 	BracketLeft                         // "("
 	BracketRight                        // ")"
@@ -19,7 +22,9 @@ const (
 	Semicolon                           // ";"
 	Dot                                 // "."
 	Arrow                               // "->"
+	Hash                                // "#"
 	If                                  // "if"
+	Begin                               // "begin"
 	End                                 // "end"
 	Case                                // "case"
 	Of                                  // "of"
@@ -29,11 +34,22 @@ const (
 	After                               // "after"
 	Try                                 // "try"
 	Recover                             // "recover"
+	Catch                               // "catch"
+	BinaryLeft                          // "<<"
+	BinaryRight                         // ">>"
 )
 
 type Token struct {
 	Type  TokenType
 	Value string
+	Pos   types.Pos
+}
+
+// The `%` comment, it is trailing when it follows a token on the same line.
+type Comment struct {
+	Text     string
+	Pos      types.Pos
+	Trailing bool
 }
 
 func (t Token) IsNil() bool {
@@ -54,6 +70,8 @@ func (t TokenType) String() string {
 		return "_"
 	case String:
 		return "string"
+	case Char:
+		return "char"
 	case Number:
 		return "num"
 	case Operator:
@@ -78,8 +96,12 @@ func (t TokenType) String() string {
 		return "."
 	case Arrow:
 		return "->"
+	case Hash:
+		return "#"
 	case If:
 		return "if"
+	case Begin:
+		return "begin"
 	case End:
 		return "end"
 	case Case:
@@ -98,6 +120,12 @@ func (t TokenType) String() string {
 		return "try"
 	case Recover:
 		return "recover"
+	case Catch:
+		return "catch"
+	case BinaryLeft:
+		return "<<"
+	case BinaryRight:
+		return ">>"
 	default:
 		return "unknown token"
 	}
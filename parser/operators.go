@@ -6,21 +6,34 @@ var operatorPrecedence = map[string]int{
 	// priority 1: :
 	// priority 2: #
 	// priority 3: Unary + - bnot not
-	"*":   4,
-	"/":   4,
-	"rem": 4,
-	"+":   5,
-	"-":   5,
-	"++":  6,
-	"!=":  7, // instead of "/="
-	"==":  7,
-	"<":   7,
-	"<=":  7, // instead of "=<"
-	">":   7,
-	">=":  7,
-	"and": 8,
-	"or":  9,
-	"!":   10,
-	"=":   10,
+	"*":       4,
+	"/":       4,
+	"div":     4,
+	"rem":     4,
+	"band":    4,
+	"+":       5,
+	"-":       5,
+	"bor":     5,
+	"bxor":    5,
+	"bsl":     5,
+	"bsr":     5,
+	"++":      6,
+	"==":      7,
+	"!=":      7, // same as "/="
+	"/=":      7,
+	"=:=":     7,
+	"=/=":     7,
+	"<":       7,
+	"<=":      7, // same as "=<"
+	"=<":      7,
+	">":       7,
+	">=":      7,
+	"and":     8,
+	"andalso": 8,
+	"or":      9,
+	"xor":     9,
+	"orelse":  9,
+	"!":       10,
+	"=":       10,
 	// priority 11: catch
 }
@@ -2,6 +2,8 @@ package parser
 
 import (
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/twolodzko/goer/parser/lexer"
 	. "github.com/twolodzko/goer/types"
@@ -11,6 +13,7 @@ import (
 // if block, function definition, function call, etc. It is a standalone unit
 // of code that can be evaluated.
 func (p *Parser) parseExpr() (Expr, error) {
+	start := p.position()
 	expr, err := p.parseTerm()
 	if err != nil {
 		return nil, err
@@ -20,13 +23,22 @@ func (p *Parser) parseExpr() (Expr, error) {
 	next, ok := p.peek()
 	if ok && next.Type == lexer.BracketLeft {
 		p.skip()
+		// calling the quoted atom calls the function named by it
+		if name, ok := expr.(QuotedAtom); ok {
+			expr = Atom(name)
+		}
 		switch expr.(type) {
 		case Atom, Variable, Bracket:
 			args, err := p.parseUntil(lexer.BracketRight)
 			if err != nil {
 				return nil, err
 			}
-			expr = Call{expr, args}
+			if expr == Atom("assert_match") && len(args) == 2 {
+				// the pattern is not evaluated, so it is not an ordinary call
+				expr = AssertMatch{args[0], args[1], start}
+			} else {
+				expr = Call{expr, args, start}
+			}
 		default:
 			// other things followed by a bracket does not make sense
 			return nil, Unexpected{next}
@@ -38,7 +50,7 @@ func (p *Parser) parseExpr() (Expr, error) {
 	if ok && isOperator(next) {
 		p.skip()
 		rhs, err := p.parseExpr()
-		return newBinaryOperation(next.Value, expr, rhs), err
+		return newBinaryOperation(next.Value, next.Pos, expr, rhs), err
 	}
 
 	return expr, nil
@@ -51,7 +63,7 @@ func (p *Parser) parseUntil(delim lexer.TokenType) ([]Expr, error) {
 	// handle empty case
 	token, ok := p.peek()
 	if !ok {
-		return exprs, Missing{delim}
+		return exprs, Missing{delim, p.position()}
 	} else if token.Type == delim {
 		p.skip()
 		return exprs, nil
@@ -67,7 +79,7 @@ func (p *Parser) parseUntil(delim lexer.TokenType) ([]Expr, error) {
 		// punctuation: "," to split and `delim` ends
 		token, ok := p.pop()
 		if !ok {
-			return exprs, Missing{delim}
+			return exprs, Missing{delim, p.position()}
 		}
 		switch token.Type {
 		case lexer.Comma:
@@ -83,14 +95,34 @@ func (p *Parser) parseUntil(delim lexer.TokenType) ([]Expr, error) {
 // Parse single term in an expression. The term can be a standalone unit of code,
 // or it may have continuation (e.g. function call or a binary operation).
 func (p *Parser) parseTerm() (Expr, error) {
+	expr, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	// maybe the record access or update, they can be chained
+	for {
+		token, ok := p.peek()
+		if !ok || token.Type != lexer.Hash {
+			return expr, nil
+		}
+		p.skip()
+		expr, err = p.parseRecord(expr, token.Pos)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Parse the term without the record access or update suffixes.
+func (p *Parser) parseOperand() (Expr, error) {
 	token, ok := p.pop()
 	if !ok {
-		return nil, EoF{}
+		return nil, EoF{p.position()}
 	}
 
 	switch token.Type {
 	case lexer.Atom:
-		return fromAtom(token), nil
+		return fromAtom(token)
 	case lexer.Variable:
 		return Variable(token.Value), nil
 	case lexer.Dummy:
@@ -101,11 +133,16 @@ func (p *Parser) parseTerm() (Expr, error) {
 	case lexer.String:
 		val, err := strconv.Unquote(token.Value)
 		return String(val), err
+	case lexer.Char:
+		return fromChar(token)
 	case lexer.Operator:
+		if token.Value == "-" && p.isRecordDecl() {
+			return p.parseRecordDecl(token.Pos)
+		}
 		// it needs to be a unary operation
-		if isOneOf(token.Value, "+", "-", "not") {
+		if isOneOf(token.Value, "+", "-", "not", "bnot") {
 			rhs, err := p.parseTerm()
-			return UnaryOperation{token.Value, rhs}, err
+			return UnaryOperation{token.Value, rhs, token.Pos}, err
 		} else {
 			return nil, Unexpected{token}
 		}
@@ -122,26 +159,51 @@ func (p *Parser) parseTerm() (Expr, error) {
 		exprs, err := p.parseUntil(lexer.SquareBracketRight)
 		return List{exprs}, err
 	case lexer.Fun:
-		var name string
+		var (
+			name  string
+			local bool
+		)
 		next, ok := p.peek()
 		if !ok {
-			return nil, EoF{}
+			return nil, EoF{p.position()}
 		}
-		if next.Type == lexer.Atom {
-			name = next.Value
+		switch next.Type {
+		case lexer.Atom:
+			var err error
+			name, err = atomName(next)
+			if err != nil {
+				return nil, err
+			}
+			p.skip()
+		case lexer.Variable:
+			// the name is visible only in the function's body
+			name, local = next.Value, true
 			p.skip()
 		}
 		branches, err := parseBranches(p, parseFunBranch)
-		return Definition{name, branches}, err
+		return Definition{name, local, branches, token.Pos}, err
+	case lexer.Begin:
+		body, err := p.parseUntil(lexer.End)
+		if err != nil {
+			return nil, err
+		}
+		if len(body) == 0 {
+			return nil, Unexpected{p.previous()}
+		}
+		return Block{body, token.Pos}, nil
 	case lexer.If:
 		branches, err := parseBranches(p, parseIfBranch)
-		return If{branches}, err
+		return If{branches, token.Pos}, err
 	case lexer.Case:
-		return p.parseCase()
+		return p.parseCase(token.Pos)
 	case lexer.Receive:
-		return p.parseReceive()
+		return p.parseReceive(token.Pos)
 	case lexer.Try:
-		return p.parseTryRecover()
+		return p.parseTry(token.Pos)
+	case lexer.BinaryLeft:
+		return p.parseBitString(token.Pos)
+	case lexer.Hash:
+		return p.parseRecord(nil, token.Pos)
 	default:
 		return nil, Unexpected{token}
 	}
@@ -160,7 +222,7 @@ func isOperator(token lexer.Token) bool {
 func (p *Parser) expect(token lexer.TokenType) error {
 	next, ok := p.pop()
 	if !ok {
-		return Missing{token}
+		return Missing{token, p.position()}
 	}
 	switch next.Type {
 	case token:
@@ -171,15 +233,15 @@ func (p *Parser) expect(token lexer.TokenType) error {
 }
 
 // Initialize a `BinaryOperation` with correct operator precedence.
-func newBinaryOperation(op string, lhs, rhs Expr) BinaryOperation {
+func newBinaryOperation(op string, pos Pos, lhs, rhs Expr) BinaryOperation {
 	switch rhs := rhs.(type) {
 	case BinaryOperation:
 		if operatorPrecedence[op] <= operatorPrecedence[rhs.Op] {
 			// correct precedence: 2 * (3 + 5) -> (2 * 3) + 5
-			return BinaryOperation{rhs.Op, newBinaryOperation(op, lhs, rhs.Lhs), rhs.Rhs}
+			return BinaryOperation{rhs.Op, newBinaryOperation(op, pos, lhs, rhs.Lhs), rhs.Rhs, rhs.Pos}
 		}
 	}
-	return BinaryOperation{op, lhs, rhs}
+	return BinaryOperation{op, lhs, rhs, pos}
 }
 
 // Check if the `value` is one of the following values.
@@ -193,13 +255,55 @@ func isOneOf[T comparable](value T, set ...T) bool {
 }
 
 // Transform special atoms (booleans) to specific values.
-func fromAtom(token lexer.Token) Expr {
-	switch token.Value {
+func fromAtom(token lexer.Token) (Expr, error) {
+	name, err := atomName(token)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
 	case "true":
-		return Bool(true)
+		return Bool(true), nil
 	case "false":
-		return Bool(false)
+		return Bool(false), nil
 	default:
-		return Atom(token.Value)
+		if strings.HasPrefix(token.Value, "'") {
+			return QuotedAtom(name), nil
+		}
+		return Atom(name), nil
+	}
+}
+
+// Name of the atom, the quoted atoms are unquoted.
+func atomName(token lexer.Token) (string, error) {
+	name := token.Value
+	if !strings.HasPrefix(name, "'") {
+		return name, nil
+	}
+	if len(name) < 2 || !strings.HasSuffix(name, "'") {
+		return "", Unexpected{token}
+	}
+	var b strings.Builder
+	name = name[1 : len(name)-1]
+	for name != "" {
+		r, _, tail, err := strconv.UnquoteChar(name, '\'')
+		if err != nil {
+			return "", Unexpected{token}
+		}
+		b.WriteRune(r)
+		name = tail
+	}
+	return b.String(), nil
+}
+
+// Transform the `$c` character to its code point.
+func fromChar(token lexer.Token) (Expr, error) {
+	char := strings.TrimPrefix(token.Value, "$")
+	if r, size := utf8.DecodeRuneInString(char); r != '\\' && size == len(char) {
+		return Int(r), nil
+	}
+	r, _, tail, err := strconv.UnquoteChar(char, '\'')
+	if err != nil || tail != "" {
+		return nil, Unexpected{token}
 	}
+	return Int(r), nil
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/twolodzko/goer/parser/lexer"
 	. "github.com/twolodzko/goer/types"
 )
@@ -25,124 +26,249 @@ func TestParse(t *testing.T) {
 		{`"".`, []Expr{String("")}},
 		{`"Hello, World!".`, []Expr{String("Hello, World!")}},
 		{`"\"Hello,\nWorld!\"".`, []Expr{String("\"Hello,\nWorld!\"")}},
+		{"'EXIT'.", []Expr{QuotedAtom("EXIT")}},
+		{"'hello world'.", []Expr{QuotedAtom("hello world")}},
+		{`'it\'s'.`, []Expr{QuotedAtom("it's")}},
+		{"'foo'.", []Expr{QuotedAtom("foo")}},
+		{"'true'.", []Expr{Bool(true)}},
+		{"$a.", []Expr{Int('a')}},
+		{"$\\n.", []Expr{Int('\n')}},
+		{"$'.", []Expr{Int('\'')}},
+		{"$ł.", []Expr{Int('ł')}},
+		{`$\x41.`, []Expr{Int('A')}},
 		{"{}.", []Expr{Tuple{}}},
 		{"{1,2,3}.", []Expr{Tuple{[]Expr{Int(1), Int(2), Int(3)}}}},
 		{"[].", []Expr{List{}}},
 		{"[1,2,3].", []Expr{List{[]Expr{Int(1), Int(2), Int(3)}}}},
 
+		// records
+		{"-record(user, {name, age = 0}).", []Expr{
+			RecordDecl{"user", []RecordField{{"name", nil}, {"age", Int(0)}}, Pos{}},
+		}},
+		{"#user{}.", []Expr{Record{nil, "user", nil, Pos{}}}},
+		{"#user{name = N, age = 1 + 2}.", []Expr{
+			Record{nil, "user", []RecordField{
+				{"name", Variable("N")},
+				{"age", BinaryOperation{"+", Int(1), Int(2), Pos{}}},
+			}, Pos{}},
+		}},
+		{"U#user.name.", []Expr{RecordAccess{Variable("U"), "user", "name", Pos{}}}},
+		{"U#user{age = 3}#user.age + 1.", []Expr{
+			BinaryOperation{
+				"+",
+				RecordAccess{Record{Variable("U"), "user", []RecordField{{"age", Int(3)}}, Pos{}}, "user", "age", Pos{}},
+				Int(1), Pos{},
+			},
+		}},
+		{"-U#user.age.", []Expr{UnaryOperation{"-", RecordAccess{Variable("U"), "user", "age", Pos{}}, Pos{}}}},
+		{"- record.", []Expr{UnaryOperation{"-", Atom("record"), Pos{}}}},
+
 		// series of expressions
 		{"1,2,3.", []Expr{Int(1), Int(2), Int(3)}},
 
 		// unary operations
-		{"- 5 .", []Expr{UnaryOperation{"-", Int(5)}}},
-		{"+ Y .", []Expr{UnaryOperation{"+", Variable("Y")}}},
-		{"not X .", []Expr{UnaryOperation{"not", Variable("X")}}},
+		{"- 5 .", []Expr{UnaryOperation{"-", Int(5), Pos{}}}},
+		{"+ Y .", []Expr{UnaryOperation{"+", Variable("Y"), Pos{}}}},
+		{"not X .", []Expr{UnaryOperation{"not", Variable("X"), Pos{}}}},
 
 		// binary expressions
-		{"X = 42.", []Expr{BinaryOperation{"=", Variable("X"), Int(42)}}},
-		{"2 + 3.", []Expr{BinaryOperation{"+", Int(2), Int(3)}}},
+		{"X = 42.", []Expr{BinaryOperation{"=", Variable("X"), Int(42), Pos{}}}},
+		{"2 + 3.", []Expr{BinaryOperation{"+", Int(2), Int(3), Pos{}}}},
 		{"2 + 3 - 4 + 5.", []Expr{
 			BinaryOperation{"+",
 				BinaryOperation{"-",
-					BinaryOperation{"+", Int(2), Int(3)},
-					Int(4),
+					BinaryOperation{"+", Int(2), Int(3), Pos{}},
+					Int(4), Pos{},
 				},
-				Int(5),
+				Int(5), Pos{},
 			},
 		}},
 		{"6 + 7 * 8 / 9.", []Expr{
 			BinaryOperation{"+",
 				Int(6),
 				BinaryOperation{"/",
-					BinaryOperation{"*", Int(7), Int(8)},
-					Int(9),
-				},
+					BinaryOperation{"*", Int(7), Int(8), Pos{}},
+					Int(9), Pos{},
+				}, Pos{},
 			},
 		}},
-		{"(2 + 2).", []Expr{Bracket{BinaryOperation{"+", Int(2), Int(2)}}}},
+		{"(2 + 2).", []Expr{Bracket{BinaryOperation{"+", Int(2), Int(2), Pos{}}}}},
 		{"(2 + 4) / 3.", []Expr{
 			BinaryOperation{"/",
-				Bracket{BinaryOperation{"+", Int(2), Int(4)}},
-				Int(3),
+				Bracket{BinaryOperation{"+", Int(2), Int(4), Pos{}}},
+				Int(3), Pos{},
 			},
 		}},
 		{"(2 + 4) / (3 - 5).", []Expr{
 			BinaryOperation{"/",
-				Bracket{BinaryOperation{"+", Int(2), Int(4)}},
-				Bracket{BinaryOperation{"-", Int(3), Int(5)}},
+				Bracket{BinaryOperation{"+", Int(2), Int(4), Pos{}}},
+				Bracket{BinaryOperation{"-", Int(3), Int(5), Pos{}}}, Pos{},
 			},
 		}},
 		{"(1+2)/3.", []Expr{
 			BinaryOperation{"/",
-				Bracket{BinaryOperation{"+", Int(1), Int(2)}},
-				Int(3),
+				Bracket{BinaryOperation{"+", Int(1), Int(2), Pos{}}},
+				Int(3), Pos{},
 			},
 		}},
 		{"X = 1/2 + 3.", []Expr{
 			BinaryOperation{"=",
 				Variable("X"),
 				BinaryOperation{"+",
-					BinaryOperation{"/", Int(1), Int(2)},
-					Int(3),
+					BinaryOperation{"/", Int(1), Int(2), Pos{}},
+					Int(3), Pos{},
+				}, Pos{},
+			},
+		}},
+		{"1 + 2 band 3 bor 4.", []Expr{
+			BinaryOperation{"bor",
+				BinaryOperation{"+",
+					Int(1),
+					BinaryOperation{"band", Int(2), Int(3), Pos{}}, Pos{},
+				},
+				Int(4), Pos{},
+			},
+		}},
+		{"bnot X bsl 7 div 2.", []Expr{
+			BinaryOperation{"bsl",
+				UnaryOperation{"bnot", Variable("X"), Pos{}},
+				BinaryOperation{"div", Int(7), Int(2), Pos{}}, Pos{},
+			},
+		}},
+		{"X =< Y + 1 =:= Z.", []Expr{
+			BinaryOperation{"=:=",
+				BinaryOperation{"=<",
+					Variable("X"),
+					BinaryOperation{"+", Variable("Y"), Int(1), Pos{}}, Pos{},
+				},
+				Variable("Z"), Pos{},
+			},
+		}},
+		{"A orelse B andalso C.", []Expr{
+			BinaryOperation{"orelse",
+				Variable("A"),
+				BinaryOperation{"andalso", Variable("B"), Variable("C"), Pos{}}, Pos{},
+			},
+		}},
+		{"X > 0 andalso Y xor Z.", []Expr{
+			BinaryOperation{"xor",
+				BinaryOperation{"andalso",
+					BinaryOperation{">", Variable("X"), Int(0), Pos{}},
+					Variable("Y"), Pos{},
 				},
+				Variable("Z"), Pos{},
 			},
 		}},
 		{"_ = true.", []Expr{
 			BinaryOperation{"=",
 				Dummy{},
-				Bool(true),
+				Bool(true), Pos{},
 			},
 		}},
 
+		// binaries
+		{"<<>>.", []Expr{BitString{nil, Pos{}}}},
+		{`<<1, "abc", X:16, (Y + 1):8/little-signed, Rest/binary>>.`, []Expr{BitString{
+			[]Segment{
+				{Int(1), nil, nil},
+				{String("abc"), nil, nil},
+				{Variable("X"), Int(16), nil},
+				{Bracket{BinaryOperation{"+", Variable("Y"), Int(1), Pos{}}}, Int(8), []string{"little", "signed"}},
+				{Variable("Rest"), nil, []string{"binary"}},
+			},
+			Pos{},
+		}}},
+		{"<<Len:16, Data:Len/bytes>> = B.", []Expr{BinaryOperation{"=",
+			BitString{
+				[]Segment{
+					{Variable("Len"), Int(16), nil},
+					{Variable("Data"), Variable("Len"), []string{"bytes"}},
+				},
+				Pos{},
+			},
+			Variable("B"), Pos{},
+		}}},
+
 		// functions
-		{"foo().", []Expr{Call{Atom("foo"), nil}}},
-		{"Bar().", []Expr{Call{Variable("Bar"), nil}}},
-		{"identity(X).", []Expr{Call{Atom("identity"), []Expr{Variable("X")}}}},
-		{"Identity(X).", []Expr{Call{Variable("Identity"), []Expr{Variable("X")}}}},
+		{"foo().", []Expr{Call{Atom("foo"), nil, Pos{}}}},
+		{"Bar().", []Expr{Call{Variable("Bar"), nil, Pos{}}}},
+		{"'foo'().", []Expr{Call{Atom("foo"), nil, Pos{}}}},
+		{"identity(X).", []Expr{Call{Atom("identity"), []Expr{Variable("X")}, Pos{}}}},
+		{"Identity(X).", []Expr{Call{Variable("Identity"), []Expr{Variable("X")}, Pos{}}}},
+		{"assert_match({ok, _}, f()).", []Expr{
+			AssertMatch{Tuple{[]Expr{Atom("ok"), Dummy{}}}, Call{Atom("f"), nil, Pos{}}, Pos{}},
+		}},
+		{"assert_match(X).", []Expr{Call{Atom("assert_match"), []Expr{Variable("X")}, Pos{}}}},
 		{"fun(X) -> X end.", []Expr{
 			Definition{
 				"",
+				false,
 				[]FunBranch{
 					{
 						[]Expr{Variable("X")},
 						nil,
 						[]Expr{Variable("X")},
 					},
-				}}},
+				}, Pos{}}},
 		},
 		{"fun (0) -> true; (_) -> false end.", []Expr{
 			Definition{
 				"",
+				false,
 				[]FunBranch{
 					{[]Expr{Int(0)}, nil, []Expr{Bool(true)}},
 					{[]Expr{Dummy{}}, nil, []Expr{Bool(false)}},
-				}},
+				}, Pos{}},
 		}},
 		{"(fun(X) -> X end)(true).", []Expr{
 			Call{
 				Bracket{
 					Definition{
 						"",
+						false,
 						[]FunBranch{
 							{
 								[]Expr{Variable("X")},
 								nil,
 								[]Expr{Variable("X")},
 							},
-						}}},
-				[]Expr{Bool(true)},
+						}, Pos{}}},
+				[]Expr{Bool(true)}, Pos{},
+			},
+		}},
+		{"fun Loop(X) -> Loop(X) end.", []Expr{
+			Definition{
+				"Loop",
+				true,
+				[]FunBranch{
+					{
+						[]Expr{Variable("X")},
+						nil,
+						[]Expr{Call{Variable("Loop"), []Expr{Variable("X")}, Pos{}}},
+					},
+				}, Pos{}}},
+		},
+		{"begin X = 1, X + 1 end * 2.", []Expr{
+			BinaryOperation{
+				"*",
+				Block{[]Expr{
+					BinaryOperation{"=", Variable("X"), Int(1), Pos{}},
+					BinaryOperation{"+", Variable("X"), Int(1), Pos{}},
+				}, Pos{}},
+				Int(2), Pos{},
 			},
 		}},
 		{"fun identity(X) -> X end.", []Expr{
 			Definition{
 				"identity",
+				false,
 				[]FunBranch{
 					{
 						[]Expr{Variable("X")},
 						nil,
 						[]Expr{Variable("X")},
 					},
-				}}},
+				}, Pos{}}},
 		},
 
 		// control flow
@@ -152,11 +278,11 @@ func TestParse(t *testing.T) {
 					BinaryOperation{
 						"==",
 						Variable("X"),
-						Bool(true),
+						Bool(true), Pos{},
 					},
 					[]Expr{Bool(true)},
 				},
-			},
+			}, Pos{},
 		}}},
 		{"if X == 1 -> true; _ -> false end.", []Expr{
 			If{[]IfBranch{
@@ -164,7 +290,7 @@ func TestParse(t *testing.T) {
 					BinaryOperation{
 						"==",
 						Variable("X"),
-						Int(1),
+						Int(1), Pos{},
 					},
 					[]Expr{Bool(true)},
 				},
@@ -172,17 +298,17 @@ func TestParse(t *testing.T) {
 					Dummy{},
 					[]Expr{Bool(false)},
 				},
-			}},
+			}, Pos{}},
 		}},
 		{"case X of Y when Y == 1 -> Y+2 end.", []Expr{Case{
 			Variable("X"),
 			[]PatternBranch{
 				{
 					Variable("Y"),
-					[]Expr{BinaryOperation{"==", Variable("Y"), Int(1)}},
-					[]Expr{BinaryOperation{"+", Variable("Y"), Int(2)}},
+					[]Expr{BinaryOperation{"==", Variable("Y"), Int(1), Pos{}}},
+					[]Expr{BinaryOperation{"+", Variable("Y"), Int(2), Pos{}}},
 				},
-			},
+			}, Pos{},
 		}}},
 		{"case X of true -> 1; false -> 2 end.", []Expr{Case{
 			Variable("X"),
@@ -197,45 +323,76 @@ func TestParse(t *testing.T) {
 					nil,
 					[]Expr{Int(2)},
 				},
-			},
+			}, Pos{},
 		}}},
 		{"try 1/0 recover nan end.", []Expr{
 			TryRecover{
-				[]Expr{BinaryOperation{"/", Int(1), Int(0)}},
-				[]Expr{Atom("nan")},
+				[]Expr{BinaryOperation{"/", Int(1), Int(0), Pos{}}},
+				[]Expr{Atom("nan")}, Pos{},
 			},
 		}},
 
+		{"try 1/0 catch badarith -> nan end.", []Expr{
+			TryCatch{
+				[]Expr{BinaryOperation{"/", Int(1), Int(0), Pos{}}},
+				[]CatchBranch{
+					{Dummy{}, Atom("badarith"), nil, nil, []Expr{Atom("nan")}},
+				}, Pos{},
+			},
+		}},
+		{"try X, Y catch error:R when R == badarith -> nan; exit:_ -> exited end.", []Expr{
+			TryCatch{
+				[]Expr{Variable("X"), Variable("Y")},
+				[]CatchBranch{
+					{
+						Atom("error"),
+						Variable("R"),
+						nil,
+						[]Expr{BinaryOperation{"==", Variable("R"), Atom("badarith"), Pos{}}},
+						[]Expr{Atom("nan")},
+					},
+					{Atom("exit"), Dummy{}, nil, nil, []Expr{Atom("exited")}},
+				}, Pos{},
+			},
+		}},
+		{"try f() catch C:R:S -> S end.", []Expr{
+			TryCatch{
+				[]Expr{Call{Atom("f"), nil, Pos{}}},
+				[]CatchBranch{
+					{Variable("C"), Variable("R"), Variable("S"), nil, []Expr{Variable("S")}},
+				}, Pos{},
+			},
+		}},
 		// receive
 		{"receive true -> 1; false -> 2 end.", []Expr{Receive{
 			[]PatternBranch{
 				{Bool(true), nil, []Expr{Int(1)}},
 				{Bool(false), nil, []Expr{Int(2)}},
 			},
-			IfBranch{},
+			IfBranch{}, Pos{},
 		}}},
 		{"receive after 0 -> true end.", []Expr{Receive{
 			nil,
-			IfBranch{Int(0), []Expr{Bool(true)}},
+			IfBranch{Int(0), []Expr{Bool(true)}}, Pos{},
 		}}},
 		{"receive true -> 1 after 5 -> 2 end.", []Expr{Receive{
 			[]PatternBranch{
 				{Bool(true), nil, []Expr{Int(1)}},
 			},
-			IfBranch{Int(5), []Expr{Int(2)}},
+			IfBranch{Int(5), []Expr{Int(2)}}, Pos{},
 		}}},
 
 		// full expressions
 		{"- 2 + 1.", []Expr{
 			BinaryOperation{"+",
-				UnaryOperation{"-", Int(2)},
-				Int(1),
+				UnaryOperation{"-", Int(2), Pos{}},
+				Int(1), Pos{},
 			},
 		}},
 		{"2 + - 1.", []Expr{
 			BinaryOperation{"+",
 				Int(2),
-				UnaryOperation{"-", Int(1)},
+				UnaryOperation{"-", Int(1), Pos{}}, Pos{},
 			},
 		}},
 		{
@@ -246,13 +403,14 @@ func TestParse(t *testing.T) {
 					Variable("Identity"),
 					Definition{
 						"",
+						false,
 						[]FunBranch{
 							{
 								[]Expr{Variable("X")},
 								nil,
 								[]Expr{Variable("X")},
 							},
-						}},
+						}, Pos{}}, Pos{},
 				},
 				BinaryOperation{
 					"=",
@@ -261,14 +419,14 @@ func TestParse(t *testing.T) {
 						"+",
 						Call{
 							Variable("Identity"),
-							[]Expr{Int(1)},
+							[]Expr{Int(1)}, Pos{},
 						},
-						Int(2),
-					},
+						Int(2), Pos{},
+					}, Pos{},
 				},
 				Call{
 					Atom("print"),
-					[]Expr{Variable("X")},
+					[]Expr{Variable("X")}, Pos{},
 				},
 			},
 		},
@@ -279,12 +437,52 @@ func TestParse(t *testing.T) {
 			t.Errorf("parsing %q resulted in an unexpected error: %s", tt.input, err)
 		} else if reflect.TypeOf(result) != reflect.TypeOf(tt.expected) {
 			t.Errorf("for %q types mismatch: %T != %T", tt.input, tt.expected, result)
-		} else if !cmp.Equal(result, tt.expected) {
+		} else if !cmp.Equal(result, tt.expected, cmpopts.IgnoreTypes(Pos{})) {
 			t.Errorf("for %q expected %v, got: %v", tt.input, tt.expected, result)
 		}
 	}
 }
 
+func TestParsePositions(t *testing.T) {
+	t.Parallel()
+
+	input := `X = foo(1),
+	case X of
+		Y -> - Y + 2
+	end.`
+	expected := []Expr{
+		BinaryOperation{"=",
+			Variable("X"),
+			Call{Atom("foo"), []Expr{Int(1)}, Pos{"test.ge", 5, 5}},
+			Pos{"test.ge", 5, 3},
+		},
+		Case{
+			Variable("X"),
+			[]PatternBranch{
+				{
+					Variable("Y"),
+					nil,
+					[]Expr{
+						BinaryOperation{"+",
+							UnaryOperation{"-", Variable("Y"), Pos{"test.ge", 7, 8}},
+							Int(2),
+							Pos{"test.ge", 7, 12},
+						},
+					},
+				},
+			},
+			Pos{"test.ge", 6, 2},
+		},
+	}
+
+	result, err := ParseAt(input, Pos{"test.ge", 5, 1})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if !cmp.Equal(result, expected) {
+		t.Errorf("expected %v, got: %v", expected, result)
+	}
+}
+
 func TestParseExceptions(t *testing.T) {
 	t.Parallel()
 
@@ -292,29 +490,40 @@ func TestParseExceptions(t *testing.T) {
 		input    string
 		expected error
 	}{
-		{"35", Missing{lexer.Dot}},
-		{"(X.", Unexpected{lexer.Token{lexer.Dot, "."}}},
-		{"+.", Unexpected{lexer.Token{lexer.Dot, "."}}},
-		{"* 5.", Unexpected{lexer.Token{lexer.Operator, "*"}}},
-		{"{1,2,3.", Unexpected{lexer.Token{lexer.Dot, "."}}},
-		{"5 >+< 7 .", Unexpected{lexer.Token{lexer.Operator, ">+<"}}},
-		{"1,2} .", Unexpected{lexer.Token{lexer.BraceRight, "}"}}},
-		{"2 + 2) .", Unexpected{lexer.Token{lexer.BracketRight, ")"}}},
-		{"1(2) .", Unexpected{lexer.Token{lexer.BracketLeft, "("}}},
-		{"{1,2,3} (4,5) .", Unexpected{lexer.Token{lexer.BracketLeft, "("}}},
-		{"if 1 -> 1 after 2 -> 2 end.", Unexpected{lexer.Token{lexer.After, "after"}}},
-		{"case 1 of 1 -> 1 after 2 -> 2 end.", Unexpected{lexer.Token{lexer.After, "after"}}},
-		{"if true -> end.", Unexpected{lexer.Token{lexer.End, "end"}}},
-		{"if false -> ; true -> end.", Unexpected{lexer.Token{lexer.Semicolon, ";"}}},
-		{"case X of 1 -> end.", Unexpected{lexer.Token{lexer.End, "end"}}},
-		{"case X of 1 -> ; 2 -> 2 end.", Unexpected{lexer.Token{lexer.Semicolon, ";"}}},
-		{"case X of 1 -> 1; 2 -> end.", Unexpected{lexer.Token{lexer.End, "end"}}},
-		{"receive X -> end.", Unexpected{lexer.Token{lexer.End, "end"}}},
-		{"receive X -> X; _ -> end.", Unexpected{lexer.Token{lexer.End, "end"}}},
-		{"fun() -> end.", Unexpected{lexer.Token{lexer.End, "end"}}},
-		{"fun (1) -> 1; (X) -> end.", Unexpected{lexer.Token{lexer.End, "end"}}},
-		{"try 1/0 recover end.", Unexpected{lexer.Token{lexer.End, "end"}}},
-		{"try recover ok end.", Unexpected{lexer.Token{lexer.Recover, "recover"}}},
+		{"35", Missing{lexer.Dot, Pos{Line: 1, Col: 3}}},
+		{"(X.", Unexpected{lexer.Token{lexer.Dot, ".", Pos{Line: 1, Col: 3}}}},
+		{"+.", Unexpected{lexer.Token{lexer.Dot, ".", Pos{Line: 1, Col: 2}}}},
+		{"* 5.", Unexpected{lexer.Token{lexer.Operator, "*", Pos{Line: 1, Col: 1}}}},
+		{"{1,2,3.", Unexpected{lexer.Token{lexer.Dot, ".", Pos{Line: 1, Col: 7}}}},
+		{"5 >+< 7 .", Unexpected{lexer.Token{lexer.Operator, ">+<", Pos{Line: 1, Col: 3}}}},
+		{"1,2} .", Unexpected{lexer.Token{lexer.BraceRight, "}", Pos{Line: 1, Col: 4}}}},
+		{"2 + 2) .", Unexpected{lexer.Token{lexer.BracketRight, ")", Pos{Line: 1, Col: 6}}}},
+		{"1(2) .", Unexpected{lexer.Token{lexer.BracketLeft, "(", Pos{Line: 1, Col: 2}}}},
+		{"{1,2,3} (4,5) .", Unexpected{lexer.Token{lexer.BracketLeft, "(", Pos{Line: 1, Col: 9}}}},
+		{"if 1 -> 1 after 2 -> 2 end.", Unexpected{lexer.Token{lexer.After, "after", Pos{Line: 1, Col: 11}}}},
+		{"case 1 of 1 -> 1 after 2 -> 2 end.", Unexpected{lexer.Token{lexer.After, "after", Pos{Line: 1, Col: 18}}}},
+		{"if true -> end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 12}}}},
+		{"if false -> ; true -> end.", Unexpected{lexer.Token{lexer.Semicolon, ";", Pos{Line: 1, Col: 13}}}},
+		{"case X of 1 -> end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 16}}}},
+		{"case X of 1 -> ; 2 -> 2 end.", Unexpected{lexer.Token{lexer.Semicolon, ";", Pos{Line: 1, Col: 16}}}},
+		{"case X of 1 -> 1; 2 -> end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 24}}}},
+		{"receive X -> end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 14}}}},
+		{"receive X -> X; _ -> end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 22}}}},
+		{"fun() -> end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 10}}}},
+		{"fun (1) -> 1; (X) -> end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 22}}}},
+		{"try 1/0 recover end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 17}}}},
+		{"try recover ok end.", Unexpected{lexer.Token{lexer.Recover, "recover", Pos{Line: 1, Col: 5}}}},
+		{"try catch _ -> ok end.", Unexpected{lexer.Token{lexer.Catch, "catch", Pos{Line: 1, Col: 5}}}},
+		{"try 1/0 catch end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 15}}}},
+		{"try 1/0 catch _ -> end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 20}}}},
+		{"begin end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 7}}}},
+		{"begin 1 .", Unexpected{lexer.Token{lexer.Dot, ".", Pos{Line: 1, Col: 9}}}},
+		{"try 1/0 end.", Unexpected{lexer.Token{lexer.End, "end", Pos{Line: 1, Col: 9}}}},
+		{"#user{name}.", Unexpected{lexer.Token{lexer.BraceRight, "}", Pos{Line: 1, Col: 11}}}},
+		{"#user.name.", Unexpected{lexer.Token{lexer.Dot, ".", Pos{Line: 1, Col: 6}}}},
+		{"U#User{}.", Unexpected{lexer.Token{lexer.Variable, "User", Pos{Line: 1, Col: 3}}}},
+		{`'a\q'.`, Unexpected{lexer.Token{lexer.Atom, `'a\q'`, Pos{Line: 1, Col: 1}}}},
+		{`$\q.`, Unexpected{lexer.Token{lexer.Char, `$\q`, Pos{Line: 1, Col: 1}}}},
 	}
 	for _, tt := range testCases {
 		_, err := Parse(tt.input)
@@ -4,50 +4,86 @@ import (
 	"bufio"
 	"io"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/twolodzko/goer/types"
 )
 
 type Reader struct {
 	*bufio.Reader
 	cache string
+	at    types.Pos // position of the next line, or the cache
+	start types.Pos // position where the last code block started
 }
 
 func NewReader(in io.Reader) *Reader {
-	return &Reader{bufio.NewReader(in), ""}
+	return &Reader{bufio.NewReader(in), "", types.Pos{Line: 1, Col: 1}, types.Pos{}}
 }
 
 // Return next chunk of text (code block) to be parsed.
 func (reader *Reader) Next() (string, error) {
 	var out string
 	isString := false
+	isAtom := false
+	isStarted := false
 	for {
-		line, err := reader.readLine()
+		line, pos, err := reader.readLine()
 		if err != nil && err != io.EOF {
 			return out, err
 		}
 
 		isComment := false
 		isEscaped := false
+		isChar := false
 		for i, r := range line {
+			if !isStarted && !unicode.IsSpace(r) {
+				isStarted = true
+				reader.start = pos
+				reader.start.Col += utf8.RuneCountInString(line[:i])
+			}
+
+			if isChar && r != '\n' {
+				// the character of the `$c` literal is taken as-is
+				isChar = false
+				if r == '\\' {
+					isEscaped = true
+				}
+				continue
+			}
+
 			switch r {
 			case '"':
-				if !isComment && !isEscaped {
+				if !isComment && !isEscaped && !isAtom {
 					isString = !isString
 				}
+			case '\'':
+				if !isComment && !isEscaped && !isString {
+					isAtom = !isAtom
+				}
+			case '$':
+				if !isComment && !isEscaped && !isString && !isAtom {
+					isChar = true
+				}
 			case '%':
-				isComment = true
+				if !isString && !isAtom {
+					isComment = true
+				}
 			case '\\':
 				isEscaped = true
 				continue
 			case '.':
-				if !isComment && !isEscaped && !isString {
+				if !isComment && !isEscaped && !isString && !isAtom && isTerminator(line[i+1:]) {
 					if len(line) > i+1 {
 						reader.cache = line[i+1:]
+						reader.at = pos
+						reader.at.Col += utf8.RuneCountInString(line[:i+1])
 					}
-					out += " " + line[:i+1]
+					out += line[:i+1]
 					return strings.TrimSpace(out), nil
 				}
 			case '\n':
-				out += "\n" + line
+				out += line
 			}
 			isEscaped = false
 		}
@@ -58,12 +94,36 @@ func (reader *Reader) Next() (string, error) {
 	}
 }
 
-// Read line from cache or input.
-func (reader *Reader) readLine() (string, error) {
+// The dot ends the code block when it is followed by a whitespace, comment, or the end
+// of the input, otherwise it is a part of the expression, e.g. the `Rec#name.field` access.
+func isTerminator(rest string) bool {
+	r, size := utf8.DecodeRuneInString(rest)
+	return size == 0 || unicode.IsSpace(r) || r == '%'
+}
+
+// Position in the input where the code block returned by the last call of `Next` starts.
+func (reader *Reader) Pos() types.Pos {
+	return reader.start
+}
+
+// Read line from cache or input, return it with the position where it starts.
+func (reader *Reader) readLine() (string, types.Pos, error) {
+	pos := reader.at
 	if reader.cache != "" {
 		cache := reader.cache
 		reader.cache = ""
-		return cache, nil
+		if strings.HasSuffix(cache, "\n") {
+			reader.at = types.Pos{Line: pos.Line + 1, Col: 1}
+		}
+		return cache, pos, nil
+	}
+	line, err := reader.ReadString('\n')
+	if pos.Line == 1 && strings.HasPrefix(line, "#!") {
+		// skip the `#!/usr/bin/env goer` line of the script, but not its end
+		line = line[len(strings.TrimRight(line, "\r\n")):]
+	}
+	if strings.HasSuffix(line, "\n") {
+		reader.at = types.Pos{Line: pos.Line + 1, Col: 1}
 	}
-	return reader.ReadString('\n')
+	return line, pos, err
 }
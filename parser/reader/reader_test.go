@@ -3,6 +3,8 @@ package reader
 import (
 	"strings"
 	"testing"
+
+	"github.com/twolodzko/goer/types"
 )
 
 func TestReader(t *testing.T) {
@@ -14,6 +16,9 @@ func TestReader(t *testing.T) {
 		{" 2 + 2 / 4 .", []string{"2 + 2 / 4 ."}},
 		{"1. 2+2. 3+3+3.", []string{"1.", "2+2.", "3+3+3."}},
 		{"fun foo(X) -> X end. foo(X).", []string{"fun foo(X) -> X end.", "foo(X)."}},
+		{"U#user.name.\nU#user.age.", []string{"U#user.name.", "U#user.age."}},
+		{"'a. b'. \"c. d\". $. . 'it\\'s.'.", []string{"'a. b'.", "\"c. d\".", "$. .", "'it\\'s.'."}},
+		{"\"50%\". ok.", []string{"\"50%\".", "ok."}},
 	}
 
 	for _, tt := range testCases {
@@ -28,3 +33,52 @@ func TestReader(t *testing.T) {
 		}
 	}
 }
+
+func TestReaderPos(t *testing.T) {
+	input := "1.  2+2.\n\n% comment\n  foo(\n  X). bar.\n"
+	testCases := []struct {
+		expected string
+		pos      types.Pos
+	}{
+		{"1.", types.Pos{Line: 1, Col: 1}},
+		{"2+2.", types.Pos{Line: 1, Col: 5}},
+		{"% comment\n  foo(\n  X).", types.Pos{Line: 3, Col: 1}},
+		{"bar.", types.Pos{Line: 5, Col: 7}},
+	}
+
+	r := NewReader(strings.NewReader(input))
+	for _, tt := range testCases {
+		result, err := r.Next()
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		} else if result != tt.expected {
+			t.Errorf("expected '%s', got '%s'", tt.expected, result)
+		} else if r.Pos() != tt.pos {
+			t.Errorf("for '%s' expected position %v, got %v", tt.expected, tt.pos, r.Pos())
+		}
+	}
+}
+
+func TestReaderShebang(t *testing.T) {
+	input := "#!/usr/bin/env goer\nfoo(\n  X).\n#!not. bar.\n"
+	testCases := []struct {
+		expected string
+		pos      types.Pos
+	}{
+		{"foo(\n  X).", types.Pos{Line: 2, Col: 1}},
+		{"#!not.", types.Pos{Line: 4, Col: 1}},
+		{"bar.", types.Pos{Line: 4, Col: 8}},
+	}
+
+	r := NewReader(strings.NewReader(input))
+	for _, tt := range testCases {
+		result, err := r.Next()
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		} else if result != tt.expected {
+			t.Errorf("expected '%s', got '%s'", tt.expected, result)
+		} else if r.Pos() != tt.pos {
+			t.Errorf("for '%s' expected position %v, got %v", tt.expected, tt.pos, r.Pos())
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 )
 
 // Parse the "case" statement.
-func (p *Parser) parseCase() (Expr, error) {
+func (p *Parser) parseCase(pos Pos) (Expr, error) {
 	arg, err := p.parseExpr()
 	if err != nil {
 		return nil, err
@@ -16,47 +16,57 @@ func (p *Parser) parseCase() (Expr, error) {
 		return nil, err
 	}
 	branches, err := parseBranches(p, parsePatternBranch)
-	return Case{arg, branches}, err
+	return Case{arg, branches, pos}, err
 }
 
-// Parse the "try ... recover ... end" block.
-func (p *Parser) parseTryRecover() (Expr, error) {
-	var (
-		try TryRecover
-		err error
-	)
-
-	try.Body, err = p.parseUntil(lexer.Recover)
-	if err != nil {
-		return nil, err
-	}
-	if len(try.Body) == 0 {
-		return nil, Unexpected{lexer.Token{lexer.Recover, "recover"}}
-	}
+// Parse the "try ... recover ... end" or "try ... catch ... end" block.
+func (p *Parser) parseTry(pos Pos) (Expr, error) {
+	var body []Expr
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, expr)
 
-	try.Recover, err = p.parseUntil(lexer.End)
-	if err != nil {
-		return nil, err
-	}
-	if len(try.Recover) == 0 {
-		return nil, Unexpected{lexer.Token{lexer.End, "end"}}
+		token, ok := p.pop()
+		if !ok {
+			return nil, Missing{lexer.Catch, p.position()}
+		}
+		switch token.Type {
+		case lexer.Comma:
+			// skip
+		case lexer.Recover:
+			fallback, err := p.parseUntil(lexer.End)
+			if err != nil {
+				return nil, err
+			}
+			if len(fallback) == 0 {
+				return nil, Unexpected{p.previous()}
+			}
+			return TryRecover{body, fallback, pos}, nil
+		case lexer.Catch:
+			branches, err := parseBranches(p, parseCatchBranch)
+			return TryCatch{body, branches, pos}, err
+		default:
+			return nil, Unexpected{token}
+		}
 	}
-
-	return try, nil
 }
 
 // Parse the "receive" statement.
-func (p *Parser) parseReceive() (Expr, error) {
+func (p *Parser) parseReceive(pos Pos) (Expr, error) {
 	var (
 		receive Receive
 		err     error
 	)
+	receive.Pos = pos
 
 	// after branch without any receive branches
 	// see: https://www.erlang.org/doc/reference_manual/expressions#receive
 	token, ok := p.peek()
 	if !ok {
-		return nil, EoF{}
+		return nil, EoF{p.position()}
 	}
 	if token.Type == lexer.After {
 		p.skip()
@@ -76,7 +86,7 @@ func (p *Parser) parseReceive() (Expr, error) {
 
 		token, ok := p.pop()
 		if !ok {
-			return nil, EoF{}
+			return nil, EoF{p.position()}
 		}
 		switch token.Type {
 		case lexer.Semicolon:
@@ -165,7 +175,45 @@ func parsePatternBranch(p *Parser) (PatternBranch, error) {
 	return branch, err
 }
 
-// Parse individual branches (in "if", "case", or "fun" blocks) until the "end" token.
+// Parse the branch of the `[ class ":" ] pattern [ ":" stack ] [ "when" guards ] "->" body` form.
+// When the class is not given, the branch matches errors of any class. The stack
+// can be given only together with the class.
+func parseCatchBranch(p *Parser) (CatchBranch, error) {
+	var (
+		branch CatchBranch
+		err    error
+	)
+	branch.Class = Dummy{}
+	branch.Pattern, err = p.parseExpr()
+	if err != nil {
+		return branch, err
+	}
+	token, ok := p.peek()
+	if ok && token.Type == lexer.Operator && token.Value == ":" {
+		p.skip()
+		branch.Class = branch.Pattern
+		branch.Pattern, err = p.parseExpr()
+		if err != nil {
+			return branch, err
+		}
+		token, ok = p.peek()
+		if ok && token.Type == lexer.Operator && token.Value == ":" {
+			p.skip()
+			branch.Stack, err = p.parseExpr()
+			if err != nil {
+				return branch, err
+			}
+		}
+	}
+	branch.Guards, err = p.maybeGuards()
+	if err != nil {
+		return branch, err
+	}
+	branch.Body, err = p.parseBranchBody()
+	return branch, err
+}
+
+// Parse individual branches (in "if", "case", "catch", or "fun" blocks) until the "end" token.
 func parseBranches[T any](p *Parser, parse func(*Parser) (T, error)) ([]T, error) {
 	var branches []T
 	for {
@@ -178,7 +226,7 @@ func parseBranches[T any](p *Parser, parse func(*Parser) (T, error)) ([]T, error
 		// check if this is the final branch
 		token, ok := p.pop()
 		if !ok {
-			return nil, Missing{lexer.End}
+			return nil, Missing{lexer.End, p.position()}
 		}
 		switch token.Type {
 		case lexer.Semicolon:
@@ -204,7 +252,7 @@ func (p *Parser) parseBranchBody() ([]Expr, error) {
 		// punctuation: "," to split and "end" or ";" ends
 		token, ok := p.peek()
 		if !ok {
-			return nil, Missing{lexer.End}
+			return nil, Missing{lexer.End, p.position()}
 		}
 		switch token.Type {
 		case lexer.Comma:
@@ -221,7 +269,7 @@ func (p *Parser) parseBranchBody() ([]Expr, error) {
 func (p *Parser) maybeGuards() ([]Expr, error) {
 	token, ok := p.pop()
 	if !ok {
-		return nil, Missing{lexer.Arrow}
+		return nil, Missing{lexer.Arrow, p.position()}
 	}
 	switch token.Type {
 	case lexer.Arrow:
@@ -232,3 +280,205 @@ func (p *Parser) maybeGuards() ([]Expr, error) {
 		return nil, Unexpected{token}
 	}
 }
+
+// Parse the `<<Segment, ...>>` bit string, the opening "<<" is already consumed.
+func (p *Parser) parseBitString(pos Pos) (BitString, error) {
+	var segments []Segment
+
+	// handle empty case
+	token, ok := p.peek()
+	if ok && token.Type == lexer.BinaryRight {
+		p.skip()
+		return BitString{segments, pos}, nil
+	}
+
+	for {
+		segment, err := p.parseSegment()
+		if err != nil {
+			return BitString{}, err
+		}
+		segments = append(segments, segment)
+
+		token, ok := p.pop()
+		if !ok {
+			return BitString{}, Missing{lexer.BinaryRight, p.position()}
+		}
+		switch token.Type {
+		case lexer.Comma:
+			// skip
+		case lexer.BinaryRight:
+			return BitString{segments, pos}, nil
+		default:
+			return BitString{}, Unexpected{token}
+		}
+	}
+}
+
+// Parse the `Value [ ":" Size ] [ "/" Type [ "-" Type ]* ]` segment of the bit string.
+// Value and size are terms, so the expressions need to be enclosed in brackets.
+func (p *Parser) parseSegment() (Segment, error) {
+	var (
+		segment Segment
+		err     error
+	)
+	segment.Value, err = p.parseTerm()
+	if err != nil {
+		return segment, err
+	}
+	if p.maybeOperator(":") {
+		segment.Size, err = p.parseTerm()
+		if err != nil {
+			return segment, err
+		}
+	}
+	if p.maybeOperator("/") {
+		for {
+			token, ok := p.pop()
+			if !ok {
+				return segment, EoF{p.position()}
+			}
+			if token.Type != lexer.Atom || !isOneOf(token.Value, segmentTypes...) {
+				return segment, Unexpected{token}
+			}
+			segment.Types = append(segment.Types, token.Value)
+			if !p.maybeOperator("-") {
+				break
+			}
+		}
+	}
+	return segment, nil
+}
+
+// Type specifiers allowed in the segments of bit strings.
+var segmentTypes = []string{"integer", "binary", "bytes", "signed", "unsigned", "big", "little"}
+
+// If the next token is the operator, consume it.
+func (p *Parser) maybeOperator(op string) bool {
+	token, ok := p.peek()
+	if ok && token.Type == lexer.Operator && token.Value == op {
+		p.skip()
+		return true
+	}
+	return false
+}
+
+// The next tokens are `record(`, so the preceding "-" starts the record declaration.
+func (p *Parser) isRecordDecl() bool {
+	if p.pos+1 >= len(p.tokens) {
+		return false
+	}
+	name, next := p.tokens[p.pos], p.tokens[p.pos+1]
+	return name.Type == lexer.Atom && name.Value == "record" && next.Type == lexer.BracketLeft
+}
+
+// Parse the `record(Name, {Field [= Default], ...})` declaration, the "-" is already consumed.
+func (p *Parser) parseRecordDecl(pos Pos) (RecordDecl, error) {
+	var (
+		decl RecordDecl
+		err  error
+	)
+	decl.Pos = pos
+	p.skip() // record
+	err = p.expect(lexer.BracketLeft)
+	if err != nil {
+		return decl, err
+	}
+	decl.Name, err = p.parseRecordName()
+	if err != nil {
+		return decl, err
+	}
+	err = p.expect(lexer.Comma)
+	if err != nil {
+		return decl, err
+	}
+	err = p.expect(lexer.BraceLeft)
+	if err != nil {
+		return decl, err
+	}
+	decl.Fields, err = p.parseRecordFields(true)
+	if err != nil {
+		return decl, err
+	}
+	return decl, p.expect(lexer.BracketRight)
+}
+
+// Parse the `Name{Field = Value, ...}` record expression or the `Name.Field` access,
+// the "#" is already consumed. The `expr` is the accessed or updated record, or `nil`.
+func (p *Parser) parseRecord(expr Expr, pos Pos) (Expr, error) {
+	name, err := p.parseRecordName()
+	if err != nil {
+		return nil, err
+	}
+	token, ok := p.pop()
+	if !ok {
+		return nil, EoF{p.position()}
+	}
+	switch {
+	case token.Type == lexer.BraceLeft:
+		fields, err := p.parseRecordFields(false)
+		return Record{expr, name, fields, pos}, err
+	case token.Type == lexer.Dot && expr != nil:
+		field, err := p.parseRecordName()
+		return RecordAccess{expr, name, field, pos}, err
+	default:
+		return nil, Unexpected{token}
+	}
+}
+
+// Parse the atom naming the record or its field.
+func (p *Parser) parseRecordName() (string, error) {
+	token, ok := p.pop()
+	if !ok {
+		return "", EoF{p.position()}
+	}
+	if token.Type != lexer.Atom {
+		return "", Unexpected{token}
+	}
+	return atomName(token)
+}
+
+// Parse the `Field = Value, ...}` fields of the record, the opening "{" is already
+// consumed. In the declarations, the values (defaults) are optional.
+func (p *Parser) parseRecordFields(optional bool) ([]RecordField, error) {
+	var fields []RecordField
+
+	token, ok := p.peek()
+	if ok && token.Type == lexer.BraceRight {
+		p.skip()
+		return fields, nil
+	}
+
+	for {
+		var (
+			field RecordField
+			err   error
+		)
+		field.Name, err = p.parseRecordName()
+		if err != nil {
+			return nil, err
+		}
+		if p.maybeOperator("=") {
+			field.Value, err = p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, field)
+
+		token, ok := p.pop()
+		if !ok {
+			return nil, Missing{lexer.BraceRight, p.position()}
+		}
+		if field.Value == nil && !optional {
+			return nil, Unexpected{token}
+		}
+		switch token.Type {
+		case lexer.Comma:
+			// skip
+		case lexer.BraceRight:
+			return fields, nil
+		default:
+			return nil, Unexpected{token}
+		}
+	}
+}
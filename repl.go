@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/twolodzko/goer/core"
+	"github.com/twolodzko/goer/core/envir"
+	goerrors "github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	"github.com/twolodzko/goer/lineedit"
+	"github.com/twolodzko/goer/parser"
+	"github.com/twolodzko/goer/parser/lexer"
+	"github.com/twolodzko/goer/parser/reader"
+	"github.com/twolodzko/goer/types"
+)
+
+const (
+	// the number of lines kept in the history file
+	historySize = 1000
+	// how long to wait for the messages in the shell's mailbox
+	flushTimeout = 10 * time.Millisecond
+)
+
+type evaluator = func(string, *envir.Env, pids.Pid) (types.Expr, error)
+
+// The interactive shell, it evaluates the expressions as they are entered,
+// and runs the `:command`s. The names bound in the shell are kept in the branch
+// of the environment holding the build-in functions. The results of the
+// expressions are numbered, and can be recalled with `v(N)`.
+type shell struct {
+	env       *envir.Env
+	builtins  *envir.Env
+	results   map[int]types.Expr
+	count     int
+	pid       pids.Pid
+	parseEval evaluator
+	evalFile  evaluator
+	editor    *lineedit.Editor
+	out       io.Writer
+	// the file where the history is kept, it is not saved when empty
+	history string
+	// the exit status given to `halt`
+	status int
+}
+
+var commands = []struct {
+	name, args, help string
+}{
+	{":help", "", "show this help"},
+	{":env", "", "list the bound variables and the defined functions"},
+	{":load", " file", "evaluate the file"},
+	{":reset", "", "forget all the variables, the functions, and the results"},
+	{":flush", "", "show and remove the messages from the shell's mailbox"},
+	{":quit", "", "exit the shell, ^D exits as well"},
+}
+
+func repl(parseEval, evalFile evaluator) {
+	pid := pids.NewPid()
+	defer pid.Close()
+
+	s := newShell(os.Stdin, os.Stdout, parseEval, evalFile, pid)
+	// the piped input is not kept in the history
+	if home, err := os.UserHomeDir(); err == nil && s.editor.Interactive() {
+		s.history = filepath.Join(home, ".goer_history")
+		s.loadHistory()
+	}
+
+	fmt.Println("Type :help for the list of the shell commands, press ^D to exit.")
+	fmt.Println()
+	if status := s.run(); status != 0 {
+		pid.Close()
+		os.Exit(status)
+	}
+}
+
+func newShell(in io.Reader, out io.Writer, parseEval, evalFile evaluator, pid pids.Pid) *shell {
+	builtins := core.NewEnv()
+	s := &shell{builtins.Branch(), builtins, make(map[int]types.Expr), 0, pid, parseEval, evalFile, lineedit.NewEditor(in, out), out, "", 0}
+	s.editor.Complete = s.names
+	builtins.Set("v", s.v)
+	return s
+}
+
+// Read the lines until the shell is quit, or the input ends. The lines are collected until
+// they form the complete expressions terminated by the dots, which are then evaluated.
+// Return the exit status given to `halt`, or 0.
+func (s *shell) run() int {
+	var pending string
+	for {
+		prompt := fmt.Sprintf("%d> ", s.count+1)
+		if pending != "" {
+			prompt = ".. "
+		}
+		line, err := s.editor.ReadLine(prompt)
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			pending = ""
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				s.printError(err, "")
+			}
+			return s.status
+		}
+
+		if pending == "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			s.remember(line)
+			if !s.command(strings.Fields(line)) {
+				return s.status
+			}
+			continue
+		}
+		s.remember(line)
+
+		var chunks []string
+		chunks, pending = split(pending + line + "\n")
+		for _, code := range chunks {
+			if !s.eval(code) {
+				return s.status
+			}
+		}
+	}
+}
+
+// Split the code into the complete expressions, and the rest that is not finished yet.
+func split(code string) ([]string, string) {
+	var chunks []string
+	r := reader.NewReader(strings.NewReader(code))
+	for {
+		chunk, err := r.Next()
+		if err == io.EOF {
+			// the rest may be only the comments
+			if tokens, err := lexer.Tokenize(chunk); err == nil && len(tokens) == 0 {
+				return chunks, ""
+			}
+			return chunks, chunk
+		}
+		if err != nil {
+			return append(chunks, chunk), ""
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+// Evaluate the expression, and print its result. When it fails, the variables it
+// bound are forgotten, but the ones bound before are kept, even if it crashed the shell.
+// Return false when the expression called `halt`, so the shell should quit.
+func (s *shell) eval(code string) bool {
+	s.count++
+	if expr, ok := s.shellCall(code); ok {
+		s.results[s.count] = expr
+		fmt.Fprintln(s.out, expr)
+		return true
+	}
+
+	bound := maps.Clone(s.env.Elems)
+	expr, err := s.protected(code)
+	if s.halted(err) {
+		return false
+	}
+	if err != nil {
+		s.env.Elems = bound
+		s.printError(err, code)
+		return true
+	}
+	s.results[s.count] = expr
+	fmt.Fprintln(s.out, expr)
+	return true
+}
+
+// The error is the call of `halt`, its status becomes the exit status of the shell.
+func (s *shell) halted(err error) bool {
+	halt, ok := goerrors.Cause(err).(goerrors.Halt)
+	if ok {
+		s.status = halt.Status
+	}
+	return ok
+}
+
+func (s *shell) protected(code string) (expr types.Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = goerrors.New("the shell crashed: %v", r)
+		}
+	}()
+	return s.parseEval(code, s.env, s.pid)
+}
+
+// Run the shell functions `f()`, `f(X)`, and `b()`, called as the whole expression.
+// They are not run when the user defined the functions with the same names.
+func (s *shell) shellCall(code string) (types.Expr, bool) {
+	exprs, err := parser.Parse(code)
+	if err != nil || len(exprs) != 1 {
+		return nil, false
+	}
+	call, ok := exprs[0].(types.Call)
+	if !ok {
+		return nil, false
+	}
+	name, ok := call.Callable.(types.Atom)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := s.env.Elems[string(name)]; ok {
+		return nil, false
+	}
+
+	switch {
+	case name == "f" && len(call.Args) == 0:
+		// the functions and the records are kept
+		for name := range s.env.Elems {
+			if isVariable(name) {
+				s.env.Unset(types.Variable(name))
+			}
+		}
+	case name == "f" && len(call.Args) == 1:
+		variable, ok := call.Args[0].(types.Variable)
+		if !ok {
+			return nil, false
+		}
+		// forgetting the unbound variable is fine
+		s.env.Unset(variable)
+	case name == "b" && len(call.Args) == 0:
+		s.printBindings(isVariable)
+	default:
+		return nil, false
+	}
+	return types.Atom("ok"), true
+}
+
+// The `v(N)` function returning the result of the N-th expression,
+// or for the negative N, the result of the N-th previous expression.
+func (s *shell) v(args []types.Expr, _ *envir.Env, _ pids.Pid) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, goerrors.WrongNumberArgs{}
+	}
+	n, ok := args[0].(types.Int)
+	if !ok {
+		return nil, goerrors.NotNumber{args[0]}
+	}
+	if n < 0 {
+		// the current expression is not counted
+		n += types.Int(s.count)
+	}
+	result, ok := s.results[int(n)]
+	if !ok {
+		return nil, goerrors.New("there is no result of the expression %d", n)
+	}
+	return result, nil
+}
+
+// Run the shell command, return false when the shell should quit.
+func (s *shell) command(args []string) bool {
+	switch args[0] {
+	case ":help", ":h":
+		for _, cmd := range commands {
+			fmt.Fprintf(s.out, "%-12s %s\n", cmd.name+cmd.args, cmd.help)
+		}
+	case ":env":
+		s.printBindings(func(name string) bool { return !isRecord(name) })
+	case ":load", ":l":
+		if len(args) != 2 {
+			fmt.Fprintln(s.out, "usage: :load file")
+			break
+		}
+		expr, err := s.evalFile(args[1], s.env, s.pid)
+		if s.halted(err) {
+			return false
+		}
+		if err != nil {
+			s.printError(err, "")
+			break
+		}
+		fmt.Fprintln(s.out, expr)
+	case ":reset":
+		s.env = s.builtins.Branch()
+		s.results = make(map[int]types.Expr)
+		s.count = 0
+		fmt.Fprintln(s.out, "ok")
+	case ":flush":
+		s.flush()
+	case ":quit", ":q":
+		return false
+	default:
+		fmt.Fprintf(s.out, "unknown command '%s', type :help for the list of the commands\n", args[0])
+	}
+	return true
+}
+
+// Print the names bound in the shell that are kept by the filter, and their values.
+func (s *shell) printBindings(keep func(string) bool) {
+	var names []string
+	for name := range s.env.Elems {
+		if keep(name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(s.out, "%s = %v\n", name, s.env.Elems[name])
+	}
+}
+
+// The name is the name of a variable, not of a function or a record.
+func isVariable(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r) || r == '_'
+}
+
+// The name is the key under which the record is declared.
+func isRecord(name string) bool {
+	return strings.HasPrefix(name, "#")
+}
+
+// Print the messages waiting in the shell's mailbox, removing them. The messages
+// are delivered asynchronously, so the ones that were just sent are waited for.
+func (s *shell) flush() {
+	for {
+		select {
+		case msg := <-s.pid.Messages():
+			fmt.Fprintf(s.out, "Shell got %v\n", msg)
+		case <-time.After(flushTimeout):
+			return
+		}
+	}
+}
+
+// The names that can be completed: the commands, the build-in functions, and the bound names,
+// without the records.
+func (s *shell) names() []string {
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	for _, env := range []*envir.Env{s.builtins, s.env} {
+		for name := range env.Elems {
+			if !isRecord(name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func (s *shell) printError(err error, source string) {
+	fmt.Fprintln(s.out, formatError(err, source))
+}
+
+// Add the line to the history, and append it to the history file.
+func (s *shell) remember(line string) {
+	if !s.editor.AddHistory(line) || s.history == "" {
+		return
+	}
+	file, err := os.OpenFile(s.history, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+}
+
+// Read the history file, keeping only its last lines.
+func (s *shell) loadHistory() {
+	file, err := os.Open(s.history)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		s.editor.AddHistory(scanner.Text())
+	}
+	if len(s.editor.History) > historySize {
+		s.editor.History = s.editor.History[len(s.editor.History)-historySize:]
+		// rewrite the file, so it does not grow indefinitely
+		os.WriteFile(s.history, []byte(strings.Join(s.editor.History, "\n")+"\n"), 0o600)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/twolodzko/goer/core"
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/pids"
+	"github.com/twolodzko/goer/types"
+)
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		input   string
+		chunks  []string
+		pending string
+	}{
+		{"", nil, ""},
+		{"X = 1.\n", []string{"X = 1."}, ""},
+		{"X = 1. Y = 2.\n", []string{"X = 1.", "Y = 2."}, ""},
+		{"X = 1 +\n", nil, "X = 1 +\n"},
+		{"X = 1. Y =\n", []string{"X = 1."}, " Y =\n"},
+		{"X = 1. % comment\n", []string{"X = 1."}, ""},
+		{"\"a.\n", nil, "\"a.\n"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			chunks, pending := split(tt.input)
+			if !cmp.Equal(chunks, tt.chunks) {
+				t.Errorf("expected %q, got %q", tt.chunks, chunks)
+			}
+			if pending != tt.pending {
+				t.Errorf("expected %q pending, got %q", tt.pending, pending)
+			}
+		})
+	}
+}
+
+func TestShell(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.ge")
+	if err := os.WriteFile(script, []byte("-record(point, {x}).\nfun double(X) -> X * 2 end.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		"X = {1,",
+		"  2}.",
+		":load " + script,
+		"double(21).",
+		"self() ! hello.",
+		":flush",
+		":env",
+		"Y.",
+		":reset",
+		":env",
+		":nope",
+		":quit",
+		"double(1).",
+	}, "\n")
+	var out strings.Builder
+	pid := pids.NewPid()
+	defer pid.Close()
+	s := newShell(strings.NewReader(input), &out, core.ParseEval, core.EvalFile, pid)
+	s.history = filepath.Join(dir, "history")
+	s.run()
+
+	expected := strings.Join([]string{
+		"1> .. true",
+		"2> fun double (X) -> X * 2 end",
+		"2> 42",
+		"3> hello",
+		"4> Shell got hello",
+		"4> X = {1,2}",
+		"double = fun double (X) -> X * 2 end",
+		"4> ERROR: 1:1: variable 'Y' is unbound",
+		"1 | Y.",
+		"  | ^",
+		"5> ok",
+		"1> 1> unknown command ':nope', type :help for the list of the commands",
+		"1> ",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	history, err := os.ReadFile(s.history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(history)), "\n"); len(lines) != 12 || lines[0] != "X = {1," {
+		t.Errorf("unexpected history: %q", lines)
+	}
+}
+
+func TestShellBindings(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		input    []string
+		expected []string
+	}{
+		// forgetting the variables
+		{[]string{"X = 1.", "f(X).", "X = 2.", "X."}, []string{"true", "ok", "true", "2"}},
+		{[]string{"X = 1.", "Y = 2.", "f().", "{X, Y} = {3, 4}.", "Y."}, []string{"true", "true", "ok", "true", "4"}},
+		{[]string{"f(Unknown)."}, []string{"ok"}},
+		// the functions and the records are kept, and not listed
+		{[]string{"fun g() -> 1 end.", "-record(r, {a}).", "X = 1.", "f().", "{g(), #r{a = 2}}.", "b()."}, []string{
+			"fun g () -> 1 end", "ok", "true", "ok", "{1,{r,2}}", "ok",
+		}},
+		{[]string{"fun g() -> 1 end.", "X = 1.", "b()."}, []string{"fun g () -> 1 end", "true", "X = 1\nok"}},
+		{[]string{"X = 1.", "Y = {X}.", "b()."}, []string{"true", "true", "X = 1\nY = {1}\nok"}},
+		// the user-defined functions take precedence
+		{[]string{"fun f(X) -> X + 1 end.", "X = 1.", "f(X)."}, []string{"fun f (X) -> X + 1 end", "true", "2"}},
+		// the results
+		{[]string{"1 + 2.", "v(1) * 2.", "v(-1) + v(-2)."}, []string{"3", "6", "9"}},
+		{[]string{"v(1)."}, []string{"ERROR: 1:1: there is no result of the expression 1\n1 | v(1).\n  | ^\n    in v/1, called at 1:1"}},
+		// the failed expressions do not bind the variables
+		{[]string{"X = 1.", "Y = 2, Z = error(boom).", "b()."}, []string{
+			"true",
+			"ERROR: 1:12: exception error: boom\n1 | Y = 2, Z = error(boom).\n  |            ^\n    in error/1, called at 1:12",
+			"X = 1\nok",
+		}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.input, " "), func(t *testing.T) {
+			t.Parallel()
+
+			var out strings.Builder
+			pid := pids.NewPid()
+			defer pid.Close()
+			s := newShell(strings.NewReader(strings.Join(tt.input, "\n")), &out, core.ParseEval, core.EvalFile, pid)
+			s.run()
+
+			var expected strings.Builder
+			for i, result := range tt.expected {
+				fmt.Fprintf(&expected, "%d> %s\n", i+1, result)
+			}
+			fmt.Fprintf(&expected, "%d> ", len(tt.expected)+1)
+			if out.String() != expected.String() {
+				t.Errorf("expected:\n%s\ngot:\n%s", expected.String(), out.String())
+			}
+		})
+	}
+}
+
+func TestShellNames(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	pid := pids.NewPid()
+	defer pid.Close()
+	s := newShell(strings.NewReader("-record(point, {x}).\nX = 1.\n"), &out, core.ParseEval, core.EvalFile, pid)
+	s.run()
+
+	names := s.names()
+	if !slices.Contains(names, "X") || !slices.Contains(names, ":env") || !slices.Contains(names, "print") {
+		t.Errorf("expected the variables, the commands, and the build-ins, got %q", names)
+	}
+	// the records are not completed
+	if slices.Contains(names, "#point") {
+		t.Errorf("unexpected record in %q", names)
+	}
+}
+
+func TestShellCrash(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	pid := pids.NewPid()
+	defer pid.Close()
+	crashing := func(code string, env *envir.Env, pid pids.Pid) (types.Expr, error) {
+		if code == "crash." {
+			env.Set("Y", types.Int(2))
+			panic("oops")
+		}
+		return core.ParseEval(code, env, pid)
+	}
+	s := newShell(strings.NewReader("X = 1.\ncrash.\nb().\n"), &out, crashing, core.EvalFile, pid)
+	s.run()
+
+	expected := "1> true\n2> ERROR: the shell crashed: oops\n3> X = 1\nok\n4> "
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestShellHalt(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	pid := pids.NewPid()
+	defer pid.Close()
+	s := newShell(strings.NewReader("X = 1.\ntry halt(3) catch _:_ -> caught end.\nX.\n"), &out, core.ParseEval, core.EvalFile, pid)
+	if status := s.run(); status != 3 {
+		t.Errorf("expected the status 3, got %d", status)
+	}
+	// the shell quits without evaluating the rest
+	if expected := "1> true\n2> "; out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,107 @@
+package types
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+)
+
+// Positions of the types in the standard term order:
+// number < atom < reference < fun < pid < tuple < list < string < binary.
+const (
+	OrderNumber = iota
+	OrderAtom
+	OrderRef
+	OrderFun
+	OrderPid
+	OrderTuple
+	OrderList
+	OrderString
+	OrderBinary
+)
+
+// Values defined outside of this package, like pids or functions,
+// implement it to take part in the term order.
+type Ordered interface {
+	// Position of the type in the term order.
+	Order() int
+	// Compare to another value of the same order.
+	Compare(other Expr) int
+}
+
+// Compare two values using the standard term order. Returns a negative number when
+// lhs is smaller than rhs, a positive number when it is larger, and zero if they are equal.
+// Tuples are compared by their sizes first, and then element by element, lists, strings,
+// and binaries are compared lexicographically.
+func Compare(lhs, rhs Expr) int {
+	if o := cmp.Compare(order(lhs), order(rhs)); o != 0 {
+		return o
+	}
+	switch lhs := lhs.(type) {
+	case Int:
+		return cmp.Compare(lhs, rhs.(Int))
+	case Atom, Bool:
+		return cmp.Compare(atomName(lhs), atomName(rhs))
+	case String:
+		return cmp.Compare(lhs, rhs.(String))
+	case Binary:
+		return bytes.Compare(lhs, rhs.(Binary))
+	case Tuple:
+		rhs := rhs.(Tuple)
+		if o := cmp.Compare(len(lhs.Values), len(rhs.Values)); o != 0 {
+			return o
+		}
+		return compareAll(lhs.Values, rhs.Values)
+	case List:
+		return compareAll(lhs.Values, rhs.(List).Values)
+	case Ordered:
+		return lhs.Compare(rhs)
+	}
+	if rhs, ok := rhs.(Ordered); ok {
+		return -rhs.Compare(lhs)
+	}
+	// the Go functions, like the build-ins
+	return cmp.Compare(funcPointer(lhs), funcPointer(rhs))
+}
+
+// Compare the values lexicographically.
+func compareAll(lhs, rhs []Expr) int {
+	for i := 0; i < min(len(lhs), len(rhs)); i++ {
+		if o := Compare(lhs[i], rhs[i]); o != 0 {
+			return o
+		}
+	}
+	return cmp.Compare(len(lhs), len(rhs))
+}
+
+// Position of the value in the term order.
+func order(expr Expr) int {
+	switch expr := expr.(type) {
+	case Int:
+		return OrderNumber
+	case Atom, Bool:
+		return OrderAtom
+	case Tuple:
+		return OrderTuple
+	case List:
+		return OrderList
+	case String:
+		return OrderString
+	case Binary:
+		return OrderBinary
+	case Ordered:
+		return expr.Order()
+	}
+	if isGoFunc(expr) {
+		return OrderFun
+	}
+	panic(fmt.Sprintf("value of type %T cannot be compared", expr))
+}
+
+// Booleans are the atoms, so they are compared by their names.
+func atomName(expr Expr) string {
+	if b, ok := expr.(Bool); ok {
+		return fmt.Sprint(b)
+	}
+	return string(expr.(Atom))
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		lhs, rhs Expr
+		expected int
+	}{
+		{Int(1), Int(1), 0},
+		{Int(-5), Int(2), -1},
+		{Int(1000), Atom("a"), -1},
+		{Atom("abc"), Atom("abd"), -1},
+		{Atom("zzz"), Bool(false), 1},
+		{Bool(false), Bool(true), -1},
+		{Atom("true"), Bool(true), 0},
+		{Atom("foo"), Tuple{}, -1},
+		{Tuple{[]Expr{Int(9)}}, Tuple{[]Expr{Int(1), Int(2)}}, -1},
+		{Tuple{[]Expr{Int(1), Int(3)}}, Tuple{[]Expr{Int(1), Int(2)}}, 1},
+		{Tuple{[]Expr{Int(1), Atom("x")}}, Tuple{[]Expr{Int(1), Atom("x")}}, 0},
+		{Tuple{[]Expr{Int(1), Int(2), Int(3)}}, List{}, -1},
+		{List{}, List{[]Expr{Int(1)}}, -1},
+		{List{[]Expr{Int(1), Int(2)}}, List{[]Expr{Int(1)}}, 1},
+		{List{[]Expr{Int(2)}}, List{[]Expr{Int(1), Int(5)}}, 1},
+		{List{[]Expr{Atom("a")}}, String(""), -1},
+		{String("abc"), String("abd"), -1},
+		{String("b"), String("abc"), 1},
+		{String("b"), Binary{}, -1},
+		{Binary{1, 2}, Binary{1, 3}, -1},
+		{Binary{1, 2}, Binary{1}, 1},
+	}
+
+	for _, tt := range testCases {
+		result := Compare(tt.lhs, tt.rhs)
+		if result != tt.expected {
+			t.Errorf("comparing %v and %v expected %d, got %d", tt.lhs, tt.rhs, tt.expected, result)
+		}
+		result = Compare(tt.rhs, tt.lhs)
+		if result != -tt.expected {
+			t.Errorf("comparing %v and %v expected %d, got %d", tt.rhs, tt.lhs, -tt.expected, result)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"reflect"
+)
+
+// Values defined outside of this package, like pids or functions,
+// implement it to be hashed. They are compared by their identity.
+type Hashable interface {
+	Ordered
+	Hash() uint64
+}
+
+// Check if the two values are the same terms. Booleans are the same as the
+// atoms with the same names, pids and functions are compared by identity.
+func Equal(lhs, rhs Expr) bool {
+	switch lhs := lhs.(type) {
+	case Int:
+		rhs, ok := rhs.(Int)
+		return ok && lhs == rhs
+	case Atom, Bool:
+		switch rhs.(type) {
+		case Atom, Bool:
+			return atomName(lhs) == atomName(rhs)
+		default:
+			return false
+		}
+	case String:
+		rhs, ok := rhs.(String)
+		return ok && lhs == rhs
+	case Binary:
+		rhs, ok := rhs.(Binary)
+		return ok && bytes.Equal(lhs, rhs)
+	case Tuple:
+		rhs, ok := rhs.(Tuple)
+		return ok && equalAll(lhs.Values, rhs.Values)
+	case List:
+		rhs, ok := rhs.(List)
+		return ok && equalAll(lhs.Values, rhs.Values)
+	case Ordered:
+		rhs, ok := rhs.(Ordered)
+		return ok && lhs.Order() == rhs.Order() && lhs.Compare(rhs) == 0
+	}
+	if isGoFunc(lhs) {
+		return isGoFunc(rhs) && funcPointer(lhs) == funcPointer(rhs)
+	}
+	// not a term
+	return reflect.DeepEqual(lhs, rhs)
+}
+
+func equalAll(lhs, rhs []Expr) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+	for i := range lhs {
+		if !Equal(lhs[i], rhs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Seed of the hashes, so they are the same during the run of the program.
+var seed = maphash.MakeSeed()
+
+// Hash the term, the values that are `Equal` have the same hashes.
+func Hash(expr Expr) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	writeHash(&h, expr)
+	return h.Sum64()
+}
+
+func writeHash(h *maphash.Hash, expr Expr) {
+	var buf [binary.MaxVarintLen64]byte
+	writeInt := func(x uint64) {
+		n := binary.PutUvarint(buf[:], x)
+		h.Write(buf[:n])
+	}
+
+	switch expr := expr.(type) {
+	case Int:
+		h.WriteByte(OrderNumber)
+		writeInt(uint64(expr))
+	case Atom, Bool:
+		h.WriteByte(OrderAtom)
+		name := atomName(expr)
+		writeInt(uint64(len(name)))
+		h.WriteString(name)
+	case String:
+		h.WriteByte(OrderString)
+		writeInt(uint64(len(expr)))
+		h.WriteString(string(expr))
+	case Binary:
+		h.WriteByte(OrderBinary)
+		writeInt(uint64(len(expr)))
+		h.Write(expr)
+	case Tuple:
+		h.WriteByte(OrderTuple)
+		writeInt(uint64(len(expr.Values)))
+		for _, val := range expr.Values {
+			writeHash(h, val)
+		}
+	case List:
+		h.WriteByte(OrderList)
+		writeInt(uint64(len(expr.Values)))
+		for _, val := range expr.Values {
+			writeHash(h, val)
+		}
+	case Hashable:
+		h.WriteByte(byte(expr.Order()))
+		writeInt(expr.Hash())
+	default:
+		if isGoFunc(expr) {
+			h.WriteByte(OrderFun)
+			writeInt(uint64(funcPointer(expr)))
+		} else {
+			panic(fmt.Sprintf("value of type %T cannot be hashed", expr))
+		}
+	}
+}
+
+// The value is a Go function, like the build-ins.
+func isGoFunc(expr Expr) bool {
+	return expr != nil && reflect.ValueOf(expr).Kind() == reflect.Func
+}
+
+// The identity of the Go function. The closures made by the same code, like the wrappers
+// of the build-ins, share the code pointer, so the pointer to the closure itself is used.
+func funcPointer(fun Expr) uintptr {
+	ptr := reflect.New(reflect.TypeOf(fun))
+	ptr.Elem().Set(reflect.ValueOf(fun))
+	return *(*uintptr)(ptr.UnsafePointer())
+}
@@ -0,0 +1,133 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+
+	fun := func() {}
+	wrap := func(n int) func() int { return func() int { return n } }
+	one := wrap(1)
+
+	testCases := []struct {
+		lhs, rhs Expr
+		expected bool
+	}{
+		{Int(1), Int(1), true},
+		{Int(1), Int(2), false},
+		{Int(1), String("1"), false},
+		{Atom("foo"), Atom("foo"), true},
+		{Atom("foo"), String("foo"), false},
+		{Atom("true"), Bool(true), true},
+		{Bool(false), Atom("false"), true},
+		{Bool(false), Bool(true), false},
+		{String(""), String(""), true},
+		{Tuple{}, Tuple{nil}, true},
+		{Tuple{}, List{}, false},
+		{List{}, List{[]Expr{}}, true},
+		{List{[]Expr{Int(1)}}, List{[]Expr{Int(1), Int(2)}}, false},
+		{
+			Tuple{[]Expr{Atom("ok"), List{[]Expr{Bool(true), String("x")}}}},
+			Tuple{[]Expr{Atom("ok"), List{[]Expr{Atom("true"), String("x")}}}},
+			true,
+		},
+		{Binary{}, Binary{}, true},
+		{Binary{1, 2}, Binary{1, 2}, true},
+		{Binary{1, 2}, Binary{1}, false},
+		{Binary("abc"), String("abc"), false},
+		{fun, fun, true},
+		{fun, func() {}, false},
+		{fun, Int(1), false},
+		{one, one, true},
+		{one, wrap(1), false},
+	}
+
+	for _, tt := range testCases {
+		if result := Equal(tt.lhs, tt.rhs); result != tt.expected {
+			t.Errorf("for %v == %v expected %v, got %v", tt.lhs, tt.rhs, tt.expected, result)
+		}
+		if result := Equal(tt.rhs, tt.lhs); result != tt.expected {
+			t.Errorf("for %v == %v expected %v, got %v", tt.rhs, tt.lhs, tt.expected, result)
+		}
+		if tt.expected && Hash(tt.lhs) != Hash(tt.rhs) {
+			t.Errorf("hashes of the equal values %v and %v differ", tt.lhs, tt.rhs)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	values := []Expr{
+		Int(0),
+		Int(1),
+		Atom("foo"),
+		Atom("bar"),
+		String("foo"),
+		Bool(true),
+		Tuple{},
+		List{},
+		Tuple{[]Expr{Int(1)}},
+		List{[]Expr{Int(1)}},
+		List{[]Expr{Atom("ab"), Atom("c")}},
+		List{[]Expr{Atom("a"), Atom("bc")}},
+		List{[]Expr{List{[]Expr{Int(1)}}, Int(2)}},
+		List{[]Expr{List{[]Expr{Int(1), Int(2)}}}},
+	}
+
+	seen := make(map[uint64]Expr)
+	for _, val := range values {
+		hash := Hash(val)
+		if prev, ok := seen[hash]; ok {
+			t.Errorf("%v and %v have the same hash", prev, val)
+		}
+		seen[hash] = val
+	}
+}
+
+// A large, nested term to be compared.
+func benchmarkTerm() Expr {
+	var values []Expr
+	for i := 0; i < 100; i++ {
+		values = append(values, Tuple{[]Expr{Int(i), Atom("item"), String("value"), List{[]Expr{Bool(true), Int(-i)}}}})
+	}
+	return List{values}
+}
+
+func BenchmarkEqual(b *testing.B) {
+	lhs, rhs := benchmarkTerm(), benchmarkTerm()
+	for i := 0; i < b.N; i++ {
+		Equal(lhs, rhs)
+	}
+}
+
+func BenchmarkDeepEqual(b *testing.B) {
+	lhs, rhs := benchmarkTerm(), benchmarkTerm()
+	for i := 0; i < b.N; i++ {
+		reflect.DeepEqual(lhs, rhs)
+	}
+}
+
+func BenchmarkEqualInt(b *testing.B) {
+	var lhs, rhs Expr = Int(42), Int(42)
+	for i := 0; i < b.N; i++ {
+		Equal(lhs, rhs)
+	}
+}
+
+func BenchmarkDeepEqualInt(b *testing.B) {
+	var lhs, rhs Expr = Int(42), Int(42)
+	for i := 0; i < b.N; i++ {
+		reflect.DeepEqual(lhs, rhs)
+	}
+}
+
+func BenchmarkHash(b *testing.B) {
+	term := benchmarkTerm()
+	for i := 0; i < b.N; i++ {
+		Hash(term)
+	}
+}
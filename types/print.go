@@ -2,11 +2,62 @@ package types
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+func (p Pos) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
 func (s String) String() string {
-	return fmt.Sprintf("\"%s\"", string(s))
+	return strconv.Quote(string(s))
+}
+
+func (a Atom) String() string {
+	if isPlainAtom(string(a)) {
+		return string(a)
+	}
+	return quoteAtom(string(a))
+}
+
+func (a QuotedAtom) String() string {
+	return quoteAtom(string(a))
+}
+
+// Write the atom in the quotes.
+func quoteAtom(a string) string {
+	// reuse the escapes of the string, but for the other quote
+	s := strconv.Quote(a)
+	s = strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// The atom can be written without the quotes.
+func isPlainAtom(s string) bool {
+	switch s {
+	case "begin", "fun", "if", "case", "of", "receive", "after", "end", "when", "try", "recover", "catch",
+		"not", "rem", "div", "and", "or", "xor", "andalso", "orelse",
+		"bnot", "band", "bor", "bxor", "bsl", "bsr":
+		return false
+	}
+	for i, r := range s {
+		if i == 0 && !unicode.IsLower(r) {
+			return false
+		}
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_' && r != '@' {
+			return false
+		}
+	}
+	return s != ""
 }
 
 func (d Dummy) String() string {
@@ -21,6 +72,53 @@ func (l List) String() string {
 	return fmt.Sprintf("[%s]", stringify(l.Values))
 }
 
+func (b Binary) String() string {
+	if len(b) > 0 && isPrintable(b) {
+		return fmt.Sprintf("<<%q>>", string(b))
+	}
+	var s []string
+	for _, x := range b {
+		s = append(s, fmt.Sprint(x))
+	}
+	return fmt.Sprintf("<<%s>>", strings.Join(s, ","))
+}
+
+// All the bytes are the printable characters.
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b BitString) String() string {
+	var s []string
+	for _, segment := range b.Segments {
+		s = append(s, fmt.Sprint(segment))
+	}
+	return fmt.Sprintf("<<%s>>", strings.Join(s, ","))
+}
+
+func (s Segment) String() string {
+	str := fmt.Sprint(s.Value)
+	if s.Size != nil {
+		str += fmt.Sprintf(":%v", s.Size)
+	}
+	if s.Types != nil {
+		str += "/" + strings.Join(s.Types, "-")
+	}
+	return str
+}
+
+func (b Block) String() string {
+	return fmt.Sprintf("begin %s end", stringify(b.Body))
+}
+
 func (b Bracket) String() string {
 	return fmt.Sprintf("(%v)", b.Expr)
 }
@@ -43,9 +141,12 @@ func (d Definition) String() string {
 		s = append(s, fmt.Sprint(branch))
 	}
 	body := strings.Join(s, "; ")
-	if d.Name != "" {
+	if d.Local {
 		return fmt.Sprintf("fun %s %s end", d.Name, body)
 	}
+	if d.Name != "" {
+		return fmt.Sprintf("fun %v %s end", Atom(d.Name), body)
+	}
 	return fmt.Sprintf("fun %s end", body)
 }
 
@@ -83,6 +184,75 @@ func (b PatternBranch) String() string {
 	return fmt.Sprintf("%v when %s -> %s", b.Pattern, stringify(b.Guards), stringify(b.Body))
 }
 
+func (a AssertMatch) String() string {
+	return fmt.Sprintf("assert_match(%v, %v)", a.Pattern, a.Expr)
+}
+
+func (r RecordDecl) String() string {
+	return fmt.Sprintf("-record(%v, {%s})", Atom(r.Name), stringifyFields(r.Fields))
+}
+
+func (f RecordField) String() string {
+	if f.Value == nil {
+		return fmt.Sprint(Atom(f.Name))
+	}
+	return fmt.Sprintf("%v = %v", Atom(f.Name), f.Value)
+}
+
+func (r Record) String() string {
+	s := fmt.Sprintf("#%v{%s}", Atom(r.Name), stringifyFields(r.Fields))
+	if r.Expr != nil {
+		return fmt.Sprint(r.Expr) + s
+	}
+	return s
+}
+
+func (r RecordAccess) String() string {
+	return fmt.Sprintf("%v#%v.%v", r.Expr, Atom(r.Name), Atom(r.Field))
+}
+
+func stringifyFields(fields []RecordField) string {
+	var s []string
+	for _, field := range fields {
+		s = append(s, fmt.Sprint(field))
+	}
+	return strings.Join(s, ",")
+}
+
+// Pretty-print the value, the tuples and lists that do not fit in the
+// line `width` are broken into multiple lines, one element per line.
+func Pretty(expr Expr, width int) string {
+	return pretty(expr, 0, width)
+}
+
+func pretty(expr Expr, indent, width int) string {
+	s := fmt.Sprint(expr)
+	if indent+utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	var (
+		open, close string
+		values      []Expr
+	)
+	switch expr := expr.(type) {
+	case Tuple:
+		open, close, values = "{", "}", expr.Values
+	case List:
+		open, close, values = "[", "]", expr.Values
+	default:
+		return s
+	}
+
+	// the elements are aligned after the opening bracket
+	var parts []string
+	for _, val := range values {
+		parts = append(parts, pretty(val, indent+1, width))
+	}
+	sep := ",\n" + strings.Repeat(" ", indent+1)
+	return open + strings.Join(parts, sep) + close
+}
+
 // Convert list of expressions to a comma-separated string representation.
 func stringify(exprs []Expr) string {
 	var s []string
@@ -0,0 +1,66 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestPretty(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input    Expr
+		width    int
+		expected string
+	}{
+		{Int(42), 1, "42"},
+		{Tuple{[]Expr{Atom("ok"), Int(1)}}, 80, "{ok,1}"},
+		{List{}, 0, "[]"},
+		{Tuple{[]Expr{Atom("ok"), Int(1)}}, 5, "{ok,\n 1}"},
+		{
+			List{[]Expr{Tuple{[]Expr{Atom("a"), Atom("b")}}, List{[]Expr{Int(1), Int(2), Int(3), Int(4)}}}},
+			10,
+			"[{a,b},\n [1,2,3,4]]",
+		},
+		{
+			Tuple{[]Expr{Atom("key"), List{[]Expr{String("first"), String("second")}}}},
+			12,
+			"{key,\n [\"first\",\n  \"second\"]}",
+		},
+	}
+
+	for _, tt := range testCases {
+		result := Pretty(tt.input, tt.width)
+		if result != tt.expected {
+			t.Errorf("for %v expected %q, got %q", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestAtomString(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input    Atom
+		expected string
+	}{
+		{"ok", "ok"},
+		{"is_atom", "is_atom"},
+		{"foo@bar", "foo@bar"},
+		{"EXIT", "'EXIT'"},
+		{"_x", "'_x'"},
+		{"hello world", "'hello world'"},
+		{"it's", `'it\'s'`},
+		{`say "hi"`, `'say "hi"'`},
+		{"a\nb", `'a\nb'`},
+		{"", "''"},
+		{"end", "'end'"},
+		{"andalso", "'andalso'"},
+	}
+
+	for _, tt := range testCases {
+		result := tt.input.String()
+		if result != tt.expected {
+			t.Errorf("for %q expected %s, got %s", string(tt.input), tt.expected, result)
+		}
+	}
+}
@@ -9,6 +9,21 @@ type (
 	Variable string
 )
 
+// Atom written in the quotes, like `'max'`. Unlike the atoms written without the quotes,
+// it does not evaluate to the function named by it, so it is always the atom.
+type QuotedAtom string
+
+// Position in the source code.
+type Pos struct {
+	File      string
+	Line, Col int
+}
+
+// The position is known.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
 // Dummy variable ("_" in Erlang). In pattern matching it matches anything.
 type Dummy struct{}
 
@@ -28,6 +43,30 @@ func (l List) Append(exprs ...Expr) List {
 	return List{append(l.Values, exprs...)}
 }
 
+// Sequence of bytes.
+type Binary []byte
+
+// The `<<Segment, ...>>` expression that constructs a binary, or matches it.
+type BitString struct {
+	Segments []Segment
+	Pos      Pos
+}
+
+// Segment of the bit string in the `Value:Size/Type-Specifiers` form, where the size and
+// the specifiers are optional. The size is `nil` when not given, the default specifiers are
+// `integer`, `unsigned`, and `big`.
+type Segment struct {
+	Value Expr
+	Size  Expr
+	Types []string
+}
+
+// Sequence of expressions in the `begin ... end` block.
+type Block struct {
+	Body []Expr
+	Pos  Pos
+}
+
 // Expression enclosed in brackets.
 type Bracket struct {
 	Expr Expr
@@ -37,12 +76,14 @@ type Bracket struct {
 type UnaryOperation struct {
 	Op  string
 	Rhs Expr
+	Pos Pos
 }
 
 // Binary operation.
 type BinaryOperation struct {
 	Op       string
 	Lhs, Rhs Expr
+	Pos      Pos
 }
 
 // Call an expression (anonymous function, name of the function)
@@ -50,13 +91,18 @@ type BinaryOperation struct {
 type Call struct {
 	Callable Expr
 	Args     []Expr
+	Pos      Pos
 }
 
 // A function definition, consisting of one or more branches executed conditionally.
-// The branches are picked by pattern matching their arguments.
+// The branches are picked by pattern matching their arguments. The named functions
+// are defined in the enclosing environment, unless the name is `Local`, then it is
+// visible only in the function's body (Erlang's `fun Name(...) -> ... end`).
 type Definition struct {
 	Name     string
+	Local    bool
 	Branches []FunBranch
+	Pos      Pos
 }
 
 // A representation of function branch (part of the function definition).
@@ -69,6 +115,7 @@ type FunBranch struct {
 // If statement.
 type If struct {
 	Branches []IfBranch
+	Pos      Pos
 }
 
 // Body of expressions to be executed conditionally.
@@ -81,6 +128,7 @@ type IfBranch struct {
 type Case struct {
 	Arg      Expr
 	Branches []PatternBranch
+	Pos      Pos
 }
 
 // Body of expressions to be executed conditionally.
@@ -94,10 +142,67 @@ type PatternBranch struct {
 type Receive struct {
 	Branches []PatternBranch
 	After    IfBranch
+	Pos      Pos
 }
 
-// TryRecover-recover statement.
+// Try-recover statement.
 type TryRecover struct {
 	Body    []Expr
 	Recover []Expr
+	Pos     Pos
+}
+
+// Try-catch statement.
+type TryCatch struct {
+	Body     []Expr
+	Branches []CatchBranch
+	Pos      Pos
+}
+
+// Branch of the "catch" block, matching the class and the reason of the error,
+// and optionally binding the stack trace.
+type CatchBranch struct {
+	Class   Expr
+	Pattern Expr
+	Stack   Expr
+	Guards  []Expr
+	Body    []Expr
+}
+
+// Assertion `assert_match(Pattern, Expr)` that the value of the expression matches the pattern.
+type AssertMatch struct {
+	Pattern Expr
+	Expr    Expr
+	Pos     Pos
+}
+
+// Record declaration `-record(Name, {Field [= Default], ...})`.
+type RecordDecl struct {
+	Name   string
+	Fields []RecordField
+	Pos    Pos
+}
+
+// Field of the record, the value is the default value in the declaration,
+// or the assigned value in the record expression. It is `nil` when not given.
+type RecordField struct {
+	Name  string
+	Value Expr
+}
+
+// Record construction `#Name{Field = Value, ...}`, or the update
+// `Expr#Name{Field = Value, ...}` of the record, when `Expr` is not `nil`.
+type Record struct {
+	Expr   Expr
+	Name   string
+	Fields []RecordField
+	Pos    Pos
+}
+
+// Access to the field of the record `Expr#Name.Field`.
+type RecordAccess struct {
+	Expr  Expr
+	Name  string
+	Field string
+	Pos   Pos
 }
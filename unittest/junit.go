@@ -0,0 +1,105 @@
+package unittest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/twolodzko/goer/core/errors"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      string      `xml:"time,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	SystemErr string      `xml:"system-err,omitempty"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Write the report of the tests in the JUnit XML format. The failed assertions are reported
+// as the failures, the other errors thrown by the tests as the errors, and the file that
+// could not be evaluated is the error of the whole suite.
+func WriteJUnit(w io.Writer, suites []Suite) error {
+	var report junitSuites
+	var total time.Duration
+	for _, suite := range suites {
+		s := junitSuite{Name: suite.File, Time: seconds(suite.Duration)}
+		if suite.Err != nil {
+			s.Errors++
+			s.SystemErr = describe(suite.Err)
+		}
+		for _, r := range suite.Results {
+			c := junitCase{r.Name, suite.File, suite.File, r.Pos.Line, seconds(r.Duration), nil, nil}
+			switch {
+			case r.Err == nil:
+			case errors.IsAssertion(r.Err):
+				c.Failure = &junitProblem{errors.Cause(r.Err).Error(), "assertion", describe(r.Err)}
+				s.Failures++
+			default:
+				c.Error = &junitProblem{errors.Cause(r.Err).Error(), string(errors.Class(r.Err)), describe(r.Err)}
+				s.Errors++
+			}
+			s.Cases = append(s.Cases, c)
+		}
+		s.Tests = len(s.Cases)
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+		report.Errors += s.Errors
+		report.Suites = append(report.Suites, s)
+		total += suite.Duration
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// The error with its stack trace.
+func describe(err error) string {
+	lines := []string{err.Error()}
+	for _, frame := range errors.StackTrace(err) {
+		lines = append(lines, fmt.Sprintf("    in %v", frame))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,204 @@
+package unittest
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/twolodzko/goer/core"
+	"github.com/twolodzko/goer/core/envir"
+	"github.com/twolodzko/goer/core/errors"
+	"github.com/twolodzko/goer/core/pids"
+	"github.com/twolodzko/goer/types"
+)
+
+// The files with the tests are named like `*_test.ge`.
+const fileSuffix = "_test.ge"
+
+// The test functions are named like `*_test` and take no arguments.
+const funSuffix = "_test"
+
+// The configuration of running the tests.
+type Config struct {
+	// compile the code to the bytecode and run it on the virtual machine
+	VM bool
+	// the time limit of each test, zero if there is none
+	Timeout time.Duration
+}
+
+// The tests of the file, the error is set when the file itself could not be evaluated.
+type Suite struct {
+	File     string
+	Err      error
+	Results  []Result
+	Duration time.Duration
+}
+
+// The number of the failed tests.
+func (s Suite) Failed() int {
+	n := 0
+	for _, r := range s.Results {
+		if r.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// The outcome of the test function, the error is nil when it passed.
+type Result struct {
+	Name     string
+	Pos      types.Pos
+	Err      error
+	Duration time.Duration
+}
+
+// Find the test files, in the directories they are searched for recursively,
+// the files given explicitly are taken as they are.
+func Find(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(path, fileSuffix) {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Evaluate the file and call the test functions it defines, in the order they
+// were defined. Each test runs in its own process, the processes it spawns
+// are stopped when it finishes.
+func RunFile(path string, config Config) Suite {
+	start := time.Now()
+	suite := Suite{File: path}
+
+	evalFile := core.EvalFile
+	if config.VM {
+		evalFile = core.RunFile
+	}
+	builtins := core.NewEnv()
+	builtins.Set("include", includeNear(filepath.Dir(path)))
+	env := builtins.Branch()
+
+	suite.Err = run(0, func(pid pids.Pid) error {
+		_, err := evalFile(path, env, pid)
+		return err
+	})
+	if suite.Err == nil {
+		for _, test := range discover(path, env) {
+			start := time.Now()
+			err := run(config.Timeout, func(pid pids.Pid) error {
+				_, err := core.Apply(test.fun, nil, env, pid)
+				return err
+			})
+			suite.Results = append(suite.Results, Result{test.Name, test.Pos, err, time.Since(start)})
+		}
+	}
+	suite.Duration = time.Since(start)
+	return suite
+}
+
+type test struct {
+	types.Definition
+	fun types.Expr
+}
+
+// The test functions defined in the file, the ones defined by the files
+// that it includes are not its tests.
+func discover(path string, env *envir.Env) []test {
+	var tests []test
+	for name, val := range env.Elems {
+		if !strings.HasSuffix(name, funSuffix) {
+			continue
+		}
+		def, ok := core.DefinitionOf(val)
+		if !ok || def.Pos.File != path || !takesNoArgs(def) {
+			continue
+		}
+		tests = append(tests, test{def, val})
+	}
+	slices.SortFunc(tests, func(a, b test) int {
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line - b.Pos.Line
+		}
+		return a.Pos.Col - b.Pos.Col
+	})
+	return tests
+}
+
+func takesNoArgs(def types.Definition) bool {
+	for _, branch := range def.Branches {
+		if len(branch.Args) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Run the code in the new process, it is stopped after the timeout, unless it is zero.
+// The processes it spawns are stopped when it finishes, or when any of them calls `halt`.
+func run(timeout time.Duration, eval func(pids.Pid) error) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, errors.TimeLimit{timeout})
+		defer cancel()
+	}
+	group := pids.NewGroup(ctx, pids.Limits{})
+	defer group.Stop(errors.New("the test has finished"))
+	pid := pids.NewPid()
+	defer pid.Close()
+	pid = pid.WithContext(group.Context(), group)
+
+	// the build-in functions cannot be stopped, so the test is abandoned
+	// if it does not finish on time
+	done := make(chan error, 1)
+	go func() {
+		done <- eval(pid)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-group.Context().Done():
+		return context.Cause(group.Context())
+	}
+}
+
+// The `include` build-in looking for the files in the current directory, and then
+// in the directory of the test file, so the tests can be run from anywhere.
+func includeNear(dir string) func([]types.Expr, *envir.Env, pids.Pid) (types.Expr, error) {
+	return func(args []types.Expr, env *envir.Env, pid pids.Pid) (types.Expr, error) {
+		if len(args) != 1 {
+			return nil, errors.WrongNumberArgs{}
+		}
+		path, ok := args[0].(types.String)
+		if !ok {
+			return nil, errors.NotString{args[0]}
+		}
+		name := string(path)
+		if _, err := os.Stat(name); err != nil && !filepath.IsAbs(name) {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				name = filepath.Join(dir, name)
+			}
+		}
+		return core.EvalFile(name, env, pid)
+	}
+}
@@ -0,0 +1,185 @@
+package unittest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/twolodzko/goer/core/errors"
+	. "github.com/twolodzko/goer/types"
+)
+
+const code = `
+include("lib.ge").
+
+fun add_test() ->
+    assert_equal(3, add(1, 2))
+end.
+
+fun wrong_test() ->
+    assert_equal(4, add(1, 2))
+end.
+
+fun match_test() ->
+    assert_match({ok, X}, {ok, 1}),
+    assert_match({ok, 2}, {ok, X + 1})
+end.
+
+fun crash_test() ->
+    1 / 0
+end.
+
+fun slow_test() ->
+    receive after 10000 -> ok end
+end.
+
+fun halt_test() ->
+    spawn(fun() -> halt(3) end),
+    receive after 10000 -> ok end
+end.
+
+fun not_a_test(X) -> X end.
+fun helper() -> ok end.
+`
+
+// Write the files to the temporary directory, return its path.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunFile(t *testing.T) {
+	t.Parallel()
+
+	dir := writeFiles(t, map[string]string{
+		"math_test.ge": code,
+		// the tests of the included files are not run
+		"lib.ge": "fun add(X, Y) -> X + Y end. fun lib_test() -> error(boom) end.",
+	})
+	path := filepath.Join(dir, "math_test.ge")
+	timeout := 50 * time.Millisecond
+
+	type outcome struct {
+		name string
+		err  error
+	}
+	expected := []outcome{
+		{"add_test", nil},
+		{"wrong_test", errors.NotEqual{Int(4), Int(3)}},
+		{"match_test", nil},
+		{"crash_test", errors.DivisionByZero{}},
+		{"slow_test", errors.TimeLimit{timeout}},
+		{"halt_test", errors.Halt{3}},
+	}
+
+	for _, vm := range []bool{false, true} {
+		suite := RunFile(path, Config{vm, timeout})
+		if suite.Err != nil {
+			t.Fatalf("unexpected error: %s", suite.Err)
+		}
+		var result []outcome
+		for _, r := range suite.Results {
+			result = append(result, outcome{r.Name, errors.Cause(r.Err)})
+		}
+		if !cmp.Equal(result, expected, cmp.AllowUnexported(outcome{})) {
+			t.Errorf("vm=%v: expected %v, got %v", vm, expected, result)
+		}
+		if suite.Failed() != 4 {
+			t.Errorf("vm=%v: expected 4 failed tests, got %d", vm, suite.Failed())
+		}
+	}
+}
+
+func TestRunFileInvalid(t *testing.T) {
+	t.Parallel()
+
+	dir := writeFiles(t, map[string]string{
+		"syntax_test.ge":  "fun ok_test() -> ok end. X = .",
+		"missing_test.ge": `include("missing.ge").`,
+	})
+	for _, name := range []string{"syntax_test.ge", "missing_test.ge"} {
+		suite := RunFile(filepath.Join(dir, name), Config{})
+		if suite.Err == nil || len(suite.Results) > 0 {
+			t.Errorf("%s: expected an error and no results, got %v and %v", name, suite.Err, suite.Results)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+
+	dir := writeFiles(t, map[string]string{
+		"a_test.ge":             "",
+		"lib.ge":                "",
+		"nested/b_test.ge":      "",
+		"nested/c_test.txt":     "",
+		"nested/deep/d_test.ge": "",
+	})
+	files, err := Find([]string{dir, filepath.Join(dir, "lib.ge")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []string
+	for _, path := range []string{"a_test.ge", "nested/b_test.ge", "nested/deep/d_test.ge", "lib.ge"} {
+		expected = append(expected, filepath.Join(dir, path))
+	}
+	if !cmp.Equal(files, expected) {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+
+	if _, err := Find([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	t.Parallel()
+
+	suites := []Suite{
+		{"a_test.ge", nil, []Result{
+			{"ok_test", Pos{"a_test.ge", 1, 1}, nil, time.Millisecond},
+			{"assert_test", Pos{"a_test.ge", 2, 1}, errors.NotEqual{Int(1), Int(2)}, time.Millisecond},
+			{"crash_test", Pos{"a_test.ge", 3, 1}, errors.DivisionByZero{}, time.Millisecond},
+		}, time.Second},
+		{"b_test.ge", errors.New("syntax error"), nil, 0},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, suites); err != nil {
+		t.Fatal(err)
+	}
+	var report junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid XML: %s\n%s", err, buf.String())
+	}
+
+	counts := [][4]int{{report.Tests, report.Failures, report.Errors, len(report.Suites)}}
+	for _, s := range report.Suites {
+		counts = append(counts, [4]int{s.Tests, s.Failures, s.Errors, len(s.Cases)})
+	}
+	expected := [][4]int{{3, 1, 2, 2}, {3, 1, 1, 3}, {0, 0, 1, 0}}
+	if !cmp.Equal(counts, expected) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+
+	failure := report.Suites[0].Cases[1].Failure
+	if failure == nil || failure.Message != "assertion failed: expected 1, got 2" {
+		t.Errorf("unexpected failure: %v", failure)
+	}
+	if report.Suites[1].SystemErr != "syntax error" {
+		t.Errorf("unexpected error of the suite: %q", report.Suites[1].SystemErr)
+	}
+}